@@ -0,0 +1,435 @@
+package rdf
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrWorkLimit is returned when canonicalizing a dataset needs more work than
+// allowed by the MaxWork of the Canonicalizer.
+var ErrWorkLimit = errors.New("canonicalization work limit exceeded")
+
+// Canonicalizer implements the RDF Dataset Canonicalization algorithm
+// (RDFC-1.0, formerly URDNA2015), as specified by
+// https://www.w3.org/TR/rdf-canon/.
+//
+// Canonicalization assigns deterministic labels (c14n0, c14n1, ...) to the
+// blank nodes of a dataset, so that isomorphic datasets have the same
+// canonical N-Quads serialization, regardless of how the blank nodes were
+// labeled by the decoder.
+//
+// Some datasets (such as large sets of blank nodes which can only be told
+// apart by permutation) take exponential time to canonicalize. MaxWork bounds
+// the number of steps spent on distinguishing such blank nodes.
+type Canonicalizer struct {
+	Hash         func() hash.Hash // hash algorithm; SHA-256 by default
+	MaxWork      int              // maximum number of N-degree hashing steps, or 0 for no limit
+	DefaultGraph Context          // quads in this graph (or with a nil Ctx) are in the default graph
+}
+
+// NewCanonicalizer returns a new Canonicalizer, using SHA-256 and a work
+// limit suitable for untrusted input.
+func NewCanonicalizer() *Canonicalizer {
+	return &Canonicalizer{
+		Hash:         sha256.New,
+		MaxWork:      100000,
+		DefaultGraph: Blank{id: "_:defaultGraph"},
+	}
+}
+
+// Labels returns the canonical label of each blank node of the quads.
+// Blank nodes used as graph names are labeled as any other blank node.
+func (c *Canonicalizer) Labels(qs []Quad) (map[Blank]Blank, error) {
+	s, err := c.canonicalize(qs)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[Blank]Blank, len(s.canonical.order))
+	for _, id := range s.canonical.order {
+		labels[s.stmts.blanks[id]] = Blank{id: s.canonical.issued[id]}
+	}
+	return labels, nil
+}
+
+// Canonicalize returns the quads with their blank nodes relabeled, without
+// duplicates, and sorted by their canonical N-Quads serialization. Quads in
+// the default graph have the DefaultGraph of the Canonicalizer as context.
+func (c *Canonicalizer) Canonicalize(qs []Quad) ([]Quad, error) {
+	labels, err := c.Labels(qs)
+	if err != nil {
+		return nil, err
+	}
+	relabel := func(t Term) Term {
+		if b, ok := t.(Blank); ok {
+			return labels[b]
+		}
+		return t
+	}
+	type keyedQuad struct {
+		key string
+		q   Quad
+	}
+	var kqs []keyedQuad
+	seen := make(map[string]bool)
+	for _, q := range qs {
+		q.Subj = relabel(q.Subj).(Subject)
+		q.Obj = relabel(q.Obj).(Object)
+		if c.isDefaultGraph(q.Ctx) {
+			q.Ctx = nil
+		} else {
+			q.Ctx = relabel(q.Ctx).(Context)
+		}
+		k := canonicalNQuad(q)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		if q.Ctx == nil {
+			q.Ctx = c.DefaultGraph
+		}
+		kqs = append(kqs, keyedQuad{k, q})
+	}
+	sort.Slice(kqs, func(i, j int) bool {
+		return kqs[i].key < kqs[j].key
+	})
+	res := make([]Quad, len(kqs))
+	for i, kq := range kqs {
+		res[i] = kq.q
+	}
+	return res, nil
+}
+
+// NQuads returns the canonical N-Quads serialization of the quads; the
+// canonicalized quads, one per line, where quads in the default graph are
+// written without a graph name.
+func (c *Canonicalizer) NQuads(qs []Quad) (string, error) {
+	cqs, err := c.Canonicalize(qs)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, q := range cqs {
+		if c.isDefaultGraph(q.Ctx) {
+			q.Ctx = nil
+		}
+		b.WriteString(canonicalNQuad(q))
+	}
+	return b.String(), nil
+}
+
+// isDefaultGraph returns true if the given context denotes the default graph.
+func (c *Canonicalizer) isDefaultGraph(g Context) bool {
+	return g == nil || (c.DefaultGraph != nil && TermsEqual(g, c.DefaultGraph))
+}
+
+// canonicalNQuad serializes a quad as a line of N-Quads, leaving out the
+// graph name of a quad with a nil Ctx.
+func canonicalNQuad(q Quad) string {
+	if q.Ctx == nil {
+		return q.Triple.Serialize(NQuads)
+	}
+	return q.Serialize(NQuads)
+}
+
+// c14nState is the canonicalization state of a dataset.
+type c14nState struct {
+	c         *Canonicalizer
+	stmts     *isoStatements
+	canonical *c14nIssuer
+	first     map[string]string // blank node -> first degree hash
+	work      int
+}
+
+// c14nIssuer issues identifiers with a prefix and a counter, in order.
+type c14nIssuer struct {
+	prefix string
+	issued map[string]string // existing identifier -> issued identifier
+	order  []string          // existing identifiers, in issue order
+}
+
+func newC14nIssuer(prefix string) *c14nIssuer {
+	return &c14nIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+// issue returns the identifier issued for the existing identifier, issuing
+// a new one if needed.
+func (is *c14nIssuer) issue(id string) string {
+	if issued, ok := is.issued[id]; ok {
+		return issued
+	}
+	issued := "_:" + is.prefix + strconv.Itoa(len(is.order))
+	is.issued[id] = issued
+	is.order = append(is.order, id)
+	return issued
+}
+
+func (is *c14nIssuer) clone() *c14nIssuer {
+	c := &c14nIssuer{
+		prefix: is.prefix,
+		issued: make(map[string]string, len(is.issued)),
+		order:  append([]string(nil), is.order...),
+	}
+	for k, v := range is.issued {
+		c.issued[k] = v
+	}
+	return c
+}
+
+// canonicalize runs the canonicalization algorithm, issuing the canonical
+// identifiers of all the blank nodes.
+func (c *Canonicalizer) canonicalize(qs []Quad) (*c14nState, error) {
+	norm := make([]Quad, len(qs))
+	for i, q := range qs {
+		if c.isDefaultGraph(q.Ctx) {
+			q.Ctx = nil
+		}
+		norm[i] = q
+	}
+	s := &c14nState{
+		c:         c,
+		stmts:     newIsoStatements(nil, norm),
+		canonical: newC14nIssuer("c14n"),
+		first:     make(map[string]string),
+	}
+
+	// Group the blank nodes by first degree hash, and issue identifiers to
+	// those with a unique hash.
+	byHash := make(map[string][]string)
+	for id := range s.stmts.byBlank {
+		h := s.hashFirstDegree(id)
+		byHash[h] = append(byHash[h], id)
+	}
+	hashes := make([]string, 0, len(byHash))
+	for h := range byHash {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+	for _, h := range hashes {
+		if len(byHash[h]) == 1 {
+			s.canonical.issue(byHash[h][0])
+		}
+	}
+
+	// Distinguish the blank nodes sharing a hash by their N-degree hash.
+	for _, h := range hashes {
+		ids := byHash[h]
+		if len(ids) == 1 {
+			continue
+		}
+		sort.Strings(ids)
+		type result struct {
+			hash   string
+			issuer *c14nIssuer
+		}
+		var results []result
+		for _, id := range ids {
+			if _, ok := s.canonical.issued[id]; ok {
+				continue
+			}
+			issuer := newC14nIssuer("b")
+			issuer.issue(id)
+			nh, issuer, err := s.hashNDegree(id, issuer)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result{nh, issuer})
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].hash < results[j].hash
+		})
+		for _, r := range results {
+			for _, id := range r.issuer.order {
+				s.canonical.issue(id)
+			}
+		}
+	}
+	return s, nil
+}
+
+// hash returns the hex encoded hash of the string.
+func (s *c14nState) hash(str string) string {
+	h := sha256.New
+	if s.c.Hash != nil {
+		h = s.c.Hash
+	}
+	hh := h()
+	hh.Write([]byte(str))
+	return fmt.Sprintf("%x", hh.Sum(nil))
+}
+
+// nquad serializes a statement, with blank nodes replaced by fn.
+func (s *c14nState) nquad(st isoStatement, fn func(id string) string) string {
+	var b strings.Builder
+	for i, t := range st.terms {
+		if t == "" {
+			continue
+		}
+		if st.blank[i] {
+			t = fn(t)
+		}
+		b.WriteString(t)
+		b.WriteByte(' ')
+	}
+	b.WriteString(".\n")
+	return b.String()
+}
+
+// hashFirstDegree hashes the statements the blank node occurs in, with the
+// blank node labeled _:a and any other blank node labeled _:z.
+func (s *c14nState) hashFirstDegree(id string) string {
+	if h, ok := s.first[id]; ok {
+		return h
+	}
+	var lines []string
+	for _, i := range s.stmts.byBlank[id] {
+		lines = append(lines, s.nquad(s.stmts.stmts[i], func(other string) string {
+			if other == id {
+				return "_:a"
+			}
+			return "_:z"
+		}))
+	}
+	sort.Strings(lines)
+	h := s.hash(strings.Join(lines, ""))
+	s.first[id] = h
+	return h
+}
+
+// hashRelated hashes a blank node related to another by the statement, at
+// the given position (s, o or g).
+func (s *c14nState) hashRelated(related string, st isoStatement, issuer *c14nIssuer, position string) string {
+	input := position
+	if position != "g" {
+		input += st.terms[1]
+	}
+	if id, ok := s.canonical.issued[related]; ok {
+		input += id
+	} else if id, ok := issuer.issued[related]; ok {
+		input += id
+	} else {
+		input += s.hashFirstDegree(related)
+	}
+	return s.hash(input)
+}
+
+// step counts a step of N-degree hashing against the work limit.
+func (s *c14nState) step() error {
+	s.work++
+	if s.c.MaxWork > 0 && s.work > s.c.MaxWork {
+		return ErrWorkLimit
+	}
+	return nil
+}
+
+// hashNDegree hashes the blank node by the paths to its related blank nodes,
+// choosing the lexicographically least path over all their permutations. It
+// returns the hash, and the issuer with the identifiers issued for the
+// chosen paths.
+func (s *c14nState) hashNDegree(id string, issuer *c14nIssuer) (string, *c14nIssuer, error) {
+	if err := s.step(); err != nil {
+		return "", nil, err
+	}
+	positions := [4]string{"s", "", "o", "g"}
+	related := make(map[string][]string)
+	for _, i := range s.stmts.byBlank[id] {
+		st := s.stmts.stmts[i]
+		for j, t := range st.terms {
+			if !st.blank[j] || t == id {
+				continue
+			}
+			h := s.hashRelated(t, st, issuer, positions[j])
+			related[h] = append(related[h], t)
+		}
+	}
+	hashes := make([]string, 0, len(related))
+	for h := range related {
+		hashes = append(hashes, h)
+	}
+	sort.Strings(hashes)
+
+	var data strings.Builder
+	for _, h := range hashes {
+		data.WriteString(h)
+		var chosenPath string
+		var chosenIssuer *c14nIssuer
+		var err error
+		permute(related[h], func(p []string) bool {
+			if err = s.step(); err != nil {
+				return false
+			}
+			issuerCopy := issuer.clone()
+			var path string
+			var recursion []string
+			longer := func() bool {
+				return chosenPath != "" && len(path) >= len(chosenPath) && path > chosenPath
+			}
+			for _, r := range p {
+				if cid, ok := s.canonical.issued[r]; ok {
+					path += cid
+				} else {
+					if _, ok := issuerCopy.issued[r]; !ok {
+						recursion = append(recursion, r)
+					}
+					path += issuerCopy.issue(r)
+				}
+				if longer() {
+					return true
+				}
+			}
+			for _, r := range recursion {
+				var rh string
+				rh, issuerCopy, err = s.hashNDegree(r, issuerCopy)
+				if err != nil {
+					return false
+				}
+				path += issuerCopy.issue(r) + "<" + rh + ">"
+				if longer() {
+					return true
+				}
+			}
+			if chosenPath == "" || path < chosenPath {
+				chosenPath, chosenIssuer = path, issuerCopy
+			}
+			return true
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		data.WriteString(chosenPath)
+		issuer = chosenIssuer
+	}
+	return s.hash(data.String()), issuer, nil
+}
+
+// permute calls fn with each permutation of the strings, until fn returns
+// false. The slice passed to fn must not be retained.
+func permute(ss []string, fn func([]string) bool) {
+	p := append([]string(nil), ss...)
+	sort.Strings(p)
+	for {
+		if !fn(p) {
+			return
+		}
+		// Advance to the next permutation in lexicographic order.
+		i := len(p) - 2
+		for i >= 0 && p[i] >= p[i+1] {
+			i--
+		}
+		if i < 0 {
+			return
+		}
+		j := len(p) - 1
+		for p[j] <= p[i] {
+			j--
+		}
+		p[i], p[j] = p[j], p[i]
+		for l, r := i+1, len(p)-1; l < r; l, r = l+1, r-1 {
+			p[l], p[r] = p[r], p[l]
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package rdf
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			`<http://example.org/s> <http://example.org/p> "o" <http://example.org/g> .
+<http://example.org/s> <http://example.org/p> "o" .
+`,
+			`<http://example.org/s> <http://example.org/p> "o" .
+<http://example.org/s> <http://example.org/p> "o" <http://example.org/g> .
+`,
+		},
+		{
+			`_:x <http://example.org/p> _:y .
+_:y <http://example.org/q> "v" .
+_:y <http://example.org/q> "v" .
+`,
+			`_:c14n0 <http://example.org/q> "v" .
+_:c14n1 <http://example.org/p> _:c14n0 .
+`,
+		},
+		{
+			`_:s <http://example.org/p> "o" _:g .
+`,
+			`_:c14n0 <http://example.org/p> "o" _:c14n1 .
+`,
+		},
+	}
+	c := NewCanonicalizer()
+	for i, test := range tests {
+		qs, err := NewQuadDecoder(bytes.NewBufferString(test.input), NQuads).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.NQuads(qs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("#%d: NQuads() =>\n%s\nwant:\n%s", i, got, test.want)
+		}
+	}
+}
+
+// relabelings returns the input with its blank node labels replaced by each
+// of the label sets.
+func relabelings(input string, from []string, to [][]string) []string {
+	res := []string{input}
+	for _, labels := range to {
+		var pairs []string
+		for i, l := range from {
+			pairs = append(pairs, "_:"+l+" ", "_:"+labels[i]+" ")
+		}
+		res = append(res, strings.NewReplacer(pairs...).Replace(input))
+	}
+	return res
+}
+
+func TestCanonicalizeRelabeled(t *testing.T) {
+	tests := []struct {
+		input string
+		from  []string
+		to    [][]string
+	}{
+		{
+			// Needs N-degree hashing: all nodes have the same first degree hash.
+			`_:e0 <http://example.org/vocab#next> _:e1 .
+_:e0 <http://example.org/vocab#prev> _:e2 .
+_:e1 <http://example.org/vocab#next> _:e2 .
+_:e1 <http://example.org/vocab#prev> _:e0 .
+_:e2 <http://example.org/vocab#next> _:e0 .
+_:e2 <http://example.org/vocab#prev> _:e1 .
+`,
+			[]string{"e0", "e1", "e2"},
+			[][]string{{"e1", "e2", "e0"}, {"b2", "a", "zz"}, {"e2", "e1", "e0"}},
+		},
+		{
+			`_:a <http://example.org/p> _:b _:g .
+_:b <http://example.org/p> _:c _:g .
+_:c <http://example.org/p> _:d _:g .
+_:d <http://example.org/p> _:a _:g .
+_:a <http://example.org/q> _:c .
+_:g <http://example.org/label> "graph" .
+`,
+			[]string{"a", "b", "c", "d", "g"},
+			[][]string{{"d", "c", "b", "a", "x"}, {"b", "c", "d", "a", "g"}, {"n1", "n2", "n3", "n4", "n0"}},
+		},
+	}
+	c := NewCanonicalizer()
+	for i, test := range tests {
+		var first string
+		for j, input := range relabelings(test.input, test.from, test.to) {
+			qs, err := NewQuadDecoder(bytes.NewBufferString(input), NQuads).DecodeAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.NQuads(qs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if j == 0 {
+				first = got
+				if strings.Contains(got, "_:e") || strings.Contains(got, "_:a ") {
+					t.Errorf("#%d: NQuads() kept input labels:\n%s", i, got)
+				}
+				continue
+			}
+			if got != first {
+				t.Errorf("#%d.%d: NQuads() of relabeled input =>\n%s\nwant:\n%s", i, j, got, first)
+			}
+		}
+	}
+}
+
+func TestCanonicalizeOptions(t *testing.T) {
+	// Two disjoint cycles of 4 blank nodes, which can only be told apart
+	// by permutation.
+	var input string
+	for _, c := range []string{"a", "b"} {
+		for i := 0; i < 4; i++ {
+			input += "_:" + c + string(rune('0'+i)) + " <http://example.org/p> _:" + c + string(rune('0'+(i+1)%4)) + " .\n"
+		}
+	}
+	qs, err := NewQuadDecoder(bytes.NewBufferString(input), NQuads).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCanonicalizer()
+	labels, err := c.Labels(qs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[Blank]bool)
+	for _, l := range labels {
+		seen[l] = true
+	}
+	if len(labels) != 8 || len(seen) != 8 {
+		t.Errorf("Labels() => %v, want 8 distinct labels", labels)
+	}
+
+	cqs, err := c.Canonicalize(qs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsomorphicQuads(qs, cqs) {
+		t.Error("Canonicalize() => quads not isomorphic to the input")
+	}
+	for _, q := range cqs {
+		if !TermsEqual(q.Ctx, c.DefaultGraph) {
+			t.Errorf("Canonicalize() => quad in graph %v, want default graph", q.Ctx)
+		}
+	}
+
+	sha256Out, _ := c.NQuads(qs)
+	c.Hash = sha512.New
+	sha512Out, err := c.NQuads(qs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sha512Out) != len(sha256Out) {
+		t.Errorf("NQuads() with SHA-512 =>\n%s", sha512Out)
+	}
+
+	c.MaxWork = 5
+	if _, err := c.NQuads(qs); err != ErrWorkLimit {
+		t.Errorf("NQuads() with MaxWork = 5 => %v, want %v", err, ErrWorkLimit)
+	}
+	c.MaxWork = 0
+	if _, err := c.NQuads(qs); err != nil {
+		t.Errorf("NQuads() with MaxWork = 0 => %v, want no error", err)
+	}
+}
@@ -0,0 +1,267 @@
+package rdf
+
+import (
+	"io"
+	"sort"
+)
+
+// Dataset is an in-memory RDF dataset; a default graph, and a set of
+// named graphs.
+//
+// The zero value is an empty dataset ready to use, where only quads with a
+// nil Ctx are in the default graph. A Dataset is not safe for concurrent use,
+// unless only read from.
+type Dataset struct {
+	// Quads in this graph (or with a nil Ctx) are in the default graph.
+	// Quads returned from the dataset have this context when in the default
+	// graph.
+	DefaultGraph Context
+
+	def    Graph
+	graphs map[string]*namedGraph // keyed by termKey of the name
+}
+
+// namedGraph is a graph of a dataset, with its name.
+type namedGraph struct {
+	name Context
+	g    *Graph
+}
+
+// NewDataset returns a new dataset, holding the given quads.
+func NewDataset(qs ...Quad) *Dataset {
+	d := &Dataset{DefaultGraph: Blank{id: "_:defaultGraph"}}
+	for _, q := range qs {
+		d.Add(q)
+	}
+	return d
+}
+
+// isDefaultGraph returns true if the given context denotes the default graph.
+func (d *Dataset) isDefaultGraph(g Context) bool {
+	return g == nil || (d.DefaultGraph != nil && TermsEqual(g, d.DefaultGraph))
+}
+
+// Add adds a quad to the dataset, creating its named graph if needed. It
+// returns false if the dataset already holds the quad.
+func (d *Dataset) Add(q Quad) bool {
+	return d.CreateGraph(q.Ctx).Add(q.Triple)
+}
+
+// Remove removes a quad from the dataset. It returns false if the dataset
+// does not hold the quad. The named graph of the quad is kept, even if
+// empty.
+func (d *Dataset) Remove(q Quad) bool {
+	g := d.Graph(q.Ctx)
+	return g != nil && g.Remove(q.Triple)
+}
+
+// Has returns true if the dataset holds the quad.
+func (d *Dataset) Has(q Quad) bool {
+	g := d.Graph(q.Ctx)
+	return g != nil && g.Has(q.Triple)
+}
+
+// Len returns the number of quads in the dataset.
+func (d *Dataset) Len() int {
+	n := d.def.Len()
+	for _, ng := range d.graphs {
+		n += ng.g.Len()
+	}
+	return n
+}
+
+// Graph returns the graph with the given name, or the default graph if
+// the name denotes the default graph. It returns nil if there is no such
+// named graph.
+//
+// Changes to the returned graph are changes to the dataset.
+func (d *Dataset) Graph(name Context) *Graph {
+	if d.isDefaultGraph(name) {
+		return &d.def
+	}
+	if ng, ok := d.graphs[termKey(name)]; ok {
+		return ng.g
+	}
+	return nil
+}
+
+// CreateGraph returns the graph with the given name, adding an empty named
+// graph to the dataset if there is none.
+func (d *Dataset) CreateGraph(name Context) *Graph {
+	if g := d.Graph(name); g != nil {
+		return g
+	}
+	if d.graphs == nil {
+		d.graphs = make(map[string]*namedGraph)
+	}
+	g := NewGraph()
+	d.graphs[termKey(name)] = &namedGraph{name: name, g: g}
+	return g
+}
+
+// Graphs returns the names of the named graphs of the dataset, sorted by
+// their N-Triples serialization.
+func (d *Dataset) Graphs() []Context {
+	keys := make([]string, 0, len(d.graphs))
+	for k := range d.graphs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := make([]Context, len(keys))
+	for i, k := range keys {
+		names[i] = d.graphs[k].name
+	}
+	return names
+}
+
+// DropGraph removes the named graph, and all its quads, from the dataset.
+// Dropping the default graph removes all its triples. It returns false if
+// there is no such named graph.
+func (d *Dataset) DropGraph(name Context) bool {
+	if d.isDefaultGraph(name) {
+		d.def = Graph{}
+		return true
+	}
+	k := termKey(name)
+	if _, ok := d.graphs[k]; !ok {
+		return false
+	}
+	delete(d.graphs, k)
+	return true
+}
+
+// Match returns the quads matching the given pattern. A nil term is a
+// wildcard, matching any term in its position; so a nil graph matches the
+// quads of all the graphs, including the default graph. To match only the
+// default graph, use the DefaultGraph of the dataset.
+//
+// The quads are ordered by graph, with the default graph first, but in no
+// particular order within a graph.
+func (d *Dataset) Match(subj Subject, pred Predicate, obj Object, graph Context) []Quad {
+	var qs []Quad
+	match := func(name Context, g *Graph) {
+		g.match(subj, pred, obj, func(t Triple) {
+			qs = append(qs, Quad{Triple: t, Ctx: name})
+		})
+	}
+	if graph != nil {
+		if g := d.Graph(graph); g != nil {
+			if d.isDefaultGraph(graph) {
+				graph = d.DefaultGraph
+			}
+			match(graph, g)
+		}
+		return qs
+	}
+	match(d.DefaultGraph, &d.def)
+	for _, name := range d.Graphs() {
+		match(name, d.graphs[termKey(name)].g)
+	}
+	return qs
+}
+
+// Quads returns all the quads of the dataset, ordered as by Match.
+func (d *Dataset) Quads() []Quad {
+	return d.Match(nil, nil, nil, nil)
+}
+
+// Union returns a read-only view of the dataset, as a graph which is the
+// union of the default graph and all the named graphs.
+func (d *Dataset) Union() *UnionGraph {
+	return &UnionGraph{d: d}
+}
+
+// Load adds all the quads decoded by the decoder to the dataset. Quads in
+// the DefaultGraph of the decoder are added to the default graph. It returns
+// the first decoding error, if any.
+func (d *Dataset) Load(dec *QuadDecoder) error {
+	for {
+		q, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if q.Ctx == nil || (dec.DefaultGraph != nil && TermsEqual(q.Ctx, dec.DefaultGraph)) {
+			q.Ctx = nil
+		}
+		d.Add(q)
+	}
+}
+
+// Encode writes all the quads of the dataset to the encoder, with the
+// quads of the default graph in the DefaultGraph of the encoder. The quads
+// are sorted by graph, subject, predicate and object, so the output is
+// deterministic.
+//
+// The encoder is not closed.
+func (d *Dataset) Encode(enc *QuadEncoder) error {
+	type keyedQuad struct {
+		key string
+		q   Quad
+	}
+	var kqs []keyedQuad
+	for _, q := range d.Quads() {
+		var g string // the default graph sorts first
+		if d.isDefaultGraph(q.Ctx) {
+			q.Ctx = enc.DefaultGraph
+		} else {
+			g = termKey(q.Ctx)
+		}
+		kqs = append(kqs, keyedQuad{g + " " + termKey(q.Subj) + " " + termKey(q.Pred) + " " + termKey(q.Obj), q})
+	}
+	sort.Slice(kqs, func(i, j int) bool {
+		return kqs[i].key < kqs[j].key
+	})
+	qs := make([]Quad, len(kqs))
+	for i, kq := range kqs {
+		qs[i] = kq.q
+	}
+	return enc.EncodeAll(qs)
+}
+
+// UnionGraph is a read-only view of the union of the graphs of a dataset.
+// A triple in more than one graph is only matched once.
+type UnionGraph struct {
+	d *Dataset
+}
+
+// Has returns true if any graph of the dataset holds the triple.
+func (u *UnionGraph) Has(t Triple) bool {
+	if u.d.def.Has(t) {
+		return true
+	}
+	for _, ng := range u.d.graphs {
+		if ng.g.Has(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of distinct triples in the union graph.
+func (u *UnionGraph) Len() int {
+	return len(u.Triples())
+}
+
+// Triples returns all the triples of the union graph, in no particular order.
+func (u *UnionGraph) Triples() []Triple {
+	return u.Match(nil, nil, nil)
+}
+
+// Match returns the triples matching the given pattern in any graph of the
+// dataset, in no particular order. A nil term is a wildcard, matching any
+// term in its position.
+func (u *UnionGraph) Match(subj Subject, pred Predicate, obj Object) []Triple {
+	var ts []Triple
+	seen := make(map[string]bool)
+	for _, q := range u.d.Match(subj, pred, obj, nil) {
+		k := termKey(q.Subj) + " " + termKey(q.Pred) + " " + termKey(q.Obj)
+		if !seen[k] {
+			seen[k] = true
+			ts = append(ts, q.Triple)
+		}
+	}
+	return ts
+}
@@ -0,0 +1,116 @@
+package rdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDataset(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:a ex:p ex:b .
+ex:g1 { ex:a ex:p ex:b , ex:c . }
+ex:g2 { ex:b ex:p ex:c . }
+_:g3 { ex:c ex:q "x" . }
+`
+	d := NewDataset()
+	if err := d.Load(NewQuadDecoder(bytes.NewBufferString(input), TriG)); err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 5 {
+		t.Fatalf("Len() => %d, want 5", d.Len())
+	}
+
+	a, b, c := IRI{str: "http://example.org/a"}, IRI{str: "http://example.org/b"}, IRI{str: "http://example.org/c"}
+	p := IRI{str: "http://example.org/p"}
+	g1, g2 := IRI{str: "http://example.org/g1"}, IRI{str: "http://example.org/g2"}
+	tests := []struct {
+		subj  Subject
+		pred  Predicate
+		obj   Object
+		graph Context
+		want  string
+	}{
+		{a, p, b, nil, `<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/a> <http://example.org/p> <http://example.org/b> <http://example.org/g1> .
+`},
+		{a, p, b, defaultGraph, "<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .\n"},
+		{nil, nil, c, nil, `<http://example.org/a> <http://example.org/p> <http://example.org/c> <http://example.org/g1> .
+<http://example.org/b> <http://example.org/p> <http://example.org/c> <http://example.org/g2> .
+`},
+		{nil, nil, nil, g2, "<http://example.org/b> <http://example.org/p> <http://example.org/c> <http://example.org/g2> .\n"},
+		{nil, nil, nil, IRI{str: "http://example.org/missing"}, ""},
+	}
+	for i, test := range tests {
+		var got string
+		for _, q := range d.Match(test.subj, test.pred, test.obj, test.graph) {
+			got += q.Serialize(NQuads)
+		}
+		if got != test.want {
+			t.Errorf("#%d: Match(%v, %v, %v, %v) =>\n%s\nwant:\n%s", i, test.subj, test.pred, test.obj, test.graph, got, test.want)
+		}
+	}
+
+	if names := d.Graphs(); len(names) != 3 || !TermsEqual(names[0], g1) || !TermsEqual(names[1], g2) {
+		t.Errorf("Graphs() => %v, want [g1 g2 _:g3]", names)
+	}
+	if got := d.Graph(nil).Len(); got != 1 {
+		t.Errorf("Graph(nil).Len() => %d, want 1", got)
+	}
+	if got := d.Graph(g1).Match(a, p, nil); len(got) != 2 {
+		t.Errorf("Graph(g1).Match(a, p, nil) => %v, want 2 triples", got)
+	}
+
+	u := d.Union()
+	if u.Len() != 4 {
+		t.Errorf("Union().Len() => %d, want 4", u.Len())
+	}
+	if got := sortedNTriples(u.Match(nil, p, nil)); got != `<http://example.org/a> <http://example.org/p> <http://example.org/b> .
+<http://example.org/a> <http://example.org/p> <http://example.org/c> .
+<http://example.org/b> <http://example.org/p> <http://example.org/c> .
+` {
+		t.Errorf("Union().Match(nil, p, nil) =>\n%s", got)
+	}
+	if !u.Has(Triple{Subj: b, Pred: p, Obj: c}) {
+		t.Error("Union().Has() => false, want true")
+	}
+
+	if !d.DropGraph(g1) || d.DropGraph(g1) {
+		t.Error("DropGraph(g1) twice => want true, then false")
+	}
+	if d.Len() != 3 || d.Graph(g1) != nil || u.Len() != 3 {
+		t.Errorf("after DropGraph(g1): Len() => %d, Union().Len() => %d; want 3, 3", d.Len(), u.Len())
+	}
+	q := Quad{Triple: Triple{Subj: b, Pred: p, Obj: c}, Ctx: g2}
+	if !d.Has(q) || !d.Remove(q) || d.Has(q) {
+		t.Error("Has()/Remove() of quad failed")
+	}
+	if d.Graph(g2) == nil || d.Graph(g2).Len() != 0 {
+		t.Error("Remove() of last quad dropped the named graph")
+	}
+	d.CreateGraph(g1)
+	if !d.Add(Quad{Triple: Triple{Subj: a, Pred: p, Obj: c}, Ctx: g1}) {
+		t.Error("Add() => false, want true")
+	}
+
+	var buf bytes.Buffer
+	enc := NewQuadEncoder(&buf, NQuads)
+	if err := d.Encode(enc); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/a> <http://example.org/p> <http://example.org/c> <http://example.org/g1> .
+<http://example.org/c> <http://example.org/q> "x" _:g3 .
+`
+	if buf.String() != want {
+		t.Errorf("Encode() =>\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	var zero Dataset
+	zero.Add(Quad{Triple: Triple{Subj: a, Pred: p, Obj: b}})
+	if zero.Len() != 1 || len(zero.Match(nil, nil, nil, nil)) != 1 || len(zero.Graphs()) != 0 {
+		t.Error("zero Dataset: Add() to the default graph failed")
+	}
+}
@@ -15,6 +15,10 @@ const (
 	// relative IRIs: Turtle, RDF/XML, TriG, JSON-LD)
 	Base ParseOption = iota
 
+	// Loader is the DocumentLoader used to retrieve remote documents, such
+	// as JSON-LD contexts referenced by IRI.
+	Loader
+
 	// Strict mode determines how the decoder responds to errors.
 	// When true (the default), it will fail on any malformed input. When
 	// false, it will try to continue parsing, discarding only the malformed
@@ -34,7 +38,8 @@ const (
 //
 //  Option      Description        Value      (default)       Format support
 //  ------------------------------------------------------------------------------
-//  Base        Base IRI           IRI        (empty IRI)     Turtle, RDF/XML
+//  Base        Base IRI           IRI        (empty IRI)     Turtle, RDF/XML, TriG, JSON-LD
+//  Loader      Document loader    DocumentLoader (HTTP)      JSON-LD
 //  Strict      Strict mode        true/false (true)          TODO
 //  ErrOut      Error output       io.Writer  (nil)           TODO
 type TripleDecoder interface {
@@ -61,18 +66,24 @@ func NewTripleDecoder(r io.Reader, f Format) TripleDecoder {
 		return newRDFXMLDecoder(r)
 	case Turtle:
 		return newTTLDecoder(r)
+	case JSONLD:
+		return newJSONLDDecoder(r)
 	default:
 		panic(fmt.Errorf("Decoder for serialization format %v not implemented", f))
 	}
 }
 
 // QuadDecoder parses RDF quads in one of the following formats:
-// N-Quads.
+// N-Quads, TriG, JSON-LD.
 //
 // For streaming parsing, use the Decode() method to decode a single Quad
 // at a time. Or, if you want to read the whole source in one go, DecodeAll().
+//
+// Triples outside of any named graph are assigned the DefaultGraph context.
 type QuadDecoder struct {
 	l      *lexer
+	ttl    *ttlDecoder    // TriG is parsed by the Turtle decoder
+	jsonld *jsonldDecoder // JSON-LD
 	format Format
 
 	DefaultGraph Context  // default graph
@@ -83,6 +94,20 @@ type QuadDecoder struct {
 // NewQuadDecoder returns a new QuadDecoder capable of parsing quads
 // from the given io.Reader in the given serialization format.
 func NewQuadDecoder(r io.Reader, f Format) *QuadDecoder {
+	switch f {
+	case TriG:
+		return &QuadDecoder{
+			ttl:          newTriGDecoder(r),
+			format:       f,
+			DefaultGraph: Blank{id: "_:defaultGraph"},
+		}
+	case JSONLD:
+		return &QuadDecoder{
+			jsonld:       newJSONLDDecoder(r),
+			format:       f,
+			DefaultGraph: Blank{id: "_:defaultGraph"},
+		}
+	}
 	return &QuadDecoder{
 		l:            newLineLexer(r),
 		format:       f,
@@ -90,8 +115,26 @@ func NewQuadDecoder(r io.Reader, f Format) *QuadDecoder {
 	}
 }
 
+// SetOption sets a parsing option to the given value. Not all options
+// are supported by all serialization formats.
+func (d *QuadDecoder) SetOption(o ParseOption, v interface{}) error {
+	switch d.format {
+	case TriG:
+		return d.ttl.SetOption(o, v)
+	case JSONLD:
+		return d.jsonld.SetOption(o, v)
+	}
+	return fmt.Errorf("N-Quads decoder doesn't support option: %v", o)
+}
+
 // Decode returns the next valid Quad, or an error
 func (d *QuadDecoder) Decode() (Quad, error) {
+	switch d.format {
+	case TriG:
+		return d.parseTriG()
+	case JSONLD:
+		return d.parseJSONLD()
+	}
 	return d.parseNQ()
 }
 
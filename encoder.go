@@ -13,12 +13,16 @@ import (
 var ErrEncoderClosed = errors.New("Encoder is closed and cannot encode anymore")
 
 // TripleEncoder serializes RDF Triples into one of the following formats:
-// N-Triples, Turtle, RDF/XML.
+// N-Triples, Turtle, RDF/XML, JSON-LD.
 //
 // For streaming serialization, use the Encode() method to encode a single Triple
 // at a time. Or, if you want to encode multiple triples in one batch, use EncodeAll().
 // In either case; when done serializing, Close() must be called, to ensure
 // that all writes are persisted, since the Encoder uses buffered IO.
+//
+// JSON-LD and RDF/XML are not streamed; the triples are collected and the
+// document is written when Close() is called, as configured by JSONLDOptions
+// or RDFXMLOptions.
 type TripleEncoder struct {
 	format        Format            // Serialization format.
 	w             *errWriter        // Buffered writer. Set to nil when Encoder is closed.
@@ -27,6 +31,16 @@ type TripleEncoder struct {
 	curSubj       Subject           // Keep track of current subject, to enable encoding of predicate lists.
 	curPred       Predicate         // Keep track of current subject, to enable encoding of object list.
 	OpenStatement bool              // True when triple statement hasn't been closed (i.e. in a predicate/object list)
+
+	// TriG graph block state, used when encoding quads.
+	graph   Context // graph of the current block, or nil for the default graph
+	inGraph bool    // true when the graph block is open
+
+	JSONLDOptions JSONLDOptions // Options for JSON-LD serialization.
+	jsonld        []Quad        // Triples collected for JSON-LD serialization.
+
+	RDFXMLOptions RDFXMLOptions // Options for RDF/XML serialization.
+	rdfxml        []Triple      // Triples collected for RDF/XML serialization.
 }
 
 // NewTripleEncoder returns a new TripleEncoder capable of serializing into the
@@ -51,66 +65,14 @@ func (e *TripleEncoder) Encode(t Triple) error {
 			return err
 		}
 	case Turtle:
-		var s, p, o string
-
-		// object is allways rendered the same
-		o = e.prefixify(t.Obj)
-
-		if e.OpenStatement {
-			// potentially predicate/object list
-			// curSubj and curPred is set
-			if TermsEqual(e.curSubj, t.Subj) {
-				// In predicate or object list
-				if TermsEqual(e.curPred, t.Pred) {
-					// in object list
-					s = " ,\n\t"
-					p = ""
-				} else {
-					// in predicate list
-					p = e.prefixify(t.Pred)
-
-					// check if predicate introduced new prefix directive
-					if e.OpenStatement {
-						// in predicate list
-						s = " ;\n"
-						e.curPred = t.Pred
-					} else {
-						// previous statement closed
-						e.curSubj = t.Subj
-						s = e.prefixify(t.Subj)
-						e.curPred = t.Pred
-					}
-				}
-			} else {
-				// not in predicate/ojbect list
-				// close previous statement
-				e.w.write([]byte(" .\n"))
-				e.OpenStatement = false
-				p = e.prefixify(t.Pred)
-				e.curSubj = t.Subj
-				s = e.prefixify(t.Subj)
-				e.curPred = t.Pred
-			}
-		} else {
-			// either first statement, or after a prefix directive
-			p = e.prefixify(t.Pred)
-			s = e.prefixify(t.Subj)
-			e.curSubj = t.Subj
-			e.curPred = t.Pred
-		}
-
-		// allways keep statement open, in case next triple can mean predicate/object list
-		e.OpenStatement = true
-
-		e.w.write([]byte(s))
-		e.w.write([]byte("\t"))
-		e.w.write([]byte(p))
-		e.w.write([]byte("\t"))
-		e.w.write([]byte(o))
-
+		e.encodeTTL(t)
 		if e.w.err != nil {
 			return e.w.err
 		}
+	case JSONLD:
+		e.jsonld = append(e.jsonld, Quad{Triple: t})
+	case RDFXML:
+		e.rdfxml = append(e.rdfxml, t)
 	default:
 		panic("TODO")
 	}
@@ -137,74 +99,23 @@ func (e *TripleEncoder) EncodeAll(ts []Triple) error {
 		// Sort triples by Subject, then Predicate, to maximize predicate and object lists.
 		sort.Sort(bySubjectThenPred(triples(ts)))
 
-		var s, p, o string
-
 		for i, t := range ts {
-			// object is allways rendered the same
-			o = e.prefixify(t.Obj)
-
-			if e.OpenStatement {
-				// potentially predicate/object list
-				// curSubj and curPred is set
-				if TermsEqual(e.curSubj, t.Subj) {
-					// In predicate or object list
-					if TermsEqual(e.curPred, t.Pred) {
-						// in object list
-
-						// check if this triple is a duplicate of the preceeding triple
-						if i > 0 && TermsEqual(t.Obj, ts[i-1].Obj) {
-							continue
-						}
-
-						s = " ,\n\t"
-						p = ""
-					} else {
-						// in predicate list
-						p = e.prefixify(t.Pred)
-
-						// check if predicate introduced new prefix directive
-						if e.OpenStatement {
-							// in predicate list
-							s = " ;\n"
-							e.curPred = t.Pred
-						} else {
-							// previous statement closed
-							e.curSubj = t.Subj
-							s = e.prefixify(t.Subj)
-							e.curPred = t.Pred
-						}
-					}
-				} else {
-					// not in predicate/ojbect list
-					// close previous statement
-					e.w.write([]byte(" .\n"))
-					e.OpenStatement = false
-					p = e.prefixify(t.Pred)
-					e.curSubj = t.Subj
-					s = e.prefixify(t.Subj)
-					e.curPred = t.Pred
-				}
-			} else {
-				// either first statement, or after a prefix directive
-				p = e.prefixify(t.Pred)
-				s = e.prefixify(t.Subj)
-				e.curSubj = t.Subj
-				e.curPred = t.Pred
+			// check if this triple is a duplicate of the preceeding triple
+			if i > 0 && TriplesEqual(t, ts[i-1]) {
+				continue
 			}
 
-			// allways keep statement open, in case next triple can mean predicate/object list
-			e.OpenStatement = true
-
-			e.w.write([]byte(s))
-			e.w.write([]byte("\t"))
-			e.w.write([]byte(p))
-			e.w.write([]byte("\t"))
-			e.w.write([]byte(o))
-
+			e.encodeTTL(t)
 			if e.w.err != nil {
 				return e.w.err
 			}
 		}
+	case JSONLD:
+		for _, t := range ts {
+			e.jsonld = append(e.jsonld, Quad{Triple: t})
+		}
+	case RDFXML:
+		e.rdfxml = append(e.rdfxml, ts...)
 	default:
 		panic("TODO")
 	}
@@ -217,6 +128,16 @@ func (e *TripleEncoder) EncodeAll(ts []Triple) error {
 //
 // The encoder cannot encode anymore when Close() has been called.
 func (e *TripleEncoder) Close() error {
+	if e.format == JSONLD {
+		if err := encodeJSONLD(e.w.w, e.jsonld, e.JSONLDOptions); err != nil {
+			return err
+		}
+	}
+	if e.format == RDFXML {
+		if err := encodeRDFXML(e.w.w, e.rdfxml, e.RDFXMLOptions); err != nil {
+			return err
+		}
+	}
 	if e.OpenStatement {
 		e.w.write([]byte(" .")) // Close final statement
 		if e.w.err != nil {
@@ -228,6 +149,87 @@ func (e *TripleEncoder) Close() error {
 	return err
 }
 
+// encodeTTL writes a triple in Turtle syntax, continuing the predicate or object
+// list of the previous triple when possible.
+func (e *TripleEncoder) encodeTTL(t Triple) {
+	var s, p, o string
+
+	// object is allways rendered the same
+	o = e.prefixify(t.Obj)
+
+	if e.OpenStatement {
+		// potentially predicate/object list
+		// curSubj and curPred is set
+		if TermsEqual(e.curSubj, t.Subj) {
+			// In predicate or object list
+			if TermsEqual(e.curPred, t.Pred) {
+				// in object list
+				s = " ,\n\t"
+				p = ""
+			} else {
+				// in predicate list
+				p = e.prefixify(t.Pred)
+
+				// check if predicate introduced new prefix directive
+				if e.OpenStatement {
+					// in predicate list
+					s = " ;\n"
+					e.curPred = t.Pred
+				} else {
+					// previous statement closed
+					e.curSubj = t.Subj
+					s = e.prefixify(t.Subj)
+					e.curPred = t.Pred
+				}
+			}
+		} else {
+			// not in predicate/ojbect list
+			// close previous statement
+			e.w.write([]byte(" .\n"))
+			e.OpenStatement = false
+			p = e.prefixify(t.Pred)
+			e.curSubj = t.Subj
+			s = e.prefixify(t.Subj)
+			e.curPred = t.Pred
+		}
+	} else {
+		// either first statement, or after a prefix directive
+		p = e.prefixify(t.Pred)
+		s = e.prefixify(t.Subj)
+		e.curSubj = t.Subj
+		e.curPred = t.Pred
+	}
+
+	if e.graph != nil && !e.inGraph {
+		// Open TriG graph block. The label is rendered last, since any
+		// prefix directive emitted above will close the block.
+		e.w.write([]byte(e.prefixify(e.graph)))
+		e.w.write([]byte(" {\n"))
+		e.inGraph = true
+	}
+
+	// allways keep statement open, in case next triple can mean predicate/object list
+	e.OpenStatement = true
+
+	e.w.write([]byte(s))
+	e.w.write([]byte("\t"))
+	e.w.write([]byte(p))
+	e.w.write([]byte("\t"))
+	e.w.write([]byte(o))
+}
+
+// closeGraph closes the open statement, and the current TriG graph block, if any.
+func (e *TripleEncoder) closeGraph() {
+	if e.OpenStatement {
+		e.w.write([]byte(" .\n"))
+		e.OpenStatement = false
+	}
+	if e.inGraph {
+		e.w.write([]byte("}\n"))
+		e.inGraph = false
+	}
+}
+
 func (e *TripleEncoder) prefixify(t Term) string {
 	if t.Type() == TermIRI {
 		if t.(IRI).str == "http://www.w3.org/1999/02/22-rdf-syntax-ns#type" {
@@ -245,12 +247,9 @@ func (e *TripleEncoder) prefixify(t Term) string {
 		if !ok {
 			prefix = fmt.Sprintf("ns%d", e.nsCount)
 			e.ns[first] = prefix
-			if e.OpenStatement {
-				e.w.write([]byte(" .\n"))
-			}
+			e.closeGraph()
 			e.w.write([]byte(fmt.Sprintf("@prefix %s:\t<%s> .\n", prefix, first)))
 			e.nsCount++
-			e.OpenStatement = false
 		}
 		return fmt.Sprintf("%s:%s", prefix, rest)
 	}
@@ -270,12 +269,9 @@ func (e *TripleEncoder) prefixify(t Term) string {
 			if !ok {
 				prefix = fmt.Sprintf("ns%d", e.nsCount)
 				e.ns[first] = prefix
-				if e.OpenStatement {
-					e.w.write([]byte(" .\n"))
-				}
+				e.closeGraph()
 				e.w.write([]byte(fmt.Sprintf("@prefix %s:\t<%s> .\n", prefix, first)))
 				e.nsCount++
-				e.OpenStatement = false
 			}
 			return fmt.Sprintf("\"%s\"^^%s:%s", t.Serialize(formatInternal), prefix, rest)
 		}
@@ -345,24 +341,58 @@ func (ew *errWriter) write(buf []byte) {
 	_, ew.err = ew.w.Write(buf)
 }
 
-// QuadEncoder serializes RDF Quads. Currently only supports N-Quads.
+// QuadEncoder serializes RDF Quads into one of the following formats:
+// N-Quads, TriG, JSON-LD.
+//
+// When encoding TriG, consecutive quads in the same graph are grouped in a
+// graph block, while quads in the default graph are written without one.
+//
+// When encoding JSON-LD, the quads are collected and the document is written
+// when Close() is called, as configured by JSONLDOptions.
 type QuadEncoder struct {
-	w *errWriter
+	format Format
+	w      *errWriter
+	ttl    *TripleEncoder // Turtle encoder for the triples in TriG graph blocks
+	jsonld []Quad         // Quads collected for JSON-LD serialization
+
+	DefaultGraph  Context       // quads in this graph (or with a nil Ctx) are in the default graph
+	JSONLDOptions JSONLDOptions // options for JSON-LD serialization
 }
 
-// NewQuadEncoder returns a new QuadEncoder on the given writer. The only supported
-// format is NQuads.
+// NewQuadEncoder returns a new QuadEncoder on the given writer. The supported
+// formats are NQuads, TriG and JSONLD.
 func NewQuadEncoder(w io.Writer, f Format) *QuadEncoder {
-	if f != NQuads {
-		panic("NewQuadEncoder: only N-Quads format supported ATM")
+	if f != NQuads && f != TriG && f != JSONLD {
+		panic("NewQuadEncoder: only N-Quads, TriG and JSON-LD formats supported ATM")
+	}
+	e := &QuadEncoder{
+		format:       f,
+		w:            &errWriter{w: bufio.NewWriter(w)},
+		DefaultGraph: Blank{id: "_:defaultGraph"},
 	}
-	return &QuadEncoder{
-		w: &errWriter{w: bufio.NewWriter(w)},
+	if f == TriG {
+		e.ttl = &TripleEncoder{
+			format: Turtle,
+			w:      e.w,
+			ns:     make(map[string]string),
+		}
 	}
+	return e
 }
 
 // Encode encodes a Quad.
 func (e *QuadEncoder) Encode(q Quad) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	switch e.format {
+	case TriG:
+		e.encodeTriG(q)
+		return e.w.err
+	case JSONLD:
+		e.collectJSONLD(q)
+		return nil
+	}
 	_, err := e.w.w.Write([]byte(q.Serialize(NQuads)))
 	if err != nil {
 		return err
@@ -371,10 +401,45 @@ func (e *QuadEncoder) Encode(q Quad) error {
 }
 
 // EncodeAll encodes all quads.
+//
+// When encoding TriG, duplicate quads are ignored, and the given slice of quads
+// is sorted in-place by graph, then subject and predicate. All prefix directives
+// are written before the first graph block.
 func (e *QuadEncoder) EncodeAll(qs []Quad) error {
 	if e.w == nil {
 		return ErrEncoderClosed
 	}
+	if e.format == TriG {
+		sort.Sort(byGraphThenSubjectThenPred{qs: qs, defaultGraph: e.DefaultGraph})
+
+		// Write all prefix directives up front, so that graph
+		// blocks doesn't have to be interrupted by them.
+		for _, q := range qs {
+			if !e.isDefaultGraph(q.Ctx) {
+				e.ttl.prefixify(q.Ctx)
+			}
+			e.ttl.prefixify(q.Subj)
+			e.ttl.prefixify(q.Pred)
+			e.ttl.prefixify(q.Obj)
+		}
+
+		for i, q := range qs {
+			if i > 0 && QuadsEqual(q, qs[i-1]) {
+				continue
+			}
+			e.encodeTriG(q)
+			if e.w.err != nil {
+				return e.w.err
+			}
+		}
+		return nil
+	}
+	if e.format == JSONLD {
+		for _, q := range qs {
+			e.collectJSONLD(q)
+		}
+		return nil
+	}
 	for _, q := range qs {
 		_, err := e.w.w.Write([]byte(q.Serialize(NQuads)))
 		if err != nil {
@@ -386,7 +451,85 @@ func (e *QuadEncoder) EncodeAll(qs []Quad) error {
 
 // Close closes the encoder and flushes the underlying buffering writer.
 func (e *QuadEncoder) Close() error {
+	if e.format == JSONLD {
+		if err := encodeJSONLD(e.w.w, e.jsonld, e.JSONLDOptions); err != nil {
+			return err
+		}
+	}
+	if e.format == TriG {
+		e.ttl.closeGraph()
+		if e.w.err != nil {
+			return e.w.err
+		}
+	}
 	err := e.w.w.Flush()
 	e.w = nil
 	return err
 }
+
+// encodeTriG writes the quad's triple to the graph block of the quad's context,
+// closing the current graph block if it belongs to another graph.
+func (e *QuadEncoder) encodeTriG(q Quad) {
+	g := q.Ctx
+	if e.isDefaultGraph(g) {
+		g = nil
+	}
+	switch {
+	case e.ttl.graph == nil && g == nil:
+		// still in default graph
+	case e.ttl.graph != nil && g != nil && TermsEqual(e.ttl.graph, g):
+		// still in same graph
+	default:
+		e.ttl.closeGraph()
+		e.ttl.graph = g
+	}
+	e.ttl.encodeTTL(q.Triple)
+}
+
+// collectJSONLD adds the quad to the JSON-LD document to be written on Close().
+func (e *QuadEncoder) collectJSONLD(q Quad) {
+	if e.isDefaultGraph(q.Ctx) {
+		q.Ctx = nil
+	}
+	e.jsonld = append(e.jsonld, q)
+}
+
+// isDefaultGraph returns true if the given context denotes the default graph.
+func (e *QuadEncoder) isDefaultGraph(g Context) bool {
+	return g == nil || (e.DefaultGraph != nil && TermsEqual(g, e.DefaultGraph))
+}
+
+type byGraphThenSubjectThenPred struct {
+	qs           []Quad
+	defaultGraph Context
+}
+
+func (q byGraphThenSubjectThenPred) Len() int {
+	return len(q.qs)
+}
+
+func (q byGraphThenSubjectThenPred) Swap(i, j int) {
+	q.qs[i], q.qs[j] = q.qs[j], q.qs[i]
+}
+
+func (q byGraphThenSubjectThenPred) Less(i, j int) bool {
+	// The default graph is sorted first.
+	g, h := q.graph(i), q.graph(j)
+	switch {
+	case g < h:
+		return true
+	case h < g:
+		return false
+	default:
+		// graphs are equal, continue by comparing subjects
+		return bySubjectThenPred{q.qs[i].Triple, q.qs[j].Triple}.Less(0, 1)
+	}
+}
+
+func (q byGraphThenSubjectThenPred) graph(i int) string {
+	g := q.qs[i].Ctx
+	if g == nil || (q.defaultGraph != nil && TermsEqual(g, q.defaultGraph)) {
+		return ""
+	}
+	return g.Serialize(NTriples)
+}
@@ -0,0 +1,196 @@
+package rdf
+
+import "io"
+
+// Graph is an in-memory RDF graph; a set of triples, indexed for
+// triple pattern matching.
+//
+// The triples are kept in three indexes (subject-predicate-object,
+// predicate-object-subject and object-subject-predicate), so that every
+// pattern can be answered without scanning the whole graph.
+//
+// The zero value is an empty graph ready to use. A Graph is not safe for
+// concurrent use, unless only read from.
+type Graph struct {
+	spo tripleIndex
+	pos tripleIndex
+	osp tripleIndex
+	n   int
+}
+
+// tripleIndex maps the keys of three terms, in index order, to a triple.
+type tripleIndex map[string]map[string]map[string]Triple
+
+// NewGraph returns a new graph, holding the given triples.
+func NewGraph(ts ...Triple) *Graph {
+	g := &Graph{}
+	for _, t := range ts {
+		g.Add(t)
+	}
+	return g
+}
+
+// termKey returns the key of a term in the indexes. Unlike TermsEqual, it
+// distinguishes literals with the same lexical form and different datatypes.
+func termKey(t Term) string {
+	return t.Serialize(NTriples)
+}
+
+// Add adds a triple to the graph. It returns false if the graph already
+// holds the triple.
+func (g *Graph) Add(t Triple) bool {
+	if g.spo == nil {
+		g.spo, g.pos, g.osp = make(tripleIndex), make(tripleIndex), make(tripleIndex)
+	}
+	s, p, o := termKey(t.Subj), termKey(t.Pred), termKey(t.Obj)
+	if !g.spo.add(s, p, o, t) {
+		return false
+	}
+	g.pos.add(p, o, s, t)
+	g.osp.add(o, s, p, t)
+	g.n++
+	return true
+}
+
+// Remove removes a triple from the graph. It returns false if the graph
+// does not hold the triple.
+func (g *Graph) Remove(t Triple) bool {
+	s, p, o := termKey(t.Subj), termKey(t.Pred), termKey(t.Obj)
+	if !g.spo.remove(s, p, o) {
+		return false
+	}
+	g.pos.remove(p, o, s)
+	g.osp.remove(o, s, p)
+	g.n--
+	return true
+}
+
+// Has returns true if the graph holds the triple.
+func (g *Graph) Has(t Triple) bool {
+	_, ok := g.spo[termKey(t.Subj)][termKey(t.Pred)][termKey(t.Obj)]
+	return ok
+}
+
+// Len returns the number of triples in the graph.
+func (g *Graph) Len() int {
+	return g.n
+}
+
+// Triples returns all the triples of the graph, in no particular order.
+func (g *Graph) Triples() []Triple {
+	return g.Match(nil, nil, nil)
+}
+
+// Match returns the triples matching the given pattern, in no particular
+// order. A nil term is a wildcard, matching any term in its position.
+func (g *Graph) Match(subj Subject, pred Predicate, obj Object) []Triple {
+	var ts []Triple
+	g.match(subj, pred, obj, func(t Triple) {
+		ts = append(ts, t)
+	})
+	return ts
+}
+
+// match calls fn for each triple matching the pattern, using the index
+// where the bound terms of the pattern form a prefix.
+func (g *Graph) match(subj Subject, pred Predicate, obj Object, fn func(Triple)) {
+	var s, p, o string
+	if subj != nil {
+		s = termKey(subj)
+	}
+	if pred != nil {
+		p = termKey(pred)
+	}
+	if obj != nil {
+		o = termKey(obj)
+	}
+	switch {
+	case subj != nil && pred != nil && obj != nil:
+		if t, ok := g.spo[s][p][o]; ok {
+			fn(t)
+		}
+	case subj != nil && pred != nil:
+		g.spo.each2(s, p, fn)
+	case subj != nil && obj != nil:
+		g.osp.each2(o, s, fn)
+	case pred != nil && obj != nil:
+		g.pos.each2(p, o, fn)
+	case subj != nil:
+		g.spo.each1(s, fn)
+	case pred != nil:
+		g.pos.each1(p, fn)
+	case obj != nil:
+		g.osp.each1(o, fn)
+	default:
+		for k := range g.spo {
+			g.spo.each1(k, fn)
+		}
+	}
+}
+
+// Load adds all the triples decoded by the decoder to the graph. It returns
+// the first decoding error, if any.
+func (g *Graph) Load(dec TripleDecoder) error {
+	for {
+		t, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		g.Add(t)
+	}
+}
+
+// add indexes a triple by the keys a, b and c. It returns false if the
+// triple is already indexed.
+func (idx tripleIndex) add(a, b, c string, t Triple) bool {
+	m, ok := idx[a]
+	if !ok {
+		m = make(map[string]map[string]Triple)
+		idx[a] = m
+	}
+	n, ok := m[b]
+	if !ok {
+		n = make(map[string]Triple)
+		m[b] = n
+	}
+	if _, ok := n[c]; ok {
+		return false
+	}
+	n[c] = t
+	return true
+}
+
+// remove removes the triple indexed by the keys a, b and c, pruning any
+// empty maps. It returns false if there is no such triple.
+func (idx tripleIndex) remove(a, b, c string) bool {
+	if _, ok := idx[a][b][c]; !ok {
+		return false
+	}
+	delete(idx[a][b], c)
+	if len(idx[a][b]) == 0 {
+		delete(idx[a], b)
+		if len(idx[a]) == 0 {
+			delete(idx, a)
+		}
+	}
+	return true
+}
+
+// each1 calls fn for each triple indexed under the key a.
+func (idx tripleIndex) each1(a string, fn func(Triple)) {
+	for _, n := range idx[a] {
+		for _, t := range n {
+			fn(t)
+		}
+	}
+}
+
+// each2 calls fn for each triple indexed under the keys a and b.
+func (idx tripleIndex) each2(a, b string, fn func(Triple)) {
+	for _, t := range idx[a][b] {
+		fn(t)
+	}
+}
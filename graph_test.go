@@ -0,0 +1,110 @@
+package rdf
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sortedNTriples returns the triples as sorted N-Triples.
+func sortedNTriples(ts []Triple) string {
+	lines := make([]string, 0, len(ts))
+	for _, t := range ts {
+		lines = append(lines, t.Serialize(NTriples))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "")
+}
+
+func TestGraph(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:a ex:p ex:b , ex:c , "1" , 1 .
+ex:a ex:q ex:b .
+ex:b ex:p ex:c .
+_:x ex:q ex:a .
+`
+	g := NewGraph()
+	if err := g.Load(NewTripleDecoder(bytes.NewBufferString(input), Turtle)); err != nil {
+		t.Fatal(err)
+	}
+	if g.Len() != 7 {
+		t.Fatalf("Len() => %d, want 7", g.Len())
+	}
+
+	a, b, c := IRI{str: "http://example.org/a"}, IRI{str: "http://example.org/b"}, IRI{str: "http://example.org/c"}
+	p, q := IRI{str: "http://example.org/p"}, IRI{str: "http://example.org/q"}
+	x := Blank{id: "_:x"}
+	tests := []struct {
+		subj Subject
+		pred Predicate
+		obj  Object
+		want string
+	}{
+		{a, p, b, "<http://example.org/a> <http://example.org/p> <http://example.org/b> .\n"},
+		{a, p, c, "<http://example.org/a> <http://example.org/p> <http://example.org/c> .\n"},
+		{a, q, c, ""},
+		{a, p, nil, `<http://example.org/a> <http://example.org/p> "1" .
+<http://example.org/a> <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/a> <http://example.org/p> <http://example.org/b> .
+<http://example.org/a> <http://example.org/p> <http://example.org/c> .
+`},
+		{a, nil, b, `<http://example.org/a> <http://example.org/p> <http://example.org/b> .
+<http://example.org/a> <http://example.org/q> <http://example.org/b> .
+`},
+		{nil, p, c, `<http://example.org/a> <http://example.org/p> <http://example.org/c> .
+<http://example.org/b> <http://example.org/p> <http://example.org/c> .
+`},
+		{x, nil, nil, "_:x <http://example.org/q> <http://example.org/a> .\n"},
+		{nil, q, nil, `<http://example.org/a> <http://example.org/q> <http://example.org/b> .
+_:x <http://example.org/q> <http://example.org/a> .
+`},
+		{nil, nil, a, "_:x <http://example.org/q> <http://example.org/a> .\n"},
+		{nil, nil, Literal{str: "1", DataType: xsdString}, "<http://example.org/a> <http://example.org/p> \"1\" .\n"},
+		{c, nil, nil, ""},
+		{nil, nil, nil, `<http://example.org/a> <http://example.org/p> "1" .
+<http://example.org/a> <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/a> <http://example.org/p> <http://example.org/b> .
+<http://example.org/a> <http://example.org/p> <http://example.org/c> .
+<http://example.org/a> <http://example.org/q> <http://example.org/b> .
+<http://example.org/b> <http://example.org/p> <http://example.org/c> .
+_:x <http://example.org/q> <http://example.org/a> .
+`},
+	}
+	for i, test := range tests {
+		if got := sortedNTriples(g.Match(test.subj, test.pred, test.obj)); got != test.want {
+			t.Errorf("#%d: Match(%v, %v, %v) =>\n%s\nwant:\n%s", i, test.subj, test.pred, test.obj, got, test.want)
+		}
+	}
+
+	abc := Triple{Subj: a, Pred: p, Obj: c}
+	if g.Add(abc) {
+		t.Error("Add() of existing triple => true, want false")
+	}
+	if !g.Has(abc) {
+		t.Error("Has() => false, want true")
+	}
+	if !g.Remove(abc) || g.Remove(abc) {
+		t.Error("Remove() twice => want true, then false")
+	}
+	if g.Has(abc) || g.Len() != 6 {
+		t.Errorf("after Remove(): Has() => %v, Len() => %d; want false, 6", g.Has(abc), g.Len())
+	}
+	if got := len(g.Match(nil, nil, c)); got != 1 {
+		t.Errorf("after Remove(): len(Match(nil, nil, c)) => %d, want 1", got)
+	}
+	for _, tr := range g.Triples() {
+		g.Remove(tr)
+	}
+	if g.Len() != 0 || len(g.spo) != 0 || len(g.pos) != 0 || len(g.osp) != 0 {
+		t.Errorf("after removing all triples: Len() => %d, indexes not empty", g.Len())
+	}
+
+	var zero Graph
+	if zero.Has(abc) || zero.Len() != 0 || zero.Match(a, nil, nil) != nil || zero.Remove(abc) {
+		t.Error("zero Graph is not empty")
+	}
+	if !zero.Add(abc) || !zero.Has(abc) {
+		t.Error("zero Graph: Add() failed")
+	}
+}
@@ -0,0 +1,293 @@
+package rdf
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// Isomorphic returns true if the two sets of triples are isomorphic; that is,
+// if they are equal once the blank nodes of a are relabeled with a bijection
+// to the blank nodes of b. Duplicate triples are ignored.
+func Isomorphic(a, b []Triple) bool {
+	_, ok := IsomorphicMapping(a, b)
+	return ok
+}
+
+// IsomorphicMapping is like Isomorphic, but also returns the mapping from the
+// blank nodes of a to the blank nodes of b, when one is found.
+func IsomorphicMapping(a, b []Triple) (map[Blank]Blank, bool) {
+	return isomorphism(newIsoStatements(a, nil), newIsoStatements(b, nil))
+}
+
+// IsomorphicQuads returns true if the two sets of quads are isomorphic. Blank
+// node graph names are mapped as any other blank node, while quads with a
+// nil Ctx are in the default graph.
+func IsomorphicQuads(a, b []Quad) bool {
+	_, ok := IsomorphicQuadsMapping(a, b)
+	return ok
+}
+
+// IsomorphicQuadsMapping is like IsomorphicQuads, but also returns the mapping
+// from the blank nodes of a to the blank nodes of b, when one is found.
+func IsomorphicQuadsMapping(a, b []Quad) (map[Blank]Blank, bool) {
+	return isomorphism(newIsoStatements(nil, a), newIsoStatements(nil, b))
+}
+
+// Isomorphic returns true if the two datasets are isomorphic, with the
+// default graph of each dataset only matching the default graph of the other.
+func (d *Dataset) Isomorphic(other *Dataset) bool {
+	return IsomorphicQuads(d.defaultNilQuads(), other.defaultNilQuads())
+}
+
+// defaultNilQuads returns the quads of the dataset, with a nil Ctx for the
+// quads in the default graph.
+func (d *Dataset) defaultNilQuads() []Quad {
+	qs := d.Quads()
+	for i := range qs {
+		if d.isDefaultGraph(qs[i].Ctx) {
+			qs[i].Ctx = nil
+		}
+	}
+	return qs
+}
+
+// isoStatement is a triple or quad, with the terms as keys. The key of a
+// blank node is its label, and blank marks which positions hold one.
+type isoStatement struct {
+	terms [4]string
+	blank [4]bool
+}
+
+// isoStatements is a set of statements, with an index from the blank nodes
+// to the statements they occur in.
+type isoStatements struct {
+	stmts   []isoStatement
+	keys    map[string]bool  // set of statements, serialized
+	byBlank map[string][]int // blank node -> indexes of statements
+	blanks  map[string]Blank // blank node labels
+}
+
+// newIsoStatements returns the set of statements of either the triples or
+// the quads.
+func newIsoStatements(ts []Triple, qs []Quad) *isoStatements {
+	s := &isoStatements{
+		keys:    make(map[string]bool),
+		byBlank: make(map[string][]int),
+		blanks:  make(map[string]Blank),
+	}
+	add := func(terms ...Term) {
+		var st isoStatement
+		for i, t := range terms {
+			if t == nil {
+				continue
+			}
+			if b, ok := t.(Blank); ok {
+				st.terms[i], st.blank[i] = b.id, true
+				s.blanks[b.id] = b
+			} else {
+				st.terms[i] = termKey(t)
+			}
+		}
+		k := st.key(nil)
+		if s.keys[k] {
+			return
+		}
+		s.keys[k] = true
+	indexing:
+		for i := range st.terms {
+			if !st.blank[i] {
+				continue
+			}
+			for j := 0; j < i; j++ {
+				if st.blank[j] && st.terms[j] == st.terms[i] {
+					continue indexing
+				}
+			}
+			s.byBlank[st.terms[i]] = append(s.byBlank[st.terms[i]], len(s.stmts))
+		}
+		s.stmts = append(s.stmts, st)
+	}
+	for _, t := range ts {
+		add(t.Subj, t.Pred, t.Obj)
+	}
+	for _, q := range qs {
+		if q.Ctx == nil {
+			add(q.Subj, q.Pred, q.Obj)
+		} else {
+			add(q.Subj, q.Pred, q.Obj, q.Ctx)
+		}
+	}
+	return s
+}
+
+// key serializes the statement, with blank nodes relabeled by the mapping
+// when given.
+func (st isoStatement) key(mapping map[string]string) string {
+	var k string
+	for i, t := range st.terms {
+		if st.blank[i] && mapping != nil {
+			t = mapping[t]
+		}
+		k += strconv.Itoa(len(t)) + ":" + t
+	}
+	return k
+}
+
+// isoColors maps each blank node to the hash of its neighbourhood.
+type isoColors map[string]uint64
+
+// refine computes new colors for the blank nodes, by hashing the color of
+// each blank node with the statements it occurs in, where other blank nodes
+// are replaced by their color. It returns the number of distinct colors.
+func (s *isoStatements) refine(colors isoColors) (isoColors, int) {
+	next := make(isoColors, len(colors))
+	distinct := make(map[uint64]bool)
+	for n, idxs := range s.byBlank {
+		sigs := make([]string, 0, len(idxs))
+		for _, i := range idxs {
+			st := s.stmts[i]
+			var sig string
+			for j, t := range st.terms {
+				switch {
+				case !st.blank[j]:
+					sig += strconv.Itoa(len(t)) + ":" + t
+				case t == n:
+					sig += "@"
+				default:
+					sig += "#" + strconv.FormatUint(colors[t], 16)
+				}
+				sig += "|"
+			}
+			sigs = append(sigs, sig)
+		}
+		sort.Strings(sigs)
+		h := fnv.New64a()
+		h.Write([]byte(strconv.FormatUint(colors[n], 16)))
+		for _, sig := range sigs {
+			h.Write([]byte{0})
+			h.Write([]byte(sig))
+		}
+		next[n] = h.Sum64()
+		distinct[next[n]] = true
+	}
+	return next, len(distinct)
+}
+
+// classes groups the blank nodes by color, with sorted labels.
+func (c isoColors) classes() map[uint64][]string {
+	cls := make(map[uint64][]string)
+	for n, color := range c {
+		cls[color] = append(cls[color], n)
+	}
+	for _, ns := range cls {
+		sort.Strings(ns)
+	}
+	return cls
+}
+
+// refineBoth refines the colors of both statement sets in lockstep, until
+// the partitions are stable. It returns false if the partitions differ,
+// in which case the sets cannot be isomorphic under the given colors.
+func refineBoth(a, b *isoStatements, ca, cb isoColors) (isoColors, isoColors, bool) {
+	na, nb := -1, -1
+	for {
+		nextA, da := a.refine(ca)
+		nextB, db := b.refine(cb)
+		if da != db {
+			return nil, nil, false
+		}
+		if da == na && db == nb {
+			break
+		}
+		ca, cb, na, nb = nextA, nextB, da, db
+	}
+	clsA, clsB := ca.classes(), cb.classes()
+	if len(clsA) != len(clsB) {
+		return nil, nil, false
+	}
+	for color, ns := range clsA {
+		if len(clsB[color]) != len(ns) {
+			return nil, nil, false
+		}
+	}
+	return ca, cb, true
+}
+
+// isomorphism searches for a bijection between the blank nodes of a and b
+// which makes the statement sets equal.
+func isomorphism(a, b *isoStatements) (map[Blank]Blank, bool) {
+	if len(a.stmts) != len(b.stmts) || len(a.byBlank) != len(b.byBlank) {
+		return nil, false
+	}
+	ca, cb := make(isoColors), make(isoColors)
+	for n := range a.byBlank {
+		ca[n] = 0
+	}
+	for n := range b.byBlank {
+		cb[n] = 0
+	}
+	ca, cb, ok := refineBoth(a, b, ca, cb)
+	if !ok {
+		return nil, false
+	}
+	m, ok := isoSearch(a, b, ca, cb)
+	if !ok {
+		return nil, false
+	}
+	mapping := make(map[Blank]Blank, len(m))
+	for x, y := range m {
+		mapping[a.blanks[x]] = b.blanks[y]
+	}
+	return mapping, true
+}
+
+// isoSearch finds a bijection compatible with the colors, by distinguishing
+// a blank node of the smallest ambiguous color class and each of its
+// candidates in turn, and refining the colors again.
+func isoSearch(a, b *isoStatements, ca, cb isoColors) (map[string]string, bool) {
+	clsA, clsB := ca.classes(), cb.classes()
+	var pick uint64
+	size := 0
+	for color, ns := range clsA {
+		if len(ns) > 1 && (size == 0 || len(ns) < size || (len(ns) == size && color < pick)) {
+			pick, size = color, len(ns)
+		}
+	}
+	if size == 0 {
+		// All colors are unique; the bijection is given by them.
+		m := make(map[string]string, len(clsA))
+		for color, ns := range clsA {
+			m[ns[0]] = clsB[color][0]
+		}
+		for _, st := range a.stmts {
+			if !b.keys[st.key(m)] {
+				return nil, false
+			}
+		}
+		return m, true
+	}
+
+	x := clsA[pick][0]
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatUint(pick, 16) + "*"))
+	marked := h.Sum64()
+	for _, y := range clsB[pick] {
+		ca2, cb2 := make(isoColors, len(ca)), make(isoColors, len(cb))
+		for n, c := range ca {
+			ca2[n] = c
+		}
+		for n, c := range cb {
+			cb2[n] = c
+		}
+		ca2[x], cb2[y] = marked, marked
+		ca2, cb2, ok := refineBoth(a, b, ca2, cb2)
+		if !ok {
+			continue
+		}
+		if m, ok := isoSearch(a, b, ca2, cb2); ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
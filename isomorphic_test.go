@@ -0,0 +1,190 @@
+package rdf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsomorphic(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{
+			`<http://example.org/s> <http://example.org/p> "o" .`,
+			`<http://example.org/s> <http://example.org/p> "o" .`,
+			true,
+		},
+		{
+			`<http://example.org/s> <http://example.org/p> "o" .`,
+			`<http://example.org/s> <http://example.org/p> "o"@en .`,
+			false,
+		},
+		{
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/q> "x" .`,
+			`_:x <http://example.org/p> _:y .
+_:y <http://example.org/q> "x" .`,
+			true,
+		},
+		{
+			// The blank nodes are swapped.
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/q> "x" .`,
+			`_:y <http://example.org/p> _:x .
+_:y <http://example.org/q> "x" .`,
+			false,
+		},
+		{
+			// A cycle of 3 and a cycle of 2 + self-loop look alike locally.
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/p> _:c .
+_:c <http://example.org/p> _:a .`,
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/p> _:a .
+_:c <http://example.org/p> _:c .`,
+			false,
+		},
+		{
+			// Two cycles of 3 against one cycle of 6: refinement alone cannot
+			// tell them apart, but no bijection exists.
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/p> _:c .
+_:c <http://example.org/p> _:a .
+_:d <http://example.org/p> _:e .
+_:e <http://example.org/p> _:f .
+_:f <http://example.org/p> _:d .`,
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/p> _:c .
+_:c <http://example.org/p> _:d .
+_:d <http://example.org/p> _:e .
+_:e <http://example.org/p> _:f .
+_:f <http://example.org/p> _:a .`,
+			false,
+		},
+		{
+			// Symmetric graphs need backtracking.
+			`_:a <http://example.org/p> _:b .
+_:b <http://example.org/p> _:c .
+_:c <http://example.org/p> _:d .
+_:d <http://example.org/p> _:a .`,
+			`_:w <http://example.org/p> _:z .
+_:z <http://example.org/p> _:y .
+_:y <http://example.org/p> _:x .
+_:x <http://example.org/p> _:w .`,
+			true,
+		},
+		{
+			`_:a <http://example.org/p> _:a .`,
+			`_:a <http://example.org/p> _:b .`,
+			false,
+		},
+		{
+			`_:a <http://example.org/p> "1" .
+_:a <http://example.org/p> "1" .`,
+			`_:b <http://example.org/p> "1" .`,
+			true,
+		},
+		{
+			`_:a <http://example.org/p> "1" .
+_:b <http://example.org/p> "1" .`,
+			`_:b <http://example.org/p> "1" .`,
+			false,
+		},
+	}
+	for i, test := range tests {
+		a, err := NewTripleDecoder(bytes.NewBufferString(test.a), NTriples).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewTripleDecoder(bytes.NewBufferString(test.b), NTriples).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, got := IsomorphicMapping(a, b)
+		if got != test.want {
+			t.Errorf("#%d: Isomorphic() => %v, want %v", i, got, test.want)
+			continue
+		}
+		if !got {
+			continue
+		}
+		// The mapping must relabel a into b.
+		relabel := func(t Term) Term {
+			if bn, ok := t.(Blank); ok {
+				return m[bn]
+			}
+			return t
+		}
+		g := NewGraph(b...)
+		for _, tr := range a {
+			tr = Triple{Subj: relabel(tr.Subj).(Subject), Pred: tr.Pred, Obj: relabel(tr.Obj).(Object)}
+			if !g.Has(tr) {
+				t.Errorf("#%d: mapping %v does not relabel %v into b", i, m, tr)
+			}
+		}
+	}
+}
+
+func TestIsomorphicTurtle(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:s ex:p [ ex:q ( 1 2 [ ex:r "x" ] ) ] ; ex:p [ ex:q ( 1 2 ) ] .
+`
+	a, err := NewTripleDecoder(bytes.NewBufferString(input), Turtle).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := NewTripleEncoder(&buf, NTriples)
+	if err := enc.EncodeAll(a); err != nil {
+		t.Fatal(err)
+	}
+	enc.Close()
+	b, err := NewTripleDecoder(bytes.NewReader(buf.Bytes()), NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewTripleDecoder(bytes.NewBufferString(input), Turtle).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Isomorphic(a, b) || !Isomorphic(c, a) {
+		t.Error("Isomorphic() => false for reparsed Turtle, want true")
+	}
+	if Isomorphic(a, b[1:]) {
+		t.Error("Isomorphic() => true for graph with a missing triple, want false")
+	}
+}
+
+func TestIsomorphicQuads(t *testing.T) {
+	a := `_:g { _:a <http://example.org/p> "x" . }
+<http://example.org/g> { _:a <http://example.org/p> "y" . }
+_:a <http://example.org/p> "z" .
+`
+	b := `<http://example.org/g> { _:b <http://example.org/p> "y" . }
+_:h { _:b <http://example.org/p> "x" . }
+_:b <http://example.org/p> "z" .
+`
+	c := `_:h { _:b <http://example.org/p> "x" . }
+_:b <http://example.org/p> "y" .
+<http://example.org/g> { _:b <http://example.org/p> "z" . }
+`
+	load := func(s string) *Dataset {
+		d := NewDataset()
+		if err := d.Load(NewQuadDecoder(bytes.NewBufferString(s), TriG)); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	da, db, dc := load(a), load(b), load(c)
+	if !da.Isomorphic(db) {
+		t.Error("Dataset.Isomorphic() => false, want true")
+	}
+	if da.Isomorphic(dc) {
+		t.Error("Dataset.Isomorphic() with triples in other graphs => true, want false")
+	}
+	m, ok := IsomorphicQuadsMapping(da.defaultNilQuads(), db.defaultNilQuads())
+	if !ok || len(m) != 2 || m[Blank{id: "_:g"}] != (Blank{id: "_:h"}) {
+		t.Errorf("IsomorphicQuadsMapping() => %v, %v; want _:g mapped to _:h", m, ok)
+	}
+}
@@ -0,0 +1,956 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	rdfJSON = IRI{str: rdfNS + "JSON"}
+
+	rgxpLangTag = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+)
+
+// DocumentLoader loads remote documents, such as the JSON-LD contexts
+// referenced by IRI in a JSON-LD document.
+type DocumentLoader interface {
+	// LoadDocument retrieves the document with the given URL, and
+	// returns it parsed as JSON.
+	LoadDocument(url string) (interface{}, error)
+}
+
+// DocumentLoaderFunc is an adapter to allow the use of ordinary functions
+// as DocumentLoaders.
+type DocumentLoaderFunc func(url string) (interface{}, error)
+
+// LoadDocument calls f(url).
+func (f DocumentLoaderFunc) LoadDocument(url string) (interface{}, error) {
+	return f(url)
+}
+
+// HTTPDocumentLoader loads documents over HTTP. It is the default
+// DocumentLoader of the JSON-LD decoder.
+type HTTPDocumentLoader struct {
+	// Client is the HTTP client used to perform requests. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// LoadDocument fetches the document with the given URL, and parses it as JSON.
+func (l *HTTPDocumentLoader) LoadDocument(url string) (interface{}, error) {
+	c := l.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/ld+json, application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	return decodeJSON(resp.Body)
+}
+
+// decodeJSON parses a JSON document, keeping numbers as json.Number.
+func decodeJSON(r io.Reader) (interface{}, error) {
+	var doc interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// jsonldDecoder decodes JSON-LD documents into RDF. The whole document
+// must be read before the expansion algorithm can run, so the resulting
+// quads are buffered and emitted one at a time.
+type jsonldDecoder struct {
+	r      io.Reader
+	base   string
+	loader DocumentLoader
+
+	parsed bool
+	quads  []Quad // quads not yet emitted; the default graph has a nil Ctx
+}
+
+func newJSONLDDecoder(r io.Reader) *jsonldDecoder {
+	return &jsonldDecoder{r: r}
+}
+
+// SetOption sets a ParseOption to the give value
+func (d *jsonldDecoder) SetOption(o ParseOption, v interface{}) error {
+	switch o {
+	case Base:
+		iri, ok := v.(IRI)
+		if !ok {
+			return fmt.Errorf("ParseOption \"Base\" must be an IRI.")
+		}
+		d.base = iri.str
+	case Loader:
+		l, ok := v.(DocumentLoader)
+		if !ok {
+			return fmt.Errorf("ParseOption \"Loader\" must be a DocumentLoader.")
+		}
+		d.loader = l
+	default:
+		return fmt.Errorf("JSON-LD decoder doesn't support option: %v", o)
+	}
+	return nil
+}
+
+// Decode parses a JSON-LD document, and returns the next triple in the
+// default graph, or an error. Triples in named graphs are ignored; use a
+// QuadDecoder to decode those.
+func (d *jsonldDecoder) Decode() (Triple, error) {
+	for {
+		q, err := d.decodeQuad()
+		if err != nil {
+			return Triple{}, err
+		}
+		if q.Ctx == nil {
+			return q.Triple, nil
+		}
+	}
+}
+
+// DecodeAll parses the whole JSON-LD document, and returns all triples
+// in the default graph, or an error.
+func (d *jsonldDecoder) DecodeAll() ([]Triple, error) {
+	var ts []Triple
+	for t, err := d.Decode(); err != io.EOF; t, err = d.Decode() {
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	return ts, nil
+}
+
+// decodeQuad returns the next quad of the document, or an error.
+func (d *jsonldDecoder) decodeQuad() (Quad, error) {
+	if !d.parsed {
+		d.parsed = true
+		doc, err := decodeJSON(d.r)
+		if err != nil {
+			return Quad{}, err
+		}
+		d.quads, err = jsonldToRDF(doc, d.base, d.loader)
+		if err != nil {
+			return Quad{}, err
+		}
+	}
+	if len(d.quads) == 0 {
+		return Quad{}, io.EOF
+	}
+	q := d.quads[0]
+	d.quads = d.quads[1:]
+	return q, nil
+}
+
+// parseJSONLD returns the next Quad from a JSON-LD document, or an error.
+func (d *QuadDecoder) parseJSONLD() (Quad, error) {
+	q, err := d.jsonld.decodeQuad()
+	if err != nil {
+		return q, err
+	}
+	if q.Ctx == nil {
+		q.Ctx = d.DefaultGraph
+	}
+	return q, nil
+}
+
+// jsonldToRDF expands the JSON-LD document and converts it to RDF quads.
+// Quads in the default graph have a nil context.
+func jsonldToRDF(doc interface{}, base string, loader DocumentLoader) (qs []Quad, err error) {
+	defer recoverJSONLD(&err)
+	p := newJSONLDProcessor(loader)
+	return p.toRDF(p.expandDocument(doc, base)), nil
+}
+
+// recoverJSONLD catches JSON-LD processing errors and binds them to the
+// given error pointer.
+func recoverJSONLD(errp *error) {
+	if e := recover(); e != nil {
+		jerr, ok := e.(jsonldError)
+		if !ok {
+			panic(e)
+		}
+		*errp = jerr
+	}
+}
+
+// expandDocument runs the Expansion algorithm on a JSON-LD document,
+// and returns the expanded document as an array.
+func (p *jsonldProcessor) expandDocument(doc interface{}, base string) []interface{} {
+	expanded := p.expand(newJSONLDContext(base), nil, doc, base, false, false)
+	if m, ok := expanded.(map[string]interface{}); ok && len(m) == 1 {
+		if g, ok := m["@graph"]; ok {
+			expanded = g
+		}
+	}
+	if expanded == nil {
+		return []interface{}{}
+	}
+	return asArray(expanded)
+}
+
+// nodeMap holds the node objects of each graph, keyed by graph name and
+// subject. The default graph is named "@default".
+type nodeMap map[string]map[string]map[string]interface{}
+
+// generateNodeMap implements the Node Map Generation algorithm. The active
+// subject is either a subject identifier, or a node reference when processing
+// reverse properties. An empty active property means null.
+func (p *jsonldProcessor) generateNodeMap(element interface{}, nm nodeMap, activeGraph string, activeSubject interface{}, activeProperty string, list map[string]interface{}) {
+	if a, ok := element.([]interface{}); ok {
+		for _, item := range a {
+			p.generateNodeMap(item, nm, activeGraph, activeSubject, activeProperty, list)
+		}
+		return
+	}
+	elem, ok := element.(map[string]interface{})
+	if !ok {
+		return
+	}
+	graph, ok := nm[activeGraph]
+	if !ok {
+		graph = make(map[string]map[string]interface{})
+		nm[activeGraph] = graph
+	}
+	var subjectNode map[string]interface{}
+	if s, ok := activeSubject.(string); ok {
+		subjectNode = graph[s]
+	}
+
+	if types, ok := elem["@type"]; ok {
+		var relabeled []interface{}
+		for _, t := range asArray(types) {
+			if s, ok := t.(string); ok && strings.HasPrefix(s, "_:") {
+				t = p.bnodes.issue(s)
+			}
+			relabeled = append(relabeled, t)
+		}
+		if _, ok := types.([]interface{}); ok {
+			elem["@type"] = relabeled
+		} else {
+			elem["@type"] = relabeled[0]
+		}
+	}
+
+	switch {
+	case hasKey(elem, "@value"):
+		if list == nil {
+			if subjectNode != nil {
+				addUnique(subjectNode, activeProperty, elem)
+			}
+		} else {
+			list["@list"] = append(list["@list"].([]interface{}), elem)
+		}
+	case hasKey(elem, "@list"):
+		result := map[string]interface{}{"@list": []interface{}{}}
+		p.generateNodeMap(elem["@list"], nm, activeGraph, activeSubject, activeProperty, result)
+		if list == nil {
+			if subjectNode != nil {
+				subjectNode[activeProperty] = append(asArray(subjectNode[activeProperty]), result)
+			}
+		} else {
+			list["@list"] = append(list["@list"].([]interface{}), result)
+		}
+	default:
+		var id string
+		if s, ok := elem["@id"].(string); ok {
+			id = s
+			if strings.HasPrefix(id, "_:") {
+				id = p.bnodes.issue(id)
+			}
+		} else {
+			id = p.bnodes.issue("")
+		}
+		node, ok := graph[id]
+		if !ok {
+			node = map[string]interface{}{"@id": id}
+			graph[id] = node
+		}
+		if ref, ok := activeSubject.(map[string]interface{}); ok {
+			addUnique(node, activeProperty, ref)
+		} else if activeProperty != "" {
+			ref := map[string]interface{}{"@id": id}
+			if list == nil {
+				if subjectNode != nil {
+					addUnique(subjectNode, activeProperty, ref)
+				}
+			} else {
+				list["@list"] = append(list["@list"].([]interface{}), ref)
+			}
+		}
+		if types, ok := elem["@type"]; ok {
+			for _, t := range asArray(types) {
+				addUnique(node, "@type", t)
+			}
+		}
+		if idx, ok := elem["@index"]; ok {
+			if prev, ok := node["@index"]; ok && !jsonEqual(prev, idx) {
+				jsonldErrorf("conflicting indexes", "%v", id)
+			}
+			node["@index"] = idx
+		}
+		if rev, ok := elem["@reverse"].(map[string]interface{}); ok {
+			ref := map[string]interface{}{"@id": id}
+			for _, prop := range sortedKeys(rev) {
+				for _, v := range asArray(rev[prop]) {
+					p.generateNodeMap(v, nm, activeGraph, ref, prop, nil)
+				}
+			}
+		}
+		if g, ok := elem["@graph"]; ok {
+			p.generateNodeMap(g, nm, id, nil, "", nil)
+		}
+		if inc, ok := elem["@included"]; ok {
+			p.generateNodeMap(inc, nm, activeGraph, nil, "", nil)
+		}
+		for _, prop := range sortedKeys(elem) {
+			switch prop {
+			case "@id", "@type", "@index", "@reverse", "@graph", "@included":
+				continue
+			}
+			value := elem[prop]
+			if strings.HasPrefix(prop, "_:") {
+				prop = p.bnodes.issue(prop)
+			}
+			if _, ok := node[prop]; !ok {
+				node[prop] = []interface{}{}
+			}
+			p.generateNodeMap(value, nm, activeGraph, id, prop, nil)
+		}
+	}
+}
+
+// toRDF implements the Deserialize JSON-LD to RDF algorithm, converting
+// an expanded JSON-LD document to quads.
+func (p *jsonldProcessor) toRDF(expanded []interface{}) []Quad {
+	nm := nodeMap{"@default": make(map[string]map[string]interface{})}
+	p.generateNodeMap(expanded, nm, "@default", nil, "", nil)
+
+	graphs := make([]string, 0, len(nm))
+	for g := range nm {
+		graphs = append(graphs, g)
+	}
+	sort.Strings(graphs)
+
+	var quads []Quad
+	for _, g := range graphs {
+		var ctx Context
+		if g != "@default" {
+			t, ok := jsonldResource(g)
+			if !ok {
+				continue
+			}
+			ctx = t.(Context)
+		}
+		var triples []Triple
+		subjects := make([]string, 0, len(nm[g]))
+		for s := range nm[g] {
+			subjects = append(subjects, s)
+		}
+		sort.Strings(subjects)
+		for _, s := range subjects {
+			subj, ok := jsonldResource(s)
+			if !ok {
+				continue
+			}
+			node := nm[g][s]
+			for _, prop := range sortedKeys(node) {
+				if prop == "@type" {
+					for _, t := range asArray(node[prop]) {
+						if ts, ok := t.(string); ok {
+							if obj, ok := jsonldResource(ts); ok {
+								triples = append(triples, Triple{Subj: subj.(Subject), Pred: rdfType, Obj: obj.(Object)})
+							}
+						}
+					}
+					continue
+				}
+				if jsonldKeywords[prop] || strings.HasPrefix(prop, "_:") {
+					continue
+				}
+				pred, ok := jsonldResource(prop)
+				if !ok {
+					continue
+				}
+				for _, item := range asArray(node[prop]) {
+					obj, listTriples := p.objectToRDF(item)
+					if obj != nil {
+						triples = append(triples, Triple{Subj: subj.(Subject), Pred: pred.(Predicate), Obj: obj})
+					}
+					triples = append(triples, listTriples...)
+				}
+			}
+		}
+		for _, t := range triples {
+			quads = append(quads, Quad{Triple: t, Ctx: ctx})
+		}
+	}
+	return quads
+}
+
+// objectToRDF implements the Object to RDF Conversion algorithm. It returns
+// nil if the item cannot be converted. Any triples needed to represent a list
+// are also returned.
+func (p *jsonldProcessor) objectToRDF(item interface{}) (Object, []Triple) {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if isListObject(m) {
+		return p.listToRDF(asArray(m["@list"]))
+	}
+	if !isValueObject(m) {
+		id, _ := m["@id"].(string)
+		t, ok := jsonldResource(id)
+		if !ok {
+			return nil, nil
+		}
+		return t.(Object), nil
+	}
+
+	value := m["@value"]
+	dt, _ := m["@type"].(string)
+	if dt != "" && dt != "@json" {
+		if _, ok := jsonldResource(dt); !ok || strings.HasPrefix(dt, "_:") {
+			return nil, nil
+		}
+	}
+	lang, hasLang := m["@language"].(string)
+	if hasLang && !rgxpLangTag.MatchString(lang) {
+		return nil, nil
+	}
+
+	var str string
+	datatype := IRI{str: dt}
+	if dt == "@json" {
+		str = canonicalJSON(value)
+		datatype = rdfJSON
+	} else if b, ok := value.(bool); ok {
+		str = strconv.FormatBool(b)
+		if dt == "" {
+			datatype = xsdBoolean
+		}
+	} else if f, ok := jsonNumber(value); ok {
+		if f != math.Trunc(f) || math.Abs(f) >= 1e21 || dt == xsdDouble.str {
+			str = canonicalDouble(f)
+			if dt == "" {
+				datatype = xsdDouble
+			}
+		} else {
+			str = canonicalInteger(value, f)
+			if dt == "" {
+				datatype = xsdInteger
+			}
+		}
+	} else {
+		str, _ = value.(string)
+		if hasLang {
+			return Literal{str: str, lang: lang, DataType: rdfLangString}, nil
+		}
+		if dt == "" {
+			datatype = xsdString
+		}
+	}
+	return Literal{str: str, DataType: datatype}, nil
+}
+
+// listToRDF implements the List to RDF Conversion algorithm, and returns
+// the head of the list along with the triples making up the list.
+func (p *jsonldProcessor) listToRDF(list []interface{}) (Object, []Triple) {
+	if len(list) == 0 {
+		return rdfNil, nil
+	}
+	bnodes := make([]Blank, len(list))
+	for i := range list {
+		bnodes[i] = Blank{id: p.bnodes.issue("")}
+	}
+	var triples []Triple
+	for i, item := range list {
+		obj, embedded := p.objectToRDF(item)
+		if obj != nil {
+			triples = append(triples, Triple{Subj: bnodes[i], Pred: rdfFirst, Obj: obj})
+		}
+		var rest Object = rdfNil
+		if i < len(list)-1 {
+			rest = bnodes[i+1]
+		}
+		triples = append(triples, Triple{Subj: bnodes[i], Pred: rdfRest, Obj: rest})
+		triples = append(triples, embedded...)
+	}
+	return bnodes[0], triples
+}
+
+// jsonldResource converts a node identifier to an IRI or Blank node. It
+// returns false if the identifier is not a well-formed absolute IRI or
+// blank node identifier.
+func jsonldResource(id string) (Term, bool) {
+	if strings.HasPrefix(id, "_:") {
+		return Blank{id: id}, true
+	}
+	if !isAbsoluteIRI(id) {
+		return nil, false
+	}
+	iri, err := NewIRI(id)
+	if err != nil {
+		return nil, false
+	}
+	return iri, true
+}
+
+// hasKey returns true if the map contains the key.
+func hasKey(m map[string]interface{}, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+// addUnique adds value to the array entry key of obj, unless an equal
+// value is already present.
+func addUnique(obj map[string]interface{}, key string, value interface{}) {
+	vals := asArray(obj[key])
+	for _, v := range vals {
+		if jsonEqual(v, value) {
+			if _, ok := obj[key]; !ok {
+				obj[key] = []interface{}{}
+			}
+			return
+		}
+	}
+	obj[key] = append(vals, value)
+}
+
+// canonicalInteger returns the canonical lexical form of an integral
+// JSON number. Integers are kept as written when possible, to avoid
+// losing precision on large numbers.
+func canonicalInteger(v interface{}, f float64) string {
+	if n, ok := v.(json.Number); ok {
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+	}
+	return strconv.FormatFloat(f, 'f', 0, 64)
+}
+
+// canonicalJSON serializes a JSON value according to the JSON
+// Canonicalization Scheme (RFC 8785).
+func canonicalJSON(v interface{}) string {
+	var b strings.Builder
+	writeCanonicalJSON(&b, v)
+	return b.String()
+}
+
+func writeCanonicalJSON(b *strings.Builder, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(x))
+	case string:
+		writeJSONString(b, x)
+	case []interface{}:
+		b.WriteByte('[')
+		for i, item := range x {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonicalJSON(b, item)
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := sortedKeys(x)
+		// JCS orders keys by their UTF-16 code units.
+		sort.Slice(keys, func(i, j int) bool { return utf16Less(keys[i], keys[j]) })
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeJSONString(b, k)
+			b.WriteByte(':')
+			writeCanonicalJSON(b, x[k])
+		}
+		b.WriteByte('}')
+	default:
+		f, _ := jsonNumber(v)
+		b.WriteString(es6Number(f))
+	}
+}
+
+// writeJSONString writes s as a JSON string, escaping only what is required.
+func writeJSONString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// utf16Less compares two strings by their UTF-16 code units.
+func utf16Less(a, b string) bool {
+	for a != "" && b != "" {
+		ra, na := utf8.DecodeRuneInString(a)
+		rb, nb := utf8.DecodeRuneInString(b)
+		if ra != rb {
+			return utf16Unit(ra) < utf16Unit(rb)
+		}
+		a, b = a[na:], b[nb:]
+	}
+	return a == "" && b != ""
+}
+
+// utf16Unit returns the first UTF-16 code unit of r.
+func utf16Unit(r rune) rune {
+	if r >= 0x10000 {
+		return 0xD800 + ((r - 0x10000) >> 10)
+	}
+	return r
+}
+
+// es6Number formats a number as ECMAScript's Number.prototype.toString().
+func es6Number(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	if a := math.Abs(f); a < 1e21 && a >= 1e-6 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(s, 'e')
+	mantissa, exp := s[:i], s[i+1:]
+	sign := exp[0]
+	exp = strings.TrimLeft(exp[1:], "0")
+	return mantissa + "e" + string(sign) + exp
+}
+
+// JSONLDOptions configures the serialization of JSON-LD documents.
+type JSONLDOptions struct {
+	// Context is the JSON-LD context to compact the document against. It can
+	// be a context object, the IRI of a remote context, or an array of those,
+	// in any Go value which marshals to JSON. When nil, the document is
+	// written in expanded form.
+	Context interface{}
+
+	// Loader retrieves remote contexts. Defaults to an HTTPDocumentLoader.
+	Loader DocumentLoader
+
+	// Base is the base IRI which IRIs are made relative to when compacting.
+	Base IRI
+
+	// UseNativeTypes writes xsd:boolean, xsd:integer and xsd:double literals
+	// as native JSON booleans and numbers.
+	UseNativeTypes bool
+
+	// UseRDFType writes rdf:type statements as regular properties, instead
+	// of using @type.
+	UseRDFType bool
+}
+
+// encodeJSONLD serializes the quads as a JSON-LD document. Quads with a nil
+// context are in the default graph.
+func encodeJSONLD(w io.Writer, qs []Quad, opts JSONLDOptions) (err error) {
+	defer recoverJSONLD(&err)
+	p := newJSONLDProcessor(opts.Loader)
+	var doc interface{} = fromRDF(qs, opts.UseNativeTypes, opts.UseRDFType)
+	if opts.Context != nil {
+		ctx, nerr := normalizeJSON(opts.Context)
+		if nerr != nil {
+			return nerr
+		}
+		doc = p.compactDocument(doc.([]interface{}), ctx, opts.Base.str)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	return enc.Encode(doc)
+}
+
+// normalizeJSON converts any Go value to its generic JSON representation,
+// as decoded by decodeJSON.
+func normalizeJSON(v interface{}) (interface{}, error) {
+	if isGenericJSON(v) {
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(bytes.NewReader(b))
+}
+
+// isGenericJSON returns true if v only consists of the types produced by decodeJSON.
+func isGenericJSON(v interface{}) bool {
+	switch x := v.(type) {
+	case nil, string, bool, json.Number:
+		return true
+	case map[string]interface{}:
+		for _, e := range x {
+			if !isGenericJSON(e) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, e := range x {
+			if !isGenericJSON(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// fromRDF implements the Serialize RDF as JSON-LD algorithm, converting
+// quads to an expanded JSON-LD document. Quads with a nil context are in
+// the default graph.
+func fromRDF(qs []Quad, useNativeTypes, useRDFType bool) []interface{} {
+	defaultGraph := make(map[string]map[string]interface{})
+	graphMap := map[string]map[string]map[string]interface{}{"@default": defaultGraph}
+	referencedOnce := make(map[string]*nodeUsage) // nil when referenced more than once
+	nilUsages := make(map[string][]nodeUsage)
+
+	for _, q := range qs {
+		name := "@default"
+		if q.Ctx != nil {
+			name = termID(q.Ctx)
+		}
+		g, ok := graphMap[name]
+		if !ok {
+			g = make(map[string]map[string]interface{})
+			graphMap[name] = g
+		}
+		if _, ok := defaultGraph[name]; !ok && name != "@default" {
+			defaultGraph[name] = map[string]interface{}{"@id": name}
+		}
+		s := termID(q.Subj)
+		node, ok := g[s]
+		if !ok {
+			node = map[string]interface{}{"@id": s}
+			g[s] = node
+		}
+		o := ""
+		if q.Obj.Type() != TermLiteral {
+			o = termID(q.Obj)
+			if _, ok := g[o]; !ok {
+				g[o] = map[string]interface{}{"@id": o}
+			}
+		}
+		pred := termID(q.Pred)
+		if pred == rdfType.str && !useRDFType && o != "" {
+			addUnique(node, "@type", o)
+			continue
+		}
+		value := rdfToObject(q.Obj, useNativeTypes)
+		vals := asArray(node[pred])
+		found := false
+		for _, v := range vals {
+			if jsonEqual(v, value) {
+				value = v.(map[string]interface{})
+				found = true
+				break
+			}
+		}
+		if !found {
+			node[pred] = append(vals, value)
+		}
+		if o == rdfNil.str {
+			nilUsages[name] = append(nilUsages[name], nodeUsage{node, pred, value})
+		} else if _, ok := referencedOnce[o]; ok {
+			referencedOnce[o] = nil
+		} else if strings.HasPrefix(o, "_:") {
+			referencedOnce[o] = &nodeUsage{node, pred, value}
+		}
+	}
+
+	for name, g := range graphMap {
+		for _, u := range nilUsages[name] {
+			node, property, head := u.node, u.property, u.value
+			list := []interface{}{}
+			var listNodes []string
+			for property == rdfRest.str && isListNode(node, referencedOnce) {
+				list = append(list, node[rdfFirst.str].([]interface{})[0])
+				id := node["@id"].(string)
+				listNodes = append(listNodes, id)
+				nu := referencedOnce[id]
+				node, property, head = nu.node, nu.property, nu.value
+				if !strings.HasPrefix(node["@id"].(string), "_:") {
+					break
+				}
+			}
+			delete(head, "@id")
+			for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+				list[i], list[j] = list[j], list[i]
+			}
+			head["@list"] = list
+			for _, id := range listNodes {
+				delete(g, id)
+			}
+		}
+	}
+
+	result := []interface{}{}
+	for _, s := range sortedNodeKeys(defaultGraph) {
+		node := defaultGraph[s]
+		if g, ok := graphMap[s]; ok {
+			nodes := []interface{}{}
+			for _, gs := range sortedNodeKeys(g) {
+				if n := g[gs]; len(n) > 1 {
+					nodes = append(nodes, n)
+				}
+			}
+			node["@graph"] = nodes
+		}
+		if len(node) > 1 {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// nodeUsage records where a node is referenced: the node referencing it, the
+// property, and the node reference value.
+type nodeUsage struct {
+	node     map[string]interface{}
+	property string
+	value    map[string]interface{}
+}
+
+// isListNode returns true if the node is a well-formed RDF list node, which
+// can be folded into a JSON-LD list.
+func isListNode(node map[string]interface{}, referencedOnce map[string]*nodeUsage) bool {
+	id, _ := node["@id"].(string)
+	if !strings.HasPrefix(id, "_:") || referencedOnce[id] == nil {
+		return false
+	}
+	for k, v := range node {
+		switch k {
+		case "@id":
+		case rdfFirst.str, rdfRest.str:
+			if len(asArray(v)) != 1 {
+				return false
+			}
+		case "@type":
+			types := asArray(v)
+			if len(types) != 1 || types[0] != rdfNS+"List" {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return hasKey(node, rdfFirst.str) && hasKey(node, rdfRest.str)
+}
+
+// sortedNodeKeys returns the keys of the node map, sorted lexicographically.
+func sortedNodeKeys(m map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// termID returns the node identifier of an IRI or blank node.
+func termID(t Term) string {
+	switch x := t.(type) {
+	case IRI:
+		return x.str
+	case Blank:
+		return x.id
+	}
+	return t.String()
+}
+
+// rdfToObject implements the RDF to Object Conversion algorithm.
+func rdfToObject(o Object, useNativeTypes bool) map[string]interface{} {
+	l, ok := o.(Literal)
+	if !ok {
+		return map[string]interface{}{"@id": termID(o)}
+	}
+	result := make(map[string]interface{})
+	var value interface{} = l.str
+	dt := l.DataType.str
+	typ := ""
+	switch {
+	case l.lang != "":
+		result["@language"] = l.lang
+	case dt == "" || dt == xsdString.str:
+	case dt == rdfJSON.str:
+		v, err := decodeJSON(strings.NewReader(l.str))
+		if err != nil {
+			jsonldErrorf("invalid JSON literal", "%s", l.str)
+		}
+		value, typ = v, "@json"
+	case useNativeTypes && dt == xsdBoolean.str:
+		switch l.str {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		default:
+			typ = dt
+		}
+	case useNativeTypes && dt == xsdInteger.str:
+		if i, err := strconv.ParseInt(l.str, 10, 64); err == nil {
+			value = json.Number(strconv.FormatInt(i, 10))
+		} else {
+			typ = dt
+		}
+	case useNativeTypes && dt == xsdDouble.str:
+		if f, err := strconv.ParseFloat(l.str, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			value = json.Number(es6Number(f))
+		} else {
+			typ = dt
+		}
+	default:
+		typ = dt
+	}
+	result["@value"] = value
+	if typ != "" {
+		result["@type"] = typ
+	}
+	return result
+}
@@ -0,0 +1,811 @@
+package rdf
+
+import (
+	"sort"
+	"strings"
+)
+
+// This file implements the JSON-LD 1.1 Compaction algorithms, as described in
+// https://www.w3.org/TR/json-ld11-api/#compaction-algorithms.
+
+// compactDocument compacts an expanded JSON-LD document against the given
+// context, and returns the compacted document with the context included.
+func (p *jsonldProcessor) compactDocument(expanded []interface{}, ctx interface{}, base string) map[string]interface{} {
+	if m, ok := ctx.(map[string]interface{}); ok {
+		if c, ok := m["@context"]; ok {
+			ctx = c
+		}
+	}
+	active := p.processContext(newJSONLDContext(base), ctx, base, nil, false, true, true)
+
+	var result map[string]interface{}
+	switch c := p.compact(active, nil, expanded).(type) {
+	case map[string]interface{}:
+		result = c
+	case []interface{}:
+		result = make(map[string]interface{})
+		if len(c) > 0 {
+			result[p.compactIRI(active, "@graph", nil, true, false)] = c
+		}
+	default:
+		result = make(map[string]interface{})
+	}
+	if !isEmptyContext(ctx) {
+		result["@context"] = ctx
+	}
+	return result
+}
+
+// isEmptyContext returns true if the context has no definitions.
+func isEmptyContext(ctx interface{}) bool {
+	switch c := ctx.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(c) == 0
+	case []interface{}:
+		return len(c) == 0
+	}
+	return false
+}
+
+// compact implements the Compaction algorithm. The active property is nil
+// when not in the scope of a property.
+func (p *jsonldProcessor) compact(active *jsonldContext, activeProperty *string, element interface{}) interface{} {
+	typeScoped := active
+	var propDef *termDef
+	if activeProperty != nil {
+		propDef = typeScoped.terms[*activeProperty]
+	}
+
+	switch e := element.(type) {
+	case []interface{}:
+		result := make([]interface{}, 0, len(e))
+		for _, item := range e {
+			if c := p.compact(active, activeProperty, item); c != nil {
+				result = append(result, c)
+			}
+		}
+		if len(result) != 1 || (activeProperty != nil && (*activeProperty == "@graph" || *activeProperty == "@set")) ||
+			propDef.hasContainer("@list") || propDef.hasContainer("@set") {
+			return result
+		}
+		return result[0]
+	case map[string]interface{}:
+		return p.compactObject(active, typeScoped, activeProperty, propDef, e)
+	default:
+		return element
+	}
+}
+
+// compactObject compacts a JSON object (a map), as part of the Compaction algorithm.
+func (p *jsonldProcessor) compactObject(active, typeScoped *jsonldContext, activeProperty *string, propDef *termDef, element map[string]interface{}) interface{} {
+	if active.previous != nil {
+		_, hasValue := element["@value"]
+		_, hasID := element["@id"]
+		if !hasValue && !(hasID && len(element) == 1) {
+			active = active.previous
+		}
+	}
+	if propDef != nil && propDef.hasContext {
+		active = p.processContext(active, propDef.context, propDef.baseURL, nil, true, true, true)
+	}
+
+	if isValueObject(element) || isNodeReference(element) {
+		prop := ""
+		if activeProperty != nil {
+			prop = *activeProperty
+		}
+		r := p.compactValue(active, prop, element)
+		if _, ok := r.(map[string]interface{}); !ok {
+			return r
+		}
+		if td := active.terms[prop]; td != nil && td.typ == "@json" {
+			return r
+		}
+	}
+
+	if isListObject(element) && active.terms[strOrEmpty(activeProperty)].hasContainer("@list") {
+		return p.compact(active, activeProperty, element["@list"])
+	}
+
+	insideReverse := activeProperty != nil && *activeProperty == "@reverse"
+	result := make(map[string]interface{})
+
+	if types, ok := element["@type"]; ok {
+		var compacted []string
+		for _, t := range asArray(types) {
+			if s, ok := t.(string); ok {
+				compacted = append(compacted, p.compactIRI(typeScoped, s, nil, true, false))
+			}
+		}
+		sort.Strings(compacted)
+		for _, term := range compacted {
+			if td, ok := typeScoped.terms[term]; ok && td.hasContext {
+				active = p.processContext(active, td.context, td.baseURL, nil, false, false, true)
+			}
+		}
+	}
+
+	for _, expProp := range sortedKeys(element) {
+		expValue := element[expProp]
+		switch expProp {
+		case "@id":
+			if s, ok := expValue.(string); ok {
+				result[p.compactIRI(active, "@id", nil, true, false)] = p.compactIRI(active, s, nil, false, false)
+			}
+			continue
+		case "@type":
+			var compacted interface{}
+			if s, ok := expValue.(string); ok {
+				compacted = p.compactIRI(typeScoped, s, nil, true, false)
+			} else {
+				var ts []interface{}
+				for _, t := range asArray(expValue) {
+					if s, ok := t.(string); ok {
+						ts = append(ts, p.compactIRI(typeScoped, s, nil, true, false))
+					}
+				}
+				compacted = ts
+			}
+			alias := p.compactIRI(active, "@type", nil, true, false)
+			asArr := active.terms[alias].hasContainer("@set")
+			if ts, ok := compacted.([]interface{}); ok && len(ts) == 1 && !asArr {
+				compacted = ts[0]
+			}
+			addValue(result, alias, compacted, asArr)
+			continue
+		case "@reverse":
+			r := "@reverse"
+			compacted, _ := p.compact(active, &r, expValue).(map[string]interface{})
+			for _, prop := range sortedKeys(compacted) {
+				if td, ok := active.terms[prop]; ok && td.reverse {
+					addValue(result, prop, compacted[prop], td.hasContainer("@set"))
+					delete(compacted, prop)
+				}
+			}
+			if len(compacted) > 0 {
+				result[p.compactIRI(active, "@reverse", nil, true, false)] = compacted
+			}
+			continue
+		case "@preserve":
+			compacted := p.compact(active, activeProperty, expValue)
+			if a, ok := compacted.([]interface{}); !ok || len(a) > 0 {
+				result["@preserve"] = compacted
+			}
+			continue
+		case "@index":
+			if propDef.hasContainer("@index") {
+				continue
+			}
+			result[p.compactIRI(active, expProp, nil, true, false)] = expValue
+			continue
+		case "@direction", "@language", "@value":
+			result[p.compactIRI(active, expProp, nil, true, false)] = expValue
+			continue
+		}
+
+		if a, ok := expValue.([]interface{}); ok && len(a) == 0 {
+			itemProp := p.compactIRI(active, expProp, expValue, true, insideReverse)
+			nestResult := p.nestResult(active, result, itemProp)
+			addValue(nestResult, itemProp, []interface{}{}, true)
+		}
+
+		for _, expItem := range asArray(expValue) {
+			itemProp := p.compactIRI(active, expProp, expItem, true, insideReverse)
+			nestResult := p.nestResult(active, result, itemProp)
+			td := active.terms[itemProp]
+			asArr := td.hasContainer("@set") || itemProp == "@graph" || itemProp == "@list"
+
+			item, _ := expItem.(map[string]interface{})
+			var compactedItem interface{}
+			switch {
+			case isListObject(item):
+				compactedItem = p.compact(active, &itemProp, item["@list"])
+			case isGraphObject(item):
+				compactedItem = p.compact(active, &itemProp, item["@graph"])
+			default:
+				compactedItem = p.compact(active, &itemProp, expItem)
+			}
+
+			switch {
+			case isListObject(item):
+				list := asArray(compactedItem)
+				if list == nil {
+					list = []interface{}{}
+				}
+				compactedItem = list
+				if !td.hasContainer("@list") {
+					m := map[string]interface{}{p.compactIRI(active, "@list", nil, true, false): compactedItem}
+					if idx, ok := item["@index"]; ok {
+						m[p.compactIRI(active, "@index", nil, true, false)] = idx
+					}
+					addValue(nestResult, itemProp, m, asArr)
+				} else {
+					nestResult[itemProp] = compactedItem
+				}
+			case isGraphObject(item):
+				_, hasID := item["@id"]
+				switch {
+				case td.hasContainer("@graph") && td.hasContainer("@id"):
+					mapObject := subMap(nestResult, itemProp)
+					var mapKey string
+					if hasID {
+						mapKey = p.compactIRI(active, item["@id"].(string), nil, false, false)
+					} else {
+						mapKey = p.compactIRI(active, "@none", nil, true, false)
+					}
+					addValue(mapObject, mapKey, compactedItem, asArr)
+				case td.hasContainer("@graph") && td.hasContainer("@index") && !hasID:
+					mapObject := subMap(nestResult, itemProp)
+					mapKey, ok := item["@index"].(string)
+					if !ok {
+						mapKey = p.compactIRI(active, "@none", nil, true, false)
+					}
+					addValue(mapObject, mapKey, compactedItem, asArr)
+				case td.hasContainer("@graph") && !hasID:
+					if a, ok := compactedItem.([]interface{}); ok && len(a) > 1 {
+						compactedItem = map[string]interface{}{p.compactIRI(active, "@included", nil, true, false): a}
+					}
+					addValue(nestResult, itemProp, compactedItem, asArr)
+				default:
+					m := map[string]interface{}{p.compactIRI(active, "@graph", nil, true, false): asArray(compactedItem)}
+					if hasID {
+						m[p.compactIRI(active, "@id", nil, true, false)] = p.compactIRI(active, item["@id"].(string), nil, false, false)
+					}
+					if idx, ok := item["@index"]; ok {
+						m[p.compactIRI(active, "@index", nil, true, false)] = idx
+					}
+					addValue(nestResult, itemProp, m, asArr)
+				}
+			case !td.hasContainer("@graph") && (td.hasContainer("@language") || td.hasContainer("@index") ||
+				td.hasContainer("@id") || td.hasContainer("@type")):
+				p.compactMapItem(active, td, nestResult, itemProp, item, compactedItem, asArr)
+			default:
+				addValue(nestResult, itemProp, compactedItem, asArr)
+			}
+		}
+	}
+	return result
+}
+
+// compactMapItem adds a compacted item to the language, index, id or type map of the item property.
+func (p *jsonldProcessor) compactMapItem(active *jsonldContext, td *termDef, nestResult map[string]interface{}, itemProp string, item map[string]interface{}, compactedItem interface{}, asArr bool) {
+	mapObject := subMap(nestResult, itemProp)
+	var container string
+	for _, c := range []string{"@language", "@index", "@id", "@type"} {
+		if td.hasContainer(c) {
+			container = c
+			break
+		}
+	}
+	containerKey := p.compactIRI(active, container, nil, true, false)
+	indexKey := "@index"
+	if td.index != "" {
+		indexKey = td.index
+	}
+	var mapKey string
+	ci, _ := compactedItem.(map[string]interface{})
+	switch {
+	case container == "@language" && isValueObject(item):
+		compactedItem = item["@value"]
+		mapKey, _ = item["@language"].(string)
+	case container == "@index" && indexKey == "@index":
+		mapKey, _ = item["@index"].(string)
+	case container == "@index":
+		containerKey = p.compactIRI(active, indexKey, nil, true, false)
+		if ci != nil {
+			vals := asArray(ci[containerKey])
+			if len(vals) > 0 {
+				if s, ok := vals[0].(string); ok {
+					mapKey = s
+					vals = vals[1:]
+					switch len(vals) {
+					case 0:
+						delete(ci, containerKey)
+					case 1:
+						ci[containerKey] = vals[0]
+					default:
+						ci[containerKey] = vals
+					}
+				}
+			}
+		}
+	case container == "@id":
+		if ci != nil {
+			mapKey, _ = ci[containerKey].(string)
+			delete(ci, containerKey)
+		}
+	case container == "@type":
+		if ci != nil {
+			vals := asArray(ci[containerKey])
+			if len(vals) > 0 {
+				mapKey, _ = vals[0].(string)
+				vals = vals[1:]
+				switch len(vals) {
+				case 0:
+					delete(ci, containerKey)
+				case 1:
+					ci[containerKey] = vals[0]
+				default:
+					ci[containerKey] = vals
+				}
+			}
+			if len(ci) == 1 {
+				if id, ok := item["@id"]; ok && hasKey(ci, p.compactIRI(active, "@id", nil, true, false)) {
+					compactedItem = p.compact(active, &itemProp, map[string]interface{}{"@id": id})
+				}
+			}
+		}
+	}
+	if mapKey == "" {
+		mapKey = p.compactIRI(active, "@none", nil, true, false)
+	}
+	addValue(mapObject, mapKey, compactedItem, asArr)
+}
+
+// nestResult returns the map where the item property is added; either the
+// result itself, or the nested map if the term is nested.
+func (p *jsonldProcessor) nestResult(active *jsonldContext, result map[string]interface{}, itemProp string) map[string]interface{} {
+	td := active.terms[itemProp]
+	if td == nil || td.nest == "" {
+		return result
+	}
+	if exp, _ := p.expandIRI(active, td.nest, false, true, nil, nil); exp != "@nest" {
+		jsonldErrorf("invalid @nest value", "%s", td.nest)
+	}
+	return subMap(result, td.nest)
+}
+
+// subMap returns the map in entry key of m, creating it if needed.
+func subMap(m map[string]interface{}, key string) map[string]interface{} {
+	sm, ok := m[key].(map[string]interface{})
+	if !ok {
+		sm = make(map[string]interface{})
+		m[key] = sm
+	}
+	return sm
+}
+
+func strOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// inverseContext returns the inverse context of the active context, used
+// to select terms when compacting. It's created on first use.
+func (c *jsonldContext) inverseContext() map[string]map[string]map[string]map[string]string {
+	if c.inverse != nil {
+		return c.inverse
+	}
+	result := make(map[string]map[string]map[string]map[string]string)
+	defaultLanguage := "@none"
+	if c.language != "" {
+		defaultLanguage = strings.ToLower(c.language)
+	}
+
+	terms := make([]string, 0, len(c.terms))
+	for t := range c.terms {
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) < len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+
+	setOnce := func(m map[string]string, key, term string) {
+		if _, ok := m[key]; !ok {
+			m[key] = term
+		}
+	}
+
+	for _, term := range terms {
+		td := c.terms[term]
+		if td.id == "" {
+			continue
+		}
+		container := "@none"
+		if len(td.container) > 0 {
+			container = strings.Join(td.container, "")
+		}
+		containerMap, ok := result[td.id]
+		if !ok {
+			containerMap = make(map[string]map[string]map[string]string)
+			result[td.id] = containerMap
+		}
+		tlm, ok := containerMap[container]
+		if !ok {
+			tlm = map[string]map[string]string{
+				"@language": make(map[string]string),
+				"@type":     make(map[string]string),
+				"@any":      {"@none": term},
+			}
+			containerMap[container] = tlm
+		}
+		typeMap, langMap := tlm["@type"], tlm["@language"]
+		switch {
+		case td.reverse:
+			setOnce(typeMap, "@reverse", term)
+		case td.typ == "@none":
+			setOnce(langMap, "@any", term)
+			setOnce(typeMap, "@any", term)
+		case td.typ != "":
+			setOnce(typeMap, td.typ, term)
+		case td.hasLanguage && td.hasDirection:
+			langDir := "@null"
+			switch {
+			case td.language != "" && td.direction != "":
+				langDir = strings.ToLower(td.language) + "_" + td.direction
+			case td.language != "":
+				langDir = strings.ToLower(td.language)
+			case td.direction != "":
+				langDir = "_" + td.direction
+			}
+			setOnce(langMap, langDir, term)
+		case td.hasLanguage:
+			lang := "@null"
+			if td.language != "" {
+				lang = strings.ToLower(td.language)
+			}
+			setOnce(langMap, lang, term)
+		case td.hasDirection:
+			dir := "@none"
+			if td.direction != "" {
+				dir = "_" + td.direction
+			}
+			setOnce(langMap, dir, term)
+		case c.direction != "":
+			langDir := "_" + c.direction
+			if c.language != "" {
+				langDir = strings.ToLower(c.language) + langDir
+			}
+			setOnce(langMap, langDir, term)
+			setOnce(langMap, "@none", term)
+			setOnce(typeMap, "@none", term)
+		default:
+			setOnce(langMap, defaultLanguage, term)
+			setOnce(langMap, "@none", term)
+			setOnce(typeMap, "@none", term)
+		}
+	}
+	c.inverse = result
+	return result
+}
+
+// selectTerm implements the Term Selection algorithm. It returns "" if no
+// term is found.
+func (c *jsonldContext) selectTerm(iri string, containers []string, typeLanguage string, preferred []string) string {
+	containerMap := c.inverseContext()[iri]
+	for _, container := range containers {
+		tlm, ok := containerMap[container]
+		if !ok {
+			continue
+		}
+		valueMap := tlm[typeLanguage]
+		for _, item := range preferred {
+			if term, ok := valueMap[item]; ok {
+				return term
+			}
+		}
+	}
+	return ""
+}
+
+// compactIRI implements the IRI Compaction algorithm. The value is the value
+// associated with the IRI, used to select the best term, or nil.
+func (p *jsonldProcessor) compactIRI(active *jsonldContext, iri string, value interface{}, vocab, reverse bool) string {
+	if vocab {
+		if _, ok := active.inverseContext()[iri]; ok {
+			if term := p.selectTermFor(active, iri, value, reverse); term != "" {
+				return term
+			}
+		}
+	}
+
+	if vocab && active.hasVocab && strings.HasPrefix(iri, active.vocab) && len(iri) > len(active.vocab) {
+		suffix := iri[len(active.vocab):]
+		if _, ok := active.terms[suffix]; !ok {
+			return suffix
+		}
+	}
+
+	compactIRI := ""
+	for term, td := range active.terms {
+		if td.id == "" || td.id == iri || !strings.HasPrefix(iri, td.id) || !td.prefix {
+			continue
+		}
+		candidate := term + ":" + iri[len(td.id):]
+		if compactIRI != "" && (len(candidate) > len(compactIRI) ||
+			(len(candidate) == len(compactIRI) && candidate >= compactIRI)) {
+			continue
+		}
+		if ctd, ok := active.terms[candidate]; !ok || (ctd.id == iri && value == nil) {
+			compactIRI = candidate
+		}
+	}
+	if compactIRI != "" {
+		return compactIRI
+	}
+
+	if i := strings.Index(iri, ":"); i > 0 && !strings.HasPrefix(iri[i+1:], "//") {
+		if td, ok := active.terms[iri[:i]]; ok && td.prefix {
+			jsonldErrorf("IRI confused with prefix", "%s", iri)
+		}
+	}
+
+	if !vocab && active.hasBase {
+		return relativeIRI(active.base, iri)
+	}
+	return iri
+}
+
+// selectTermFor selects the term best matching the IRI and its value, as part
+// of the IRI Compaction algorithm.
+func (p *jsonldProcessor) selectTermFor(active *jsonldContext, iri string, value interface{}, reverse bool) string {
+	defaultLanguage := "@none"
+	if active.direction != "" {
+		defaultLanguage = strings.ToLower(active.language) + "_" + active.direction
+	} else if active.language != "" {
+		defaultLanguage = strings.ToLower(active.language)
+	}
+
+	vm, _ := value.(map[string]interface{})
+	if pres, ok := vm["@preserve"]; ok {
+		vm, _ = asArray(pres)[0].(map[string]interface{})
+	}
+
+	var containers []string
+	typeLanguage, typeLanguageValue := "@language", "@null"
+	_, hasIndex := vm["@index"]
+	if hasIndex && !isGraphObject(vm) {
+		containers = append(containers, "@index", "@index@set")
+	}
+
+	switch {
+	case reverse:
+		typeLanguage, typeLanguageValue = "@type", "@reverse"
+		containers = append(containers, "@set")
+	case isListObject(vm):
+		if !hasIndex {
+			containers = append(containers, "@list")
+		}
+		list := asArray(vm["@list"])
+		commonType, commonLanguage := "", ""
+		if len(list) == 0 {
+			commonLanguage = defaultLanguage
+		}
+		for _, item := range list {
+			itemLanguage, itemType := "@none", "@none"
+			if isValueObject(item) {
+				im := item.(map[string]interface{})
+				if dir, ok := im["@direction"].(string); ok {
+					lang, _ := im["@language"].(string)
+					itemLanguage = strings.ToLower(lang) + "_" + dir
+				} else if lang, ok := im["@language"].(string); ok {
+					itemLanguage = strings.ToLower(lang)
+				} else if t, ok := im["@type"].(string); ok {
+					itemType = t
+				} else {
+					itemLanguage = "@null"
+				}
+			} else {
+				itemType = "@id"
+			}
+			if commonLanguage == "" {
+				commonLanguage = itemLanguage
+			} else if commonLanguage != itemLanguage && isValueObject(item) {
+				commonLanguage = "@none"
+			}
+			if commonType == "" {
+				commonType = itemType
+			} else if commonType != itemType {
+				commonType = "@none"
+			}
+			if commonLanguage == "@none" && commonType == "@none" {
+				break
+			}
+		}
+		if commonLanguage == "" {
+			commonLanguage = "@none"
+		}
+		if commonType == "" {
+			commonType = "@none"
+		}
+		if commonType != "@none" {
+			typeLanguage, typeLanguageValue = "@type", commonType
+		} else {
+			typeLanguageValue = commonLanguage
+		}
+	case isGraphObject(vm):
+		_, hasID := vm["@id"]
+		if hasIndex {
+			containers = append(containers, "@graph@index", "@graph@index@set")
+		}
+		if hasID {
+			containers = append(containers, "@graph@id", "@graph@id@set")
+		}
+		containers = append(containers, "@graph", "@graph@set", "@set")
+		if !hasIndex {
+			containers = append(containers, "@graph@index", "@graph@index@set")
+		}
+		if !hasID {
+			containers = append(containers, "@graph@id", "@graph@id@set")
+		}
+		containers = append(containers, "@index", "@index@set")
+		typeLanguage, typeLanguageValue = "@type", "@id"
+	default:
+		if isValueObject(vm) {
+			dir, hasDir := vm["@direction"].(string)
+			lang, hasLang := vm["@language"].(string)
+			switch {
+			case hasDir && !hasIndex:
+				typeLanguageValue = strings.ToLower(lang) + "_" + dir
+				containers = append(containers, "@language", "@language@set")
+			case hasLang && !hasIndex:
+				typeLanguageValue = strings.ToLower(lang)
+				containers = append(containers, "@language", "@language@set")
+			default:
+				if t, ok := vm["@type"].(string); ok {
+					typeLanguage, typeLanguageValue = "@type", t
+				}
+			}
+		} else {
+			typeLanguage, typeLanguageValue = "@type", "@id"
+			containers = append(containers, "@id", "@id@set", "@type", "@set@type")
+		}
+		containers = append(containers, "@set")
+	}
+	containers = append(containers, "@none")
+	if !hasIndex {
+		containers = append(containers, "@index", "@index@set")
+	}
+	if isValueObject(vm) && len(vm) == 1 {
+		containers = append(containers, "@language", "@language@set")
+	}
+
+	var preferred []string
+	if typeLanguageValue == "@reverse" {
+		preferred = append(preferred, "@reverse")
+	}
+	if id, ok := vm["@id"].(string); ok && (typeLanguageValue == "@id" || typeLanguageValue == "@reverse") {
+		compacted := p.compactIRI(active, id, nil, true, false)
+		if td, ok := active.terms[compacted]; ok && td.id == id {
+			preferred = append(preferred, "@vocab", "@id", "@none")
+		} else {
+			preferred = append(preferred, "@id", "@vocab", "@none")
+		}
+	} else {
+		preferred = append(preferred, typeLanguageValue, "@none")
+		if l, ok := vm["@list"].([]interface{}); ok && len(l) == 0 {
+			typeLanguage = "@any"
+		}
+	}
+	preferred = append(preferred, "@any")
+	for _, pv := range preferred {
+		if i := strings.Index(pv, "_"); i >= 0 {
+			preferred = append(preferred, pv[i:])
+			break
+		}
+	}
+	return active.selectTerm(iri, containers, typeLanguage, preferred)
+}
+
+// compactValue implements the Value Compaction algorithm.
+func (p *jsonldProcessor) compactValue(active *jsonldContext, activeProperty string, value map[string]interface{}) interface{} {
+	td := active.terms[activeProperty]
+	language, direction := active.language, active.direction
+	var typ string
+	if td != nil {
+		if td.hasLanguage {
+			language = td.language
+		}
+		if td.hasDirection {
+			direction = td.direction
+		}
+		typ = td.typ
+	}
+	_, hasIndex := value["@index"]
+	indexOK := !hasIndex || td.hasContainer("@index")
+
+	var result interface{} = value
+	vt, hasType := value["@type"].(string)
+	v := value["@value"]
+	lang, _ := value["@language"].(string)
+	dir, _ := value["@direction"].(string)
+	id, _ := value["@id"].(string)
+	switch {
+	case isNodeReference(value):
+		switch typ {
+		case "@id":
+			return p.compactIRI(active, id, nil, false, false)
+		case "@vocab":
+			return p.compactIRI(active, id, nil, true, false)
+		}
+	case hasType && vt == typ:
+		result = v
+	case typ == "@none" || hasType:
+		if hasType {
+			m := copyMap(value)
+			m["@type"] = p.compactIRI(active, vt, nil, true, false)
+			result = m
+		}
+	case !isString(v):
+		if indexOK {
+			result = v
+		}
+	case strings.ToLower(lang) == strings.ToLower(language) && dir == direction:
+		if indexOK {
+			result = v
+		}
+	}
+
+	if m, ok := result.(map[string]interface{}); ok {
+		compacted := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			compacted[p.compactIRI(active, k, nil, true, false)] = v
+		}
+		return compacted
+	}
+	return result
+}
+
+// isNodeReference returns true if the object only has an @id entry, and
+// optionally an @index.
+func isNodeReference(m map[string]interface{}) bool {
+	_, hasID := m["@id"]
+	_, hasIndex := m["@index"]
+	return hasID && (len(m) == 1 || (len(m) == 2 && hasIndex))
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// relativeIRI returns the IRI relative to the base IRI, if possible.
+func relativeIRI(base, iri string) string {
+	b, r := splitIRI(base), splitIRI(iri)
+	if b.scheme != r.scheme || (b.auth == nil) != (r.auth == nil) || (b.auth != nil && *b.auth != *r.auth) {
+		return iri
+	}
+	baseSegs := strings.Split(removeDotSegments(b.path), "/")
+	iriSegs := strings.Split(removeDotSegments(r.path), "/")
+	last := 1
+	if r.query != nil || r.frag != nil {
+		last = 0
+	}
+	for len(baseSegs) > 0 && len(iriSegs) > last && baseSegs[0] == iriSegs[0] {
+		baseSegs, iriSegs = baseSegs[1:], iriSegs[1:]
+	}
+	var rel string
+	if len(baseSegs) > 0 {
+		rel = strings.Repeat("../", len(baseSegs)-1)
+	}
+	path := strings.Join(iriSegs, "/")
+	if rel == "" && strings.Contains(strings.SplitN(path, "/", 2)[0], ":") {
+		path = "./" + path
+	}
+	rel += path
+	if r.query != nil {
+		rel += "?" + *r.query
+	}
+	if r.frag != nil {
+		rel += "#" + *r.frag
+	}
+	if rel == "" {
+		rel = "./"
+	}
+	return rel
+}
@@ -0,0 +1,1641 @@
+package rdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// This file implements the JSON-LD 1.1 Context Processing, IRI Expansion and
+// Expansion algorithms, as described in https://www.w3.org/TR/json-ld11-api/.
+//
+// JSON values are represented as decoded by encoding/json: map[string]interface{},
+// []interface{}, string, bool, nil, and json.Number or float64 for numbers.
+
+// maxRemoteContexts is the maximum number of nested remote contexts to load,
+// to guard against recursive context inclusion.
+const maxRemoteContexts = 32
+
+var (
+	rgxpKeywordForm = regexp.MustCompile(`^@[a-zA-Z]+$`)
+	rgxpIRIScheme   = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	rgxpIRIParts    = regexp.MustCompile(`^(?:([^:/?#]+):)?(?://([^/?#]*))?([^?#]*)(?:\?([^#]*))?(?:#(.*))?$`)
+)
+
+// jsonldKeywords are the keywords defined by JSON-LD 1.1.
+var jsonldKeywords = map[string]bool{
+	"@base": true, "@container": true, "@context": true, "@direction": true,
+	"@graph": true, "@id": true, "@import": true, "@included": true,
+	"@index": true, "@json": true, "@language": true, "@list": true,
+	"@nest": true, "@none": true, "@prefix": true, "@propagate": true,
+	"@protected": true, "@reverse": true, "@set": true, "@type": true,
+	"@value": true, "@version": true, "@vocab": true,
+
+	// framing keywords
+	"@default": true, "@embed": true, "@explicit": true,
+	"@omitDefault": true, "@requireAll": true,
+}
+
+// jsonldError is an error which occurs during JSON-LD processing. The code is
+// one of the error codes defined by the JSON-LD 1.1 API specification.
+type jsonldError struct {
+	code    string
+	details string
+}
+
+func (e jsonldError) Error() string {
+	if e.details == "" {
+		return e.code
+	}
+	return fmt.Sprintf("%s: %s", e.code, e.details)
+}
+
+// jsonldErrorf terminates JSON-LD processing with the given error code.
+func jsonldErrorf(code, format string, args ...interface{}) {
+	panic(jsonldError{code: code, details: fmt.Sprintf(format, args...)})
+}
+
+// jsonldContext is a JSON-LD active context.
+type jsonldContext struct {
+	base         string // base IRI
+	hasBase      bool   // false when base IRI is null
+	originalBase string // the original base URL of the document
+	vocab        string // vocabulary mapping, or "" if none
+	hasVocab     bool
+	language     string // default language, or "" if none
+	direction    string // default base direction, or "" if none
+	terms        map[string]*termDef
+	previous     *jsonldContext // non-propagated contexts keeps a reference to the previous context
+
+	inverse map[string]map[string]map[string]map[string]string // inverse context, created when compacting
+}
+
+// termDef is a JSON-LD term definition.
+type termDef struct {
+	id           string // IRI mapping, or "" for a null mapping
+	reverse      bool
+	typ          string   // type mapping
+	language     string   // language mapping
+	hasLanguage  bool     // true when the language mapping is set (can be null, i.e. "")
+	direction    string   // direction mapping
+	hasDirection bool     // true when the direction mapping is set (can be null, i.e. "")
+	container    []string // container mapping
+	index        string   // index mapping
+	nest         string   // nest value
+	prefix       bool     // can be used as prefix in compact IRIs
+	protected    bool
+	context      interface{} // local (scoped) context
+	hasContext   bool
+	baseURL      string // base URL of the scoped context
+}
+
+// hasContainer returns true if the term definition's container mapping includes c.
+func (td *termDef) hasContainer(c string) bool {
+	if td == nil {
+		return false
+	}
+	for _, x := range td.container {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
+
+// sameAs returns true if the two definitions are the same, not considering
+// the protected flag.
+func (td *termDef) sameAs(o *termDef) bool {
+	if td.id != o.id || td.reverse != o.reverse || td.typ != o.typ ||
+		td.language != o.language || td.hasLanguage != o.hasLanguage ||
+		td.direction != o.direction || td.hasDirection != o.hasDirection ||
+		td.index != o.index || td.nest != o.nest || td.prefix != o.prefix ||
+		td.hasContext != o.hasContext || len(td.container) != len(o.container) {
+		return false
+	}
+	for i := range td.container {
+		if td.container[i] != o.container[i] {
+			return false
+		}
+	}
+	return !td.hasContext || jsonEqual(td.context, o.context)
+}
+
+func newJSONLDContext(base string) *jsonldContext {
+	return &jsonldContext{
+		base:         base,
+		hasBase:      base != "",
+		originalBase: base,
+		terms:        make(map[string]*termDef),
+	}
+}
+
+// clone returns a copy of the context, which can be modified without
+// affecting the original.
+func (c *jsonldContext) clone() *jsonldContext {
+	n := *c
+	n.terms = make(map[string]*termDef, len(c.terms))
+	for k, v := range c.terms {
+		n.terms[k] = v
+	}
+	n.inverse = nil
+	return &n
+}
+
+// hasProtected returns true if the context has any protected term definitions.
+func (c *jsonldContext) hasProtected() bool {
+	for _, td := range c.terms {
+		if td.protected {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonldProcessor holds the state of a JSON-LD processing session.
+type jsonldProcessor struct {
+	loader DocumentLoader
+	docs   map[string]interface{} // remote documents already loaded
+	bnodes *bnodeIssuer
+}
+
+func newJSONLDProcessor(loader DocumentLoader) *jsonldProcessor {
+	if loader == nil {
+		loader = &HTTPDocumentLoader{}
+	}
+	return &jsonldProcessor{
+		loader: loader,
+		docs:   make(map[string]interface{}),
+		bnodes: newBnodeIssuer("_:b"),
+	}
+}
+
+// loadDocument loads the remote document with the given URL, or fails with
+// the given error code.
+func (p *jsonldProcessor) loadDocument(url, code string) interface{} {
+	if doc, ok := p.docs[url]; ok {
+		return doc
+	}
+	doc, err := p.loader.LoadDocument(url)
+	if err != nil {
+		jsonldErrorf(code, "%s: %v", url, err)
+	}
+	p.docs[url] = doc
+	return doc
+}
+
+// processContext implements the Context Processing algorithm, and returns
+// the new active context resulting from applying the local context.
+func (p *jsonldProcessor) processContext(active *jsonldContext, local interface{}, baseURL string, remote []string, overrideProtected, propagate, validateScoped bool) *jsonldContext {
+	result := active.clone()
+	if m, ok := local.(map[string]interface{}); ok {
+		if v, ok := m["@propagate"]; ok {
+			b, ok := v.(bool)
+			if !ok {
+				jsonldErrorf("invalid @propagate value", "%v", v)
+			}
+			propagate = b
+		}
+	}
+	if !propagate && result.previous == nil {
+		result.previous = active
+	}
+
+	for _, ctx := range asArray(local) {
+		switch c := ctx.(type) {
+		case nil:
+			if !overrideProtected && result.hasProtected() {
+				jsonldErrorf("invalid context nullification", "context has protected terms")
+			}
+			prev := result
+			result = newJSONLDContext(active.originalBase)
+			if !propagate {
+				result.previous = prev
+			}
+			continue
+		case string:
+			url := resolveIRI(baseURL, c)
+			if !validateScoped && stringIn(url, remote) {
+				continue
+			}
+			if len(remote) > maxRemoteContexts {
+				jsonldErrorf("context overflow", "%s", url)
+			}
+			doc := p.loadDocument(url, "loading remote context failed")
+			m, ok := doc.(map[string]interface{})
+			if !ok {
+				jsonldErrorf("invalid remote context", "%s", url)
+			}
+			rctx, ok := m["@context"]
+			if !ok {
+				jsonldErrorf("invalid remote context", "%s: no @context entry", url)
+			}
+			r := make([]string, len(remote), len(remote)+1)
+			copy(r, remote)
+			result = p.processContext(result, rctx, url, append(r, url), false, true, validateScoped)
+			continue
+		case map[string]interface{}:
+			p.processLocalContext(result, c, baseURL, remote, overrideProtected, propagate)
+		default:
+			jsonldErrorf("invalid local context", "%v", ctx)
+		}
+	}
+	return result
+}
+
+// processLocalContext applies the entries of the local context object to the result context.
+func (p *jsonldProcessor) processLocalContext(result *jsonldContext, c map[string]interface{}, baseURL string, remote []string, overrideProtected, propagate bool) {
+	if v, ok := c["@version"]; ok {
+		if f, ok := jsonNumber(v); !ok || f != 1.1 {
+			jsonldErrorf("invalid @version value", "%v", v)
+		}
+	}
+	if v, ok := c["@import"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			jsonldErrorf("invalid @import value", "%v", v)
+		}
+		url := resolveIRI(baseURL, s)
+		doc := p.loadDocument(url, "loading remote context failed")
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			jsonldErrorf("invalid remote context", "%s", url)
+		}
+		imported, ok := m["@context"].(map[string]interface{})
+		if !ok {
+			jsonldErrorf("invalid remote context", "%s", url)
+		}
+		if _, ok := imported["@import"]; ok {
+			jsonldErrorf("invalid context entry", "imported context contains @import")
+		}
+		merged := make(map[string]interface{}, len(imported)+len(c))
+		for k, v := range imported {
+			merged[k] = v
+		}
+		for k, v := range c {
+			merged[k] = v
+		}
+		c = merged
+	}
+	if v, ok := c["@base"]; ok && len(remote) == 0 {
+		switch b := v.(type) {
+		case nil:
+			result.base = ""
+			result.hasBase = false
+		case string:
+			if isAbsoluteIRI(b) {
+				result.base = b
+			} else if result.hasBase {
+				result.base = resolveIRI(result.base, b)
+			} else if b == "" {
+				// empty relative reference against null base
+			} else {
+				jsonldErrorf("invalid base IRI", "%s", b)
+			}
+			result.hasBase = result.base != ""
+		default:
+			jsonldErrorf("invalid base IRI", "%v", v)
+		}
+	}
+	if v, ok := c["@vocab"]; ok {
+		switch s := v.(type) {
+		case nil:
+			result.vocab = ""
+			result.hasVocab = false
+		case string:
+			if !isAbsoluteIRI(s) && !strings.HasPrefix(s, "_:") && !result.hasBase && !result.hasVocab && s != "" {
+				jsonldErrorf("invalid vocab mapping", "%s", s)
+			}
+			vocab, _ := p.expandIRI(result, s, true, true, nil, nil)
+			result.vocab = vocab
+			result.hasVocab = true
+		default:
+			jsonldErrorf("invalid vocab mapping", "%v", v)
+		}
+	}
+	if v, ok := c["@language"]; ok {
+		switch s := v.(type) {
+		case nil:
+			result.language = ""
+		case string:
+			result.language = s
+		default:
+			jsonldErrorf("invalid default language", "%v", v)
+		}
+	}
+	if v, ok := c["@direction"]; ok {
+		switch s := v.(type) {
+		case nil:
+			result.direction = ""
+		case string:
+			if s != "ltr" && s != "rtl" {
+				jsonldErrorf("invalid base direction", "%s", s)
+			}
+			result.direction = s
+		default:
+			jsonldErrorf("invalid base direction", "%v", v)
+		}
+	}
+	if v, ok := c["@propagate"]; ok {
+		if _, ok := v.(bool); !ok {
+			jsonldErrorf("invalid @propagate value", "%v", v)
+		}
+	}
+	protected := false
+	if v, ok := c["@protected"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			jsonldErrorf("invalid @protected value", "%v", v)
+		}
+		protected = b
+	}
+
+	defined := make(map[string]bool)
+	for _, k := range sortedKeys(c) {
+		switch k {
+		case "@base", "@direction", "@import", "@language", "@propagate", "@protected", "@version", "@vocab":
+			continue
+		}
+		p.createTermDef(result, c, k, defined, baseURL, protected, overrideProtected, remote)
+	}
+}
+
+// createTermDef implements the Create Term Definition algorithm.
+func (p *jsonldProcessor) createTermDef(active *jsonldContext, local map[string]interface{}, term string, defined map[string]bool, baseURL string, protected, overrideProtected bool, remote []string) {
+	if d, ok := defined[term]; ok {
+		if d {
+			return
+		}
+		jsonldErrorf("cyclic IRI mapping", "%s", term)
+	}
+	if term == "" {
+		jsonldErrorf("invalid term definition", "empty term")
+	}
+	defined[term] = false
+	value := local[term]
+
+	if term == "@type" {
+		m, ok := value.(map[string]interface{})
+		if !ok || len(m) == 0 {
+			jsonldErrorf("keyword redefinition", "%s", term)
+		}
+		for k, v := range m {
+			switch k {
+			case "@container":
+				if v != "@set" {
+					jsonldErrorf("keyword redefinition", "%s", term)
+				}
+			case "@protected":
+			default:
+				jsonldErrorf("keyword redefinition", "%s", term)
+			}
+		}
+	} else if jsonldKeywords[term] {
+		jsonldErrorf("keyword redefinition", "%s", term)
+	} else if rgxpKeywordForm.MatchString(term) {
+		// Terms having the form of a keyword are ignored.
+		defined[term] = true
+		return
+	}
+
+	previous := active.terms[term]
+	delete(active.terms, term)
+
+	simpleTerm := false
+	var m map[string]interface{}
+	switch v := value.(type) {
+	case nil:
+		m = map[string]interface{}{"@id": nil}
+	case string:
+		m = map[string]interface{}{"@id": v}
+		simpleTerm = true
+	case map[string]interface{}:
+		m = v
+	default:
+		jsonldErrorf("invalid term definition", "%s", term)
+	}
+
+	def := &termDef{}
+	if v, ok := m["@protected"]; ok {
+		b, ok := v.(bool)
+		if !ok {
+			jsonldErrorf("invalid @protected value", "%v", v)
+		}
+		protected = b
+	}
+	def.protected = protected
+
+	if v, ok := m["@type"]; ok {
+		s, ok := v.(string)
+		if !ok {
+			jsonldErrorf("invalid type mapping", "%v", v)
+		}
+		typ, _ := p.expandIRI(active, s, false, true, local, defined)
+		switch typ {
+		case "@id", "@vocab", "@json", "@none":
+		default:
+			if !isAbsoluteIRI(typ) {
+				jsonldErrorf("invalid type mapping", "%s", typ)
+			}
+		}
+		def.typ = typ
+	}
+
+	if v, ok := m["@reverse"]; ok {
+		if _, ok := m["@id"]; ok {
+			jsonldErrorf("invalid reverse property", "%s", term)
+		}
+		if _, ok := m["@nest"]; ok {
+			jsonldErrorf("invalid reverse property", "%s", term)
+		}
+		s, ok := v.(string)
+		if !ok {
+			jsonldErrorf("invalid IRI mapping", "%v", v)
+		}
+		if rgxpKeywordForm.MatchString(s) {
+			defined[term] = true
+			return
+		}
+		id, _ := p.expandIRI(active, s, false, true, local, defined)
+		if !strings.Contains(id, ":") {
+			jsonldErrorf("invalid IRI mapping", "%s", id)
+		}
+		def.id = id
+		if c, ok := m["@container"]; ok {
+			switch c {
+			case nil:
+			case "@set", "@index":
+				def.container = []string{c.(string)}
+			default:
+				jsonldErrorf("invalid reverse property", "%v", c)
+			}
+		}
+		def.reverse = true
+		active.terms[term] = def
+		defined[term] = true
+		return
+	}
+
+	if v, ok := m["@id"]; ok && v != term {
+		switch s := v.(type) {
+		case nil:
+			// null mapping; the term is not expanded
+		case string:
+			if !jsonldKeywords[s] && rgxpKeywordForm.MatchString(s) {
+				defined[term] = true
+				return
+			}
+			id, _ := p.expandIRI(active, s, false, true, local, defined)
+			if !jsonldKeywords[id] && !strings.Contains(id, ":") {
+				jsonldErrorf("invalid IRI mapping", "%s", id)
+			}
+			if id == "@context" {
+				jsonldErrorf("invalid keyword alias", "%s", term)
+			}
+			def.id = id
+			if strings.Contains(strings.Trim(term, ":"), ":") || strings.Contains(term, "/") {
+				defined[term] = true
+				if exp, _ := p.expandIRI(active, term, false, true, local, defined); exp != id {
+					jsonldErrorf("invalid IRI mapping", "%s does not expand to %s", term, id)
+				}
+			}
+			if !strings.Contains(term, ":") && !strings.Contains(term, "/") && simpleTerm &&
+				(strings.HasPrefix(id, "_:") || (id != "" && strings.ContainsAny(id[len(id)-1:], ":/?#[]@"))) {
+				def.prefix = true
+			}
+		default:
+			jsonldErrorf("invalid IRI mapping", "%v", v)
+		}
+	} else if i := strings.Index(term, ":"); i > 0 {
+		prefix, suffix := term[:i], term[i+1:]
+		if _, ok := local[prefix]; ok {
+			p.createTermDef(active, local, prefix, defined, baseURL, false, overrideProtected, remote)
+		}
+		if pd, ok := active.terms[prefix]; ok && pd.id != "" && !strings.HasPrefix(suffix, "//") {
+			def.id = pd.id + suffix
+		} else {
+			def.id = term
+		}
+	} else if strings.Contains(term, "/") {
+		id, _ := p.expandIRI(active, term, false, true, nil, nil)
+		if !isAbsoluteIRI(id) {
+			jsonldErrorf("invalid IRI mapping", "%s", id)
+		}
+		def.id = id
+	} else if term == "@type" {
+		def.id = "@type"
+	} else if active.hasVocab {
+		def.id = active.vocab + term
+	} else {
+		jsonldErrorf("invalid IRI mapping", "%s: no vocabulary mapping", term)
+	}
+
+	if v, ok := m["@container"]; ok {
+		def.container = validContainer(v)
+		if def.hasContainer("@type") {
+			if def.typ == "" {
+				def.typ = "@id"
+			} else if def.typ != "@id" && def.typ != "@vocab" {
+				jsonldErrorf("invalid type mapping", "%s", def.typ)
+			}
+		}
+	}
+
+	if v, ok := m["@index"]; ok {
+		s, ok := v.(string)
+		if !def.hasContainer("@index") || !ok || jsonldKeywords[s] {
+			jsonldErrorf("invalid term definition", "@index: %v", v)
+		}
+		def.index = s
+	}
+
+	if v, ok := m["@context"]; ok {
+		// Validate the scoped context.
+		func() {
+			defer func() {
+				if e := recover(); e != nil {
+					if _, ok := e.(jsonldError); ok {
+						jsonldErrorf("invalid scoped context", "%v", e)
+					}
+					panic(e)
+				}
+			}()
+			p.processContext(active, v, baseURL, remote, true, true, false)
+		}()
+		def.context = v
+		def.hasContext = true
+		def.baseURL = baseURL
+	}
+
+	if _, ok := m["@type"]; !ok {
+		if v, ok := m["@language"]; ok {
+			switch s := v.(type) {
+			case nil:
+			case string:
+				def.language = s
+			default:
+				jsonldErrorf("invalid language mapping", "%v", v)
+			}
+			def.hasLanguage = true
+		}
+		if v, ok := m["@direction"]; ok {
+			switch v {
+			case nil, "ltr", "rtl":
+				if v != nil {
+					def.direction = v.(string)
+				}
+			default:
+				jsonldErrorf("invalid base direction", "%v", v)
+			}
+			def.hasDirection = true
+		}
+	}
+
+	if v, ok := m["@nest"]; ok {
+		s, ok := v.(string)
+		if !ok || (jsonldKeywords[s] && s != "@nest") {
+			jsonldErrorf("invalid @nest value", "%v", v)
+		}
+		def.nest = s
+	}
+
+	if v, ok := m["@prefix"]; ok {
+		if strings.Contains(term, ":") || strings.Contains(term, "/") {
+			jsonldErrorf("invalid term definition", "%s cannot be a prefix", term)
+		}
+		b, ok := v.(bool)
+		if !ok {
+			jsonldErrorf("invalid @prefix value", "%v", v)
+		}
+		if b && jsonldKeywords[def.id] {
+			jsonldErrorf("invalid term definition", "keyword %s cannot be a prefix", def.id)
+		}
+		def.prefix = b
+	}
+
+	for k := range m {
+		switch k {
+		case "@id", "@reverse", "@container", "@context", "@direction", "@index",
+			"@language", "@nest", "@prefix", "@protected", "@type":
+		default:
+			jsonldErrorf("invalid term definition", "%s: unexpected entry %s", term, k)
+		}
+	}
+
+	if !overrideProtected && previous != nil && previous.protected {
+		if !def.sameAs(previous) {
+			jsonldErrorf("protected term redefinition", "%s", term)
+		}
+		def = previous
+	}
+
+	active.terms[term] = def
+	defined[term] = true
+}
+
+// validContainer validates a container mapping, and returns it as a sorted slice.
+func validContainer(v interface{}) []string {
+	var cs []string
+	switch c := v.(type) {
+	case nil:
+		return nil
+	case string:
+		cs = []string{c}
+	case []interface{}:
+		for _, x := range c {
+			s, ok := x.(string)
+			if !ok {
+				jsonldErrorf("invalid container mapping", "%v", v)
+			}
+			cs = append(cs, s)
+		}
+	default:
+		jsonldErrorf("invalid container mapping", "%v", v)
+	}
+	has := make(map[string]bool)
+	for _, c := range cs {
+		switch c {
+		case "@graph", "@id", "@index", "@language", "@list", "@set", "@type":
+			has[c] = true
+		default:
+			jsonldErrorf("invalid container mapping", "%v", v)
+		}
+	}
+	ok := false
+	switch {
+	case len(has) == 1:
+		ok = true
+	case has["@list"]:
+		ok = false
+	case has["@graph"] && (has["@id"] || has["@index"]):
+		ok = len(has) == 2 || (len(has) == 3 && has["@set"])
+	case has["@set"]:
+		ok = len(has) == 2 || (len(has) == 3 && has["@graph"])
+	}
+	if !ok {
+		jsonldErrorf("invalid container mapping", "%v", v)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// expandIRI implements the IRI Expansion algorithm. It returns false if
+// value expands to null.
+func (p *jsonldProcessor) expandIRI(active *jsonldContext, value string, documentRelative, vocab bool, local map[string]interface{}, defined map[string]bool) (string, bool) {
+	if jsonldKeywords[value] {
+		return value, true
+	}
+	if rgxpKeywordForm.MatchString(value) {
+		return "", false
+	}
+	if local != nil {
+		if _, ok := local[value]; ok && !defined[value] {
+			p.createTermDef(active, local, value, defined, active.base, false, false, nil)
+		}
+	}
+	if td, ok := active.terms[value]; ok && jsonldKeywords[td.id] {
+		return td.id, true
+	}
+	if vocab {
+		if td, ok := active.terms[value]; ok {
+			return td.id, td.id != ""
+		}
+	}
+	if i := strings.Index(value, ":"); i > 0 {
+		prefix, suffix := value[:i], value[i+1:]
+		if prefix == "_" || strings.HasPrefix(suffix, "//") {
+			return value, true
+		}
+		if local != nil {
+			if _, ok := local[prefix]; ok && !defined[prefix] {
+				p.createTermDef(active, local, prefix, defined, active.base, false, false, nil)
+			}
+		}
+		if td, ok := active.terms[prefix]; ok && td.id != "" && td.prefix {
+			return td.id + suffix, true
+		}
+		if isAbsoluteIRI(value) {
+			return value, true
+		}
+	}
+	if vocab && active.hasVocab {
+		return active.vocab + value, true
+	}
+	if documentRelative {
+		if active.hasBase {
+			return resolveIRI(active.base, value), true
+		}
+	}
+	return value, true
+}
+
+// expand implements the Expansion algorithm. The active property is nil
+// when not in the scope of a property.
+func (p *jsonldProcessor) expand(active *jsonldContext, activeProperty *string, element interface{}, baseURL string, frameExpansion, fromMap bool) interface{} {
+	if element == nil {
+		return nil
+	}
+	if activeProperty != nil && *activeProperty == "@default" {
+		frameExpansion = false
+	}
+	var propDef *termDef
+	if activeProperty != nil {
+		propDef = active.terms[*activeProperty]
+	}
+
+	switch e := element.(type) {
+	case []interface{}:
+		result := make([]interface{}, 0, len(e))
+		for _, item := range e {
+			expanded := p.expand(active, activeProperty, item, baseURL, frameExpansion, fromMap)
+			if l, ok := expanded.([]interface{}); ok && propDef.hasContainer("@list") {
+				expanded = map[string]interface{}{"@list": l}
+			}
+			switch x := expanded.(type) {
+			case nil:
+			case []interface{}:
+				result = append(result, x...)
+			default:
+				result = append(result, x)
+			}
+		}
+		return result
+	case map[string]interface{}:
+		return p.expandObject(active, activeProperty, propDef, e, baseURL, frameExpansion, fromMap)
+	default:
+		// scalar
+		if activeProperty == nil || *activeProperty == "@graph" {
+			// Free-floating scalars are dropped.
+			return nil
+		}
+		if propDef != nil && propDef.hasContext {
+			active = p.processContext(active, propDef.context, propDef.baseURL, nil, true, true, true)
+		}
+		return p.expandValue(active, *activeProperty, element)
+	}
+}
+
+// expandObject expands a JSON object (a map), as part of the Expansion algorithm.
+func (p *jsonldProcessor) expandObject(active *jsonldContext, activeProperty *string, propDef *termDef, element map[string]interface{}, baseURL string, frameExpansion, fromMap bool) interface{} {
+	keys := sortedKeys(element)
+
+	if active.previous != nil && !fromMap {
+		revert := true
+		for _, k := range keys {
+			if exp, _ := p.expandIRI(active, k, false, true, nil, nil); exp == "@value" {
+				revert = false
+				break
+			}
+		}
+		if len(keys) == 1 {
+			if exp, _ := p.expandIRI(active, keys[0], false, true, nil, nil); exp == "@id" {
+				revert = false
+			}
+		}
+		if revert {
+			active = active.previous
+		}
+	}
+
+	if propDef != nil && propDef.hasContext {
+		active = p.processContext(active, propDef.context, propDef.baseURL, nil, true, true, true)
+	}
+	if ctx, ok := element["@context"]; ok {
+		active = p.processContext(active, ctx, baseURL, nil, false, true, true)
+	}
+
+	typeScoped := active
+	inputType := ""
+	typeFound := false
+	for _, k := range keys {
+		if exp, _ := p.expandIRI(active, k, false, true, nil, nil); exp != "@type" {
+			continue
+		}
+		var types []string
+		for _, t := range asArray(element[k]) {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			if td, ok := typeScoped.terms[t]; ok && td.hasContext {
+				active = p.processContext(active, td.context, td.baseURL, nil, false, false, true)
+			}
+		}
+		if !typeFound && len(types) > 0 {
+			last := asArray(element[k])
+			if s, ok := last[len(last)-1].(string); ok {
+				inputType, _ = p.expandIRI(active, s, false, true, nil, nil)
+			}
+			typeFound = true
+		}
+	}
+
+	result := make(map[string]interface{})
+	p.expandEntries(active, typeScoped, activeProperty, element, result, baseURL, inputType, frameExpansion)
+
+	if v, ok := result["@value"]; ok {
+		for k := range result {
+			switch k {
+			case "@direction", "@index", "@language", "@type", "@value":
+			default:
+				jsonldErrorf("invalid value object", "unexpected entry %s", k)
+			}
+		}
+		_, hasLang := result["@language"]
+		_, hasDir := result["@direction"]
+		t, hasType := result["@type"]
+		if hasType && (hasLang || hasDir) {
+			jsonldErrorf("invalid value object", "both @type and @language or @direction")
+		}
+		if t == "@json" {
+			// JSON literals can have any value
+		} else if v == nil {
+			return nil
+		} else if _, ok := v.(string); !ok && hasLang {
+			jsonldErrorf("invalid language-tagged value", "%v", v)
+		} else if hasType {
+			ts, ok := t.(string)
+			if !ok && !frameExpansion {
+				jsonldErrorf("invalid typed value", "%v", t)
+			}
+			if ok && (!isAbsoluteIRI(ts) || strings.HasPrefix(ts, "_:")) && !frameExpansion {
+				jsonldErrorf("invalid typed value", "%v", t)
+			}
+		}
+	} else if t, ok := result["@type"]; ok {
+		if _, ok := t.([]interface{}); !ok {
+			result["@type"] = []interface{}{t}
+		}
+	} else if _, ok := result["@set"]; ok {
+		if len(result) > 2 || (len(result) == 2 && result["@index"] == nil) {
+			jsonldErrorf("invalid set or list object", "unexpected entries")
+		}
+		return result["@set"]
+	} else if _, ok := result["@list"]; ok {
+		if len(result) > 2 || (len(result) == 2 && result["@index"] == nil) {
+			jsonldErrorf("invalid set or list object", "unexpected entries")
+		}
+	}
+
+	if _, ok := result["@language"]; ok && len(result) == 1 {
+		return nil
+	}
+
+	if activeProperty == nil || *activeProperty == "@graph" {
+		_, hasValue := result["@value"]
+		_, hasList := result["@list"]
+		_, hasID := result["@id"]
+		if len(result) == 0 || hasValue || hasList {
+			return nil
+		}
+		if hasID && len(result) == 1 && !frameExpansion {
+			return nil
+		}
+	}
+	return result
+}
+
+// expandEntries expands the entries of element into result. It's called recursively
+// for nested properties.
+func (p *jsonldProcessor) expandEntries(active, typeScoped *jsonldContext, activeProperty *string, element, result map[string]interface{}, baseURL, inputType string, frameExpansion bool) {
+	var nests []string
+	for _, key := range sortedKeys(element) {
+		value := element[key]
+		if key == "@context" {
+			continue
+		}
+		expProp, ok := p.expandIRI(active, key, false, true, nil, nil)
+		if !ok || (!strings.Contains(expProp, ":") && !jsonldKeywords[expProp]) {
+			continue
+		}
+
+		if jsonldKeywords[expProp] {
+			if activeProperty != nil && *activeProperty == "@reverse" {
+				jsonldErrorf("invalid reverse property map", "%s", key)
+			}
+			if _, ok := result[expProp]; ok && expProp != "@included" && expProp != "@type" {
+				jsonldErrorf("colliding keywords", "%s", expProp)
+			}
+			var expValue interface{}
+			switch expProp {
+			case "@id":
+				switch v := value.(type) {
+				case string:
+					expValue, _ = p.expandIRI(active, v, true, false, nil, nil)
+					if frameExpansion {
+						expValue = []interface{}{expValue}
+					}
+				case map[string]interface{}:
+					if !frameExpansion || len(v) != 0 {
+						jsonldErrorf("invalid @id value", "%v", value)
+					}
+					expValue = []interface{}{v}
+				case []interface{}:
+					if !frameExpansion {
+						jsonldErrorf("invalid @id value", "%v", value)
+					}
+					ids := make([]interface{}, 0, len(v))
+					for _, x := range v {
+						s, ok := x.(string)
+						if !ok {
+							jsonldErrorf("invalid @id value", "%v", value)
+						}
+						id, _ := p.expandIRI(active, s, true, false, nil, nil)
+						ids = append(ids, id)
+					}
+					expValue = ids
+				default:
+					jsonldErrorf("invalid @id value", "%v", value)
+				}
+			case "@type":
+				var types []interface{}
+				switch v := value.(type) {
+				case string:
+					types = []interface{}{v}
+				case []interface{}:
+					types = v
+				case map[string]interface{}:
+					if !frameExpansion {
+						jsonldErrorf("invalid type value", "%v", value)
+					}
+					if d, ok := v["@default"]; ok {
+						s, ok := d.(string)
+						if !ok {
+							jsonldErrorf("invalid type value", "%v", value)
+						}
+						exp, _ := p.expandIRI(typeScoped, s, true, true, nil, nil)
+						expValue = map[string]interface{}{"@default": exp}
+					} else if len(v) != 0 {
+						jsonldErrorf("invalid type value", "%v", value)
+					} else {
+						expValue = []interface{}{v}
+					}
+				default:
+					jsonldErrorf("invalid type value", "%v", value)
+				}
+				if types != nil {
+					exps := make([]interface{}, 0, len(types))
+					for _, t := range types {
+						switch s := t.(type) {
+						case string:
+							exp, _ := p.expandIRI(typeScoped, s, true, true, nil, nil)
+							exps = append(exps, exp)
+						case map[string]interface{}:
+							if !frameExpansion || len(s) != 0 {
+								jsonldErrorf("invalid type value", "%v", value)
+							}
+							exps = append(exps, s)
+						default:
+							jsonldErrorf("invalid type value", "%v", value)
+						}
+					}
+					if _, ok := value.(string); ok && !frameExpansion {
+						expValue = exps[0]
+					} else {
+						expValue = exps
+					}
+				}
+				if prev, ok := result["@type"]; ok {
+					expValue = append(asArray(prev), asArray(expValue)...)
+				}
+			case "@graph":
+				g := "@graph"
+				expValue = asArray(p.expand(active, &g, value, baseURL, frameExpansion, false))
+			case "@included":
+				expValue = asArray(p.expand(active, nil, value, baseURL, frameExpansion, false))
+				for _, v := range expValue.([]interface{}) {
+					if !isNodeObject(v) {
+						jsonldErrorf("invalid @included value", "%v", v)
+					}
+				}
+				if prev, ok := result["@included"]; ok {
+					expValue = append(asArray(prev), expValue.([]interface{})...)
+				}
+			case "@value":
+				if inputType == "@json" {
+					expValue = value
+				} else {
+					switch value.(type) {
+					case nil, string, bool, json.Number, float64, int:
+						expValue = value
+					default:
+						if !frameExpansion {
+							jsonldErrorf("invalid value object value", "%v", value)
+						}
+						expValue = asArray(value)
+					}
+				}
+				if expValue == nil {
+					result["@value"] = nil
+					continue
+				}
+			case "@language":
+				switch v := value.(type) {
+				case string:
+					expValue = v
+				default:
+					if !frameExpansion {
+						jsonldErrorf("invalid language-tagged string", "%v", value)
+					}
+					expValue = asArray(value)
+				}
+			case "@direction":
+				switch value {
+				case "ltr", "rtl":
+					expValue = value
+				default:
+					if !frameExpansion {
+						jsonldErrorf("invalid base direction", "%v", value)
+					}
+					expValue = asArray(value)
+				}
+			case "@index":
+				if _, ok := value.(string); !ok {
+					jsonldErrorf("invalid @index value", "%v", value)
+				}
+				expValue = value
+			case "@list":
+				if activeProperty == nil || *activeProperty == "@graph" {
+					continue
+				}
+				expValue = asArray(p.expand(active, activeProperty, value, baseURL, frameExpansion, false))
+			case "@set":
+				expValue = p.expand(active, activeProperty, value, baseURL, frameExpansion, false)
+			case "@reverse":
+				if _, ok := value.(map[string]interface{}); !ok {
+					jsonldErrorf("invalid @reverse value", "%v", value)
+				}
+				r := "@reverse"
+				ev, _ := p.expand(active, &r, value, baseURL, frameExpansion, false).(map[string]interface{})
+				if rev, ok := ev["@reverse"].(map[string]interface{}); ok {
+					for _, prop := range sortedKeys(rev) {
+						addValue(result, prop, rev[prop], true)
+					}
+				}
+				if len(ev) > 1 || (len(ev) == 1 && ev["@reverse"] == nil) {
+					rmap, _ := result["@reverse"].(map[string]interface{})
+					if rmap == nil {
+						rmap = make(map[string]interface{})
+						result["@reverse"] = rmap
+					}
+					for _, prop := range sortedKeys(ev) {
+						if prop == "@reverse" {
+							continue
+						}
+						for _, item := range asArray(ev[prop]) {
+							if isValueObject(item) || isListObject(item) {
+								jsonldErrorf("invalid reverse property value", "%v", item)
+							}
+							addValue(rmap, prop, item, true)
+						}
+					}
+				}
+				continue
+			case "@nest":
+				nests = append(nests, key)
+				continue
+			case "@default":
+				if !frameExpansion {
+					continue
+				}
+				if value == "@null" {
+					expValue = value
+				} else {
+					expValue = p.expand(active, activeProperty, value, baseURL, frameExpansion, false)
+				}
+			case "@embed", "@explicit", "@omitDefault", "@requireAll":
+				if !frameExpansion {
+					continue
+				}
+				// Framing flags are validated by the Framing algorithm.
+				expValue = value
+			default:
+				continue
+			}
+			result[expProp] = expValue
+			continue
+		}
+
+		td := active.terms[key]
+		var expValue interface{}
+		if td != nil && td.typ == "@json" {
+			expValue = map[string]interface{}{"@value": value, "@type": "@json"}
+		} else if vm, ok := value.(map[string]interface{}); ok && td.hasContainer("@language") {
+			var vals []interface{}
+			dir := active.direction
+			if td.hasDirection {
+				dir = td.direction
+			}
+			for _, lang := range sortedKeys(vm) {
+				for _, item := range asArray(vm[lang]) {
+					if item == nil {
+						continue
+					}
+					s, ok := item.(string)
+					if !ok {
+						jsonldErrorf("invalid language map value", "%v", item)
+					}
+					v := map[string]interface{}{"@value": s}
+					if exp, _ := p.expandIRI(active, lang, false, true, nil, nil); exp != "@none" {
+						v["@language"] = lang
+					}
+					if dir != "" {
+						v["@direction"] = dir
+					}
+					vals = append(vals, v)
+				}
+			}
+			expValue = vals
+		} else if vm, ok := value.(map[string]interface{}); ok && (td.hasContainer("@index") || td.hasContainer("@type") || td.hasContainer("@id")) {
+			expValue = p.expandIndexMap(active, key, td, vm, baseURL, frameExpansion)
+		} else {
+			k := key
+			expValue = p.expand(active, &k, value, baseURL, frameExpansion, false)
+		}
+		if expValue == nil {
+			continue
+		}
+		if td.hasContainer("@list") && !isListObject(expValue) {
+			expValue = map[string]interface{}{"@list": asArray(expValue)}
+		}
+		if td.hasContainer("@graph") && !td.hasContainer("@id") && !td.hasContainer("@index") {
+			var gs []interface{}
+			for _, ev := range asArray(expValue) {
+				gs = append(gs, map[string]interface{}{"@graph": asArray(ev)})
+			}
+			expValue = gs
+		}
+		if td != nil && td.reverse {
+			rmap, _ := result["@reverse"].(map[string]interface{})
+			if rmap == nil {
+				rmap = make(map[string]interface{})
+				result["@reverse"] = rmap
+			}
+			for _, item := range asArray(expValue) {
+				if isValueObject(item) || isListObject(item) {
+					jsonldErrorf("invalid reverse property value", "%v", item)
+				}
+				addValue(rmap, expProp, item, true)
+			}
+		} else {
+			addValue(result, expProp, expValue, true)
+		}
+	}
+
+	for _, key := range nests {
+		for _, nv := range asArray(element[key]) {
+			nm, ok := nv.(map[string]interface{})
+			if !ok {
+				jsonldErrorf("invalid @nest value", "%v", nv)
+			}
+			for k := range nm {
+				if exp, _ := p.expandIRI(active, k, false, true, nil, nil); exp == "@value" {
+					jsonldErrorf("invalid @nest value", "%v", nv)
+				}
+			}
+			p.expandEntries(active, typeScoped, activeProperty, nm, result, baseURL, inputType, frameExpansion)
+		}
+	}
+}
+
+// expandIndexMap expands the value of a property with an index, id or type map container.
+func (p *jsonldProcessor) expandIndexMap(active *jsonldContext, key string, td *termDef, value map[string]interface{}, baseURL string, frameExpansion bool) interface{} {
+	var result []interface{}
+	indexKey := "@index"
+	if td.index != "" {
+		indexKey = td.index
+	}
+	for _, index := range sortedKeys(value) {
+		mapCtx := active
+		if (td.hasContainer("@id") || td.hasContainer("@type")) && active.previous != nil {
+			mapCtx = active.previous
+		}
+		if td.hasContainer("@type") {
+			if itd, ok := mapCtx.terms[index]; ok && itd.hasContext {
+				mapCtx = p.processContext(mapCtx, itd.context, itd.baseURL, nil, false, true, true)
+			}
+		} else {
+			mapCtx = active
+		}
+		expIndex, _ := p.expandIRI(active, index, false, true, nil, nil)
+		k := key
+		items := asArray(p.expand(mapCtx, &k, asArray(value[index]), baseURL, frameExpansion, true))
+		for _, item := range items {
+			if td.hasContainer("@graph") && !isGraphObject(item) {
+				item = map[string]interface{}{"@graph": asArray(item)}
+			}
+			m, _ := item.(map[string]interface{})
+			switch {
+			case td.hasContainer("@index") && indexKey != "@index" && expIndex != "@none":
+				reExp := p.expandValue(active, indexKey, index)
+				expIndexKey, _ := p.expandIRI(active, indexKey, false, true, nil, nil)
+				vals := append([]interface{}{reExp}, asArray(m[expIndexKey])...)
+				m[expIndexKey] = vals
+				if isValueObject(m) {
+					jsonldErrorf("invalid value object", "%v", m)
+				}
+			case td.hasContainer("@index") && expIndex != "@none":
+				if _, ok := m["@index"]; !ok {
+					m["@index"] = index
+				}
+			case td.hasContainer("@id") && expIndex != "@none":
+				if _, ok := m["@id"]; !ok {
+					m["@id"], _ = p.expandIRI(active, index, true, false, nil, nil)
+				}
+			case td.hasContainer("@type") && expIndex != "@none":
+				m["@type"] = append([]interface{}{expIndex}, asArray(m["@type"])...)
+			}
+			result = append(result, item)
+		}
+	}
+	if result == nil {
+		result = []interface{}{}
+	}
+	return result
+}
+
+// expandValue implements the Value Expansion algorithm.
+func (p *jsonldProcessor) expandValue(active *jsonldContext, activeProperty string, value interface{}) interface{} {
+	td := active.terms[activeProperty]
+	if s, ok := value.(string); ok && td != nil {
+		switch td.typ {
+		case "@id":
+			id, _ := p.expandIRI(active, s, true, false, nil, nil)
+			return map[string]interface{}{"@id": id}
+		case "@vocab":
+			id, _ := p.expandIRI(active, s, true, true, nil, nil)
+			return map[string]interface{}{"@id": id}
+		}
+	}
+	result := map[string]interface{}{"@value": value}
+	if td != nil && td.typ != "" && td.typ != "@id" && td.typ != "@vocab" && td.typ != "@none" {
+		result["@type"] = td.typ
+	} else if _, ok := value.(string); ok {
+		lang := active.language
+		if td != nil && td.hasLanguage {
+			lang = td.language
+		}
+		dir := active.direction
+		if td != nil && td.hasDirection {
+			dir = td.direction
+		}
+		if lang != "" {
+			result["@language"] = lang
+		}
+		if dir != "" {
+			result["@direction"] = dir
+		}
+	}
+	return result
+}
+
+// Helper functions for JSON-LD processing:
+
+// asArray returns v wrapped in a slice, unless it already is one.
+func asArray(v interface{}) []interface{} {
+	switch a := v.(type) {
+	case []interface{}:
+		return a
+	case nil:
+		return nil
+	default:
+		return []interface{}{v}
+	}
+}
+
+// addValue adds value to the entry key of the object, as an array.
+// If asArr is false and the entry is not present, value is added as is.
+func addValue(obj map[string]interface{}, key string, value interface{}, asArr bool) {
+	if vs, ok := value.([]interface{}); ok {
+		if len(vs) == 0 && asArr {
+			if _, ok := obj[key]; !ok {
+				obj[key] = []interface{}{}
+			}
+		}
+		for _, v := range vs {
+			addValue(obj, key, v, asArr)
+		}
+		return
+	}
+	prev, ok := obj[key]
+	if !ok {
+		if asArr {
+			obj[key] = []interface{}{value}
+		} else {
+			obj[key] = value
+		}
+		return
+	}
+	obj[key] = append(asArray(prev), value)
+}
+
+// sortedKeys returns the keys of the map, sorted lexicographically.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringIn(s string, ss []string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func isValueObject(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = m["@value"]
+	return ok
+}
+
+func isListObject(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = m["@list"]
+	return ok
+}
+
+func isGraphObject(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := m["@graph"]; !ok {
+		return false
+	}
+	for k := range m {
+		switch k {
+		case "@graph", "@id", "@index", "@context":
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isNodeObject(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := m["@value"]; ok {
+		return false
+	}
+	if _, ok := m["@list"]; ok {
+		return false
+	}
+	if _, ok := m["@set"]; ok {
+		return false
+	}
+	return true
+}
+
+// jsonNumber returns the float value of a JSON number.
+func jsonNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// jsonEqual tests if two JSON values are deeply equal. Arrays are compared in order.
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		if f, ok := jsonNumber(a); ok {
+			g, ok := jsonNumber(b)
+			return ok && f == g
+		}
+		return a == b
+	}
+}
+
+// isAbsoluteIRI returns true if the string has an IRI scheme.
+func isAbsoluteIRI(s string) bool {
+	return rgxpIRIScheme.MatchString(s)
+}
+
+// resolveIRI resolves a relative IRI reference against a base IRI,
+// as specified in RFC 3986 section 5.2.
+func resolveIRI(base, ref string) string {
+	if base == "" || isAbsoluteIRI(ref) {
+		return ref
+	}
+	b, r := splitIRI(base), splitIRI(ref)
+	t := r
+	t.scheme = b.scheme
+	if r.auth == nil {
+		t.auth = b.auth
+		switch {
+		case r.path == "":
+			t.path = b.path
+			if r.query == nil {
+				t.query = b.query
+			}
+		case strings.HasPrefix(r.path, "/"):
+			t.path = removeDotSegments(r.path)
+		default:
+			// Merge the reference path with the base path.
+			if b.auth != nil && b.path == "" {
+				t.path = removeDotSegments("/" + r.path)
+			} else {
+				t.path = removeDotSegments(b.path[:strings.LastIndex(b.path, "/")+1] + r.path)
+			}
+		}
+	} else {
+		t.path = removeDotSegments(r.path)
+	}
+	return t.String()
+}
+
+// iriParts are the components of an IRI reference. Optional
+// components which are not present are nil.
+type iriParts struct {
+	scheme, path      string
+	auth, query, frag *string
+}
+
+// splitIRI splits an IRI reference into its components.
+func splitIRI(s string) iriParts {
+	var p iriParts
+	m := rgxpIRIParts.FindStringSubmatchIndex(s)
+	group := func(i int) *string {
+		if m[2*i] < 0 {
+			return nil
+		}
+		x := s[m[2*i]:m[2*i+1]]
+		return &x
+	}
+	if g := group(1); g != nil {
+		p.scheme = *g
+	}
+	p.auth = group(2)
+	p.path = *group(3)
+	p.query = group(4)
+	p.frag = group(5)
+	return p
+}
+
+// String recomposes the IRI reference.
+func (p iriParts) String() string {
+	var buf strings.Builder
+	if p.scheme != "" {
+		buf.WriteString(p.scheme)
+		buf.WriteByte(':')
+	}
+	if p.auth != nil {
+		buf.WriteString("//")
+		buf.WriteString(*p.auth)
+	}
+	buf.WriteString(p.path)
+	if p.query != nil {
+		buf.WriteByte('?')
+		buf.WriteString(*p.query)
+	}
+	if p.frag != nil {
+		buf.WriteByte('#')
+		buf.WriteString(*p.frag)
+	}
+	return buf.String()
+}
+
+// removeDotSegments removes the special "." and ".." segments from a path,
+// as specified in RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	var out []string
+	in := path
+	for len(in) > 0 {
+		switch {
+		case strings.HasPrefix(in, "../"):
+			in = in[3:]
+		case strings.HasPrefix(in, "./"):
+			in = in[2:]
+		case strings.HasPrefix(in, "/./"):
+			in = in[2:]
+		case in == "/.":
+			in = "/"
+		case strings.HasPrefix(in, "/../"):
+			in = in[3:]
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "/..":
+			in = "/"
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		case in == "." || in == "..":
+			in = ""
+		default:
+			i := strings.Index(in[1:], "/")
+			if i < 0 {
+				out = append(out, in)
+				in = ""
+			} else {
+				out = append(out, in[:i+1])
+				in = in[i+1:]
+			}
+		}
+	}
+	return strings.Join(out, "")
+}
+
+// bnodeIssuer issues new blank node identifiers, keeping track of
+// existing identifiers which has been relabeled.
+type bnodeIssuer struct {
+	prefix string
+	n      int
+	issued map[string]string
+	order  []string // existing identifiers in the order they were issued
+}
+
+func newBnodeIssuer(prefix string) *bnodeIssuer {
+	return &bnodeIssuer{prefix: prefix, issued: make(map[string]string)}
+}
+
+// issue returns the new identifier for the given existing identifier, or a
+// fresh identifier if old is "".
+func (bi *bnodeIssuer) issue(old string) string {
+	if old != "" {
+		if id, ok := bi.issued[old]; ok {
+			return id
+		}
+	}
+	id := bi.prefix + strconv.Itoa(bi.n)
+	bi.n++
+	if old != "" {
+		bi.issued[old] = id
+		bi.order = append(bi.order, old)
+	}
+	return id
+}
+
+// canonicalDouble returns the canonical lexical form of a xsd:double.
+func canonicalDouble(f float64) string {
+	if math.IsInf(f, 1) {
+		return "INF"
+	}
+	if math.IsInf(f, -1) {
+		return "-INF"
+	}
+	if math.IsNaN(f) {
+		return "NaN"
+	}
+	s := strconv.FormatFloat(f, 'E', -1, 64)
+	i := strings.IndexByte(s, 'E')
+	mantissa, exp := s[:i], s[i+1:]
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
@@ -0,0 +1,566 @@
+package rdf
+
+import "strings"
+
+// This file implements the JSON-LD 1.1 Framing algorithms, as described in
+// https://www.w3.org/TR/json-ld11-framing/.
+
+// JSONLDFrameOptions are options for FrameJSONLD. The framing flags are the
+// defaults used for frames which do not set them explicitly.
+type JSONLDFrameOptions struct {
+	// Loader retrieves remote contexts. Defaults to an HTTPDocumentLoader.
+	Loader DocumentLoader
+
+	// Base is the base IRI which IRIs are made relative to when compacting.
+	Base IRI
+
+	// UseNativeTypes outputs xsd:boolean, xsd:integer and xsd:double literals
+	// as native JSON booleans and numbers.
+	UseNativeTypes bool
+
+	// Embed is the default @embed flag: "@once" (used when empty), "@always"
+	// or "@never".
+	Embed string
+
+	// Explicit is the default @explicit flag. When set, only the properties
+	// listed in the frame are output.
+	Explicit bool
+
+	// OmitDefault is the default @omitDefault flag. When set, properties of
+	// the frame missing from a node are left out, instead of being output
+	// with their @default value or null.
+	OmitDefault bool
+
+	// RequireAll is the default @requireAll flag. When set, a node must match
+	// all the properties of the frame, instead of any of them.
+	RequireAll bool
+
+	// OmitGraph leaves out the top-level @graph when a single node matches
+	// the frame.
+	OmitGraph bool
+}
+
+// FrameJSONLD shapes the triples into trees of JSON objects, by matching
+// their nodes against the frame document and embedding the nodes they
+// reference. The frame can be any Go value which marshals to a JSON-LD frame,
+// and its @context is used to compact the result. The returned document is
+// deterministic for a given set of triples, and can be passed to
+// json.Marshal.
+func FrameJSONLD(ts []Triple, frame interface{}, opts JSONLDFrameOptions) (doc map[string]interface{}, err error) {
+	defer recoverJSONLD(&err)
+	frame, err = normalizeJSON(frame)
+	if err != nil {
+		return nil, err
+	}
+	qs := make([]Quad, len(ts))
+	for i, t := range ts {
+		qs[i] = Quad{Triple: t}
+	}
+	p := newJSONLDProcessor(opts.Loader)
+	return p.frameDocument(fromRDF(qs, opts.UseNativeTypes, false), frame, opts), nil
+}
+
+// frameDocument implements the Framing API algorithm on an expanded JSON-LD
+// document, and returns the framed document compacted against the context
+// of the frame.
+func (p *jsonldProcessor) frameDocument(input []interface{}, frame interface{}, opts JSONLDFrameOptions) map[string]interface{} {
+	fm, ok := frame.(map[string]interface{})
+	if !ok {
+		jsonldErrorf("invalid frame", "%v", frame)
+	}
+	ctx := fm["@context"]
+	base := opts.Base.str
+	expandedFrame := asArray(p.expand(newJSONLDContext(base), nil, fm, base, true, false))
+
+	// The input only holds the default graph, so it is the same as the
+	// merged graph the algorithm frames by default.
+	nm := nodeMap{"@default": make(map[string]map[string]interface{})}
+	p.generateNodeMap(input, nm, "@default", nil, "", nil)
+
+	embed := opts.Embed
+	if embed == "" {
+		embed = "@once"
+	}
+	s := &framingState{
+		embed:       frameEmbed(map[string]interface{}{"@embed": embed}, ""),
+		explicit:    opts.Explicit,
+		omitDefault: opts.OmitDefault,
+		requireAll:  opts.RequireAll,
+		subjects:    nm["@default"],
+	}
+	top := make(map[string]interface{})
+	s.frame(sortedNodeKeys(s.subjects), subframe(expandedFrame), top, "")
+	framed := asArray(top[""])
+	pruneBlankNodes(framed)
+	for i, v := range framed {
+		framed[i] = cleanupPreserve(v)
+	}
+
+	if m, ok := ctx.(map[string]interface{}); ok {
+		if c, ok := m["@context"]; ok {
+			ctx = c
+		}
+	}
+	active := p.processContext(newJSONLDContext(base), ctx, base, nil, false, true, true)
+	items := asArray(p.compact(active, nil, framed))
+	var result map[string]interface{}
+	if len(items) == 1 && opts.OmitGraph {
+		result = items[0].(map[string]interface{})
+	} else {
+		if items == nil {
+			items = []interface{}{}
+		}
+		result = map[string]interface{}{p.compactIRI(active, "@graph", nil, true, false): items}
+	}
+	for k, v := range result {
+		result[k] = cleanupNull(v)
+	}
+	if !isEmptyContext(ctx) {
+		result["@context"] = ctx
+	}
+	return result
+}
+
+// framingState is the state of the Framing algorithm.
+type framingState struct {
+	embed       string
+	explicit    bool
+	omitDefault bool
+	requireAll  bool
+
+	subjects map[string]map[string]interface{} // node map of the framed graph
+	stack    []string                          // subjects being embedded
+	embedded map[string]bool                   // subjects embedded with @once
+}
+
+// frame implements the Framing algorithm. It matches the subjects against
+// the frame, and adds the output for each match to the property of parent.
+// An empty property means the top level.
+func (s *framingState) frame(subjects []string, frame map[string]interface{}, parent map[string]interface{}, property string) {
+	embed := frameEmbed(frame, s.embed)
+	explicit := frameFlag(frame, "@explicit", s.explicit)
+	requireAll := frameFlag(frame, "@requireAll", s.requireAll)
+
+	for _, id := range subjects {
+		node := s.subjects[id]
+		if node == nil || !s.matches(node, frame, requireAll) {
+			continue
+		}
+		if property == "" {
+			s.embedded = make(map[string]bool)
+		}
+		output := map[string]interface{}{"@id": id}
+		if embed == "@never" || stringIn(id, s.stack) || (embed == "@once" && s.embedded[id]) {
+			addValue(parent, property, output, true)
+			continue
+		}
+		if embed == "@once" {
+			s.embedded[id] = true
+		}
+		s.stack = append(s.stack, id)
+
+		for _, prop := range sortedKeys(node) {
+			if rgxpKeywordForm.MatchString(prop) {
+				output[prop] = cloneJSON(node[prop])
+				continue
+			}
+			if _, ok := frame[prop]; explicit && !ok {
+				continue
+			}
+			for _, o := range asArray(node[prop]) {
+				om, _ := o.(map[string]interface{})
+				sub := s.implicitFrame(embed, explicit, requireAll)
+				if f, ok := frame[prop]; ok {
+					sub = subframe(f)
+				}
+				switch {
+				case isListObject(om):
+					listFrame := s.implicitFrame(embed, explicit, requireAll)
+					if lf, ok := sub["@list"]; ok {
+						listFrame = subframe(lf)
+					}
+					list := map[string]interface{}{"@list": []interface{}{}}
+					addValue(output, prop, list, true)
+					for _, item := range asArray(om["@list"]) {
+						if im, ok := item.(map[string]interface{}); ok && isNodeReference(im) {
+							s.frame([]string{im["@id"].(string)}, listFrame, list, "@list")
+						} else {
+							addValue(list, "@list", cloneJSON(item), true)
+						}
+					}
+				case isNodeReference(om):
+					s.frame([]string{om["@id"].(string)}, sub, output, prop)
+				case valueMatch(sub, om):
+					addValue(output, prop, cloneJSON(o), true)
+				}
+			}
+		}
+
+		for _, prop := range sortedKeys(frame) {
+			if prop == "@type" {
+				if _, ok := output["@type"]; ok {
+					continue
+				}
+				for _, t := range asArray(frame["@type"]) {
+					if tm, ok := t.(map[string]interface{}); ok && hasKey(tm, "@default") {
+						output["@type"] = asArray(cloneJSON(tm["@default"]))
+					}
+				}
+				continue
+			}
+			if rgxpKeywordForm.MatchString(prop) {
+				continue
+			}
+			next := subframe(frame[prop])
+			if _, ok := output[prop]; ok || frameFlag(next, "@omitDefault", s.omitDefault) {
+				continue
+			}
+			var preserve interface{} = "@null"
+			if d, ok := next["@default"]; ok {
+				preserve = cloneJSON(d)
+			}
+			output[prop] = []interface{}{map[string]interface{}{"@preserve": asArray(preserve)}}
+		}
+
+		if rev, ok := frame["@reverse"].(map[string]interface{}); ok {
+			for _, prop := range sortedKeys(rev) {
+				sub := subframe(rev[prop])
+				for _, sid := range sortedNodeKeys(s.subjects) {
+					for _, v := range asArray(s.subjects[sid][prop]) {
+						if vm, ok := v.(map[string]interface{}); ok && vm["@id"] == id {
+							s.frame([]string{sid}, sub, subMap(output, "@reverse"), prop)
+							break
+						}
+					}
+				}
+			}
+		}
+
+		addValue(parent, property, output, true)
+		s.stack = s.stack[:len(s.stack)-1]
+	}
+}
+
+// implicitFrame returns the frame used for properties not in the frame,
+// which matches any node and carries over the flags.
+func (s *framingState) implicitFrame(embed string, explicit, requireAll bool) map[string]interface{} {
+	return map[string]interface{}{"@embed": embed, "@explicit": explicit, "@requireAll": requireAll}
+}
+
+// matches implements the Frame Matching algorithm, returning true if the
+// node matches the frame.
+func (s *framingState) matches(node, frame map[string]interface{}, requireAll bool) bool {
+	wildcard, matchesSome := true, false
+	for _, key := range sortedKeys(frame) {
+		frameValues := asArray(frame[key])
+		nodeValues := asArray(node[key])
+		isEmpty := len(frameValues) == 0
+		matchThis := false
+		switch {
+		case key == "@id":
+			if isEmpty || isEmptyMap(frameValues[0]) {
+				matchThis = true
+			} else {
+				for _, id := range frameValues {
+					matchThis = matchThis || id == node["@id"]
+				}
+			}
+			if !requireAll {
+				return matchThis
+			}
+		case key == "@type":
+			wildcard = false
+			switch {
+			case isEmpty:
+				if len(nodeValues) > 0 {
+					return false
+				}
+				matchThis = true
+			case len(frameValues) == 1 && isEmptyMap(frameValues[0]):
+				matchThis = len(nodeValues) > 0
+			default:
+				for _, t := range frameValues {
+					if tm, ok := t.(map[string]interface{}); ok && hasKey(tm, "@default") {
+						matchThis = true
+					} else {
+						for _, nt := range nodeValues {
+							matchThis = matchThis || nt == t
+						}
+					}
+				}
+				if !requireAll {
+					return matchThis
+				}
+			}
+		case rgxpKeywordForm.MatchString(key):
+			continue
+		default:
+			var propFrame map[string]interface{}
+			if !isEmpty {
+				propFrame = subframe(frameValues)
+			}
+			wildcard = false
+			if len(nodeValues) == 0 && hasKey(propFrame, "@default") {
+				continue
+			}
+			if len(nodeValues) > 0 && isEmpty {
+				return false
+			}
+			switch {
+			case propFrame == nil:
+				matchThis = true
+			case isListObject(propFrame):
+				list, _ := nodeValues[0].(map[string]interface{})
+				if lf := asArray(propFrame["@list"]); isListObject(list) && len(lf) > 0 {
+					pattern, _ := lf[0].(map[string]interface{})
+					for _, item := range asArray(list["@list"]) {
+						im, _ := item.(map[string]interface{})
+						if isValueObject(pattern) {
+							matchThis = matchThis || valueMatch(pattern, im)
+						} else {
+							matchThis = matchThis || s.nodeMatch(pattern, im, requireAll)
+						}
+					}
+				}
+			case isValueObject(propFrame):
+				for _, v := range nodeValues {
+					vm, _ := v.(map[string]interface{})
+					matchThis = matchThis || valueMatch(propFrame, vm)
+				}
+			case isNodeReference(propFrame):
+				for _, v := range nodeValues {
+					vm, _ := v.(map[string]interface{})
+					matchThis = matchThis || s.nodeMatch(propFrame, vm, requireAll)
+				}
+			default:
+				matchThis = len(nodeValues) > 0
+			}
+		}
+		if !matchThis && requireAll {
+			return false
+		}
+		matchesSome = matchesSome || matchThis
+	}
+	return wildcard || matchesSome
+}
+
+// nodeMatch returns true if value references a node matching the frame.
+func (s *framingState) nodeMatch(frame, value map[string]interface{}, requireAll bool) bool {
+	id, ok := value["@id"].(string)
+	if !ok || s.subjects[id] == nil {
+		return false
+	}
+	return s.matches(s.subjects[id], frame, requireAll)
+}
+
+// valueMatch returns true if the value object matches the value pattern.
+// An empty map in the pattern matches any value, and an empty pattern
+// matches any value object.
+func valueMatch(pattern, value map[string]interface{}) bool {
+	v2, t2, l2 := asArray(pattern["@value"]), asArray(pattern["@type"]), asArray(pattern["@language"])
+	if len(v2) == 0 && len(t2) == 0 && len(l2) == 0 {
+		return true
+	}
+	if !isValueObject(value) {
+		return false
+	}
+	v1, t1, l1 := value["@value"], value["@type"], value["@language"]
+	if !containsJSON(v2, v1, false) && (len(v2) == 0 || !isEmptyMap(v2[0])) {
+		return false
+	}
+	if !(t1 == nil && len(t2) == 0) && !containsJSON(t2, t1, false) && (t1 == nil || len(t2) == 0 || !isEmptyMap(t2[0])) {
+		return false
+	}
+	if !(l1 == nil && len(l2) == 0) && !containsJSON(l2, l1, true) && (l1 == nil || len(l2) == 0 || !isEmptyMap(l2[0])) {
+		return false
+	}
+	return true
+}
+
+// containsJSON returns true if vs contains a value equal to v. Strings are
+// compared case-insensitively when fold is set.
+func containsJSON(vs []interface{}, v interface{}, fold bool) bool {
+	for _, x := range vs {
+		if a, ok := x.(string); ok && fold {
+			if b, ok := v.(string); ok && strings.EqualFold(a, b) {
+				return true
+			}
+		} else if jsonEqual(x, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// subframe returns the frame held by the value of a frame entry: the first
+// frame of an array, or an empty frame if there is none.
+func subframe(v interface{}) map[string]interface{} {
+	vs := asArray(v)
+	if len(vs) == 0 {
+		return map[string]interface{}{}
+	}
+	m, ok := vs[0].(map[string]interface{})
+	if !ok {
+		jsonldErrorf("invalid frame", "%v", v)
+	}
+	return m
+}
+
+// frameFlag returns the value of a boolean framing flag of the frame, or
+// def if it is not set.
+func frameFlag(frame map[string]interface{}, flag string, def bool) bool {
+	switch v := frameFlagValue(frame, flag).(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return def
+}
+
+// frameEmbed returns the @embed flag of the frame, or def if it is not set.
+func frameEmbed(frame map[string]interface{}, def string) string {
+	switch v := frameFlagValue(frame, "@embed").(type) {
+	case nil:
+		return def
+	case bool:
+		if v {
+			return "@once"
+		}
+		return "@never"
+	case string:
+		switch v {
+		case "@always", "@once", "@never":
+			return v
+		}
+	}
+	jsonldErrorf("invalid @embed value", "%v", frame["@embed"])
+	return ""
+}
+
+// frameFlagValue returns the value of a framing flag, unwrapping arrays
+// and value objects.
+func frameFlagValue(frame map[string]interface{}, flag string) interface{} {
+	v := frame[flag]
+	if a, ok := v.([]interface{}); ok {
+		if len(a) == 0 {
+			return nil
+		}
+		v = a[0]
+	}
+	if m, ok := v.(map[string]interface{}); ok && isValueObject(m) {
+		v = m["@value"]
+	}
+	return v
+}
+
+// pruneBlankNodes removes the @id of the nodes identified by a blank node
+// which is not referenced anywhere else in the framed output.
+func pruneBlankNodes(framed []interface{}) {
+	counts := make(map[string]int)
+	walkNodes(framed, func(m map[string]interface{}) {
+		if id, ok := m["@id"].(string); ok && strings.HasPrefix(id, "_:") {
+			counts[id]++
+		}
+		for _, t := range asArray(m["@type"]) {
+			if s, ok := t.(string); ok && strings.HasPrefix(s, "_:") {
+				counts[s]++
+			}
+		}
+	})
+	walkNodes(framed, func(m map[string]interface{}) {
+		if id, ok := m["@id"].(string); ok && counts[id] == 1 {
+			delete(m, "@id")
+		}
+	})
+}
+
+// walkNodes calls fn for each map in v, outside of value objects.
+func walkNodes(v interface{}, fn func(map[string]interface{})) {
+	switch x := v.(type) {
+	case []interface{}:
+		for _, item := range x {
+			walkNodes(item, fn)
+		}
+	case map[string]interface{}:
+		if isValueObject(x) || hasKey(x, "@preserve") {
+			return
+		}
+		fn(x)
+		for _, k := range sortedKeys(x) {
+			walkNodes(x[k], fn)
+		}
+	}
+}
+
+// cleanupPreserve replaces the @preserve entries added for default values
+// with the values they hold.
+func cleanupPreserve(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		for i, item := range x {
+			x[i] = cleanupPreserve(item)
+		}
+	case map[string]interface{}:
+		if p, ok := x["@preserve"]; ok {
+			return asArray(p)[0]
+		}
+		if isValueObject(x) {
+			return x
+		}
+		for k, item := range x {
+			x[k] = cleanupPreserve(item)
+		}
+	}
+	return v
+}
+
+// cleanupNull replaces the "@null" default values with null, removing them
+// from arrays.
+func cleanupNull(v interface{}) interface{} {
+	switch x := v.(type) {
+	case string:
+		if x == "@null" {
+			return nil
+		}
+	case []interface{}:
+		result := make([]interface{}, 0, len(x))
+		for _, item := range x {
+			if c := cleanupNull(item); c != nil {
+				result = append(result, c)
+			}
+		}
+		return result
+	case map[string]interface{}:
+		for k, item := range x {
+			x[k] = cleanupNull(item)
+		}
+	}
+	return v
+}
+
+// cloneJSON returns a deep copy of a JSON value.
+func cloneJSON(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		result := make([]interface{}, len(x))
+		for i, item := range x {
+			result[i] = cloneJSON(item)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(x))
+		for k, item := range x {
+			result[k] = cloneJSON(item)
+		}
+		return result
+	}
+	return v
+}
+
+// isEmptyMap returns true if v is a map without entries, which is a
+// wildcard in frames.
+func isEmptyMap(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	return ok && len(m) == 0
+}
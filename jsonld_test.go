@@ -0,0 +1,823 @@
+package rdf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// jsonldTestLoader serves remote contexts to the tests without network access.
+var jsonldTestLoader = DocumentLoaderFunc(func(url string) (interface{}, error) {
+	switch url {
+	case "http://example.org/context.jsonld":
+		return decodeJSON(strings.NewReader(`{"@context": {"name": "http://xmlns.com/foaf/0.1/name"}}`))
+	case "http://example.org/import.jsonld":
+		return decodeJSON(strings.NewReader(`{"@context": {"@vocab": "http://example.org/vocab#"}}`))
+	case "http://example.org/nocontext.jsonld":
+		return decodeJSON(strings.NewReader(`{"name": "x"}`))
+	}
+	return nil, errors.New("not found")
+})
+
+var jsonldTestSuite = []struct {
+	input   string
+	errWant string
+	want    string // N-Quads
+}{
+	// #0 plain node with literal property
+	{`{"@id": "http://example.org/a", "http://example.org/p": "v"}`, "",
+		`<http://example.org/a> <http://example.org/p> "v" .`},
+
+	// #1 terms and compact IRIs
+	{`{
+		"@context": {"ex": "http://example.org/", "name": "ex:name"},
+		"@id": "ex:a",
+		"@type": "ex:Person",
+		"name": "Alice"
+	}`, "",
+		`<http://example.org/a> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Person> .
+<http://example.org/a> <http://example.org/name> "Alice" .`},
+
+	// #2 type coercion
+	{`{
+		"@context": {
+			"ex": "http://example.org/",
+			"xsd": "http://www.w3.org/2001/XMLSchema#",
+			"knows": {"@id": "ex:knows", "@type": "@id"},
+			"age": {"@id": "ex:age", "@type": "xsd:integer"},
+			"kind": {"@id": "ex:kind", "@type": "@vocab"},
+			"@vocab": "http://example.org/vocab#"
+		},
+		"@id": "ex:a",
+		"knows": "ex:b",
+		"age": "42",
+		"kind": "Friendly"
+	}`, "",
+		`<http://example.org/a> <http://example.org/age> "42"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/a> <http://example.org/kind> <http://example.org/vocab#Friendly> .
+<http://example.org/a> <http://example.org/knows> <http://example.org/b> .`},
+
+	// #3 native types
+	{`{
+		"@id": "http://example.org/a",
+		"http://example.org/p": [true, 1, 1.5, 1.0, 1e21, 12345678901234567890123]
+	}`, "",
+		`<http://example.org/a> <http://example.org/p> "true"^^<http://www.w3.org/2001/XMLSchema#boolean> .
+<http://example.org/a> <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/a> <http://example.org/p> "1.5E0"^^<http://www.w3.org/2001/XMLSchema#double> .
+<http://example.org/a> <http://example.org/p> "1.0E21"^^<http://www.w3.org/2001/XMLSchema#double> .
+<http://example.org/a> <http://example.org/p> "1.2345678901234568E22"^^<http://www.w3.org/2001/XMLSchema#double> .`},
+
+	// #4 default language, language maps and null language
+	{`{
+		"@context": {
+			"@vocab": "http://example.org/",
+			"@language": "en",
+			"label": {"@container": "@language"},
+			"code": {"@language": null}
+		},
+		"@id": "http://example.org/a",
+		"title": "Title",
+		"code": "X1",
+		"label": {"de": "Etikett", "fr": ["étiquette"], "@none": "label"}
+	}`, "",
+		`<http://example.org/a> <http://example.org/code> "X1" .
+<http://example.org/a> <http://example.org/label> "Etikett"@de .
+<http://example.org/a> <http://example.org/label> "étiquette"@fr .
+<http://example.org/a> <http://example.org/label> "label" .
+<http://example.org/a> <http://example.org/title> "Title"@en .`},
+
+	// #5 lists
+	{`{
+		"@context": {"@vocab": "http://example.org/", "items": {"@container": "@list"}},
+		"@id": "http://example.org/a",
+		"items": ["x", {"@id": "http://example.org/b"}],
+		"empty": {"@list": []}
+	}`, "",
+		`<http://example.org/a> <http://example.org/empty> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+<http://example.org/a> <http://example.org/items> _:b0 .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> "x" .
+_:b0 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:b1 .
+_:b1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> <http://example.org/b> .
+_:b1 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .`},
+
+	// #6 reverse properties
+	{`{
+		"@context": {"@vocab": "http://example.org/", "parent": {"@reverse": "http://example.org/child"}},
+		"@id": "http://example.org/a",
+		"parent": {"@id": "http://example.org/b"},
+		"@reverse": {"http://example.org/knows": {"@id": "http://example.org/c"}}
+	}`, "",
+		`<http://example.org/b> <http://example.org/child> <http://example.org/a> .
+<http://example.org/c> <http://example.org/knows> <http://example.org/a> .`},
+
+	// #7 named graphs and blank nodes
+	{`{
+		"@context": {"@vocab": "http://example.org/"},
+		"@graph": [
+			{"@id": "_:x", "p": "default"},
+			{"@id": "http://example.org/g", "@graph": {"p": {"@id": "_:x"}}}
+		]
+	}`, "",
+		`_:b0 <http://example.org/p> "default" .
+_:b1 <http://example.org/p> _:b0 <http://example.org/g> .`},
+
+	// #8 relative IRIs against the document base, and @base
+	{`{
+		"@context": {"@base": "http://example.org/base/"},
+		"@id": "../a",
+		"http://example.org/p": {"@id": "b#frag"}
+	}`, "",
+		`<http://example.org/a> <http://example.org/p> <http://example.org/base/b#frag> .`},
+
+	// #9 relative IRIs without base are dropped
+	{`{"@id": "a", "http://example.org/p": "v", "rel": "dropped"}`, "", ``},
+
+	// #10 JSON literals
+	{`{
+		"@context": {"data": {"@id": "http://example.org/data", "@type": "@json"}},
+		"@id": "http://example.org/a",
+		"data": {"b": [1.0, "x<"], "a": null}
+	}`, "",
+		`<http://example.org/a> <http://example.org/data> "{\"a\":null,\"b\":[1,\"x<\"]}"^^<http://www.w3.org/1999/02/22-rdf-syntax-ns#JSON> .`},
+
+	// #11 remote contexts and @import
+	{`{
+		"@context": ["http://example.org/context.jsonld", {"@import": "http://example.org/import.jsonld"}],
+		"@id": "http://example.org/a",
+		"name": "Alice",
+		"age": 7
+	}`, "",
+		`<http://example.org/a> <http://example.org/vocab#age> "7"^^<http://www.w3.org/2001/XMLSchema#integer> .
+<http://example.org/a> <http://xmlns.com/foaf/0.1/name> "Alice" .`},
+
+	// #12 property-scoped and type-scoped contexts
+	{`{
+		"@context": {
+			"@vocab": "http://example.org/",
+			"Person": {"@context": {"name": "http://xmlns.com/foaf/0.1/name"}},
+			"address": {"@context": {"@vocab": "http://example.org/address#"}}
+		},
+		"@id": "http://example.org/a",
+		"@type": "Person",
+		"name": "Alice",
+		"address": {"city": "Oslo"}
+	}`, "",
+		`<http://example.org/a> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Person> .
+<http://example.org/a> <http://example.org/address> _:b0 .
+<http://example.org/a> <http://xmlns.com/foaf/0.1/name> "Alice" .
+_:b0 <http://example.org/address#city> "Oslo" .`},
+
+	// #13 index, id and type maps
+	{`{
+		"@context": {
+			"@vocab": "http://example.org/",
+			"byIndex": {"@container": "@index"},
+			"byID": {"@container": "@id"},
+			"byType": {"@container": "@type"}
+		},
+		"@id": "http://example.org/a",
+		"byIndex": {"one": "1"},
+		"byID": {"http://example.org/b": {"p": "b"}},
+		"byType": {"T": {"@id": "http://example.org/c"}}
+	}`, "",
+		`<http://example.org/a> <http://example.org/byID> <http://example.org/b> .
+<http://example.org/a> <http://example.org/byIndex> "1" .
+<http://example.org/a> <http://example.org/byType> <http://example.org/c> .
+<http://example.org/b> <http://example.org/p> "b" .
+<http://example.org/c> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/T> .`},
+
+	// #14 nested properties and @included
+	{`{
+		"@context": {"@vocab": "http://example.org/", "meta": "@nest"},
+		"@id": "http://example.org/a",
+		"meta": {"p": "nested"},
+		"@included": [{"@id": "http://example.org/b", "q": "included"}]
+	}`, "",
+		`<http://example.org/a> <http://example.org/p> "nested" .
+<http://example.org/b> <http://example.org/q> "included" .`},
+
+	// #15 typed values and value objects
+	{`{
+		"@id": "http://example.org/a",
+		"http://example.org/p": [
+			{"@value": "2020-01-01", "@type": "http://www.w3.org/2001/XMLSchema#date"},
+			{"@value": "hei", "@language": "nb"},
+			{"@value": 5, "@type": "http://www.w3.org/2001/XMLSchema#double"},
+			{"@value": null}
+		]
+	}`, "",
+		`<http://example.org/a> <http://example.org/p> "2020-01-01"^^<http://www.w3.org/2001/XMLSchema#date> .
+<http://example.org/a> <http://example.org/p> "hei"@nb .
+<http://example.org/a> <http://example.org/p> "5.0E0"^^<http://www.w3.org/2001/XMLSchema#double> .`},
+
+	// Negative tests:
+
+	// #16
+	{`{"@context": 1, "http://example.org/p": "v"}`, "invalid local context: 1", ""},
+	// #17
+	{`{"@context": {"a": "b:c", "b": "a:c"}, "a": "v"}`, "cyclic IRI mapping: a", ""},
+	// #18
+	{`{"@context": "http://example.org/missing.jsonld"}`,
+		"loading remote context failed: http://example.org/missing.jsonld: not found", ""},
+	// #19
+	{`{"@context": "http://example.org/nocontext.jsonld"}`,
+		"invalid remote context: http://example.org/nocontext.jsonld: no @context entry", ""},
+	// #20
+	{`{"@context": {"@id": "http://example.org/"}}`, "keyword redefinition: @id", ""},
+	// #21
+	{`{"@id": "http://example.org/a", "http://example.org/p": {"@value": "x", "@type": "http://example.org/t", "@language": "en"}}`,
+		"invalid value object: both @type and @language or @direction", ""},
+	// #22
+	{`{"@context": {"@vocab": "http://example.org/", "p": {"@container": ["@list", "@set"]}}}`,
+		"invalid container mapping: [@list @set]", ""},
+	// #23
+	{`{"@context": [{"@protected": true, "p": "http://example.org/p"}, {"p": "http://example.org/q"}]}`,
+		"protected term redefinition: p", ""},
+	// #24
+	{`{"@id": "http://example.org/a", "@reverse": {"http://example.org/p": "v"}}`,
+		`invalid reverse property value: map[@value:v]`, ""},
+	// #25
+	{`{"@id": "http://example.org/a", "@type": 1}`, "invalid type value: 1", ""},
+}
+
+// sortedNQuads serializes the quads as N-Quads, one statement per line in
+// sorted order.
+func sortedNQuads(qs []Quad) string {
+	lines := make([]string, 0, len(qs))
+	for _, q := range qs {
+		if TermsEqual(q.Ctx, defaultGraph) {
+			lines = append(lines, q.Triple.Serialize(NQuads))
+		} else {
+			lines = append(lines, q.Serialize(NQuads))
+		}
+	}
+	return sortLines(strings.Join(lines, ""))
+}
+
+// sortLines returns the non-empty lines of s in sorted order.
+func sortLines(s string) string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestJSONLD(t *testing.T) {
+	for i, test := range jsonldTestSuite {
+		dec := NewQuadDecoder(bytes.NewBufferString(test.input), JSONLD)
+		if err := dec.SetOption(Loader, jsonldTestLoader); err != nil {
+			t.Fatal(err)
+		}
+		quads, err := dec.DecodeAll()
+		if test.errWant != "" && err == nil {
+			t.Errorf("#%d: decode JSON-LD => <no error>, want %q", i, test.errWant)
+			continue
+		}
+
+		if test.errWant != "" && err != nil {
+			if !strings.HasSuffix(err.Error(), test.errWant) {
+				t.Errorf("#%d: decode JSON-LD => %v, want %q", i, err.Error(), test.errWant)
+			}
+			continue
+		}
+
+		if test.errWant == "" && err != nil {
+			t.Errorf("#%d: decode JSON-LD => %v, want %v", i, err.Error(), test.want)
+			continue
+		}
+
+		if got, want := sortedNQuads(quads), sortLines(test.want); got != want {
+			t.Errorf("#%d: decode JSON-LD =>\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+func TestJSONLDTriples(t *testing.T) {
+	input := `{
+		"@context": {"@vocab": "http://example.org/"},
+		"@id": "http://example.org/g",
+		"p": "in default graph",
+		"@graph": {"@id": "http://example.org/a", "p": "in named graph"}
+	}`
+	dec := NewTripleDecoder(bytes.NewBufferString(input), JSONLD)
+	triples, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Triple{
+		Triple{
+			Subj: IRI{str: "http://example.org/g"},
+			Pred: IRI{str: "http://example.org/p"},
+			Obj:  Literal{str: "in default graph", DataType: xsdString},
+		},
+	}
+	if len(triples) != 1 || !TriplesEqual(triples[0], want[0]) {
+		t.Errorf("got %v, want %v", triples, want)
+	}
+
+	dec = NewTripleDecoder(bytes.NewBufferString(`{"@id": "a", "http://example.org/p": {"@id": "b"}}`), JSONLD)
+	if err := dec.SetOption(Base, IRI{str: "http://example.org/"}); err != nil {
+		t.Fatal(err)
+	}
+	triples, err = dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(triples) != 1 || triples[0].Subj != (IRI{str: "http://example.org/a"}) ||
+		triples[0].Obj != (IRI{str: "http://example.org/b"}) {
+		t.Errorf("got %v, want relative IRIs resolved against base", triples)
+	}
+
+	if err := dec.SetOption(Loader, "not a loader"); err == nil {
+		t.Errorf("SetOption(Loader, string) => <no error>, want error")
+	}
+}
+
+func TestResolveIRI(t *testing.T) {
+	// Examples from RFC 3986, section 5.4.
+	base := "http://a/b/c/d;p?q"
+	tests := []struct{ ref, want string }{
+		{"g:h", "g:h"},
+		{"g", "http://a/b/c/g"},
+		{"./g", "http://a/b/c/g"},
+		{"g/", "http://a/b/c/g/"},
+		{"/g", "http://a/g"},
+		{"//g", "http://g"},
+		{"?y", "http://a/b/c/d;p?y"},
+		{"g?y", "http://a/b/c/g?y"},
+		{"#s", "http://a/b/c/d;p?q#s"},
+		{"g#s", "http://a/b/c/g#s"},
+		{"g?y#s", "http://a/b/c/g?y#s"},
+		{";x", "http://a/b/c/;x"},
+		{"", "http://a/b/c/d;p?q"},
+		{".", "http://a/b/c/"},
+		{"./", "http://a/b/c/"},
+		{"..", "http://a/b/"},
+		{"../g", "http://a/b/g"},
+		{"../..", "http://a/"},
+		{"../../g", "http://a/g"},
+		{"../../../g", "http://a/g"},
+		{"/./g", "http://a/g"},
+		{"/../g", "http://a/g"},
+		{"g.", "http://a/b/c/g."},
+		{"..g", "http://a/b/c/..g"},
+		{"./../g", "http://a/b/g"},
+		{"g;x=1/../y", "http://a/b/c/y"},
+		{"g#s/../x", "http://a/b/c/g#s/../x"},
+	}
+	for _, test := range tests {
+		if got := resolveIRI(base, test.ref); got != test.want {
+			t.Errorf("resolveIRI(%q, %q) => %q, want %q", base, test.ref, got, test.want)
+		}
+	}
+}
+
+func TestEncodeJSONLD(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+@prefix foaf: <http://xmlns.com/foaf/0.1/> .
+ex:alice a foaf:Person ;
+	foaf:name "Alice" , "Alicia"@es ;
+	foaf:age 42 ;
+	foaf:knows ex:bob , _:c ;
+	ex:list ( "a" ex:b ) .
+_:c foaf:name "Carol" .
+`
+	ctx := map[string]interface{}{
+		"ex":    "http://example.org/",
+		"foaf":  "http://xmlns.com/foaf/0.1/",
+		"knows": map[string]interface{}{"@id": "foaf:knows", "@type": "@id"},
+		"name":  "foaf:name",
+	}
+
+	tests := []struct {
+		opts JSONLDOptions
+		want string
+	}{
+		{
+			JSONLDOptions{},
+			`[
+	{
+		"@id": "_:c",
+		"http://xmlns.com/foaf/0.1/name": [
+			{
+				"@value": "Carol"
+			}
+		]
+	},
+	{
+		"@id": "http://example.org/alice",
+		"@type": [
+			"http://xmlns.com/foaf/0.1/Person"
+		],
+		"http://example.org/list": [
+			{
+				"@list": [
+					{
+						"@value": "a"
+					},
+					{
+						"@id": "http://example.org/b"
+					}
+				]
+			}
+		],
+		"http://xmlns.com/foaf/0.1/age": [
+			{
+				"@type": "http://www.w3.org/2001/XMLSchema#integer",
+				"@value": "42"
+			}
+		],
+		"http://xmlns.com/foaf/0.1/knows": [
+			{
+				"@id": "http://example.org/bob"
+			},
+			{
+				"@id": "_:c"
+			}
+		],
+		"http://xmlns.com/foaf/0.1/name": [
+			{
+				"@value": "Alice"
+			},
+			{
+				"@language": "es",
+				"@value": "Alicia"
+			}
+		]
+	}
+]
+`,
+		},
+		{
+			JSONLDOptions{Context: ctx, UseNativeTypes: true},
+			`{
+	"@context": {
+		"ex": "http://example.org/",
+		"foaf": "http://xmlns.com/foaf/0.1/",
+		"knows": {
+			"@id": "foaf:knows",
+			"@type": "@id"
+		},
+		"name": "foaf:name"
+	},
+	"@graph": [
+		{
+			"@id": "_:c",
+			"name": "Carol"
+		},
+		{
+			"@id": "ex:alice",
+			"@type": "foaf:Person",
+			"ex:list": {
+				"@list": [
+					"a",
+					{
+						"@id": "ex:b"
+					}
+				]
+			},
+			"foaf:age": 42,
+			"knows": [
+				"ex:bob",
+				"_:c"
+			],
+			"name": [
+				"Alice",
+				{
+					"@language": "es",
+					"@value": "Alicia"
+				}
+			]
+		}
+	]
+}
+`,
+		},
+		{
+			// Remote context, relative IRIs and language maps.
+			JSONLDOptions{
+				Context: []interface{}{
+					"http://example.org/context.jsonld",
+					map[string]interface{}{
+						"@vocab": "http://xmlns.com/foaf/0.1/",
+						"names":  map[string]interface{}{"@id": "name", "@container": "@language"},
+					},
+				},
+				Loader: jsonldTestLoader,
+				Base:   IRI{str: "http://example.org/"},
+			},
+			`{
+	"@context": [
+		"http://example.org/context.jsonld",
+		{
+			"@vocab": "http://xmlns.com/foaf/0.1/",
+			"names": {
+				"@container": "@language",
+				"@id": "name"
+			}
+		}
+	],
+	"@graph": [
+		{
+			"@id": "_:c",
+			"name": "Carol"
+		},
+		{
+			"@id": "alice",
+			"@type": "Person",
+			"age": {
+				"@type": "http://www.w3.org/2001/XMLSchema#integer",
+				"@value": "42"
+			},
+			"http://example.org/list": {
+				"@list": [
+					"a",
+					{
+						"@id": "b"
+					}
+				]
+			},
+			"knows": [
+				{
+					"@id": "bob"
+				},
+				{
+					"@id": "_:c"
+				}
+			],
+			"name": "Alice",
+			"names": {
+				"es": "Alicia"
+			}
+		}
+	]
+}
+`,
+		},
+	}
+
+	for i, test := range tests {
+		ts, err := NewTripleDecoder(bytes.NewBufferString(input), Turtle).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		enc := NewTripleEncoder(&buf, JSONLD)
+		enc.JSONLDOptions = test.opts
+		if err := enc.EncodeAll(ts); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("#%d: encoding JSON-LD:\ngot:\n%s\nwant:\n%s", i, buf.String(), test.want)
+		}
+
+		// Decoding the output must yield the same graph.
+		dec := NewQuadDecoder(bytes.NewReader(buf.Bytes()), JSONLD)
+		dec.SetOption(Loader, jsonldTestLoader)
+		dec.SetOption(Base, test.opts.Base)
+		back, err := dec.DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var orig []Quad
+		for _, t := range ts {
+			orig = append(orig, Quad{Triple: t, Ctx: defaultGraph})
+		}
+		if got, want := stripBlanks(sortedNQuads(back)), stripBlanks(sortedNQuads(orig)); got != want {
+			t.Errorf("#%d: roundtrip JSON-LD:\ngot:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
+
+func TestEncodeJSONLDQuads(t *testing.T) {
+	input := `<http://example.org/a> <http://example.org/p> "default" .
+<http://example.org/a> <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/g> <http://example.org/p> <http://example.org/a> .
+`
+	qs, err := NewQuadDecoder(bytes.NewBufferString(input), NQuads).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := NewQuadEncoder(&buf, JSONLD)
+	enc.JSONLDOptions.Context = "http://example.org/nocontext.jsonld"
+	enc.JSONLDOptions.Loader = jsonldTestLoader
+	if err := enc.EncodeAll(qs); err != nil {
+		t.Fatal(err)
+	}
+	errWant := "invalid remote context: http://example.org/nocontext.jsonld: no @context entry"
+	if err := enc.Close(); err == nil || err.Error() != errWant {
+		t.Errorf("Close() => %v, want %q", err, errWant)
+	}
+
+	buf.Reset()
+	enc = NewQuadEncoder(&buf, JSONLD)
+	enc.JSONLDOptions.Context = map[string]string{"@vocab": "http://example.org/"}
+	enc.JSONLDOptions.UseNativeTypes = true
+	for _, q := range qs {
+		if err := enc.Encode(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+	"@context": {
+		"@vocab": "http://example.org/"
+	},
+	"@graph": [
+		{
+			"@id": "http://example.org/a",
+			"p": "default"
+		},
+		{
+			"@graph": [
+				{
+					"@id": "http://example.org/a",
+					"p": 1
+				}
+			],
+			"@id": "http://example.org/g",
+			"p": {
+				"@id": "http://example.org/a"
+			}
+		}
+	]
+}
+`
+	if buf.String() != want {
+		t.Errorf("encoding JSON-LD quads:\ngot:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	back, err := NewQuadDecoder(bytes.NewReader(buf.Bytes()), JSONLD).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedNQuads(back), sortedNQuads(qs); got != want {
+		t.Errorf("roundtrip JSON-LD quads:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// stripBlanks replaces all blank node labels with _:.
+func stripBlanks(s string) string {
+	return sortLines(rgxpTestBlank.ReplaceAllString(s, "_:"))
+}
+
+var rgxpTestBlank = regexp.MustCompile(`_:[a-zA-Z0-9]+`)
+
+func TestFrameJSONLD(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+ex:lib a ex:Library ;
+	ex:contains ex:dune , ex:emma .
+ex:dune a ex:Book ;
+	ex:title "Dune" ;
+	ex:publisher _:p .
+ex:emma a ex:Book ;
+	ex:title "Emma" ;
+	ex:year 1815 ;
+	ex:publisher _:p .
+_:p ex:name "Chilton" .
+`
+	ctx := `"@context": {"@vocab": "http://example.org/"}`
+	ts, err := NewTripleDecoder(bytes.NewBufferString(input), Turtle).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		frame   string
+		opts    JSONLDFrameOptions
+		errWant string
+		want    string
+	}{
+		{
+			// Nodes are embedded once per top-level node (@once).
+			`{` + ctx + `, "@type": "Library"}`,
+			JSONLDFrameOptions{UseNativeTypes: true, OmitGraph: true},
+			"",
+			`{` + ctx + `, "@id": "http://example.org/lib", "@type": "Library", "contains": [
+				{"@id": "http://example.org/dune", "@type": "Book", "title": "Dune", "publisher": {"@id": "_:b0", "name": "Chilton"}},
+				{"@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815, "publisher": {"@id": "_:b0"}}]}`,
+		},
+		{
+			`{` + ctx + `, "@type": "Library", "@embed": "@always"}`,
+			JSONLDFrameOptions{UseNativeTypes: true},
+			"",
+			`{` + ctx + `, "@graph": [{"@id": "http://example.org/lib", "@type": "Library", "contains": [
+				{"@id": "http://example.org/dune", "@type": "Book", "title": "Dune", "publisher": {"@id": "_:b0", "name": "Chilton"}},
+				{"@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815, "publisher": {"@id": "_:b0", "name": "Chilton"}}]}]}`,
+		},
+		{
+			`{` + ctx + `, "@type": "Library", "contains": {"@embed": "@never"}}`,
+			JSONLDFrameOptions{},
+			"",
+			`{` + ctx + `, "@graph": [{"@id": "http://example.org/lib", "@type": "Library", "contains": [
+				{"@id": "http://example.org/dune"}, {"@id": "http://example.org/emma"}]}]}`,
+		},
+		{
+			// Missing properties are output as null.
+			`{` + ctx + `, "@type": "Book", "@explicit": true, "title": {}, "year": {}}`,
+			JSONLDFrameOptions{UseNativeTypes: true},
+			"",
+			`{` + ctx + `, "@graph": [
+				{"@id": "http://example.org/dune", "@type": "Book", "title": "Dune", "year": null},
+				{"@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815}]}`,
+		},
+		{
+			`{` + ctx + `, "@type": "Book", "title": {}, "year": {"@default": 0}}`,
+			JSONLDFrameOptions{UseNativeTypes: true, Explicit: true},
+			"",
+			`{` + ctx + `, "@graph": [
+				{"@id": "http://example.org/dune", "@type": "Book", "title": "Dune", "year": 0},
+				{"@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815}]}`,
+		},
+		{
+			`{` + ctx + `, "@type": "Book", "@explicit": true, "title": {}, "year": {"@omitDefault": true}}`,
+			JSONLDFrameOptions{UseNativeTypes: true},
+			"",
+			`{` + ctx + `, "@graph": [
+				{"@id": "http://example.org/dune", "@type": "Book", "title": "Dune"},
+				{"@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815}]}`,
+		},
+		{
+			// Unreferenced blank node identifiers are pruned.
+			`{` + ctx + `, "@requireAll": true, "title": {}, "year": {}}`,
+			JSONLDFrameOptions{UseNativeTypes: true},
+			"",
+			`{` + ctx + `, "@graph": [
+				{"@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815, "publisher": {"name": "Chilton"}}]}`,
+		},
+		{
+			`{` + ctx + `, "title": {}, "year": {}}`,
+			JSONLDFrameOptions{UseNativeTypes: true, Explicit: true, RequireAll: true, OmitGraph: true},
+			"",
+			`{` + ctx + `, "@id": "http://example.org/emma", "@type": "Book", "title": "Emma", "year": 1815}`,
+		},
+		{
+			`{` + ctx + `, "@type": "Missing"}`,
+			JSONLDFrameOptions{},
+			"",
+			`{` + ctx + `, "@graph": []}`,
+		},
+		{
+			`{` + ctx + `, "@embed": "@sometimes"}`,
+			JSONLDFrameOptions{},
+			"invalid @embed value: @sometimes",
+			"",
+		},
+		{
+			`{}`,
+			JSONLDFrameOptions{Embed: "@last"},
+			"invalid @embed value: @last",
+			"",
+		},
+		{
+			`[]`,
+			JSONLDFrameOptions{},
+			"invalid frame: []",
+			"",
+		},
+	}
+
+	for i, test := range tests {
+		var frame interface{}
+		if err := json.Unmarshal([]byte(test.frame), &frame); err != nil {
+			t.Fatal(err)
+		}
+		doc, err := FrameJSONLD(ts, frame, test.opts)
+		if err != nil {
+			if test.errWant == "" {
+				t.Errorf("#%d: FrameJSONLD failed: %v", i, err)
+			} else if err.Error() != test.errWant {
+				t.Errorf("#%d: FrameJSONLD error:\ngot:  %v\nwant: %v", i, err, test.errWant)
+			}
+			continue
+		}
+		if test.errWant != "" {
+			t.Errorf("#%d: FrameJSONLD: expected error %q", i, test.errWant)
+			continue
+		}
+		var want interface{}
+		if err := json.Unmarshal([]byte(test.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal(doc)
+		wantJSON, _ := json.Marshal(want)
+		if string(got) != string(wantJSON) {
+			t.Errorf("#%d: framing JSON-LD:\ngot:\n%s\nwant:\n%s", i, got, wantJSON)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
 type tokenType int
@@ -44,6 +45,16 @@ const (
 	tokenPropertyListEnd   // ']'
 	tokenCollectionStart   // '('
 	tokenCollectionEnd     // ')'
+
+	// trig tokens
+	tokenGraphStart  // '{'
+	tokenGraphEnd    // '}'
+	tokenSparqlGraph // GRAPH
+
+	// sparql tokens
+	tokenVariable // ?name or $name
+	tokenKeyword  // keyword or function name, such as SELECT or STR
+	tokenSymbol   // punctuation and operators, such as '{', '&&' or '<='
 )
 
 const eof = -1
@@ -66,7 +77,8 @@ type token struct {
 // stateFn represents the state of the lexer as a function that returns the next state.
 type stateFn func(*lexer) stateFn
 
-// lexer for trig/turtle (and their line-based subsets n-triples & n-quads).
+// lexer for trig/turtle (and their line-based subsets n-triples & n-quads),
+// and SPARQL.
 //
 // Tokens for whitespace and comments are not not emitted.
 //
@@ -76,15 +88,23 @@ type stateFn func(*lexer) stateFn
 type lexer struct {
 	rdr *bufio.Reader
 
-	input    []byte     // the input being scanned (should not inlcude newlines)
-	lineMode bool       // true when lexing line-based formats (N-Triples & N-Quads)
-	unEsc    bool       // true when current token needs to be unescaped
-	state    stateFn    // the next lexing function to enter
-	line     int        // the current line number
-	pos      int        // the current position in input
-	width    int        // width of the last rune read from input
-	start    int        // start of current token
-	tokens   chan token // channel of scanned tokens
+	input      []byte     // the input being scanned (should not inlcude newlines)
+	lineMode   bool       // true when lexing line-based formats (N-Triples & N-Quads)
+	trigMode   bool       // true when lexing TriG (allows graph blocks and GRAPH keyword)
+	sparqlMode bool       // true when lexing SPARQL (adds variables, keywords and operators)
+	unEsc      bool       // true when current token needs to be unescaped
+	state      stateFn    // the next lexing function to enter
+	line       int        // the current line number
+	pos        int        // the current position in input
+	width      int        // width of the last rune read from input
+	start      int        // start of current token
+	tokens     chan token // channel of scanned tokens
+
+	// SPARQL mode: the context of a '<', which is either an IRI reference or
+	// a comparison operator.
+	prev       [2]token // the last two tokens emitted, the last first
+	parens     []bool   // the open brackets, true for the parentheses of expressions
+	exprClause bool     // true in clauses where parentheses are expressions, such as SELECT
 }
 
 func newLexer(r io.Reader) *lexer {
@@ -96,6 +116,26 @@ func newLexer(r io.Reader) *lexer {
 	return &l
 }
 
+func newTriGLexer(r io.Reader) *lexer {
+	l := lexer{
+		rdr:      bufio.NewReader(r),
+		tokens:   make(chan token),
+		trigMode: true,
+	}
+	go l.run()
+	return &l
+}
+
+func newSPARQLLexer(r io.Reader) *lexer {
+	l := lexer{
+		rdr:        bufio.NewReader(r),
+		tokens:     make(chan token),
+		sparqlMode: true,
+	}
+	go l.run()
+	return &l
+}
+
 func newLineLexer(r io.Reader) *lexer {
 	l := lexer{
 		rdr:      bufio.NewReader(r),
@@ -220,16 +260,76 @@ func (l *lexer) emit(typ tokenType) {
 		l.start = l.pos
 		return
 	}
-	l.tokens <- token{
+	tok := token{
 		typ:  typ,
 		line: l.line,
 		col:  l.start,
 		text: l.unescape(string(l.input[l.start:l.pos]), typ),
 	}
+	if l.sparqlMode {
+		l.track(tok)
+	}
+	l.tokens <- tok
 
 	l.start = l.pos
 }
 
+// track records the SPARQL token, and the brackets it opens or closes. A
+// parenthesis opens an expression when it follows a keyword, such as FILTER
+// or STR, or the function IRI of a FILTER, or is in an expression or in a
+// SELECT, GROUP BY, ORDER BY or HAVING clause. Other parentheses, such as
+// those of collections and paths, are in patterns.
+func (l *lexer) track(tok token) {
+	switch {
+	case tok.typ == tokenSymbol && tok.text == "(":
+		prev := l.prev[0]
+		isFunc := prev.typ == tokenIRIAbs || prev.typ == tokenIRIRel || prev.typ == tokenIRISuffix
+		expr := l.inExpr() || l.exprClause ||
+			(prev.typ == tokenKeyword && !strings.EqualFold(prev.text, "VALUES")) ||
+			(isFunc && l.prev[1].typ == tokenKeyword && strings.EqualFold(l.prev[1].text, "FILTER"))
+		l.parens = append(l.parens, expr)
+	case tok.typ == tokenSymbol && tok.text == "{":
+		l.parens = append(l.parens, false)
+		l.exprClause = false
+	case tok.typ == tokenSymbol && (tok.text == ")" || tok.text == "}"):
+		if len(l.parens) > 0 {
+			l.parens = l.parens[:len(l.parens)-1]
+		}
+		if tok.text == "}" {
+			l.exprClause = false
+		}
+	case tok.typ == tokenKeyword:
+		switch strings.ToUpper(tok.text) {
+		case "SELECT", "GROUP", "ORDER", "HAVING":
+			l.exprClause = true
+		case "WHERE", "VALUES", "LIMIT", "OFFSET":
+			l.exprClause = false
+		}
+	}
+	l.prev[1], l.prev[0] = l.prev[0], tok
+}
+
+// inExpr returns true if the lexer is in the parentheses of an expression.
+func (l *lexer) inExpr() bool {
+	return len(l.parens) > 0 && l.parens[len(l.parens)-1]
+}
+
+// afterOperand returns true if the last token ends an operand of an
+// expression, so that a following '<' is a comparison operator.
+func (l *lexer) afterOperand() bool {
+	switch prev := l.prev[0]; prev.typ {
+	case tokenVariable, tokenLiteral, tokenLiteral3, tokenLiteralInteger,
+		tokenLiteralDouble, tokenLiteralDecimal, tokenLiteralBoolean, tokenLang,
+		tokenIRIAbs, tokenIRIRel, tokenPrefixLabel, tokenIRISuffix:
+		return true
+	case tokenSymbol:
+		return prev.text == ")"
+	case tokenKeyword:
+		return strings.EqualFold(prev.text, "true") || strings.EqualFold(prev.text, "false")
+	}
+	return false
+}
+
 // ignore skips over the pending input before this point.
 func (l *lexer) ignore() {
 	l.start = l.pos
@@ -353,6 +453,9 @@ func (l *lexer) errorf(format string, args ...interface{}) stateFn {
 }
 
 func lexAny(l *lexer) stateFn {
+	if l.sparqlMode {
+		return lexSPARQL(l)
+	}
 	r := l.next()
 	switch r {
 	case '@':
@@ -441,6 +544,20 @@ func lexAny(l *lexer) stateFn {
 		l.ignore()
 		l.emit(tokenCollectionEnd)
 		return lexAny
+	case '{':
+		if !l.trigMode {
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.ignore()
+		l.emit(tokenGraphStart)
+		return lexAny
+	case '}':
+		if !l.trigMode {
+			return l.errorf("unexpected character: %q", r)
+		}
+		l.ignore()
+		l.emit(tokenGraphEnd)
+		return lexAny
 	case '.':
 		if isDigit(l.peek()) {
 			l.pos -= 2 // can only backup once with l.backup()
@@ -491,6 +608,18 @@ func lexAny(l *lexer) stateFn {
 		}
 		l.backup()
 		return lexPrefixLabel
+	case 'G', 'g':
+		if l.trigMode && l.acceptCaseInsensitive("GRAPH") {
+			if p := l.peek(); !isPnChars(p) && p != '.' {
+				l.emit(tokenSparqlGraph)
+				return lexAny
+			}
+			// A prefixed name starting with "graph", like graph:x
+			l.pos = l.start
+			return lexPrefixLabel
+		}
+		l.backup()
+		return lexPrefixLabel
 	case 't':
 		if l.acceptExact("true") {
 			l.emit(tokenLiteralBoolean)
@@ -789,7 +918,8 @@ func lexNumber(l *lexer) stateFn {
 					}
 				}
 			default:
-				if r == ' ' || r == ',' || r == ';' || r == eof || r == ')' || r == ']' {
+				// In SPARQL, a number may be followed by an operator, as in 2-1.
+				if r == ' ' || r == ',' || r == ';' || r == eof || r == ')' || r == ']' || r == '}' || (l.sparqlMode && (r == '-' || !isPnChars(r))) {
 					l.backup()
 					break outer
 				}
@@ -961,6 +1091,12 @@ func lexIRISuffix(l *lexer) stateFn {
 		l.emit(tokenIRISuffix)
 		return lexAny
 	}
+	if l.sparqlMode && !isPnLocalFirst(r) {
+		// prefix only IRI, followed by punctuation like ')' or '}'
+		l.backup()
+		l.emit(tokenIRISuffix)
+		return lexAny
+	}
 	if !isPnLocalFirst(r) {
 		return l.errorf("unexpected character: %q", r)
 	}
@@ -1006,3 +1142,137 @@ func lexBase(l *lexer) stateFn {
 	}
 	return l.errorf("invalid character 'b'")
 }
+
+// lexSPARQL lexes SPARQL queries and updates. IRIs, literals, numbers, blank
+// nodes and prefixed names are lexed as in Turtle.
+func lexSPARQL(l *lexer) stateFn {
+	r := l.next()
+	switch r {
+	case ' ', '\t', '\r':
+		l.ignore()
+		return lexSPARQL
+	case '\n', '#', eof:
+		// comments are not emitted; park the lexer until the next line
+		l.ignore()
+		return nil
+	case '<':
+		if !(l.inExpr() && l.afterOperand()) && isIRIRef(l.input[l.pos:]) {
+			l.ignore()
+			return lexIRI
+		}
+		if l.peek() == '=' {
+			l.next()
+		}
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '>', '!':
+		if l.peek() == '=' {
+			l.next()
+		}
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '&':
+		if l.next() != '&' {
+			return l.errorf("unexpected character: '&'")
+		}
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '|':
+		if l.peek() == '|' {
+			l.next()
+		}
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '=', '*', '/', '^', ',', ';', '.', '{', '}', '(', ')', '[', ']':
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '?', '$':
+		if p := l.peek(); isVarChar(p) {
+			l.ignore()
+			for p = l.next(); isVarChar(p); p = l.next() {
+			}
+			l.backup()
+			l.emit(tokenVariable)
+			return lexSPARQL
+		}
+		if r == '$' {
+			return l.errorf("bad variable: missing name")
+		}
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '"', '\'':
+		l.backup()
+		return lexLiteral
+	case '+', '-':
+		if isDigit(l.peek()) {
+			l.backup()
+			return lexNumber
+		}
+		l.emit(tokenSymbol)
+		return lexSPARQL
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		l.backup()
+		return lexNumber
+	case '_':
+		if l.peek() != ':' {
+			return l.errorf("illegal character %q in blank node identifier", l.peek())
+		}
+		l.next()
+		return lexBNode
+	case ':':
+		l.backup()
+		return lexPrefixLabel
+	case '@':
+		// The Turtle directives, used by N3 rules.
+		if l.acceptExact("@prefix") || l.acceptExact("@base") {
+			l.emit(tokenKeyword)
+			return lexSPARQL
+		}
+		return l.errorf("unexpected character: '@'")
+	}
+	if !isPnCharsBase(r) {
+		return l.errorf("unexpected character: %q", r)
+	}
+	// A keyword, or a prefixed name if the word is followed by ':'.
+	p := l.pos
+	for r := l.next(); (isPnChars(r) && r != ':') || r == '.'; r = l.next() {
+	}
+	l.backup()
+	if l.peek() == ':' {
+		l.pos = l.start
+		return lexPrefixLabel
+	}
+	l.pos = p
+	for r := l.next(); isAlphaOrDigit(r) || r == '_'; r = l.next() {
+	}
+	l.backup()
+	if l.pos-l.start == 1 && l.input[l.start] == 'a' {
+		l.emit(tokenRDFType)
+		return lexSPARQL
+	}
+	l.emit(tokenKeyword)
+	return lexSPARQL
+}
+
+// isVarChar returns true if the rune can be part of a variable name.
+func isVarChar(r rune) bool {
+	return isPnChars(r) && r != '-' && r != ':'
+}
+
+// isIRIRef returns true if the input (following a '<') is an IRI reference,
+// and not a comparison operator. An IRI reference never starts with '=', so
+// that '<=' is always an operator.
+func isIRIRef(input []byte) bool {
+	if len(input) > 0 && input[0] == '=' {
+		return false
+	}
+	for _, c := range string(input) {
+		switch {
+		case c == '>':
+			return true
+		case c <= ' ', c == '<', c == '"', c == '{', c == '}', c == '|', c == '^', c == '`':
+			return false
+		}
+	}
+	return false
+}
@@ -38,6 +38,12 @@ var tokenName = map[tokenType]string{
 	tokenPropertyListEnd:   "Property list end",
 	tokenCollectionStart:   "Collection start",
 	tokenCollectionEnd:     "Collection end",
+	tokenGraphStart:        "Graph start",
+	tokenGraphEnd:          "Graph end",
+	tokenSparqlGraph:       "GRAPH",
+	tokenVariable:          "Variable",
+	tokenKeyword:           "Keyword",
+	tokenSymbol:            "Symbol",
 }
 
 func (t tokenType) String() string {
@@ -381,3 +387,141 @@ two
 		}
 	}
 }
+
+func TestSPARQLTokens(t *testing.T) {
+	lexTests := []struct {
+		in   string
+		want []testToken
+	}{
+		{"SELECT ?x $y WHERE { ?x a ex:C }", []testToken{
+			{tokenKeyword, "SELECT"},
+			{tokenVariable, "x"},
+			{tokenVariable, "y"},
+			{tokenKeyword, "WHERE"},
+			{tokenSymbol, "{"},
+			{tokenVariable, "x"},
+			{tokenRDFType, "a"},
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, "C"},
+			{tokenSymbol, "}"},
+			{tokenEOF, ""}},
+		},
+		{"FILTER(?a<?b&&?c<=-1||!bound(?d)) # comment", []testToken{
+			{tokenKeyword, "FILTER"},
+			{tokenSymbol, "("},
+			{tokenVariable, "a"},
+			{tokenSymbol, "<"},
+			{tokenVariable, "b"},
+			{tokenSymbol, "&&"},
+			{tokenVariable, "c"},
+			{tokenSymbol, "<="},
+			{tokenLiteralInteger, "-1"},
+			{tokenSymbol, "||"},
+			{tokenSymbol, "!"},
+			{tokenKeyword, "bound"},
+			{tokenSymbol, "("},
+			{tokenVariable, "d"},
+			{tokenSymbol, ")"},
+			{tokenSymbol, ")"},
+			{tokenEOF, ""}},
+		},
+		{"?s <p> \"o\"@en, 1.5 ; :q _:b.", []testToken{
+			{tokenVariable, "s"},
+			{tokenIRIRel, "p"},
+			{tokenLiteral, "o"},
+			{tokenLangMarker, "@"},
+			{tokenLang, "en"},
+			{tokenSymbol, ","},
+			{tokenLiteralDecimal, "1.5"},
+			{tokenSymbol, ";"},
+			{tokenPrefixLabel, ":"},
+			{tokenIRISuffix, "q"},
+			{tokenBNode, "_:b"},
+			{tokenSymbol, "."},
+			{tokenEOF, ""}},
+		},
+		{"ex:)", []testToken{
+			{tokenPrefixLabel, "ex"},
+			{tokenIRISuffix, ""},
+			{tokenSymbol, ")"},
+			{tokenEOF, ""}},
+		},
+		{"BIND(2-1+3 AS ?x)", []testToken{
+			{tokenKeyword, "BIND"},
+			{tokenSymbol, "("},
+			{tokenLiteralInteger, "2"},
+			{tokenLiteralInteger, "-1"},
+			{tokenLiteralInteger, "+3"},
+			{tokenKeyword, "AS"},
+			{tokenVariable, "x"},
+			{tokenSymbol, ")"},
+			{tokenEOF, ""}},
+		},
+		{"FILTER(?o<=5&&?o>=5)", []testToken{
+			{tokenKeyword, "FILTER"},
+			{tokenSymbol, "("},
+			{tokenVariable, "o"},
+			{tokenSymbol, "<="},
+			{tokenLiteralInteger, "5"},
+			{tokenSymbol, "&&"},
+			{tokenVariable, "o"},
+			{tokenSymbol, ">="},
+			{tokenLiteralInteger, "5"},
+			{tokenSymbol, ")"},
+			{tokenEOF, ""}},
+		},
+		{"FILTER <f>(?o<5&&STR(?o)>?x){?s<p>?o}", []testToken{
+			{tokenKeyword, "FILTER"},
+			{tokenIRIRel, "f"},
+			{tokenSymbol, "("},
+			{tokenVariable, "o"},
+			{tokenSymbol, "<"},
+			{tokenLiteralInteger, "5"},
+			{tokenSymbol, "&&"},
+			{tokenKeyword, "STR"},
+			{tokenSymbol, "("},
+			{tokenVariable, "o"},
+			{tokenSymbol, ")"},
+			{tokenSymbol, ">"},
+			{tokenVariable, "x"},
+			{tokenSymbol, ")"},
+			{tokenSymbol, "{"},
+			{tokenVariable, "s"},
+			{tokenIRIRel, "p"},
+			{tokenVariable, "o"},
+			{tokenSymbol, "}"},
+			{tokenEOF, ""}},
+		},
+		{"SELECT (?a<?b&&?c>0 AS ?d) {?s<p>(1<q>)}", []testToken{
+			{tokenKeyword, "SELECT"},
+			{tokenSymbol, "("},
+			{tokenVariable, "a"},
+			{tokenSymbol, "<"},
+			{tokenVariable, "b"},
+			{tokenSymbol, "&&"},
+			{tokenVariable, "c"},
+			{tokenSymbol, ">"},
+			{tokenLiteralInteger, "0"},
+			{tokenKeyword, "AS"},
+			{tokenVariable, "d"},
+			{tokenSymbol, ")"},
+			{tokenSymbol, "{"},
+			{tokenVariable, "s"},
+			{tokenIRIRel, "p"},
+			{tokenSymbol, "("},
+			{tokenLiteralInteger, "1"},
+			{tokenIRIRel, "q"},
+			{tokenSymbol, ")"},
+			{tokenSymbol, "}"},
+			{tokenEOF, ""}},
+		},
+	}
+
+	for _, tt := range lexTests {
+		lex := newSPARQLLexer(strings.NewReader(tt.in))
+		res := collect(lex)
+		if !equalTokens(tt.want, res) {
+			t.Errorf("lexing %q, got:\n\t%v\nexpected:\n\t%v", tt.in, res, tt.want)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package rdf
+
+import "strconv"
+
+const owlNS = "http://www.w3.org/2002/07/owl#"
+
+// OWLReasoner materializes the inferences of the OWL 2 RL/RDF rules of
+// the OWL 2 Profiles, by forward chaining until no new triple is entailed,
+// and reports the violations of the rules which entail false.
+//
+// The rules which depend on RDF lists, such as the ones of property
+// chains, owl:intersectionOf, owl:unionOf, owl:oneOf, owl:hasKey and
+// owl:AllDisjointClasses, are instantiated for the lists of the asserted
+// triples; lists which are only inferred are not considered. The
+// cardinality rules are instantiated likewise, for the asserted
+// owl:maxCardinality and owl:maxQualifiedCardinality restrictions.
+//
+// The datatype rules (dt-*) and eq-ref, which entails x owl:sameAs x for
+// every term x, are not applied.
+type OWLReasoner struct {
+	// SkipAxioms omits the axiomatic triples of the rules cls-thing,
+	// cls-nothing1 and prp-ap, such as owl:Thing rdf:type owl:Class.
+	SkipAxioms bool
+}
+
+// owlRules are the rules of OWL 2 RL/RDF which do not depend on lists.
+var owlRules = []rule{
+	// The semantics of equality (table 4).
+	newRule("eq-sym", "?x owl:sameAs ?y", "?y owl:sameAs ?x"),
+	newRule("eq-trans", "?x owl:sameAs ?y . ?y owl:sameAs ?z", "?x owl:sameAs ?z"),
+	newRule("eq-rep-s", "?s owl:sameAs ?s2 . ?s ?p ?o", "?s2 ?p ?o"),
+	newRule("eq-rep-p", "?p owl:sameAs ?p2 . ?s ?p ?o", "?s ?p2 ?o"),
+	newRule("eq-rep-o", "?o owl:sameAs ?o2 . ?s ?p ?o", "?s ?p ?o2"),
+	newRule("eq-diff1", "?x owl:sameAs ?y . ?x owl:differentFrom ?y", ""),
+
+	// The semantics of axioms about properties (table 5).
+	newRule("prp-dom", "?p rdfs:domain ?c . ?x ?p ?y", "?x rdf:type ?c"),
+	newRule("prp-rng", "?p rdfs:range ?c . ?x ?p ?y", "?y rdf:type ?c"),
+	newRule("prp-fp", "?p rdf:type owl:FunctionalProperty . ?x ?p ?y1 . ?x ?p ?y2", "?y1 owl:sameAs ?y2"),
+	newRule("prp-ifp", "?p rdf:type owl:InverseFunctionalProperty . ?x1 ?p ?y . ?x2 ?p ?y", "?x1 owl:sameAs ?x2"),
+	newRule("prp-irp", "?p rdf:type owl:IrreflexiveProperty . ?x ?p ?x", ""),
+	newRule("prp-symp", "?p rdf:type owl:SymmetricProperty . ?x ?p ?y", "?y ?p ?x"),
+	newRule("prp-asyp", "?p rdf:type owl:AsymmetricProperty . ?x ?p ?y . ?y ?p ?x", ""),
+	newRule("prp-trp", "?p rdf:type owl:TransitiveProperty . ?x ?p ?y . ?y ?p ?z", "?x ?p ?z"),
+	newRule("prp-spo1", "?p1 rdfs:subPropertyOf ?p2 . ?x ?p1 ?y", "?x ?p2 ?y"),
+	newRule("prp-eqp1", "?p1 owl:equivalentProperty ?p2 . ?x ?p1 ?y", "?x ?p2 ?y"),
+	newRule("prp-eqp2", "?p1 owl:equivalentProperty ?p2 . ?x ?p2 ?y", "?x ?p1 ?y"),
+	newRule("prp-pdw", "?p1 owl:propertyDisjointWith ?p2 . ?x ?p1 ?y . ?x ?p2 ?y", ""),
+	newRule("prp-inv1", "?p1 owl:inverseOf ?p2 . ?x ?p1 ?y", "?y ?p2 ?x"),
+	newRule("prp-inv2", "?p1 owl:inverseOf ?p2 . ?x ?p2 ?y", "?y ?p1 ?x"),
+	newRule("prp-npa1", "?x owl:sourceIndividual ?i1 . ?x owl:assertionProperty ?p . ?x owl:targetIndividual ?i2 . ?i1 ?p ?i2", ""),
+	newRule("prp-npa2", "?x owl:sourceIndividual ?i . ?x owl:assertionProperty ?p . ?x owl:targetValue ?lt . ?i ?p ?lt", ""),
+
+	// The semantics of classes (table 6).
+	newRule("cls-nothing2", "?x rdf:type owl:Nothing", ""),
+	newRule("cls-svf1", "?x owl:someValuesFrom ?y . ?x owl:onProperty ?p . ?u ?p ?v . ?v rdf:type ?y", "?u rdf:type ?x"),
+	newRule("cls-svf2", "?x owl:someValuesFrom owl:Thing . ?x owl:onProperty ?p . ?u ?p ?v", "?u rdf:type ?x"),
+	newRule("cls-avf", "?x owl:allValuesFrom ?y . ?x owl:onProperty ?p . ?u rdf:type ?x . ?u ?p ?v", "?v rdf:type ?y"),
+	newRule("cls-hv1", "?x owl:hasValue ?y . ?x owl:onProperty ?p . ?u rdf:type ?x", "?u ?p ?y"),
+	newRule("cls-hv2", "?x owl:hasValue ?y . ?x owl:onProperty ?p . ?u ?p ?y", "?u rdf:type ?x"),
+	newRule("cls-com", "?c1 owl:complementOf ?c2 . ?x rdf:type ?c1 . ?x rdf:type ?c2", ""),
+
+	// The semantics of class axioms (table 7).
+	newRule("cax-sco", "?c1 rdfs:subClassOf ?c2 . ?x rdf:type ?c1", "?x rdf:type ?c2"),
+	newRule("cax-eqc1", "?c1 owl:equivalentClass ?c2 . ?x rdf:type ?c1", "?x rdf:type ?c2"),
+	newRule("cax-eqc2", "?c1 owl:equivalentClass ?c2 . ?x rdf:type ?c2", "?x rdf:type ?c1"),
+	newRule("cax-dw", "?c1 owl:disjointWith ?c2 . ?x rdf:type ?c1 . ?x rdf:type ?c2", ""),
+
+	// The semantics of schema vocabulary (table 9).
+	newRule("scm-cls", "?c rdf:type owl:Class", "?c rdfs:subClassOf ?c . ?c owl:equivalentClass ?c . ?c rdfs:subClassOf owl:Thing . owl:Nothing rdfs:subClassOf ?c"),
+	newRule("scm-sco", "?c1 rdfs:subClassOf ?c2 . ?c2 rdfs:subClassOf ?c3", "?c1 rdfs:subClassOf ?c3"),
+	newRule("scm-eqc1", "?c1 owl:equivalentClass ?c2", "?c1 rdfs:subClassOf ?c2 . ?c2 rdfs:subClassOf ?c1"),
+	newRule("scm-eqc2", "?c1 rdfs:subClassOf ?c2 . ?c2 rdfs:subClassOf ?c1", "?c1 owl:equivalentClass ?c2"),
+	newRule("scm-op", "?p rdf:type owl:ObjectProperty", "?p rdfs:subPropertyOf ?p . ?p owl:equivalentProperty ?p"),
+	newRule("scm-dp", "?p rdf:type owl:DatatypeProperty", "?p rdfs:subPropertyOf ?p . ?p owl:equivalentProperty ?p"),
+	newRule("scm-spo", "?p1 rdfs:subPropertyOf ?p2 . ?p2 rdfs:subPropertyOf ?p3", "?p1 rdfs:subPropertyOf ?p3"),
+	newRule("scm-eqp1", "?p1 owl:equivalentProperty ?p2", "?p1 rdfs:subPropertyOf ?p2 . ?p2 rdfs:subPropertyOf ?p1"),
+	newRule("scm-eqp2", "?p1 rdfs:subPropertyOf ?p2 . ?p2 rdfs:subPropertyOf ?p1", "?p1 owl:equivalentProperty ?p2"),
+	newRule("scm-dom1", "?p rdfs:domain ?c1 . ?c1 rdfs:subClassOf ?c2", "?p rdfs:domain ?c2"),
+	newRule("scm-dom2", "?p2 rdfs:domain ?c . ?p1 rdfs:subPropertyOf ?p2", "?p1 rdfs:domain ?c"),
+	newRule("scm-rng1", "?p rdfs:range ?c1 . ?c1 rdfs:subClassOf ?c2", "?p rdfs:range ?c2"),
+	newRule("scm-rng2", "?p2 rdfs:range ?c . ?p1 rdfs:subPropertyOf ?p2", "?p1 rdfs:range ?c"),
+	newRule("scm-hv", "?c1 owl:hasValue ?i . ?c1 owl:onProperty ?p1 . ?c2 owl:hasValue ?i . ?c2 owl:onProperty ?p2 . ?p1 rdfs:subPropertyOf ?p2", "?c1 rdfs:subClassOf ?c2"),
+	newRule("scm-svf1", "?c1 owl:someValuesFrom ?y1 . ?c1 owl:onProperty ?p . ?c2 owl:someValuesFrom ?y2 . ?c2 owl:onProperty ?p . ?y1 rdfs:subClassOf ?y2", "?c1 rdfs:subClassOf ?c2"),
+	newRule("scm-svf2", "?c1 owl:someValuesFrom ?y . ?c1 owl:onProperty ?p1 . ?c2 owl:someValuesFrom ?y . ?c2 owl:onProperty ?p2 . ?p1 rdfs:subPropertyOf ?p2", "?c1 rdfs:subClassOf ?c2"),
+	newRule("scm-avf1", "?c1 owl:allValuesFrom ?y1 . ?c1 owl:onProperty ?p . ?c2 owl:allValuesFrom ?y2 . ?c2 owl:onProperty ?p . ?y1 rdfs:subClassOf ?y2", "?c1 rdfs:subClassOf ?c2"),
+	newRule("scm-avf2", "?c1 owl:allValuesFrom ?y . ?c1 owl:onProperty ?p1 . ?c2 owl:allValuesFrom ?y . ?c2 owl:onProperty ?p2 . ?p1 rdfs:subPropertyOf ?p2", "?c2 rdfs:subClassOf ?c1"),
+}
+
+// owlAxioms are the rules of OWL 2 RL/RDF without premises.
+var owlAxioms = []rule{
+	axiomRule("cls-thing", "owl:Thing rdf:type owl:Class"),
+	axiomRule("cls-nothing1", "owl:Nothing rdf:type owl:Class"),
+	axiomRule("prp-ap", `
+rdfs:label rdf:type owl:AnnotationProperty
+rdfs:comment rdf:type owl:AnnotationProperty
+rdfs:seeAlso rdf:type owl:AnnotationProperty
+rdfs:isDefinedBy rdf:type owl:AnnotationProperty
+owl:deprecated rdf:type owl:AnnotationProperty
+owl:versionInfo rdf:type owl:AnnotationProperty
+owl:priorVersion rdf:type owl:AnnotationProperty
+owl:backwardCompatibleWith rdf:type owl:AnnotationProperty
+owl:incompatibleWith rdf:type owl:AnnotationProperty
+`),
+}
+
+// Reason returns the triples, together with the triples they entail. If
+// the triples are inconsistent, the graph is returned along with an
+// *InconsistencyError.
+func (r *OWLReasoner) Reason(ts []Triple) (*ReasonedGraph, error) {
+	reasoner := *r
+	g := newReasonedGraph(reasoner.rules(NewGraph(ts...).Match), ts)
+	g.compile = func(g *ReasonedGraph, changed []Triple) []rule {
+		for _, t := range changed {
+			if isOWLListTriple(t) {
+				return reasoner.rules(g.assertedMatch)
+			}
+		}
+		return nil
+	}
+	return g, g.Err()
+}
+
+// ReasonGraph returns the triples of the graph, together with the triples
+// they entail, as Reason does. The graph is not modified.
+func (r *OWLReasoner) ReasonGraph(g *Graph) (*ReasonedGraph, error) {
+	return r.Reason(g.Triples())
+}
+
+// rules returns the rules of the reasoner, for the asserted triples
+// matched by the function.
+func (r OWLReasoner) rules(match func(Subject, Predicate, Object) []Triple) []rule {
+	rules := append([]rule(nil), owlRules...)
+	if !r.SkipAxioms {
+		rules = append(rules, owlAxioms...)
+	}
+	return append(rules, owlListRules(match)...)
+}
+
+// owlListPredicates are the predicates of the triples read by
+// owlListRules, by IRI.
+var owlListPredicates = map[string]bool{
+	rdfNS + "first":                   true,
+	rdfNS + "rest":                    true,
+	owlNS + "propertyChainAxiom":      true,
+	owlNS + "intersectionOf":          true,
+	owlNS + "unionOf":                 true,
+	owlNS + "oneOf":                   true,
+	owlNS + "hasKey":                  true,
+	owlNS + "members":                 true,
+	owlNS + "distinctMembers":         true,
+	owlNS + "maxCardinality":          true,
+	owlNS + "maxQualifiedCardinality": true,
+	owlNS + "onProperty":              true,
+	owlNS + "onClass":                 true,
+}
+
+// isOWLListTriple returns true if the triple may change the rules returned
+// by owlListRules, which are then compiled again when it is asserted or
+// retracted.
+func isOWLListTriple(t Triple) bool {
+	p, _ := t.Pred.(IRI)
+	if owlListPredicates[p.str] {
+		return true
+	}
+	if o, ok := t.Obj.(IRI); ok && p == rdfType {
+		switch o.str {
+		case owlNS + "AllDisjointClasses", owlNS + "AllDisjointProperties", owlNS + "AllDifferent":
+			return true
+		}
+	}
+	return false
+}
+
+// owlListRules returns the rules of OWL 2 RL/RDF depending on lists, and
+// the cardinality rules, instantiated for the axioms of the triples
+// matched by the function, where nil matches any term. The
+// body of each rule starts with the triples of its axiom, which are the
+// premises of the inferred triples along with the matched ones.
+func owlListRules(match func(Subject, Predicate, Object) []Triple) []rule {
+	var rules []rule
+	owl := func(name string) IRI { return IRI{str: owlNS + name} }
+	rdfsSubClassOf := IRI{str: rdfsNS + "subClassOf"}
+	sameAs := owl("sameAs")
+	v := func(name string, i int) Var { return Var(name + strconv.Itoa(i)) }
+	each := func(pred string, fn func(t Triple, list []Term)) {
+		for _, t := range match(nil, owl(pred), nil) {
+			if list, ok := readList(match, t.Obj); ok {
+				fn(t, list)
+			}
+		}
+	}
+	decl := func(t Triple) TriplePattern {
+		return TriplePattern{Subj: t.Subj, Pred: t.Pred, Obj: t.Obj}
+	}
+
+	each("propertyChainAxiom", func(t Triple, list []Term) {
+		if len(list) == 0 {
+			return
+		}
+		r := rule{name: "prp-spo2", body: []TriplePattern{decl(t)}}
+		for i, p := range list {
+			r.body = append(r.body, TriplePattern{Subj: v("u", i), Pred: p, Obj: v("u", i+1)})
+		}
+		r.head = []TriplePattern{{Subj: Var("u0"), Pred: t.Subj, Obj: v("u", len(list))}}
+		rules = append(rules, r)
+	})
+	each("intersectionOf", func(t Triple, list []Term) {
+		int1 := rule{name: "cls-int1", body: []TriplePattern{decl(t)}, head: []TriplePattern{{Subj: Var("y"), Pred: rdfType, Obj: t.Subj}}}
+		int2 := rule{name: "cls-int2", body: []TriplePattern{decl(t), {Subj: Var("y"), Pred: rdfType, Obj: t.Subj}}}
+		scm := rule{name: "scm-int", body: []TriplePattern{decl(t)}}
+		for _, c := range list {
+			int1.body = append(int1.body, TriplePattern{Subj: Var("y"), Pred: rdfType, Obj: c})
+			int2.head = append(int2.head, TriplePattern{Subj: Var("y"), Pred: rdfType, Obj: c})
+			scm.head = append(scm.head, TriplePattern{Subj: t.Subj, Pred: rdfsSubClassOf, Obj: c})
+		}
+		rules = append(rules, int1, int2, scm)
+	})
+	each("unionOf", func(t Triple, list []Term) {
+		scm := rule{name: "scm-uni", body: []TriplePattern{decl(t)}}
+		for _, c := range list {
+			rules = append(rules, rule{
+				name: "cls-uni",
+				body: []TriplePattern{decl(t), {Subj: Var("y"), Pred: rdfType, Obj: c}},
+				head: []TriplePattern{{Subj: Var("y"), Pred: rdfType, Obj: t.Subj}},
+			})
+			scm.head = append(scm.head, TriplePattern{Subj: c, Pred: rdfsSubClassOf, Obj: t.Subj})
+		}
+		rules = append(rules, scm)
+	})
+	each("oneOf", func(t Triple, list []Term) {
+		r := rule{name: "cls-oo", body: []TriplePattern{decl(t)}}
+		for _, y := range list {
+			r.head = append(r.head, TriplePattern{Subj: y, Pred: rdfType, Obj: t.Subj})
+		}
+		rules = append(rules, r)
+	})
+	each("hasKey", func(t Triple, list []Term) {
+		r := rule{
+			name: "prp-key",
+			body: []TriplePattern{decl(t), {Subj: Var("x"), Pred: rdfType, Obj: t.Subj}, {Subj: Var("y"), Pred: rdfType, Obj: t.Subj}},
+			head: []TriplePattern{{Subj: Var("x"), Pred: sameAs, Obj: Var("y")}},
+		}
+		for i, p := range list {
+			r.body = append(r.body,
+				TriplePattern{Subj: Var("x"), Pred: p, Obj: v("z", i)},
+				TriplePattern{Subj: Var("y"), Pred: p, Obj: v("z", i)})
+		}
+		rules = append(rules, r)
+	})
+
+	// The pairwise rules of owl:members lists.
+	members := func(typ, name string, body func(a, b Term) []TriplePattern) {
+		for _, typed := range match(nil, rdfType, owl(typ)) {
+			for _, t := range match(typed.Subj, nil, nil) {
+				if t.Pred != owl("members") && (typ != "AllDifferent" || t.Pred != owl("distinctMembers")) {
+					continue
+				}
+				list, ok := readList(match, t.Obj)
+				if !ok {
+					continue
+				}
+				name := name
+				if t.Pred == owl("distinctMembers") {
+					name = "eq-diff3"
+				}
+				for i := range list {
+					for j := i + 1; j < len(list); j++ {
+						rules = append(rules, rule{name: name, body: append([]TriplePattern{decl(typed), decl(t)}, body(list[i], list[j])...)})
+					}
+				}
+			}
+		}
+	}
+	members("AllDisjointClasses", "cax-adc", func(a, b Term) []TriplePattern {
+		return []TriplePattern{{Subj: Var("x"), Pred: rdfType, Obj: a}, {Subj: Var("x"), Pred: rdfType, Obj: b}}
+	})
+	members("AllDisjointProperties", "prp-adp", func(a, b Term) []TriplePattern {
+		return []TriplePattern{{Subj: Var("u"), Pred: a, Obj: Var("y")}, {Subj: Var("u"), Pred: b, Obj: Var("y")}}
+	})
+	members("AllDifferent", "eq-diff2", func(a, b Term) []TriplePattern {
+		return []TriplePattern{{Subj: a, Pred: sameAs, Obj: b}}
+	})
+
+	// The cardinality rules, for restrictions of at most zero or one value.
+	cardinality := func(pred string, fn func(restr, onProp Triple, n int)) {
+		for _, t := range match(nil, owl(pred), nil) {
+			lit, ok := t.Obj.(Literal)
+			if !ok {
+				continue
+			}
+			n, err := strconv.Atoi(lit.str)
+			if err != nil || n > 1 {
+				continue
+			}
+			for _, onProp := range match(t.Subj, owl("onProperty"), nil) {
+				fn(t, onProp, n)
+			}
+		}
+	}
+	cardinality("maxCardinality", func(restr, onProp Triple, n int) {
+		x, p := restr.Subj, onProp.Obj
+		body := []TriplePattern{decl(restr), decl(onProp), {Subj: Var("u"), Pred: rdfType, Obj: x}, {Subj: Var("u"), Pred: p, Obj: Var("y1")}}
+		if n == 0 {
+			rules = append(rules, rule{name: "cls-maxc1", body: body})
+			return
+		}
+		rules = append(rules, rule{
+			name: "cls-maxc2",
+			body: append(body, TriplePattern{Subj: Var("u"), Pred: p, Obj: Var("y2")}),
+			head: []TriplePattern{{Subj: Var("y1"), Pred: sameAs, Obj: Var("y2")}},
+		})
+	})
+	cardinality("maxQualifiedCardinality", func(restr, onProp Triple, n int) {
+		x, p := restr.Subj, onProp.Obj
+		for _, onClass := range match(x, owl("onClass"), nil) {
+			c := onClass.Obj
+			body := []TriplePattern{decl(restr), decl(onProp), decl(onClass), {Subj: Var("u"), Pred: rdfType, Obj: x}, {Subj: Var("u"), Pred: p, Obj: Var("y1")}}
+			if c != owl("Thing") {
+				body = append(body, TriplePattern{Subj: Var("y1"), Pred: rdfType, Obj: c})
+			}
+			if n == 0 {
+				name := "cls-maxqc1"
+				if c == owl("Thing") {
+					name = "cls-maxqc2"
+				}
+				rules = append(rules, rule{name: name, body: body})
+				continue
+			}
+			name := "cls-maxqc3"
+			body = append(body, TriplePattern{Subj: Var("u"), Pred: p, Obj: Var("y2")})
+			if c != owl("Thing") {
+				body = append(body, TriplePattern{Subj: Var("y2"), Pred: rdfType, Obj: c})
+			} else {
+				name = "cls-maxqc4"
+			}
+			rules = append(rules, rule{name: name, body: body, head: []TriplePattern{{Subj: Var("y1"), Pred: sameAs, Obj: Var("y2")}}})
+		}
+	})
+	return rules
+}
+
+// readList returns the members of the RDF list starting at the node, and
+// false if it is not a well-formed list, with a single rdf:first and
+// rdf:rest per node, ending with rdf:nil.
+func readList(match func(Subject, Predicate, Object) []Triple, node Term) ([]Term, bool) {
+	var list []Term
+	seen := make(map[Term]bool)
+	for node != rdfNil {
+		subj, ok := node.(Subject)
+		if !ok || seen[node] {
+			return nil, false
+		}
+		seen[node] = true
+		first, rest := match(subj, rdfFirst, nil), match(subj, rdfRest, nil)
+		if len(first) != 1 || len(rest) != 1 {
+			return nil, false
+		}
+		list = append(list, first[0].Obj)
+		node = rest[0].Obj
+	}
+	return list, true
+}
@@ -0,0 +1,155 @@
+package rdf
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testOWLPrologue = `@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix owl: <http://www.w3.org/2002/07/owl#> .
+@prefix ex: <http://example.org/> .
+`
+
+func TestOWLReasoner(t *testing.T) {
+	ts := decodeTestTriples(t, testOWLPrologue+`
+ex:hasParent owl:inverseOf ex:hasChild .
+ex:hasAncestor a owl:TransitiveProperty .
+ex:hasParent rdfs:subPropertyOf ex:hasAncestor .
+ex:hasGrandparent owl:propertyChainAxiom ( ex:hasParent ex:hasParent ) .
+ex:Person owl:equivalentClass ex:Human .
+ex:Parent owl:intersectionOf ( ex:Person ex:HasChildren ) .
+ex:hasChild rdfs:domain ex:HasChildren .
+ex:Person owl:hasKey ( ex:ssn ) .
+
+ex:carl ex:hasParent ex:bob .
+ex:bob ex:hasParent ex:alice .
+ex:bob a ex:Human .
+ex:bobby owl:sameAs ex:bob .
+ex:robert a ex:Person ; ex:ssn "123" .
+ex:bob ex:ssn "123" .
+ex:bob ex:ssn ex:carl .
+`)
+	triple := func(s, p, o string) Triple { return testTriple(t, s, p, o) }
+	tests := []struct {
+		t    Triple
+		rule string
+	}{
+		{triple("ex:bob", "ex:hasChild", "ex:carl"), "prp-inv1"},
+		{triple("ex:carl", "ex:hasAncestor", "ex:alice"), "prp-trp"},
+		{triple("ex:carl", "ex:hasGrandparent", "ex:alice"), "prp-spo2"},
+		{triple("ex:bob", "rdf:type", "ex:Person"), "cax-eqc2"},
+		{triple("ex:bob", "rdf:type", "ex:Parent"), "cls-int1"},
+		{triple("ex:Parent", "rdfs:subClassOf", "ex:Person"), "scm-int"},
+		{triple("ex:bob", "owl:sameAs", "ex:bobby"), "eq-sym"},
+		{triple("ex:bobby", "ex:ssn", "ex:carl"), "eq-rep-s"},
+		{triple("ex:robert", "owl:sameAs", "ex:bob"), "prp-key"},
+		{triple("owl:Thing", "rdf:type", "owl:Class"), "cls-thing"},
+	}
+	g, err := (&OWLReasoner{}).Reason(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		j, ok := g.Justification(test.t)
+		if !ok || !g.IsInferred(test.t) {
+			t.Errorf("%s not inferred", test.t.Serialize(NTriples))
+			continue
+		}
+		if j.Rule != test.rule {
+			t.Errorf("%s inferred by %s, want %s", test.t.Serialize(NTriples), j.Rule, test.rule)
+		}
+		for _, p := range j.Premises {
+			if !g.Has(p) {
+				t.Errorf("%s: premise %s not in graph", test.t.Serialize(NTriples), p.Serialize(NTriples))
+			}
+		}
+	}
+	for _, tr := range ts {
+		if _, ok := g.Justification(tr); ok || g.IsInferred(tr) {
+			t.Errorf("asserted %s is justified", tr.Serialize(NTriples))
+		}
+	}
+
+	// The premises of a justification are in the order of the rule body.
+	chain := decodeTestTriples(t, testOWLPrologue+`
+ex:hasGrandparent owl:propertyChainAxiom ( ex:hasParent ex:hasParent ) .
+ex:carl ex:hasParent ex:bob .
+ex:bob ex:hasParent ex:alice .
+`)
+	g, _ = (&OWLReasoner{}).Reason(chain)
+	j, _ := g.Justification(triple("ex:carl", "ex:hasGrandparent", "ex:alice"))
+	want := Justification{Rule: "prp-spo2", Premises: []Triple{
+		g.Graph().Match(nil, IRI{str: owlNS + "propertyChainAxiom"}, nil)[0],
+		triple("ex:carl", "ex:hasParent", "ex:bob"),
+		triple("ex:bob", "ex:hasParent", "ex:alice"),
+	}}
+	if !reflect.DeepEqual(j, want) {
+		t.Errorf("Justification of property chain => %v, want %v", j, want)
+	}
+
+	g, _ = (&OWLReasoner{SkipAxioms: true}).Reason(ts)
+	if g.Has(triple("owl:Thing", "rdf:type", "owl:Class")) {
+		t.Errorf("SkipAxioms: axiom inferred")
+	}
+}
+
+func TestOWLReasonerInconsistencies(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{
+			`ex:Cat owl:disjointWith ex:Dog . ex:felix a ex:Cat . ex:Cat rdfs:subClassOf ex:Pet . ex:felix a ex:Dog .`,
+			[]string{"cax-dw: <http://example.org/Cat> <http://www.w3.org/2002/07/owl#disjointWith> <http://example.org/Dog> . <http://example.org/felix> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Cat> . <http://example.org/felix> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Dog> ."},
+		},
+		{
+			`ex:a owl:sameAs ex:b . ex:a owl:differentFrom ex:b .`,
+			[]string{"eq-diff1: <http://example.org/a> <http://www.w3.org/2002/07/owl#sameAs> <http://example.org/b> . <http://example.org/a> <http://www.w3.org/2002/07/owl#differentFrom> <http://example.org/b> ."},
+		},
+		{
+			`ex:r a owl:Restriction ; owl:onProperty ex:p ; owl:maxCardinality 0 . ex:x a ex:r ; ex:p ex:y .`,
+			[]string{`cls-maxc1: <http://example.org/r> <http://www.w3.org/2002/07/owl#maxCardinality> "0"^^<http://www.w3.org/2001/XMLSchema#integer> . <http://example.org/r> <http://www.w3.org/2002/07/owl#onProperty> <http://example.org/p> . <http://example.org/x> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/r> . <http://example.org/x> <http://example.org/p> <http://example.org/y> .`},
+		},
+		{
+			`[] a owl:AllDisjointClasses ; owl:members ( ex:A ex:B ex:C ) . ex:x a ex:A, ex:C . ex:y a ex:B .`,
+			[]string{"cax-adc"},
+		},
+		{
+			`ex:p a owl:IrreflexiveProperty . ex:x ex:p ex:x . ex:y ex:p ex:x .`,
+			[]string{"prp-irp"},
+		},
+		{
+			`ex:Cat owl:disjointWith ex:Dog . ex:felix a ex:Cat . ex:rex a ex:Dog .`,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		ts := decodeTestTriples(t, testOWLPrologue+test.input)
+		g, err := (&OWLReasoner{}).Reason(ts)
+		if g == nil {
+			t.Fatalf("%s: nil graph", test.input)
+		}
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: got error %v", test.input, err)
+			}
+			continue
+		}
+		ie, ok := err.(*InconsistencyError)
+		if !ok {
+			t.Errorf("%s: got error %v, want *InconsistencyError", test.input, err)
+			continue
+		}
+		got := make(map[string]bool)
+		for _, inc := range ie.Inconsistencies {
+			got[inc.Rule] = true
+			got[inc.String()] = true
+		}
+		for _, want := range test.want {
+			if !got[want] {
+				t.Errorf("%s: inconsistency %q not reported in:\n%v", test.input, want, err)
+			}
+		}
+	}
+}
@@ -7,19 +7,69 @@
 //
 // Data structures
 //
-// TODO.
+// Graph is an in-memory set of triples, indexed for triple pattern matching,
+// which can be loaded from any TripleDecoder. Dataset holds a default graph
+// and named graphs, and is loaded from a QuadDecoder. Canonicalizer gives
+// the blank nodes of a dataset deterministic labels (RDFC-1.0), for hashing
+// and signing.
+//
+// SPARQL
+//
+// ParseQuery parses a SPARQL 1.1 query into a Query, which can be written
+// back as SPARQL with String, or translated to the SPARQL algebra with
+// Algebra. Queries are evaluated against a Dataset with Eval, or against a
+// single Graph with EvalGraph. SERVICE patterns are sent to their remote
+// endpoints; EvalWithOptions sets how.
+//
+// Select starts a QueryBuilder, which builds a SELECT query from terms and
+// variables, without the escaping pitfalls of formatting query text by hand.
+//
+// Property paths are parsed with ParsePath, and the nodes reachable by a
+// path are given by Paths.
+//
+// ParseUpdate parses a SPARQL 1.1 update, which is applied to a Dataset with
+// Exec, one atomic operation at a time.
+//
+// The results of SELECT and ASK queries are written with a ResultsEncoder and
+// read with a ResultsDecoder, in the JSON, XML, CSV and TSV formats.
+//
+// SPARQLHandler serves a Dataset as a SPARQL endpoint over HTTP, as per the
+// SPARQL 1.1 Protocol, and SPARQLClient sends queries and updates to remote
+// endpoints.
+//
+// Reasoning
+//
+// RDFSReasoner materializes the RDFS entailments of triples, as a
+// ReasonedGraph which tells the inferred triples from the asserted ones.
+// OWLReasoner does the same with the OWL 2 RL/RDF rules, and reports
+// inconsistencies as an *InconsistencyError. Each inferred triple has a
+// Justification: the rule and premises it was derived from. RuleReasoner
+// does the same with user-defined rules, given as Go values or parsed from
+// N3 with ParseRules, with stratified negation and builtins. The triples of
+// a ReasonedGraph can be asserted and retracted with Add and Remove, which
+// maintain the inferred triples incrementally.
+//
+// Validation
+//
+// SHACLValidator validates data graphs against the shapes of a SHACL shapes
+// graph, with the constraint components of SHACL Core, SPARQL-based
+// constraints and constraint components of SHACL-SPARQL, and the triple and
+// SPARQL rules of SHACL Advanced Features, which are executed against a
+// data graph before validating it, or alone by Infer. The results are
+// returned as a ValidationReport, which Triples returns as a
+// sh:ValidationReport.
 //
 // Encoding and decoding
 //
 // The package aims to support all the RDF serialization formats standardized by W3C. Currently the following are implemented:
 //  Format     | Decode | Encode
 //  -----------|--------|--------
-//  RDF/XML    | x      | -
+//  RDF/XML    | x      | x
 //  N-Triples  | x      | x
 //  N-Quads    | x      | x
 //  Turtle     | x      | x
-//  TriG       | -      | -
-//  JSON-LD    | -      | -
+//  TriG       | x      | x
+//  JSON-LD    | x      | x
 //
 // The parsers are implemented as streaming decoders, consuming an io.Reader
 // and emitting triples/quads as soon as they are available. Simply call
@@ -106,12 +156,15 @@ const (
 	NTriples Format = iota
 	Turtle
 	RDFXML
-	// TODO: JSON-LD
 
 	// Quad serialization:
 
 	NQuads // N-Quads
-	// TODO: Format TriG
+	TriG   // TriG
+
+	// Triple and quad serialization:
+
+	JSONLD // JSON-LD
 
 	// Internal formats
 	formatInternal
@@ -258,6 +311,11 @@ type Literal struct {
 
 // Serialize returns a string representation of a Literal.
 func (l Literal) Serialize(f Format) string {
+	if f == RDFXML {
+		// The content of the property element. The datatype and language
+		// are serialized as attributes of the element.
+		return xmlTextEscaper.Replace(l.str)
+	}
 	if TermsEqual(l.DataType, rdfLangString) {
 		return fmt.Sprintf("\"%s\"@%s", escapeLiteral(l.str), l.Lang())
 	}
@@ -265,9 +323,9 @@ func (l Literal) Serialize(f Format) string {
 		switch f {
 		case formatInternal:
 			return l.str
-		case NTriples, NQuads:
+		case NTriples, NQuads, JSONLD:
 			return fmt.Sprintf("\"%s\"^^%s", escapeLiteral(l.str), l.DataType.Serialize(f))
-		case Turtle:
+		case Turtle, TriG:
 			switch l.DataType {
 			case xsdInteger, xsdDecimal, xsdBoolean, xsdDouble:
 				return l.str
@@ -97,4 +97,21 @@ func TestLiteral(t *testing.T) {
 		}
 
 	}
+
+	serializeTests := []struct {
+		l    Literal
+		f    Format
+		want string
+	}{
+		{Literal{str: "1", DataType: xsdInteger}, NTriples, `"1"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		{Literal{str: "1", DataType: xsdInteger}, Turtle, `1`},
+		{Literal{str: "1", DataType: xsdInteger}, JSONLD, `"1"^^<http://www.w3.org/2001/XMLSchema#integer>`},
+		{Literal{str: "a", DataType: xsdString}, JSONLD, `"a"`},
+		{Literal{str: "a", lang: "en", DataType: rdfLangString}, JSONLD, `"a"@en`},
+	}
+	for _, tt := range serializeTests {
+		if got := tt.l.Serialize(tt.f); got != tt.want {
+			t.Errorf("%#v.Serialize(%v) => %s; want %s", tt.l, tt.f, got, tt.want)
+		}
+	}
 }
@@ -0,0 +1,201 @@
+package rdf
+
+import "strings"
+
+const rdfsNS = "http://www.w3.org/2000/01/rdf-schema#"
+
+// RDFSReasoner materializes the RDFS entailments of triples: it applies
+// the entailment rules of RDF 1.1 Semantics (rdf1, and rdfs2 to rdfs13) by
+// forward chaining, until no new triple is entailed.
+//
+// Datatype entailment (rdfD1, rdfs1) is not supported, and entailed triples
+// which are not valid RDF, such as triples with literal subjects, are not
+// inferred.
+type RDFSReasoner struct {
+	// SkipAxioms omits the axiomatic triples of RDF and RDFS, such as
+	// rdf:type rdf:type rdf:Property, and the triples entailed by them.
+	SkipAxioms bool
+}
+
+// rdfsRules are the RDFS entailment rules.
+var rdfsRules = []rule{
+	newRule("rdf1", "?x ?p ?y", "?p rdf:type rdf:Property"),
+	newRule("rdfs2", "?p rdfs:domain ?c . ?x ?p ?y", "?x rdf:type ?c"),
+	newRule("rdfs3", "?p rdfs:range ?c . ?x ?p ?y", "?y rdf:type ?c"),
+	newRule("rdfs4a", "?x ?p ?y", "?x rdf:type rdfs:Resource"),
+	newRule("rdfs4b", "?x ?p ?y", "?y rdf:type rdfs:Resource"),
+	newRule("rdfs5", "?p rdfs:subPropertyOf ?q . ?q rdfs:subPropertyOf ?r", "?p rdfs:subPropertyOf ?r"),
+	newRule("rdfs6", "?p rdf:type rdf:Property", "?p rdfs:subPropertyOf ?p"),
+	newRule("rdfs7", "?p rdfs:subPropertyOf ?q . ?x ?p ?y", "?x ?q ?y"),
+	newRule("rdfs8", "?c rdf:type rdfs:Class", "?c rdfs:subClassOf rdfs:Resource"),
+	newRule("rdfs9", "?c rdfs:subClassOf ?d . ?x rdf:type ?c", "?x rdf:type ?d"),
+	newRule("rdfs10", "?c rdf:type rdfs:Class", "?c rdfs:subClassOf ?c"),
+	newRule("rdfs11", "?c rdfs:subClassOf ?d . ?d rdfs:subClassOf ?e", "?c rdfs:subClassOf ?e"),
+	newRule("rdfs12", "?p rdf:type rdfs:ContainerMembershipProperty", "?p rdfs:subPropertyOf rdfs:member"),
+	newRule("rdfs13", "?d rdf:type rdfs:Datatype", "?d rdfs:subClassOf rdfs:Literal"),
+}
+
+// rdfsAxioms entails the axiomatic triples of RDF and RDFS, save those of
+// the container membership properties rdf:_1, rdf:_2, ..., which are added
+// for the ones used by the triples.
+var rdfsAxioms = axiomRule("axiom", `
+rdf:type rdf:type rdf:Property
+rdf:subject rdf:type rdf:Property
+rdf:predicate rdf:type rdf:Property
+rdf:object rdf:type rdf:Property
+rdf:first rdf:type rdf:Property
+rdf:rest rdf:type rdf:Property
+rdf:value rdf:type rdf:Property
+rdf:nil rdf:type rdf:List
+
+rdf:type rdfs:domain rdfs:Resource
+rdfs:domain rdfs:domain rdf:Property
+rdfs:range rdfs:domain rdf:Property
+rdfs:subPropertyOf rdfs:domain rdf:Property
+rdfs:subClassOf rdfs:domain rdfs:Class
+rdf:subject rdfs:domain rdf:Statement
+rdf:predicate rdfs:domain rdf:Statement
+rdf:object rdfs:domain rdf:Statement
+rdfs:member rdfs:domain rdfs:Resource
+rdf:first rdfs:domain rdf:List
+rdf:rest rdfs:domain rdf:List
+rdfs:seeAlso rdfs:domain rdfs:Resource
+rdfs:isDefinedBy rdfs:domain rdfs:Resource
+rdfs:comment rdfs:domain rdfs:Resource
+rdfs:label rdfs:domain rdfs:Resource
+rdf:value rdfs:domain rdfs:Resource
+
+rdf:type rdfs:range rdfs:Class
+rdfs:domain rdfs:range rdfs:Class
+rdfs:range rdfs:range rdfs:Class
+rdfs:subPropertyOf rdfs:range rdf:Property
+rdfs:subClassOf rdfs:range rdfs:Class
+rdf:subject rdfs:range rdfs:Resource
+rdf:predicate rdfs:range rdfs:Resource
+rdf:object rdfs:range rdfs:Resource
+rdfs:member rdfs:range rdfs:Resource
+rdf:first rdfs:range rdfs:Resource
+rdf:rest rdfs:range rdf:List
+rdfs:seeAlso rdfs:range rdfs:Resource
+rdfs:isDefinedBy rdfs:range rdfs:Resource
+rdfs:comment rdfs:range rdfs:Literal
+rdfs:label rdfs:range rdfs:Literal
+rdf:value rdfs:range rdfs:Resource
+
+rdf:Alt rdfs:subClassOf rdfs:Container
+rdf:Bag rdfs:subClassOf rdfs:Container
+rdf:Seq rdfs:subClassOf rdfs:Container
+rdfs:ContainerMembershipProperty rdfs:subClassOf rdf:Property
+
+rdfs:isDefinedBy rdfs:subPropertyOf rdfs:seeAlso
+
+rdfs:Datatype rdfs:subClassOf rdfs:Class
+`)
+
+// axiomRule returns a rule without body, entailing the triples of the
+// lines of the text, which are triple patterns of prefixed names, as in the
+// other rules.
+func axiomRule(name, s string) rule {
+	return rule{name: name, head: rulePatterns(strings.Replace(s, "\n", " . ", -1))}
+}
+
+// Reason returns the triples, together with the triples they entail.
+func (r *RDFSReasoner) Reason(ts []Triple) *ReasonedGraph {
+	rules := rdfsRules
+	if !r.SkipAxioms {
+		rules = append(rules[:len(rules):len(rules)], rdfsAxioms)
+		seen := make(map[IRI]bool)
+		for _, t := range ts {
+			for _, term := range [3]Term{t.Subj, t.Pred, t.Obj} {
+				if iri, ok := term.(IRI); ok && isMembershipProperty(iri) && !seen[iri] {
+					seen[iri] = true
+					rules = append(rules, membershipAxioms(iri))
+				}
+			}
+		}
+	}
+	g := newReasonedGraph(rules, ts)
+	if !r.SkipAxioms {
+		g.compile = membershipRules
+	}
+	return g
+}
+
+// ReasonGraph returns the triples of the graph, together with the triples
+// they entail. The graph is not modified.
+func (r *RDFSReasoner) ReasonGraph(g *Graph) *ReasonedGraph {
+	return r.Reason(g.Triples())
+}
+
+// isMembershipProperty returns true if the IRI is a container membership
+// property: rdf:_n, where n is a decimal integer greater than zero.
+func isMembershipProperty(iri IRI) bool {
+	if !strings.HasPrefix(iri.str, rdfNS+"_") {
+		return false
+	}
+	n := iri.str[len(rdfNS)+1:]
+	if n == "" || n[0] == '0' {
+		return false
+	}
+	for _, c := range n {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// membershipAxioms returns a rule entailing the axiomatic triples of a
+// container membership property.
+func membershipAxioms(p IRI) rule {
+	return rule{name: "axiom", head: []TriplePattern{
+		{Subj: p, Pred: rdfType, Obj: IRI{str: rdfNS + "Property"}},
+		{Subj: p, Pred: rdfType, Obj: IRI{str: rdfsNS + "ContainerMembershipProperty"}},
+		{Subj: p, Pred: IRI{str: rdfsNS + "domain"}, Obj: IRI{str: rdfsNS + "Resource"}},
+		{Subj: p, Pred: IRI{str: rdfsNS + "range"}, Obj: IRI{str: rdfsNS + "Resource"}},
+	}}
+}
+
+// membershipRules returns the rules of the graph, with the axioms of the
+// container membership properties of the changed triples added or removed,
+// whether they are used by asserted triples or not, and nil if the rules are
+// unchanged.
+func membershipRules(g *ReasonedGraph, changed []Triple) []rule {
+	used := make(map[IRI]bool)
+	for _, t := range changed {
+		for _, term := range [3]Term{t.Subj, t.Pred, t.Obj} {
+			if iri, ok := term.(IRI); ok && isMembershipProperty(iri) {
+				used[iri] = g.isUsed(iri)
+			}
+		}
+	}
+	if len(used) == 0 {
+		return nil
+	}
+	var rules []rule
+	update := false
+	for _, r := range g.rules {
+		if len(r.body) == 0 && len(r.head) > 0 {
+			if iri, ok := r.head[0].Subj.(IRI); ok && isMembershipProperty(iri) {
+				if u, ok := used[iri]; ok {
+					delete(used, iri)
+					if !u {
+						update = true
+						continue
+					}
+				}
+			}
+		}
+		rules = append(rules, r)
+	}
+	for iri, u := range used {
+		if u {
+			rules = append(rules, membershipAxioms(iri))
+			update = true
+		}
+	}
+	if !update {
+		return nil
+	}
+	return rules
+}
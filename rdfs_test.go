@@ -0,0 +1,129 @@
+package rdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// decodeTestTriples returns the triples of a Turtle document.
+func decodeTestTriples(t *testing.T, input string) []Triple {
+	ts, err := NewTripleDecoder(bytes.NewBufferString(input), Turtle).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// testTriple returns the triple of prefixed names, of the rdf, rdfs and owl
+// namespaces, or of the ex: namespace http://example.org/.
+func testTriple(t *testing.T, s, p, o string) Triple {
+	var terms [3]PatternTerm
+	for i, name := range [3]string{s, p, o} {
+		if strings.HasPrefix(name, "ex:") {
+			terms[i] = IRI{str: "http://example.org/" + name[3:]}
+		} else {
+			terms[i] = ruleTerm(name)
+		}
+	}
+	tr, ok := instantiate(TriplePattern{Subj: terms[0], Pred: terms[1], Obj: terms[2]}, nil)
+	if !ok {
+		t.Fatalf("bad triple: %s %s %s", s, p, o)
+	}
+	return tr
+}
+
+func TestRDFSReasoner(t *testing.T) {
+	ts := decodeTestTriples(t, `@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix ex: <http://example.org/> .
+ex:Cat rdfs:subClassOf ex:Mammal .
+ex:Mammal rdfs:subClassOf ex:Animal .
+ex:hasOwner rdfs:domain ex:Pet ;
+	rdfs:range ex:Person ;
+	rdfs:subPropertyOf ex:knows .
+ex:knows rdfs:subPropertyOf ex:relatedTo .
+ex:name rdfs:range rdfs:Literal .
+ex:felix a ex:Cat ;
+	ex:hasOwner ex:alice ;
+	ex:name "Felix" .
+ex:alice a ex:Person .
+ex:list rdf:_2 ex:felix .
+`)
+	triple := func(s, p, o string) Triple { return testTriple(t, s, p, o) }
+
+	tests := []struct {
+		skipAxioms bool
+		inferred   []Triple
+		absent     []Triple
+	}{
+		{
+			true,
+			[]Triple{
+				triple("ex:felix", "rdf:type", "ex:Mammal"),
+				triple("ex:felix", "rdf:type", "ex:Animal"),
+				triple("ex:Cat", "rdfs:subClassOf", "ex:Animal"),
+				triple("ex:felix", "rdf:type", "ex:Pet"),
+				triple("ex:felix", "ex:knows", "ex:alice"),
+				triple("ex:felix", "ex:relatedTo", "ex:alice"),
+				triple("ex:hasOwner", "rdfs:subPropertyOf", "ex:relatedTo"),
+				triple("ex:hasOwner", "rdf:type", "rdf:Property"),
+				triple("ex:hasOwner", "rdfs:subPropertyOf", "ex:hasOwner"),
+				triple("ex:felix", "rdf:type", "rdfs:Resource"),
+			},
+			[]Triple{
+				triple("ex:Cat", "rdfs:subClassOf", "ex:Cat"),
+				triple("rdf:subject", "rdf:type", "rdf:Property"),
+				triple("rdf:_2", "rdf:type", "rdfs:ContainerMembershipProperty"),
+				triple("ex:list", "rdfs:member", "ex:felix"),
+			},
+		},
+		{
+			false,
+			[]Triple{
+				triple("ex:felix", "rdf:type", "ex:Animal"),
+				triple("rdf:type", "rdf:type", "rdf:Property"),
+				triple("ex:Cat", "rdf:type", "rdfs:Class"),
+				triple("ex:Cat", "rdfs:subClassOf", "ex:Cat"),
+				triple("ex:Cat", "rdfs:subClassOf", "rdfs:Resource"),
+				triple("rdf:_2", "rdf:type", "rdfs:ContainerMembershipProperty"),
+				triple("ex:list", "rdfs:member", "ex:felix"),
+				triple("rdfs:Datatype", "rdfs:subClassOf", "rdfs:Class"),
+			},
+			[]Triple{
+				triple("rdf:_1", "rdf:type", "rdfs:ContainerMembershipProperty"),
+			},
+		},
+	}
+	for _, test := range tests {
+		r := &RDFSReasoner{SkipAxioms: test.skipAxioms}
+		g := r.Reason(ts)
+		for _, tr := range ts {
+			if !g.Has(tr) || g.IsInferred(tr) {
+				t.Errorf("SkipAxioms=%v: asserted %s: Has %v, IsInferred %v", test.skipAxioms, tr.Serialize(NTriples), g.Has(tr), g.IsInferred(tr))
+			}
+		}
+		for _, tr := range test.inferred {
+			if !g.IsInferred(tr) {
+				t.Errorf("SkipAxioms=%v: %s not inferred", test.skipAxioms, tr.Serialize(NTriples))
+			}
+		}
+		for _, tr := range test.absent {
+			if g.Has(tr) {
+				t.Errorf("SkipAxioms=%v: %s entailed", test.skipAxioms, tr.Serialize(NTriples))
+			}
+		}
+		if got, want := sortedNTriples(g.Asserted()), sortedNTriples(ts); got != want {
+			t.Errorf("SkipAxioms=%v: Asserted() =>\n%s\nwant:\n%s", test.skipAxioms, got, want)
+		}
+		if n := len(g.Asserted()) + len(g.Inferred()); n != g.Len() {
+			t.Errorf("SkipAxioms=%v: %d asserted and inferred triples, Len() => %d", test.skipAxioms, n, g.Len())
+		}
+	}
+
+	// The reasoned graph is the same as the one of a graph.
+	r := &RDFSReasoner{}
+	if got, want := sortedNTriples(r.ReasonGraph(NewGraph(ts...)).Inferred()), sortedNTriples(r.Reason(ts).Inferred()); got != want {
+		t.Errorf("ReasonGraph(g).Inferred() =>\n%s\nwant:\n%s", got, want)
+	}
+}
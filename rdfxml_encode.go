@@ -0,0 +1,218 @@
+package rdf
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RDFXMLOptions are options for RDF/XML serialization.
+type RDFXMLOptions struct {
+	// TypedNodes writes the rdf:type of a subject as the name of its node
+	// element, instead of using rdf:Description. Only the first type (in
+	// sorted order) which can be written as an XML QName is folded into the
+	// element name; any other types are written as rdf:type properties.
+	TypedNodes bool
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;",
+		"\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// rdfXMLEncoder writes triples as an RDF/XML document.
+type rdfXMLEncoder struct {
+	w       *errWriter
+	ns      map[string]string // namespace->prefix mappings
+	nsOrder []string          // namespaces in order of declaration
+	nodeIDs map[string]string // blank node->rdf:nodeID mappings
+	usedIDs map[string]bool   // rdf:nodeIDs in use
+}
+
+// encodeRDFXML writes the triples as an RDF/XML document, with the triples of
+// each subject grouped in one node element. All namespaces are declared on
+// the rdf:RDF element.
+//
+// Note that the given slice of triples is sorted in-place, by subject, then
+// predicate and object.
+func encodeRDFXML(w *bufio.Writer, ts []Triple, opts RDFXMLOptions) error {
+	e := &rdfXMLEncoder{
+		w:       &errWriter{w: w},
+		ns:      map[string]string{rdfNS: "rdf"},
+		nsOrder: []string{rdfNS},
+		nodeIDs: make(map[string]string),
+		usedIDs: make(map[string]bool),
+	}
+	sort.Slice(ts, func(i, j int) bool {
+		for _, c := range [][2]Term{{ts[i].Subj, ts[j].Subj}, {ts[i].Pred, ts[j].Pred}, {ts[i].Obj, ts[j].Obj}} {
+			if a, b := c[0].Serialize(NTriples), c[1].Serialize(NTriples); a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+
+	// Collect the namespaces of all element names up front, so they
+	// can be declared on the root element.
+	for i, t := range ts {
+		if i > 0 && TriplesEqual(t, ts[i-1]) {
+			continue
+		}
+		if _, err := e.qname(t.Pred.(IRI)); err != nil {
+			return err
+		}
+		if b, ok := t.Subj.(Blank); ok && rgxpNCName.MatchString(b.String()) {
+			e.usedIDs[b.String()] = true
+		}
+		if b, ok := t.Obj.(Blank); ok && rgxpNCName.MatchString(b.String()) {
+			e.usedIDs[b.String()] = true
+		}
+	}
+	types := make(map[int]bool) // index of rdf:type triples written as node element name
+	if opts.TypedNodes {
+		for i := 0; i < len(ts); {
+			j := i
+			for j < len(ts) && TermsEqual(ts[j].Subj, ts[i].Subj) {
+				j++
+			}
+			for k := i; k < j; k++ {
+				if o, ok := ts[k].Obj.(IRI); ok && TermsEqual(ts[k].Pred, rdfType) {
+					if _, err := e.qname(o); err == nil {
+						types[k] = true
+						break
+					}
+				}
+			}
+			i = j
+		}
+	}
+
+	e.w.write([]byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<rdf:RDF"))
+	for _, ns := range e.nsOrder {
+		e.w.write([]byte(fmt.Sprintf("\n\txmlns:%s=\"%s\"", e.ns[ns], xmlAttrEscaper.Replace(ns))))
+	}
+	e.w.write([]byte(">\n"))
+
+	var node string // name of the open node element
+	for i, t := range ts {
+		if i > 0 && TriplesEqual(t, ts[i-1]) {
+			continue
+		}
+		if i == 0 || !TermsEqual(t.Subj, ts[i-1].Subj) {
+			if node != "" {
+				e.w.write([]byte(fmt.Sprintf("\t</%s>\n", node)))
+			}
+			node = "rdf:Description"
+			for k := i; k < len(ts) && TermsEqual(ts[k].Subj, t.Subj); k++ {
+				if types[k] {
+					node, _ = e.qname(ts[k].Obj.(IRI))
+					break
+				}
+			}
+			e.w.write([]byte(fmt.Sprintf("\t<%s %s>\n", node, e.nodeAttr(t.Subj, "rdf:about"))))
+		}
+		if types[i] {
+			continue
+		}
+		e.encodeProperty(t)
+	}
+	if node != "" {
+		e.w.write([]byte(fmt.Sprintf("\t</%s>\n", node)))
+	}
+	e.w.write([]byte("</rdf:RDF>\n"))
+	return e.w.err
+}
+
+// encodeProperty writes the predicate and object of the triple as a property element.
+func (e *rdfXMLEncoder) encodeProperty(t Triple) {
+	p, _ := e.qname(t.Pred.(IRI))
+	switch o := t.Obj.(type) {
+	case IRI, Blank:
+		e.w.write([]byte(fmt.Sprintf("\t\t<%s %s/>\n", p, e.nodeAttr(o, "rdf:resource"))))
+	case Literal:
+		var attr string
+		switch {
+		case o.lang != "":
+			attr = fmt.Sprintf(" xml:lang=\"%s\"", xmlAttrEscaper.Replace(o.lang))
+		case o.DataType != xsdString && o.DataType.str != "":
+			attr = fmt.Sprintf(" rdf:datatype=\"%s\"", xmlAttrEscaper.Replace(o.DataType.str))
+		}
+		e.w.write([]byte(fmt.Sprintf("\t\t<%s%s>%s</%s>\n", p, attr, o.Serialize(RDFXML), p)))
+	}
+}
+
+// nodeAttr returns the attribute identifying a node: attr for IRIs, or
+// rdf:nodeID for blank nodes.
+func (e *rdfXMLEncoder) nodeAttr(t Term, attr string) string {
+	if b, ok := t.(Blank); ok {
+		return fmt.Sprintf("rdf:nodeID=\"%s\"", e.nodeID(b))
+	}
+	return fmt.Sprintf("%s=\"%s\"", attr, xmlAttrEscaper.Replace(t.(IRI).str))
+}
+
+// nodeID returns the rdf:nodeID of a blank node. Blank node labels which are
+// not valid XML names are relabeled.
+func (e *rdfXMLEncoder) nodeID(b Blank) string {
+	label := b.String()
+	if rgxpNCName.MatchString(label) {
+		return label
+	}
+	if id, ok := e.nodeIDs[label]; ok {
+		return id
+	}
+	var id string
+	for n := len(e.nodeIDs); ; n++ {
+		id = fmt.Sprintf("b%d", n)
+		if !e.usedIDs[id] {
+			break
+		}
+	}
+	e.nodeIDs[label] = id
+	e.usedIDs[id] = true
+	return id
+}
+
+// qname returns the IRI as an XML QName, declaring a prefix for its
+// namespace if needed. An error is returned if the IRI cannot be split
+// into a namespace and a local name.
+func (e *rdfXMLEncoder) qname(iri IRI) (string, error) {
+	ns, local := splitQName(iri.str)
+	if local == "" {
+		return "", fmt.Errorf("cannot serialize IRI as an XML QName: %s", iri.Serialize(NTriples))
+	}
+	prefix, ok := e.ns[ns]
+	if !ok {
+		prefix = fmt.Sprintf("ns%d", len(e.nsOrder)-1)
+		e.ns[ns] = prefix
+		e.nsOrder = append(e.nsOrder, ns)
+	}
+	return prefix + ":" + local, nil
+}
+
+// splitQName splits the IRI into a namespace and the longest suffix which
+// is a valid XML local name. The local name is empty if there is none.
+func splitQName(iri string) (ns, local string) {
+	i := len(iri)
+	for i > 0 {
+		r, w := utf8.DecodeLastRuneInString(iri[:i])
+		if !unicode.IsLetter(r) && !unicode.IsMark(r) && !(r >= '0' && r <= '9') && r != '_' && r != '.' && r != '-' {
+			break
+		}
+		i -= w
+	}
+	for i < len(iri) {
+		r, w := utf8.DecodeRuneInString(iri[i:])
+		if unicode.IsLetter(r) || r == '_' {
+			break
+		}
+		i += w
+	}
+	if i == 0 {
+		return "", ""
+	}
+	return iri[:i], iri[i:]
+}
@@ -3,6 +3,7 @@ package rdf
 import (
 	"bytes"
 	"io"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -3913,3 +3914,143 @@ _:b1 <http://example.org/property2> _:a .
 		"",
 	},
 }
+
+func TestEncodeRDFXML(t *testing.T) {
+	input := `@prefix ex: <http://example.org/> .
+@prefix foaf: <http://xmlns.com/foaf/0.1/> .
+ex:alice a foaf:Person , ex:Author ;
+	foaf:name "Alice" , "Alicia"@es ;
+	foaf:age 42 ;
+	foaf:knows ex:bob , _:c ;
+	ex:note "a < b & \"c\"" .
+_:c foaf:name "Carol" .
+`
+	tests := []struct {
+		opts RDFXMLOptions
+		want string
+	}{
+		{
+			RDFXMLOptions{},
+			`<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF
+	xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlns:ns0="http://example.org/"
+	xmlns:ns1="http://xmlns.com/foaf/0.1/">
+	<rdf:Description rdf:about="http://example.org/alice">
+		<ns0:note>a &lt; b &amp; "c"</ns0:note>
+		<rdf:type rdf:resource="http://example.org/Author"/>
+		<rdf:type rdf:resource="http://xmlns.com/foaf/0.1/Person"/>
+		<ns1:age rdf:datatype="http://www.w3.org/2001/XMLSchema#integer">42</ns1:age>
+		<ns1:knows rdf:resource="http://example.org/bob"/>
+		<ns1:knows rdf:nodeID="c"/>
+		<ns1:name>Alice</ns1:name>
+		<ns1:name xml:lang="es">Alicia</ns1:name>
+	</rdf:Description>
+	<rdf:Description rdf:nodeID="c">
+		<ns1:name>Carol</ns1:name>
+	</rdf:Description>
+</rdf:RDF>
+`,
+		},
+		{
+			RDFXMLOptions{TypedNodes: true},
+			`<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF
+	xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlns:ns0="http://example.org/"
+	xmlns:ns1="http://xmlns.com/foaf/0.1/">
+	<ns0:Author rdf:about="http://example.org/alice">
+		<ns0:note>a &lt; b &amp; "c"</ns0:note>
+		<rdf:type rdf:resource="http://xmlns.com/foaf/0.1/Person"/>
+		<ns1:age rdf:datatype="http://www.w3.org/2001/XMLSchema#integer">42</ns1:age>
+		<ns1:knows rdf:resource="http://example.org/bob"/>
+		<ns1:knows rdf:nodeID="c"/>
+		<ns1:name>Alice</ns1:name>
+		<ns1:name xml:lang="es">Alicia</ns1:name>
+	</ns0:Author>
+	<rdf:Description rdf:nodeID="c">
+		<ns1:name>Carol</ns1:name>
+	</rdf:Description>
+</rdf:RDF>
+`,
+		},
+	}
+
+	for i, test := range tests {
+		ts, err := NewTripleDecoder(bytes.NewBufferString(input), Turtle).DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		enc := NewTripleEncoder(&b, RDFXML)
+		enc.RDFXMLOptions = test.opts
+		if err := enc.EncodeAll(ts); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.want {
+			t.Errorf("#%d: encoding RDF/XML:\ngot:\n%s\nwant:\n%s", i, b.String(), test.want)
+		}
+	}
+
+	var b bytes.Buffer
+	enc := NewTripleEncoder(&b, RDFXML)
+	enc.Encode(Triple{Subj: IRI{str: "http://example.org/s"}, Pred: IRI{str: "http://example.org/"}, Obj: IRI{str: "http://example.org/o"}})
+	want := "cannot serialize IRI as an XML QName: <http://example.org/>"
+	if err := enc.Close(); err == nil || err.Error() != want {
+		t.Errorf("encoding RDF/XML with invalid predicate => %v, want %q", err, want)
+	}
+}
+
+func TestEncodeRDFXMLRoundtrip(t *testing.T) {
+	var inputs []string
+	for _, test := range rdfxmlExamples {
+		inputs = append(inputs, test.file, test.rdfxml)
+	}
+	for _, test := range rdfxmlTestSuite {
+		if test.err == "" {
+			inputs = append(inputs, test.file, test.rdfxml)
+		}
+	}
+
+	for i := 0; i < len(inputs); i += 2 {
+		dec := NewTripleDecoder(bytes.NewBufferString(inputs[i+1]), RDFXML)
+		dec.SetOption(Base, IRI{str: "http://www.w3.org/2013/RDFXMLTests/" + inputs[i]})
+		ts, err := dec.DecodeAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, typed := range []bool{false, true} {
+			var b bytes.Buffer
+			enc := NewTripleEncoder(&b, RDFXML)
+			enc.RDFXMLOptions.TypedNodes = typed
+			if err := enc.EncodeAll(append([]Triple(nil), ts...)); err != nil {
+				t.Fatal(err)
+			}
+			if err := enc.Close(); err != nil {
+				t.Errorf("%s: encoding RDF/XML: %v", inputs[i], err)
+				continue
+			}
+			back, err := NewTripleDecoder(bytes.NewReader(b.Bytes()), RDFXML).DecodeAll()
+			if err != nil {
+				t.Errorf("%s: decoding encoded RDF/XML: %v\n%s", inputs[i], err, b.String())
+				continue
+			}
+			if got, want := rdfxmlTestNTriples(back), rdfxmlTestNTriples(ts); got != want {
+				t.Errorf("%s: roundtrip RDF/XML (typed nodes: %v):\ngot:\n%s\nwant:\n%s", inputs[i], typed, got, want)
+			}
+		}
+	}
+}
+
+// rdfxmlTestNTriples returns the triples as sorted N-Triples, with blank node labels removed.
+func rdfxmlTestNTriples(ts []Triple) string {
+	var lines []string
+	for _, t := range ts {
+		lines = append(lines, t.Serialize(NTriples))
+	}
+	sort.Strings(lines)
+	return stripBlanks(strings.Join(lines, ""))
+}
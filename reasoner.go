@@ -0,0 +1,558 @@
+package rdf
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReasonedGraph is a graph of asserted triples, together with the triples
+// inferred from them by a reasoner, such as RDFSReasoner or OWLReasoner.
+//
+// The inferred triples never include asserted ones: a triple which is both
+// asserted and entailed is reported as asserted.
+//
+// Triples can be asserted and retracted with Add and Remove, which maintain
+// the inferred triples incrementally, in time proportional to the change
+// rather than to the graph.
+type ReasonedGraph struct {
+	rules     []rule
+	all       *Graph // asserted and inferred triples
+	inferred  *Graph
+	justified map[string]Justification // by tripleKey
+
+	inconsistencies []Inconsistency
+	inconsistent    map[string]bool // by inconsistencyKey
+
+	e *evaluator // of the expressions of the rules
+
+	// compile returns the rules after the assertion or retraction of the
+	// changed triples, for the rules depending on the asserted triples,
+	// or nil if the rules are unchanged.
+	compile func(g *ReasonedGraph, changed []Triple) []rule
+	axioms  *ReasonedGraph // of the axioms alone, computed by supported
+}
+
+// Justification explains why a triple is inferred: the rule which derived
+// it, and the triples matched by the body of the rule, in order. The
+// premises of axiomatic triples are empty.
+//
+// Only the first derivation of a triple is kept; the premises are asserted or
+// inferred triples, themselves justified.
+type Justification struct {
+	Rule     string
+	Premises []Triple
+}
+
+// Inconsistency is a violation of a rule which entails false, such as the
+// membership of an individual in two disjoint classes. The triples are the
+// ones matched by the body of the rule, in order.
+type Inconsistency struct {
+	Rule    string
+	Triples []Triple
+}
+
+func (inc Inconsistency) String() string {
+	var b strings.Builder
+	b.WriteString(inc.Rule)
+	b.WriteString(":")
+	for _, t := range inc.Triples {
+		b.WriteString(" ")
+		b.WriteString(strings.TrimSuffix(t.Serialize(NTriples), "\n"))
+	}
+	return b.String()
+}
+
+// InconsistencyError is the error of a graph which entails false. It lists
+// all the violations.
+type InconsistencyError struct {
+	Inconsistencies []Inconsistency
+}
+
+func (e *InconsistencyError) Error() string {
+	if len(e.Inconsistencies) == 1 {
+		return "inconsistent graph: " + e.Inconsistencies[0].String()
+	}
+	return fmt.Sprintf("inconsistent graph: %s (and %d more)", e.Inconsistencies[0], len(e.Inconsistencies)-1)
+}
+
+// Graph returns the graph of all the triples, asserted and inferred, for
+// instance to query it with Query.EvalGraph. The graph must not be
+// modified.
+func (g *ReasonedGraph) Graph() *Graph {
+	return g.all
+}
+
+// Has returns true if the triple is asserted or inferred.
+func (g *ReasonedGraph) Has(t Triple) bool {
+	return g.all.Has(t)
+}
+
+// IsInferred returns true if the triple is inferred, and not asserted.
+func (g *ReasonedGraph) IsInferred(t Triple) bool {
+	return g.inferred.Has(t)
+}
+
+// Justification returns the justification of an inferred triple, and false
+// if the triple is not inferred.
+func (g *ReasonedGraph) Justification(t Triple) (Justification, bool) {
+	j, ok := g.justified[tripleKey(t)]
+	return j, ok
+}
+
+// Len returns the number of triples, asserted and inferred.
+func (g *ReasonedGraph) Len() int {
+	return g.all.Len()
+}
+
+// Triples returns all the triples, asserted and inferred, in no particular
+// order.
+func (g *ReasonedGraph) Triples() []Triple {
+	return g.all.Triples()
+}
+
+// Asserted returns the asserted triples, in no particular order.
+func (g *ReasonedGraph) Asserted() []Triple {
+	return g.assertedMatch(nil, nil, nil)
+}
+
+// assertedMatch returns the asserted triples matching the terms, where nil
+// matches any term.
+func (g *ReasonedGraph) assertedMatch(subj Subject, pred Predicate, obj Object) []Triple {
+	var ts []Triple
+	g.all.match(subj, pred, obj, func(t Triple) {
+		if !g.inferred.Has(t) {
+			ts = append(ts, t)
+		}
+	})
+	return ts
+}
+
+// Inferred returns the inferred triples, in no particular order.
+func (g *ReasonedGraph) Inferred() []Triple {
+	return g.inferred.Triples()
+}
+
+// Err returns an *InconsistencyError if the graph entails false, or nil.
+func (g *ReasonedGraph) Err() error {
+	if len(g.inconsistencies) == 0 {
+		return nil
+	}
+	return &InconsistencyError{Inconsistencies: g.inconsistencies}
+}
+
+// rule is an inference rule: the triples matching all the patterns of the
+// body entail the triples of the instantiated patterns of the head. A rule
+// without body entails axiomatic triples, and a rule without head entails
+// false.
+//
+// The matches of the body are then extended by the binds, and must satisfy
+// the filters and match none of the negated patterns. The rules of a
+// stratum are applied after the ones of the lower strata reach a fixpoint.
+type rule struct {
+	name    string
+	body    []TriplePattern
+	binds   []BindPattern
+	filters []Expr
+	not     []TriplePattern
+	head    []TriplePattern
+	stratum int
+}
+
+// ruleNamespaces are the prefixes of the terms of newRule.
+var ruleNamespaces = map[string]string{
+	"rdf":  rdfNS,
+	"rdfs": rdfsNS,
+	"owl":  owlNS,
+}
+
+// newRule returns a rule of the patterns of the body and head, which are
+// triple patterns separated by " . ", of variables and prefixed names, as
+// in "?c rdfs:subClassOf ?d . ?x rdf:type ?c". An empty head is false.
+func newRule(name, body, head string) rule {
+	return rule{name: name, body: rulePatterns(body), head: rulePatterns(head)}
+}
+
+func rulePatterns(s string) []TriplePattern {
+	var tps []TriplePattern
+	for _, tp := range strings.Split(s, " . ") {
+		f := strings.Fields(tp)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 3 {
+			panic("rdf: bad rule pattern: " + tp)
+		}
+		tps = append(tps, TriplePattern{Subj: ruleTerm(f[0]), Pred: ruleTerm(f[1]), Obj: ruleTerm(f[2])})
+	}
+	return tps
+}
+
+// ruleTerm returns the variable or IRI of a term of a rule pattern.
+func ruleTerm(s string) PatternTerm {
+	if strings.HasPrefix(s, "?") {
+		return Var(s[1:])
+	}
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		if ns, ok := ruleNamespaces[s[:i]]; ok {
+			return IRI{str: ns + s[i+1:]}
+		}
+	}
+	panic("rdf: bad rule term: " + s)
+}
+
+// bindings are the terms bound to the variables of a rule.
+type bindings map[Var]Term
+
+// tripleKey returns the key of a triple.
+func tripleKey(t Triple) string {
+	return termKey(t.Subj) + " " + termKey(t.Pred) + " " + termKey(t.Obj)
+}
+
+// newReasonedGraph returns a graph of the asserted triples, materialized
+// with the rules.
+func newReasonedGraph(rules []rule, asserted []Triple) *ReasonedGraph {
+	g := &ReasonedGraph{
+		rules:        rules,
+		all:          NewGraph(),
+		inferred:     NewGraph(),
+		justified:    make(map[string]Justification),
+		inconsistent: make(map[string]bool),
+	}
+	var delta []Triple
+	for _, t := range asserted {
+		if g.all.Add(t) {
+			delta = append(delta, t)
+		}
+	}
+	for stratum := 0; ; stratum++ {
+		var rules []rule
+		more := false
+		for _, r := range g.rules {
+			switch {
+			case r.stratum == stratum:
+				rules = append(rules, r)
+			case r.stratum > stratum:
+				more = true
+			}
+		}
+		if stratum > 0 {
+			// The rules of the stratum have not been applied yet.
+			delta = g.all.Triples()
+		}
+		for _, r := range rules {
+			if len(r.body) == 0 {
+				if b, ok := g.conditions(r, bindings{}); ok {
+					g.fire(r, b, nil, &delta)
+				}
+			}
+		}
+		g.materialize(rules, delta)
+		if !more {
+			return g
+		}
+	}
+}
+
+// materialize adds the triples entailed by the rules, until a fixpoint is
+// reached, and returns them. The evaluation is semi-naive: in each round,
+// only the matches of the rules using at least one triple new in the
+// previous round, the delta, are considered.
+func (g *ReasonedGraph) materialize(rules []rule, delta []Triple) []Triple {
+	var added []Triple
+	for len(delta) > 0 {
+		var next []Triple
+		g.matches(rules, delta, func(r rule, b bindings, premises []Triple) {
+			if b, ok := g.conditions(r, b); ok {
+				g.fire(r, b, premises, &next)
+			}
+		})
+		added = append(added, next...)
+		delta = next
+	}
+	return added
+}
+
+// matches calls fn with each match of the body of each rule using at least
+// one of the triples of the delta, and the matched triples.
+func (g *ReasonedGraph) matches(rules []rule, delta []Triple, fn func(r rule, b bindings, premises []Triple)) {
+	for _, r := range rules {
+		premises := make([]Triple, len(r.body))
+		for i, tp := range r.body {
+			for _, t := range delta {
+				b, ok := unifyPattern(tp, t, bindings{})
+				if !ok {
+					continue
+				}
+				premises[i] = t
+				g.join(r.body, premises, b, func(b bindings) bool {
+					fn(r, b, premises)
+					return true
+				})
+				premises[i] = Triple{}
+			}
+		}
+	}
+}
+
+// negatedMatches calls fn with each match of the body of each rule whose
+// negated patterns match one of the triples, and the matched triples.
+func (g *ReasonedGraph) negatedMatches(rules []rule, ts []Triple, fn func(r rule, b bindings, premises []Triple)) {
+	for _, r := range rules {
+		if len(r.not) == 0 {
+			continue
+		}
+		// Only the variables of the body restrict its matches.
+		vars := make(map[Var]bool)
+		for _, tp := range r.body {
+			for _, t := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+				if v, ok := t.(Var); ok {
+					vars[v] = true
+				}
+			}
+		}
+		premises := make([]Triple, len(r.body))
+		for _, tp := range r.not {
+			for _, t := range ts {
+				b, ok := unifyPattern(tp, t, bindings{})
+				if !ok {
+					continue
+				}
+				for v := range b {
+					if !vars[v] {
+						delete(b, v)
+					}
+				}
+				g.join(r.body, premises, b, func(b bindings) bool {
+					fn(r, b, premises)
+					return true
+				})
+			}
+		}
+	}
+}
+
+// conditions returns the bindings of a match of the body of the rule,
+// extended by its binds, and false if a bind fails, a filter does not hold,
+// or a negated pattern matches.
+func (g *ReasonedGraph) conditions(r rule, b bindings) (bindings, bool) {
+	b, ok := g.evaluate(r, b)
+	if !ok {
+		return nil, false
+	}
+	for _, tp := range r.not {
+		subj, pred, obj, ok := patternTerms(tp, b)
+		if !ok {
+			continue
+		}
+		found := false
+		g.all.match(subj, pred, obj, func(t Triple) {
+			if _, ok := unifyPattern(tp, t, b); ok {
+				found = true
+			}
+		})
+		if found {
+			return nil, false
+		}
+	}
+	return b, true
+}
+
+// evaluate returns the bindings of a match of the body of the rule,
+// extended by its binds, and false if a bind fails or a filter does not
+// hold.
+func (g *ReasonedGraph) evaluate(r rule, b bindings) (bindings, bool) {
+	if len(r.binds) > 0 || len(r.filters) > 0 {
+		if g.e == nil {
+			g.e = &evaluator{now: time.Now()}
+		}
+		sol := make(Solution, len(b)+len(r.binds))
+		for v, t := range b {
+			sol[string(v)] = t
+		}
+		if len(r.binds) > 0 {
+			ext := make(bindings, len(b)+len(r.binds))
+			for v, t := range b {
+				ext[v] = t
+			}
+			for _, bp := range r.binds {
+				t, err := g.e.expr(bp.Expr, sol)
+				if err != nil {
+					return nil, false
+				}
+				sol[string(bp.Var)] = t
+				ext[bp.Var] = t
+			}
+			b = ext
+		}
+		if !g.e.filter(r.filters, sol) {
+			return nil, false
+		}
+	}
+	return b, true
+}
+
+// fire adds the triples of the head of the rule, instantiated with the
+// bindings of a match of its body, to the graph and the delta, or records
+// an inconsistency if the rule has no head.
+func (g *ReasonedGraph) fire(r rule, b bindings, premises []Triple, delta *[]Triple) {
+	if len(r.head) == 0 {
+		key := inconsistencyKey(r.name, premises)
+		if !g.inconsistent[key] {
+			g.inconsistent[key] = true
+			g.inconsistencies = append(g.inconsistencies, Inconsistency{
+				Rule:    r.name,
+				Triples: append([]Triple(nil), premises...),
+			})
+		}
+		return
+	}
+	for _, h := range r.head {
+		t, ok := instantiate(h, b)
+		if ok && g.all.Add(t) {
+			g.inferred.Add(t)
+			g.justified[tripleKey(t)] = Justification{
+				Rule:     r.name,
+				Premises: append([]Triple(nil), premises...),
+			}
+			*delta = append(*delta, t)
+		}
+	}
+}
+
+func inconsistencyKey(rule string, ts []Triple) string {
+	var b strings.Builder
+	b.WriteString(rule)
+	for _, t := range ts {
+		b.WriteByte(0)
+		b.WriteString(tripleKey(t))
+	}
+	return b.String()
+}
+
+// join calls fn with the bindings of each match of the patterns of the body
+// in the graph, extending the given bindings, until fn returns false. The
+// patterns with a premise are already matched, and the matched triples are
+// set as the premises of the others. The pattern with the most bound terms
+// is matched first. It returns false if fn did.
+func (g *ReasonedGraph) join(body []TriplePattern, premises []Triple, b bindings, fn func(bindings) bool) bool {
+	next, most := -1, -1
+	for i, tp := range body {
+		if premises[i].Subj != nil {
+			continue
+		}
+		n := 0
+		for _, t := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+			if bound(t, b) != nil {
+				n++
+			}
+		}
+		if n > most {
+			next, most = i, n
+		}
+	}
+	if next < 0 {
+		return fn(b)
+	}
+	tp := body[next]
+	subj, pred, obj, ok := patternTerms(tp, b)
+	if !ok {
+		return true
+	}
+	more := true
+	g.all.match(subj, pred, obj, func(t Triple) {
+		if !more {
+			return
+		}
+		if b, ok := unifyPattern(tp, t, b); ok {
+			premises[next] = t
+			more = g.join(body, premises, b, fn)
+			premises[next] = Triple{}
+		}
+	})
+	return more
+}
+
+// patternTerms returns the terms of the pattern to match, with its bound
+// variables replaced by their terms, and nil for unbound ones. It returns
+// false if a bound term is not valid in its position, such as a literal
+// subject, so that nothing matches.
+func patternTerms(tp TriplePattern, b bindings) (subj Subject, pred Predicate, obj Object, ok bool) {
+	if t := bound(tp.Subj, b); t != nil {
+		if subj, ok = t.(Subject); !ok {
+			return nil, nil, nil, false
+		}
+	}
+	if t := bound(tp.Pred, b); t != nil {
+		if pred, ok = t.(Predicate); !ok {
+			return nil, nil, nil, false
+		}
+	}
+	if t := bound(tp.Obj, b); t != nil {
+		if obj, ok = t.(Object); !ok {
+			return nil, nil, nil, false
+		}
+	}
+	return subj, pred, obj, true
+}
+
+// bound returns the term bound to a variable, or the term itself if it is
+// not a variable. It returns nil for an unbound variable.
+func bound(t PatternTerm, b bindings) Term {
+	if v, ok := t.(Var); ok {
+		return b[v]
+	}
+	return t.(Term)
+}
+
+// unifyPattern returns the bindings extended with the terms of the triple
+// matching the variables of the pattern, and false if the triple does not
+// match it. The given bindings are not modified.
+func unifyPattern(tp TriplePattern, t Triple, b bindings) (bindings, bool) {
+	ext := b
+	ys := [3]Term{t.Subj, t.Pred, t.Obj}
+	for i, x := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+		y := ys[i]
+		v, ok := x.(Var)
+		if !ok {
+			if !sameTerm(x.(Term), y) {
+				return nil, false
+			}
+			continue
+		}
+		if bt, ok := ext[v]; ok {
+			if !sameTerm(bt, y) {
+				return nil, false
+			}
+			continue
+		}
+		if len(ext) == len(b) {
+			ext = make(bindings, len(b)+3)
+			for k, t := range b {
+				ext[k] = t
+			}
+		}
+		ext[v] = y
+	}
+	return ext, true
+}
+
+// sameTerm returns true if the terms are the same. IRIs, the most common
+// terms, are compared without computing their keys.
+func sameTerm(a, b Term) bool {
+	if a, ok := a.(IRI); ok {
+		b, ok := b.(IRI)
+		return ok && a == b
+	}
+	return termKey(a) == termKey(b)
+}
+
+// instantiate returns the triple of the pattern with its variables replaced
+// by their bindings, and false if the result is not a valid triple, such as
+// one with a literal subject.
+func instantiate(tp TriplePattern, b bindings) (Triple, bool) {
+	subj, ok1 := bound(tp.Subj, b).(Subject)
+	pred, ok2 := bound(tp.Pred, b).(Predicate)
+	obj, ok3 := bound(tp.Obj, b).(Object)
+	return Triple{Subj: subj, Pred: pred, Obj: obj}, ok1 && ok2 && ok3
+}
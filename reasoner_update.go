@@ -0,0 +1,395 @@
+package rdf
+
+import "strings"
+
+// Add asserts the triples, and updates the inferred triples incrementally:
+// only the inferences depending on the added triples are computed. A
+// triple which was inferred is asserted from now on.
+//
+// With negated patterns, added triples may invalidate inferences, which
+// are then deleted as by Remove. The inconsistencies are updated as well,
+// and reported by Err.
+func (g *ReasonedGraph) Add(ts ...Triple) {
+	var added []Triple
+	for _, t := range ts {
+		switch {
+		case g.inferred.Has(t):
+			g.inferred.Remove(t)
+			delete(g.justified, tripleKey(t))
+		case g.all.Add(t):
+			added = append(added, t)
+		}
+	}
+	g.update(added, make(map[string]Triple))
+}
+
+// Remove retracts asserted triples, and updates the inferred triples
+// incrementally, with the DRed (delete and rederive) algorithm: the
+// inferences depending on the removed triples are deleted, and then the
+// ones which have another derivation are derived again. A removed triple
+// which is entailed by the remaining ones is kept as inferred.
+//
+// Only asserted triples can be removed: removing an inferred triple, or a
+// triple which is not in the graph, has no effect.
+func (g *ReasonedGraph) Remove(ts ...Triple) {
+	gone := make(map[string]Triple)
+	for _, t := range ts {
+		if g.all.Has(t) && !g.inferred.Has(t) {
+			g.all.Remove(t)
+			gone[tripleKey(t)] = t
+		}
+	}
+	g.update(nil, gone)
+}
+
+// ruleInstance is a match of the body of a rule.
+type ruleInstance struct {
+	r        rule
+	premises []Triple
+}
+
+// update maintains the inferences after the assertion of the added triples,
+// which are in the graph, or the retraction of the gone ones, which are not,
+// stratum by stratum. The rules compiled from the asserted triples are
+// compiled again, and the matches of the rules which are no longer compiled
+// are deleted, while the ones of the new rules are added.
+func (g *ReasonedGraph) update(added []Triple, gone map[string]Triple) {
+	if len(added) == 0 && len(gone) == 0 {
+		return
+	}
+	var removedRules, newRules []rule
+	if g.compile != nil {
+		changed := append([]Triple(nil), added...)
+		for _, t := range gone {
+			changed = append(changed, t)
+		}
+		if rules := g.compile(g, changed); rules != nil {
+			removedRules, newRules = diffRules(g.rules, rules)
+			g.rules = rules
+			g.axioms = nil
+		}
+	}
+	max := 0
+	for _, rules := range [][]rule{g.rules, removedRules} {
+		for _, r := range rules {
+			if r.stratum > max {
+				max = r.stratum
+			}
+		}
+	}
+	for stratum := 0; stratum <= max; stratum++ {
+		added = g.updateStratum(stratum, removedRules, newRules, added, gone)
+	}
+
+	// The inconsistencies which still hold, once.
+	var incs []Inconsistency
+	seen := make(map[string]bool)
+	for _, inc := range g.inconsistencies {
+		key := inconsistencyKey(inc.Rule, inc.Triples)
+		if g.inconsistent[key] && !seen[key] {
+			seen[key] = true
+			incs = append(incs, inc)
+		}
+	}
+	g.inconsistencies = incs
+}
+
+// updateStratum maintains the inferences of the rules of the stratum, given
+// the triples added to the graph and the ones gone from it, by the changes
+// of the asserted triples and the lower strata. It returns the added
+// triples, with the ones newly inferred by the rules of the stratum, and
+// removes the ones it infers again from the gone ones.
+//
+// The triples inferred by the rules of a stratum are only matched by the
+// positive patterns of the rules of the same or higher strata, and by the
+// negated patterns of the rules of higher strata.
+func (g *ReasonedGraph) updateStratum(stratum int, removedRules, newRules []rule, added []Triple, gone map[string]Triple) []Triple {
+	of := func(rules []rule) []rule {
+		var rs []rule
+		for _, r := range rules {
+			if r.stratum == stratum {
+				rs = append(rs, r)
+			}
+		}
+		return rs
+	}
+	rules := of(g.rules)
+	goneTriples := make([]Triple, 0, len(gone))
+	for _, t := range gone {
+		goneTriples = append(goneTriples, t)
+	}
+
+	// Overdelete the inferences of the matches using a gone triple, or
+	// whose negated patterns match an added one, in the graph before the
+	// change: the gone triples are restored in the meantime. As the added
+	// triples are kept, some valid inferences may be deleted, but they are
+	// then derived again.
+	//
+	// The inferences which are supported are kept, and checked only once:
+	// as their premises are asserted triples which are not gone, or
+	// axiomatic ones, they stay supported whatever is overdeleted.
+	for _, t := range goneTriples {
+		g.all.Add(t)
+	}
+	var dropped []ruleInstance
+	var next []Triple
+	supported := make(map[string]bool)
+	overdelete := func(current bool) func(r rule, b bindings, premises []Triple) {
+		return func(r rule, b bindings, premises []Triple) {
+			b, ok := g.evaluate(r, b)
+			if !ok {
+				return
+			}
+			if len(r.head) == 0 {
+				key := inconsistencyKey(r.name, premises)
+				if g.inconsistent[key] {
+					delete(g.inconsistent, key)
+					if current {
+						dropped = append(dropped, ruleInstance{r, append([]Triple(nil), premises...)})
+					}
+				}
+				return
+			}
+			for _, h := range r.head {
+				t, ok := instantiate(h, b)
+				if !ok || !g.inferred.Has(t) {
+					continue
+				}
+				key := tripleKey(t)
+				if containsTriple(gone, key) || supported[key] {
+					continue
+				}
+				if j, ok := g.supported(t, stratum, gone); ok {
+					g.justified[key] = j
+					supported[key] = true
+					continue
+				}
+				gone[key] = t
+				next = append(next, t)
+			}
+		}
+	}
+	removed := overdelete(false)
+	for _, r := range of(removedRules) {
+		premises := make([]Triple, len(r.body))
+		g.join(r.body, premises, bindings{}, func(b bindings) bool {
+			removed(r, b, premises)
+			return true
+		})
+	}
+	g.negatedMatches(rules, added, overdelete(true))
+	for delta := append(goneTriples, next...); len(delta) > 0; {
+		next = nil
+		g.matches(rules, delta, overdelete(true))
+		delta = next
+	}
+	for _, t := range gone {
+		g.all.Remove(t)
+		g.inferred.Remove(t)
+		delete(g.justified, tripleKey(t))
+	}
+
+	// Derive again the gone triples with another derivation, by the rules
+	// of the stratum or lower ones, which are up to date.
+	var inferred []Triple
+	for _, t := range gone {
+		for _, r := range g.rules {
+			if r.stratum > stratum {
+				continue
+			}
+			for _, h := range r.head {
+				b, ok := unifyPattern(h, t, bindings{})
+				if !ok {
+					continue
+				}
+				premises := make([]Triple, len(r.body))
+				g.join(r.body, premises, b, func(b bindings) bool {
+					if g.all.Has(t) {
+						return false
+					}
+					if b, ok := g.conditions(r, b); ok {
+						g.fire(r, b, premises, &inferred)
+					}
+					return true
+				})
+			}
+		}
+	}
+
+	// Add the inferences of the matches using an added or derived again
+	// triple, whose negated patterns matched a gone triple, or of the new
+	// rules.
+	infer := func(r rule, b bindings, premises []Triple) {
+		if b, ok := g.conditions(r, b); ok {
+			g.fire(r, b, premises, &inferred)
+		}
+	}
+	goneTriples = goneTriples[:0]
+	for _, t := range gone {
+		if !g.all.Has(t) {
+			goneTriples = append(goneTriples, t)
+		}
+	}
+	g.negatedMatches(rules, goneTriples, infer)
+	for _, r := range of(newRules) {
+		premises := make([]Triple, len(r.body))
+		g.join(r.body, premises, bindings{}, func(b bindings) bool {
+			infer(r, b, premises)
+			return true
+		})
+	}
+	delta := append(append([]Triple(nil), inferred...), added...)
+	inferred = append(inferred, g.materialize(rules, delta)...)
+	for _, t := range inferred {
+		key := tripleKey(t)
+		if containsTriple(gone, key) {
+			delete(gone, key)
+		} else {
+			added = append(added, t)
+		}
+	}
+
+	// Report again the dropped inconsistencies which still hold.
+	for _, inc := range dropped {
+		if g.inconsistent[inconsistencyKey(inc.r.name, inc.premises)] {
+			continue
+		}
+		b, ok := bindings{}, true
+		for i, tp := range inc.r.body {
+			if !g.all.Has(inc.premises[i]) {
+				ok = false
+				break
+			}
+			if b, ok = unifyPattern(tp, inc.premises[i], b); !ok {
+				break
+			}
+		}
+		if ok {
+			infer(inc.r, b, inc.premises)
+		}
+	}
+	return added
+}
+
+// supported returns a justification of the inferred triple which holds
+// whatever is retracted, and false if there is none: either the triple is
+// entailed by the axioms alone, or by a rule of the stratum or lower ones
+// whose premises are asserted and not gone, or entailed by the axioms.
+// Such a triple needs not be overdeleted. Without this, the triples on
+// which most inferences depend, such as the axiomatic triples of RDFS or
+// the rdf:type rdfs:Class triples of the classes, would be overdeleted
+// along with most of the graph.
+func (g *ReasonedGraph) supported(t Triple, stratum int, gone map[string]Triple) (Justification, bool) {
+	if g.axioms == nil {
+		// The rules without negated patterns are monotonic: the triples
+		// they entail from the axioms are entailed by any graph.
+		var rules []rule
+		for _, r := range g.rules {
+			if len(r.not) == 0 {
+				r.stratum = 0
+				rules = append(rules, r)
+			}
+		}
+		g.axioms = newReasonedGraph(rules, nil)
+	}
+	if j, ok := g.axioms.Justification(t); ok {
+		return j, true
+	}
+	key := tripleKey(t)
+	var j Justification
+	for _, r := range g.rules {
+		if r.stratum > stratum || len(r.body) == 0 {
+			continue
+		}
+		for _, h := range r.head {
+			b, ok := unifyPattern(h, t, bindings{})
+			if !ok {
+				continue
+			}
+			premises := make([]Triple, len(r.body))
+			found := !g.join(r.body, premises, b, func(b bindings) bool {
+				for _, p := range premises {
+					if containsTriple(gone, tripleKey(p)) || (g.inferred.Has(p) && !g.axioms.Has(p)) {
+						return true
+					}
+				}
+				b, ok := g.conditions(r, b)
+				if !ok {
+					return true
+				}
+				if t, ok := instantiate(h, b); ok && tripleKey(t) == key {
+					j = Justification{Rule: r.name, Premises: append([]Triple(nil), premises...)}
+					return false
+				}
+				return true
+			})
+			if found {
+				return j, true
+			}
+		}
+	}
+	return Justification{}, false
+}
+
+// isUsed returns true if the term is in an asserted triple.
+func (g *ReasonedGraph) isUsed(t Term) bool {
+	key := termKey(t)
+	for _, idx := range [3]tripleIndex{g.all.spo, g.all.pos, g.all.osp} {
+		for _, ts := range idx[key] {
+			for _, t := range ts {
+				if !g.inferred.Has(t) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func containsTriple(ts map[string]Triple, key string) bool {
+	_, ok := ts[key]
+	return ok
+}
+
+// diffRules returns the rules which are only before, and the ones which
+// are only after.
+func diffRules(before, after []rule) (removed, added []rule) {
+	keys := make(map[string]bool)
+	for _, r := range before {
+		keys[ruleKey(r)] = true
+	}
+	for _, r := range after {
+		key := ruleKey(r)
+		if !keys[key] {
+			added = append(added, r)
+		}
+		delete(keys, key)
+	}
+	for _, r := range before {
+		if keys[ruleKey(r)] {
+			removed = append(removed, r)
+		}
+	}
+	return removed, added
+}
+
+// ruleKey returns the key of a rule, of its name and patterns. The keys of
+// compiled rules, which have no expressions, identify them.
+func ruleKey(r rule) string {
+	var b strings.Builder
+	b.WriteString(r.name)
+	for _, tps := range [2][]TriplePattern{r.body, r.head} {
+		b.WriteByte(0)
+		for _, tp := range tps {
+			for _, t := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+				if v, ok := t.(Var); ok {
+					b.WriteString("?" + string(v))
+				} else {
+					b.WriteString(termKey(t.(Term)))
+				}
+				b.WriteByte(' ')
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,292 @@
+package rdf
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// checkReasonedGraph checks that the graph, after updates, is the one
+// reasoned from its asserted triples from scratch.
+func checkReasonedGraph(t *testing.T, name string, g *ReasonedGraph, reason func([]Triple) *ReasonedGraph, asserted []Triple) {
+	t.Helper()
+	want := reason(asserted)
+	if got, want := sortedNTriples(g.Asserted()), sortedNTriples(asserted); got != want {
+		t.Errorf("%s: Asserted() =>\n%s\nwant:\n%s", name, got, want)
+	}
+	if got, want := sortedNTriples(g.Inferred()), sortedNTriples(want.Inferred()); got != want {
+		t.Errorf("%s: Inferred() =>\n%s\nwant:\n%s", name, got, want)
+	}
+	inconsistencies := func(g *ReasonedGraph) string {
+		var s []string
+		for _, inc := range g.inconsistencies {
+			s = append(s, inc.String())
+		}
+		sort.Strings(s)
+		return strings.Join(s, "\n")
+	}
+	if got, want := inconsistencies(g), inconsistencies(want); got != want {
+		t.Errorf("%s: inconsistencies =>\n%s\nwant:\n%s", name, got, want)
+	}
+	for _, tr := range g.Inferred() {
+		j, ok := g.Justification(tr)
+		if !ok {
+			t.Errorf("%s: %s not justified", name, tr.Serialize(NTriples))
+		}
+		for _, p := range j.Premises {
+			if !g.Has(p) {
+				t.Errorf("%s: premise %s of %s not in graph", name, p.Serialize(NTriples), tr.Serialize(NTriples))
+			}
+		}
+	}
+}
+
+func TestReasonedGraphUpdate(t *testing.T) {
+	rules, err := ParseRules(testRulesPrologue + `
+{ ?x ex:hasMother ?y } => { ?x ex:hasParent ?y } .
+{ ?x ex:hasParent ?y . ?y ex:hasParent ?z } => { ?x ex:hasGrandparent ?z } .
+{ ?x a ex:Person . ?x log:notIncludes { ?x ex:hasParent ?p } } => { ?x a ex:Orphan } .
+{ ?x a ex:Orphan ; ex:hasGuardian ?g } => { ?g a ex:Guardian } .
+{ ?x a ex:Orphan . ?x ex:age ?a . ?a math:lessThan 0 } => false .
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr, err := NewRuleReasoner(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		reason func([]Triple) *ReasonedGraph
+		input  string
+		extra  string
+	}{
+		{
+			"RDFS",
+			(&RDFSReasoner{}).Reason,
+			testOWLPrologue + `
+ex:Cat rdfs:subClassOf ex:Mammal .
+ex:Mammal rdfs:subClassOf ex:Animal .
+ex:Animal rdfs:subClassOf ex:Mammal .
+ex:hasOwner rdfs:domain ex:Pet ; rdfs:range ex:Person ; rdfs:subPropertyOf ex:knows .
+ex:felix a ex:Cat ; ex:hasOwner ex:alice .
+ex:list rdf:_2 ex:felix .
+`,
+			testOWLPrologue + `
+ex:knows rdfs:subPropertyOf ex:relatedTo .
+ex:tom a ex:Mammal ; ex:hasOwner ex:bob .
+ex:list rdf:_1 ex:tom ; rdf:_2 ex:tom .
+ex:Pet rdfs:subClassOf ex:Animal .
+`,
+		},
+		{
+			"OWL",
+			func(ts []Triple) *ReasonedGraph {
+				g, _ := (&OWLReasoner{}).Reason(ts)
+				return g
+			},
+			testOWLPrologue + `
+ex:hasParent owl:inverseOf ex:hasChild .
+ex:hasAncestor a owl:TransitiveProperty .
+ex:hasParent rdfs:subPropertyOf ex:hasAncestor .
+ex:hasGrandparent owl:propertyChainAxiom ( ex:hasParent ex:hasParent ) .
+ex:Person owl:equivalentClass ex:Human .
+ex:Cat owl:disjointWith ex:Dog .
+ex:carl ex:hasParent ex:bob .
+ex:bob ex:hasParent ex:alice .
+ex:bob a ex:Human .
+ex:bobby owl:sameAs ex:bob .
+ex:felix a ex:Cat .
+`,
+			testOWLPrologue + `
+ex:Parent owl:intersectionOf _:l1 .
+_:l1 rdf:first ex:Person ; rdf:rest _:l2 .
+_:l2 rdf:first ex:HasChildren ; rdf:rest rdf:nil .
+ex:hasChild rdfs:domain ex:HasChildren .
+ex:felix a ex:Dog .
+ex:alice ex:hasParent ex:eve .
+ex:r a owl:Restriction ; owl:onProperty ex:hasMother ; owl:maxCardinality 1 .
+ex:carl a ex:r ; ex:hasMother ex:m1, ex:m2 .
+`,
+		},
+		{
+			"rules",
+			func(ts []Triple) *ReasonedGraph {
+				g, _ := rr.Reason(ts)
+				return g
+			},
+			testRulesPrologue + `
+ex:a a ex:Person ; ex:hasMother ex:m ; ex:hasGuardian ex:g .
+ex:m a ex:Person ; ex:hasParent ex:gm .
+ex:b a ex:Person ; ex:hasGuardian ex:g ; ex:age -1 .
+ex:c a ex:Person .
+`,
+			testRulesPrologue + `
+ex:b ex:hasMother ex:a .
+ex:c ex:hasParent ex:b .
+ex:a ex:hasParent ex:m .
+ex:gm a ex:Person .
+`,
+		},
+	}
+	for _, test := range tests {
+		input := decodeTestTriples(t, test.input)
+		extra := decodeTestTriples(t, test.extra)
+
+		// Remove and add back each triple in turn.
+		g := test.reason(input)
+		for i, tr := range input {
+			g.Remove(tr)
+			checkReasonedGraph(t, test.name+": remove "+tr.Serialize(NTriples), g, test.reason, append(append([]Triple(nil), input[:i]...), input[i+1:]...))
+			g.Add(tr)
+			checkReasonedGraph(t, test.name+": add back "+tr.Serialize(NTriples), g, test.reason, input)
+		}
+
+		// Add and remove the extra triples, one by one and all at once.
+		asserted := input
+		for _, tr := range extra {
+			g.Add(tr)
+			asserted = append(asserted, tr)
+			checkReasonedGraph(t, test.name+": add "+tr.Serialize(NTriples), g, test.reason, asserted)
+		}
+		g.Remove(extra...)
+		checkReasonedGraph(t, test.name+": remove extra", g, test.reason, input)
+		g.Add(extra...)
+		checkReasonedGraph(t, test.name+": add extra", g, test.reason, asserted)
+
+		// Random changes.
+		rnd := rand.New(rand.NewSource(1))
+		in := make(map[string]bool)
+		for _, tr := range asserted {
+			in[tripleKey(tr)] = true
+		}
+		for i := 0; i < 10; i++ {
+			var ts []Triple
+			for _, tr := range asserted {
+				if rnd.Intn(4) == 0 {
+					ts = append(ts, tr)
+				}
+			}
+			if i%2 == 0 {
+				g.Remove(ts...)
+				for _, tr := range ts {
+					in[tripleKey(tr)] = false
+				}
+			} else {
+				g.Add(ts...)
+				for _, tr := range ts {
+					in[tripleKey(tr)] = true
+				}
+			}
+			var want []Triple
+			for _, tr := range asserted {
+				if in[tripleKey(tr)] {
+					want = append(want, tr)
+				}
+			}
+			checkReasonedGraph(t, test.name+": random change", g, test.reason, want)
+		}
+	}
+}
+
+func TestReasonedGraphAddInferred(t *testing.T) {
+	ts := decodeTestTriples(t, testOWLPrologue+`
+ex:Cat rdfs:subClassOf ex:Animal .
+ex:felix a ex:Cat .
+`)
+	g := (&RDFSReasoner{}).Reason(ts)
+	animal := testTriple(t, "ex:felix", "rdf:type", "ex:Animal")
+	if !g.IsInferred(animal) {
+		t.Fatalf("%s not inferred", animal.Serialize(NTriples))
+	}
+	g.Add(animal)
+	if g.IsInferred(animal) {
+		t.Errorf("added %s is inferred", animal.Serialize(NTriples))
+	}
+	if _, ok := g.Justification(animal); ok {
+		t.Errorf("added %s is justified", animal.Serialize(NTriples))
+	}
+
+	// The triple is still entailed once retracted.
+	g.Remove(animal)
+	if !g.IsInferred(animal) {
+		t.Errorf("removed %s not inferred", animal.Serialize(NTriples))
+	}
+
+	// Inferred triples cannot be removed.
+	n := g.Len()
+	g.Remove(animal)
+	if !g.Has(animal) || g.Len() != n {
+		t.Errorf("inferred %s removed", animal.Serialize(NTriples))
+	}
+}
+
+// benchmarkRDFSTriples returns the triples of n instances of a chain of
+// subclasses, linked by a subproperty with a domain and range.
+func benchmarkRDFSTriples(n int) []Triple {
+	ex := func(s string, a ...interface{}) IRI { return IRI{str: "http://example.org/" + fmt.Sprintf(s, a...)} }
+	var ts []Triple
+	for i := 1; i < 10; i++ {
+		ts = append(ts, Triple{Subj: ex("C%d", i), Pred: rdfsSubClassOf, Obj: ex("C%d", i-1)})
+	}
+	ts = append(ts,
+		Triple{Subj: ex("p"), Pred: IRI{str: rdfsNS + "subPropertyOf"}, Obj: ex("q")},
+		Triple{Subj: ex("q"), Pred: IRI{str: rdfsNS + "domain"}, Obj: ex("C0")},
+		Triple{Subj: ex("q"), Pred: IRI{str: rdfsNS + "range"}, Obj: ex("C0")},
+	)
+	for i := 0; i < n; i++ {
+		ts = append(ts,
+			Triple{Subj: ex("i%d", i), Pred: rdfType, Obj: ex("C9")},
+			Triple{Subj: ex("i%d", i), Pred: ex("p"), Obj: ex("i%d", (i+1)%n)},
+		)
+	}
+	return ts
+}
+
+func TestReasonedGraphRemoveRDFS(t *testing.T) {
+	ts := benchmarkRDFSTriples(20)
+	r := &RDFSReasoner{}
+	reason := func(ts []Triple) *ReasonedGraph { return r.Reason(ts) }
+	for _, i := range []int{len(ts) - 2, 4} {
+		g := r.Reason(ts)
+		g.Remove(ts[i])
+		asserted := append(append([]Triple(nil), ts[:i]...), ts[i+1:]...)
+		checkReasonedGraph(t, "Remove("+ts[i].Serialize(NTriples)+")", g, reason, asserted)
+		g.Add(ts[i])
+		checkReasonedGraph(t, "Add("+ts[i].Serialize(NTriples)+")", g, reason, ts)
+	}
+}
+
+// BenchmarkReasonedGraphRemove compares the removal of an instance, and of
+// a subclass relation, with reasoning from scratch.
+func BenchmarkReasonedGraphRemove(b *testing.B) {
+	ts := benchmarkRDFSTriples(500)
+	r := &RDFSReasoner{}
+	for _, bench := range []struct {
+		name   string
+		remove Triple
+	}{
+		{"instance", ts[len(ts)-2]},
+		{"subClassOf", ts[4]},
+	} {
+		g := r.Reason(ts)
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g.Remove(bench.remove)
+				b.StopTimer()
+				g.Add(bench.remove)
+				b.StartTimer()
+			}
+		})
+	}
+	// Reasoning from scratch, for comparison.
+	b.Run("recompute", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			r.Reason(ts[:len(ts)-1])
+		}
+	})
+}
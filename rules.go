@@ -0,0 +1,249 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rule is a user-defined inference rule: when the triple patterns of the
+// body match, and the conditions hold, the triples of the instantiated head
+// are inferred. Rules are given as Go values, or parsed from N3 with
+// ParseRules.
+//
+// For instance, the rule "if X worksFor Y and Y partOf Z then X
+// affiliatedWith Z" is:
+//
+//	rdf.Rule{
+//		Body: []rdf.TriplePattern{
+//			rdf.NewTriplePattern(rdf.Var("x"), worksFor, rdf.Var("y")),
+//			rdf.NewTriplePattern(rdf.Var("y"), partOf, rdf.Var("z")),
+//		},
+//		Head: []rdf.TriplePattern{
+//			rdf.NewTriplePattern(rdf.Var("x"), affiliatedWith, rdf.Var("z")),
+//		},
+//	}
+type Rule struct {
+	// Name identifies the rule in justifications and inconsistencies. If
+	// empty, the rule is named rule1, rule2, ... by its position.
+	Name string
+
+	// Body are the triple patterns which must all match.
+	Body []TriplePattern
+
+	// Bind are assignments of the values of SPARQL expressions to new
+	// variables, evaluated in order once the body matches. The rule does
+	// not fire if an expression is an error.
+	Bind []BindPattern
+
+	// Filter are SPARQL expressions which must all be true, as in a
+	// FILTER. They are evaluated after the binds.
+	Filter []Expr
+
+	// Not are triple patterns which must not match, given the bindings of
+	// the body and the binds: negation as failure. The rules are
+	// stratified, so that the triples matching a negated pattern are
+	// all inferred before it is tested.
+	Not []TriplePattern
+
+	// Head are the triple patterns of the inferred triples. A rule without
+	// head entails false: its matches are reported as inconsistencies.
+	Head []TriplePattern
+}
+
+// RuleReasoner materializes the inferences of user-defined rules, by
+// semi-naive bottom-up evaluation, stratum by stratum.
+type RuleReasoner struct {
+	rules []rule
+}
+
+// NewRuleReasoner returns a reasoner of the rules. It returns an error if
+// a rule is invalid, such as a rule with a variable in its head which is not
+// bound by its body, or if the rules are not stratifiable, that is if a
+// triple pattern depends on its own negation.
+func NewRuleReasoner(rules ...Rule) (*RuleReasoner, error) {
+	r := &RuleReasoner{}
+	for i, rl := range rules {
+		if rl.Name == "" {
+			rl.Name = fmt.Sprintf("rule%d", i+1)
+		}
+		if err := checkRule(rl); err != nil {
+			return nil, fmt.Errorf("rule %s: %v", rl.Name, err)
+		}
+		r.rules = append(r.rules, rule{
+			name:    rl.Name,
+			body:    rl.Body,
+			binds:   rl.Bind,
+			filters: rl.Filter,
+			not:     rl.Not,
+			head:    rl.Head,
+		})
+	}
+	if err := stratify(r.rules); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reason returns the triples, together with the triples inferred from them
+// by the rules. If a rule without head matches, the graph is returned along
+// with an *InconsistencyError.
+func (r *RuleReasoner) Reason(ts []Triple) (*ReasonedGraph, error) {
+	g := newReasonedGraph(r.rules, ts)
+	return g, g.Err()
+}
+
+// ReasonGraph returns the triples of the graph, together with the triples
+// inferred from them, as Reason does. The graph is not modified.
+func (r *RuleReasoner) ReasonGraph(g *Graph) (*ReasonedGraph, error) {
+	return r.Reason(g.Triples())
+}
+
+// checkRule returns an error if the rule is invalid.
+func checkRule(r Rule) error {
+	bound := make(map[Var]bool)
+	for _, tp := range r.Body {
+		if err := checkRulePattern(tp); err != nil {
+			return err
+		}
+		for _, t := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+			if v, ok := t.(Var); ok {
+				bound[v] = true
+			}
+		}
+	}
+	for _, bp := range r.Bind {
+		if bound[bp.Var] {
+			return fmt.Errorf("variable %s bound twice", newSPARQLWriter(nil).term(bp.Var))
+		}
+		if err := checkRuleExpr(bp.Expr); err != nil {
+			return err
+		}
+		bound[bp.Var] = true
+	}
+	for _, x := range r.Filter {
+		if err := checkRuleExpr(x); err != nil {
+			return err
+		}
+	}
+	for _, tp := range r.Not {
+		if err := checkRulePattern(tp); err != nil {
+			return err
+		}
+	}
+	for _, tp := range r.Head {
+		if err := checkRulePattern(tp); err != nil {
+			return err
+		}
+		for _, t := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+			switch t := t.(type) {
+			case Var:
+				if !bound[t] {
+					return fmt.Errorf("variable %s of head not bound by body", newSPARQLWriter(nil).term(t))
+				}
+			case Blank:
+				return errors.New("blank node in head")
+			}
+		}
+	}
+	return nil
+}
+
+func checkRulePattern(tp TriplePattern) error {
+	if tp.Path != nil {
+		return errors.New("property paths are not supported")
+	}
+	if tp.Subj == nil || tp.Pred == nil || tp.Obj == nil {
+		return errors.New("missing term in triple pattern")
+	}
+	switch tp.Pred.(type) {
+	case IRI, Var:
+		return nil
+	}
+	return fmt.Errorf("invalid predicate: %v", tp.Pred)
+}
+
+// checkRuleExpr returns an error if the expression has an EXISTS or NOT
+// EXISTS pattern, which is not stratified.
+func checkRuleExpr(x Expr) error {
+	switch x := x.(type) {
+	case nil:
+		return errors.New("missing expression")
+	case *ExistsExpr:
+		return errors.New("EXISTS is not supported; use negated patterns")
+	case *BinaryExpr:
+		if err := checkRuleExpr(x.Left); err != nil {
+			return err
+		}
+		return checkRuleExpr(x.Right)
+	case *UnaryExpr:
+		return checkRuleExpr(x.Arg)
+	case *InExpr:
+		if err := checkRuleExpr(x.Arg); err != nil {
+			return err
+		}
+		for _, y := range x.List {
+			if err := checkRuleExpr(y); err != nil {
+				return err
+			}
+		}
+	case *CallExpr:
+		for _, y := range x.Args {
+			if err := checkRuleExpr(y); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// stratify sets the strata of the rules, such that the rules inferring
+// triples which match a positive pattern of a rule are in its stratum or a
+// lower one, and the rules inferring triples which match a negated pattern
+// of a rule are in a lower stratum than it. A rule infers the triples
+// matching a pattern if a pattern of its head unifies with it.
+//
+// It returns an error if there is no such stratification.
+func stratify(rules []rule) error {
+	for changed := true; changed; {
+		changed = false
+		for i := range rules {
+			r := &rules[i]
+			s := r.stratum
+			for _, dep := range rules {
+				for _, h := range dep.head {
+					for _, tp := range r.body {
+						if dep.stratum > s && patternsUnify(h, tp) {
+							s = dep.stratum
+						}
+					}
+					for _, tp := range r.not {
+						if dep.stratum+1 > s && patternsUnify(h, tp) {
+							s = dep.stratum + 1
+						}
+					}
+				}
+			}
+			if s >= len(rules) {
+				return fmt.Errorf("rules are not stratifiable: rule %s depends on the negation of its own inferences", r.name)
+			}
+			if s != r.stratum {
+				r.stratum = s
+				changed = true
+			}
+		}
+	}
+	return nil
+}
+
+// patternsUnify returns true if a triple may match both patterns, that is
+// if their terms are the same or a variable at each position.
+func patternsUnify(a, b TriplePattern) bool {
+	for _, pair := range [3][2]PatternTerm{{a.Subj, b.Subj}, {a.Pred, b.Pred}, {a.Obj, b.Obj}} {
+		_, ok1 := pair[0].(Var)
+		_, ok2 := pair[1].(Var)
+		if !ok1 && !ok2 && termKey(pair[0].(Term)) != termKey(pair[1].(Term)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,333 @@
+package rdf
+
+import "strings"
+
+// The namespaces of the N3 builtins.
+const (
+	n3LogNS    = "http://www.w3.org/2000/10/swap/log#"
+	n3MathNS   = "http://www.w3.org/2000/10/swap/math#"
+	n3StringNS = "http://www.w3.org/2000/10/swap/string#"
+)
+
+// ParseRules parses rules in N3 syntax, as in:
+//
+//	@prefix ex: <http://example.org/> .
+//	@prefix math: <http://www.w3.org/2000/10/swap/math#> .
+//	{ ?x ex:worksFor ?y . ?y ex:partOf ?z } => { ?x ex:affiliatedWith ?z } .
+//	{ ?x ex:age ?a . ?a math:notLessThan 18 } => { ?x a ex:Adult } .
+//	{ ?x a ex:Adult . ?x ex:age ?a . ?a math:lessThan 0 } => false .
+//
+// The terms and triples are as in SPARQL, and the prefixes and base are
+// declared with either @prefix and @base, or PREFIX and BASE. Blank nodes
+// in a body are variables; they are not allowed in a head. A rule with the
+// head false entails an inconsistency.
+//
+// The builtins of the math:, string: and log: namespaces of N3 are
+// translated to the SPARQL expressions of Rule.Bind and Rule.Filter. The
+// functions, such as math:sum and string:concatenation, bind their object
+// if it is a variable not bound before; otherwise they are compared with
+// it. The relations, such as math:greaterThan and string:startsWith, are
+// filters. Negation is written with log:notIncludes, whose object is a
+// formula of a single triple pattern, and whose subject is ignored:
+//
+//	{ ?x a ex:Person . ?scope log:notIncludes { ?x ex:email ?e } } => { ?x a ex:Unreachable } .
+func ParseRules(s string) (rules []Rule, err error) {
+	p := newSPARQLParser(s)
+	defer p.recover(&err)
+	for p.peek().typ != tokenEOF {
+		switch {
+		case p.acceptKeyword("@prefix"):
+			p.parsePrefixDecl()
+			p.expectSymbol(".")
+		case p.acceptKeyword("@base"):
+			p.parseBaseDecl()
+			p.expectSymbol(".")
+		case p.acceptKeyword("PREFIX"):
+			p.parsePrefixDecl()
+		case p.acceptKeyword("BASE"):
+			p.parseBaseDecl()
+		default:
+			rules = append(rules, p.parseN3Rule())
+		}
+	}
+	return rules, nil
+}
+
+// parseN3Rule parses a rule: a body formula, "=>", and a head formula or
+// false, ended by '.'.
+func (p *sparqlParser) parseN3Rule() Rule {
+	start := p.peek()
+	var body, not []TriplePattern
+	p.parseFormula(&body, &not)
+	eq := p.next()
+	if gt := p.next(); !isSymbol(eq, "=") || !isSymbol(gt, ">") || gt.line != eq.line || gt.col != eq.col+1 {
+		p.unexpected(eq, "'=>'")
+	}
+	var head []TriplePattern
+	if !p.acceptKeyword("false") {
+		var headNot []TriplePattern
+		p.parseFormula(&head, &headNot)
+		if len(headNot) > 0 {
+			p.errorf("%d:%d: log:notIncludes in head", start.line, start.col)
+		}
+	}
+	p.expectSymbol(".")
+	return p.n3Rule(start, body, not, head)
+}
+
+// parseFormula parses the triples of a formula, between braces, adding the
+// patterns negated by log:notIncludes to not.
+func (p *sparqlParser) parseFormula(ts, not *[]TriplePattern) {
+	p.expectSymbol("{")
+	for !p.acceptSymbol("}") {
+		subj := p.parseGraphNode(ts)
+		p.parseN3PropertyList(subj, ts, not)
+		if !p.acceptSymbol(".") {
+			p.expectSymbol("}")
+			return
+		}
+	}
+}
+
+// parseN3PropertyList parses a property list, where the object of
+// log:notIncludes is a formula.
+func (p *sparqlParser) parseN3PropertyList(subj PatternTerm, ts, not *[]TriplePattern) {
+	for {
+		t := p.peek()
+		pred, path := p.parseVerb()
+		if path != nil {
+			p.errorf("%d:%d: property paths are not supported in rules", t.line, t.col)
+		}
+		for {
+			if t := p.peek(); isSymbol(t, "{") {
+				if pred != (IRI{str: n3LogNS + "notIncludes"}) {
+					p.errorf("%d:%d: formulas are only supported as objects of log:notIncludes", t.line, t.col)
+				}
+				var f, fNot []TriplePattern
+				p.parseFormula(&f, &fNot)
+				if len(f) != 1 || len(fNot) > 0 {
+					p.errorf("%d:%d: log:notIncludes of more than one triple pattern is not supported", t.line, t.col)
+				}
+				*not = append(*not, f...)
+			} else {
+				obj := p.parseGraphNode(ts)
+				*ts = append(*ts, TriplePattern{Subj: subj, Pred: pred, Obj: obj})
+			}
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if !p.acceptSymbol(";") {
+			return
+		}
+		for p.acceptSymbol(";") {
+		}
+		if !p.startsVerb(p.peek()) {
+			return
+		}
+	}
+}
+
+// n3Function is an N3 builtin computing its object from the members of its
+// subject list, or from its subject if unary.
+type n3Function struct {
+	unary    bool
+	min, max int // number of members of the list, where -1 is no maximum
+	fn       func(args []Expr) Expr
+}
+
+// n3Functions are the N3 builtin functions, by IRI.
+var n3Functions = map[string]n3Function{
+	n3MathNS + "sum":           {min: 2, max: -1, fn: func(args []Expr) Expr { return foldOperator("+", args) }},
+	n3MathNS + "difference":    {min: 2, max: 2, fn: func(args []Expr) Expr { return binary("-", args[0], args[1]) }},
+	n3MathNS + "product":       {min: 2, max: -1, fn: func(args []Expr) Expr { return foldOperator("*", args) }},
+	n3MathNS + "quotient":      {min: 2, max: 2, fn: func(args []Expr) Expr { return binary("/", args[0], args[1]) }},
+	n3MathNS + "negation":      {unary: true, fn: func(args []Expr) Expr { return &UnaryExpr{Op: "-", Arg: args[0]} }},
+	n3MathNS + "absoluteValue": {unary: true, fn: func(args []Expr) Expr { return Call("ABS", args[0]) }},
+	n3MathNS + "rounded":       {unary: true, fn: func(args []Expr) Expr { return Call("ROUND", args[0]) }},
+	n3StringNS + "concatenation": {min: 0, max: -1, fn: func(args []Expr) Expr {
+		c := &CallExpr{Name: "CONCAT"}
+		for _, arg := range args {
+			c.Args = append(c.Args, Call("STR", arg))
+		}
+		return c
+	}},
+}
+
+// n3Relations are the N3 builtin relations of a subject and an object, by
+// IRI.
+var n3Relations = map[string]func(a, b Expr) Expr{
+	n3MathNS + "equalTo":        func(a, b Expr) Expr { return Equal(a, b) },
+	n3MathNS + "notEqualTo":     func(a, b Expr) Expr { return NotEqual(a, b) },
+	n3MathNS + "lessThan":       func(a, b Expr) Expr { return Less(a, b) },
+	n3MathNS + "greaterThan":    func(a, b Expr) Expr { return Greater(a, b) },
+	n3MathNS + "notLessThan":    func(a, b Expr) Expr { return GreaterOrEqual(a, b) },
+	n3MathNS + "notGreaterThan": func(a, b Expr) Expr { return LessOrEqual(a, b) },
+
+	n3StringNS + "equalIgnoringCase":    func(a, b Expr) Expr { return Equal(lowerStr(a), lowerStr(b)) },
+	n3StringNS + "notEqualIgnoringCase": func(a, b Expr) Expr { return NotEqual(lowerStr(a), lowerStr(b)) },
+	n3StringNS + "lessThan":             func(a, b Expr) Expr { return Less(Call("STR", a), Call("STR", b)) },
+	n3StringNS + "greaterThan":          func(a, b Expr) Expr { return Greater(Call("STR", a), Call("STR", b)) },
+	n3StringNS + "contains":             func(a, b Expr) Expr { return Call("CONTAINS", Call("STR", a), Call("STR", b)) },
+	n3StringNS + "containsIgnoringCase": func(a, b Expr) Expr { return Call("CONTAINS", lowerStr(a), lowerStr(b)) },
+	n3StringNS + "startsWith":           func(a, b Expr) Expr { return Call("STRSTARTS", Call("STR", a), Call("STR", b)) },
+	n3StringNS + "endsWith":             func(a, b Expr) Expr { return Call("STRENDS", Call("STR", a), Call("STR", b)) },
+	n3StringNS + "matches":              func(a, b Expr) Expr { return Call("REGEX", Call("STR", a), b) },
+	n3StringNS + "notMatches":           func(a, b Expr) Expr { return Not(Call("REGEX", Call("STR", a), b)) },
+
+	n3LogNS + "equalTo":    func(a, b Expr) Expr { return Call("SAMETERM", a, b) },
+	n3LogNS + "notEqualTo": func(a, b Expr) Expr { return Not(Call("SAMETERM", a, b)) },
+}
+
+func lowerStr(x Expr) Expr { return Call("LCASE", Call("STR", x)) }
+
+// foldOperator returns the expressions joined by the operator.
+func foldOperator(op string, args []Expr) Expr {
+	e := args[0]
+	for _, x := range args[1:] {
+		e = binary(op, e, x)
+	}
+	return e
+}
+
+// n3Rule returns the rule of the patterns of a body and a head, where the
+// builtins of the body are translated to binds and filters, and its blank
+// nodes to variables, named by their label so as not to clash with the
+// variables of the rule.
+func (p *sparqlParser) n3Rule(start token, body, not, head []TriplePattern) Rule {
+	// The lists of the body, by head node.
+	lists := make(map[Blank][]PatternTerm)
+	firsts := make(map[Blank]PatternTerm)
+	rests := make(map[Blank]PatternTerm)
+	for _, tp := range body {
+		if b, ok := tp.Subj.(Blank); ok && strings.HasPrefix(b.id, "_:_b") {
+			switch tp.Pred {
+			case rdfFirst:
+				firsts[b] = tp.Obj
+			case rdfRest:
+				rests[b] = tp.Obj
+			}
+		}
+	}
+	var list func(t PatternTerm) ([]PatternTerm, bool)
+	list = func(t PatternTerm) ([]PatternTerm, bool) {
+		if t == rdfNil {
+			return nil, true
+		}
+		b, ok := t.(Blank)
+		if !ok {
+			return nil, false
+		}
+		if l, ok := lists[b]; ok {
+			return l, true
+		}
+		first, ok1 := firsts[b]
+		rest, ok2 := rests[b]
+		if !ok1 || !ok2 {
+			return nil, false
+		}
+		l, ok := list(rest)
+		if !ok {
+			return nil, false
+		}
+		lists[b] = append([]PatternTerm{first}, l...)
+		return lists[b], true
+	}
+
+	// The nodes of the lists of the builtins, whose triples are dropped.
+	builtin := func(tp TriplePattern) bool {
+		iri, ok := tp.Pred.(IRI)
+		if !ok {
+			return false
+		}
+		_, isFn := n3Functions[iri.str]
+		_, isRel := n3Relations[iri.str]
+		return isFn || isRel
+	}
+	dropped := make(map[Blank]bool)
+	var drop func(t PatternTerm)
+	drop = func(t PatternTerm) {
+		if b, ok := t.(Blank); ok && !dropped[b] {
+			if _, ok := firsts[b]; ok {
+				dropped[b] = true
+				drop(firsts[b])
+				drop(rests[b])
+			}
+		}
+	}
+	for _, tp := range body {
+		if builtin(tp) {
+			drop(tp.Subj)
+			drop(tp.Obj)
+		}
+	}
+
+	// Blank nodes are variables in the body.
+	variable := func(t PatternTerm) PatternTerm {
+		if b, ok := t.(Blank); ok {
+			return Var(b.id)
+		}
+		return t
+	}
+	operand := func(t PatternTerm) Expr {
+		return &TermExpr{Term: variable(t)}
+	}
+	var r Rule
+	bound := make(map[Var]bool)
+	var builtins []TriplePattern
+	for _, tp := range body {
+		if builtin(tp) {
+			builtins = append(builtins, tp)
+			continue
+		}
+		if b, ok := tp.Subj.(Blank); ok && dropped[b] {
+			continue
+		}
+		tp = TriplePattern{Subj: variable(tp.Subj), Pred: variable(tp.Pred), Obj: variable(tp.Obj)}
+		for _, t := range [3]PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+			if v, ok := t.(Var); ok {
+				bound[v] = true
+			}
+		}
+		r.Body = append(r.Body, tp)
+	}
+	for _, tp := range not {
+		r.Not = append(r.Not, TriplePattern{Subj: variable(tp.Subj), Pred: variable(tp.Pred), Obj: variable(tp.Obj)})
+	}
+
+	for _, tp := range builtins {
+		name := tp.Pred.(IRI).str
+		if rel, ok := n3Relations[name]; ok {
+			if _, isList := list(tp.Subj); isList && tp.Subj != rdfNil {
+				p.errorf("%d:%d: the subject of %s is a list", start.line, start.col, name)
+			}
+			r.Filter = append(r.Filter, rel(operand(tp.Subj), operand(tp.Obj)))
+			continue
+		}
+		fn := n3Functions[name]
+		var args []Expr
+		if fn.unary {
+			args = []Expr{operand(tp.Subj)}
+		} else {
+			members, ok := list(tp.Subj)
+			if !ok || len(members) < fn.min || (fn.max >= 0 && len(members) > fn.max) {
+				p.errorf("%d:%d: invalid subject list of %s", start.line, start.col, name)
+			}
+			for _, m := range members {
+				args = append(args, operand(m))
+			}
+		}
+		e := fn.fn(args)
+		if v, ok := variable(tp.Obj).(Var); ok && !bound[v] {
+			bound[v] = true
+			r.Bind = append(r.Bind, BindPattern{Expr: e, Var: v})
+			continue
+		}
+		r.Filter = append(r.Filter, Equal(e, operand(tp.Obj)))
+	}
+	r.Head = head
+	if r.Head == nil {
+		r.Head = []TriplePattern{}
+	}
+	return r
+}
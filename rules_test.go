@@ -0,0 +1,203 @@
+package rdf
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRulesPrologue = `@prefix ex: <http://example.org/> .
+@prefix log: <http://www.w3.org/2000/10/swap/log#> .
+@prefix math: <http://www.w3.org/2000/10/swap/math#> .
+@prefix string: <http://www.w3.org/2000/10/swap/string#> .
+`
+
+func TestRuleReasoner(t *testing.T) {
+	ex := func(s string) IRI { return IRI{str: "http://example.org/" + s} }
+	worksFor, partOf, affiliatedWith := ex("worksFor"), ex("partOf"), ex("affiliatedWith")
+	r, err := NewRuleReasoner(Rule{
+		Body: []TriplePattern{
+			NewTriplePattern(Var("x"), worksFor, Var("y")),
+			NewTriplePattern(Var("y"), partOf, Var("z")),
+		},
+		Head: []TriplePattern{
+			NewTriplePattern(Var("x"), affiliatedWith, Var("z")),
+		},
+	}, Rule{
+		Name: "transitive",
+		Body: []TriplePattern{
+			NewTriplePattern(Var("x"), partOf, Var("y")),
+			NewTriplePattern(Var("y"), partOf, Var("z")),
+		},
+		Head: []TriplePattern{
+			NewTriplePattern(Var("x"), partOf, Var("z")),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := decodeTestTriples(t, testRulesPrologue+`
+ex:alice ex:worksFor ex:lab .
+ex:lab ex:partOf ex:dept .
+ex:dept ex:partOf ex:univ .
+`)
+	g, err := r.Reason(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decodeTestTriples(t, testRulesPrologue+`
+ex:lab ex:partOf ex:univ .
+ex:alice ex:affiliatedWith ex:dept, ex:univ .
+`)
+	if got, want := sortedNTriples(g.Inferred()), sortedNTriples(want); got != want {
+		t.Errorf("Inferred() =>\n%s\nwant:\n%s", got, want)
+	}
+	triple := func(s, p, o string) Triple { return testTriple(t, s, p, o) }
+	j, _ := g.Justification(triple("ex:alice", "ex:affiliatedWith", "ex:dept"))
+	wantJ := Justification{Rule: "rule1", Premises: []Triple{
+		triple("ex:alice", "ex:worksFor", "ex:lab"),
+		triple("ex:lab", "ex:partOf", "ex:dept"),
+	}}
+	if !reflect.DeepEqual(j, wantJ) {
+		t.Errorf("Justification => %v, want %v", j, wantJ)
+	}
+	j, _ = g.Justification(triple("ex:lab", "ex:partOf", "ex:univ"))
+	if j.Rule != "transitive" {
+		t.Errorf("Justification of transitive triple => %v", j)
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	tests := []struct {
+		rules string
+		input string
+		want  string
+	}{
+		{
+			`{ ?x ex:price ?p . ?x ex:tax ?t . (?p ?t) math:sum ?total } => { ?x ex:total ?total } .`,
+			`ex:a ex:price 10 ; ex:tax 2 .`,
+			`ex:a ex:total 12 .`,
+		},
+		{
+			`{ ?x ex:age ?a . ?a math:notLessThan 18 } => { ?x a ex:Adult } .`,
+			`ex:a ex:age 18 . ex:b ex:age 17 .`,
+			`ex:a a ex:Adult .`,
+		},
+		{
+			`{ ?x ex:first ?f ; ex:last ?l . (?f " " ?l) string:concatenation ?n } => { ?x ex:name ?n } .`,
+			`ex:a ex:first "Ada" ; ex:last "Lovelace" .`,
+			`ex:a ex:name "Ada Lovelace" .`,
+		},
+		{
+			// A function is compared with a bound object.
+			`{ ?x ex:width ?w ; ex:height ?h ; ex:area ?a . (?w ?h) math:product ?a } => { ?x a ex:Consistent } .`,
+			`ex:a ex:width 2 ; ex:height 3 ; ex:area 6 . ex:b ex:width 2 ; ex:height 3 ; ex:area 5 .`,
+			`ex:a a ex:Consistent .`,
+		},
+		{
+			`{ ?x ex:name ?n . ?n string:startsWith "Dr" } => { ?x a ex:Doctor } .`,
+			`ex:a ex:name "Dr Who" . ex:b ex:name "Mr Who" .`,
+			`ex:a a ex:Doctor .`,
+		},
+		{
+			// Blank nodes of the body are variables.
+			`{ ?x ex:knows [ ex:knows ?y ] } => { ?x ex:knowsOfKnows ?y } .`,
+			`ex:a ex:knows ex:b . ex:b ex:knows ex:c .`,
+			`ex:a ex:knowsOfKnows ex:c .`,
+		},
+		{
+			// Stratified negation: ex:Orphan depends on the inferred
+			// ex:hasParent.
+			`{ ?x ex:hasMother ?y } => { ?x ex:hasParent ?y } .
+			{ ?x a ex:Person . ?x log:notIncludes { ?x ex:hasParent ?p } } => { ?x a ex:Orphan } .`,
+			`ex:a a ex:Person ; ex:hasMother ex:m . ex:b a ex:Person .`,
+			`ex:a ex:hasParent ex:m . ex:b a ex:Orphan .`,
+		},
+		{
+			// The negated rdf:type pattern only depends on the rules
+			// inferring ex:Adult, not on all the rdf:type ones.
+			`{ ?x a ex:Person . ?x log:notIncludes { ?x a ex:Adult } } => { ?x a ex:Minor } .
+			{ ?x ex:age ?a . ?a math:notLessThan 18 } => { ?x a ex:Adult } .`,
+			`ex:a a ex:Person ; ex:age 18 . ex:b a ex:Person ; ex:age 17 .`,
+			`ex:a a ex:Adult . ex:b a ex:Minor .`,
+		},
+		{
+			`PREFIX p: <http://example.org/>
+			{ ?x p:p ?y } => { ?y p:q ?x } .`,
+			`ex:a ex:p ex:b .`,
+			`ex:b ex:q ex:a .`,
+		},
+	}
+	for _, test := range tests {
+		rules, err := ParseRules(testRulesPrologue + test.rules)
+		if err != nil {
+			t.Errorf("%s: %v", test.rules, err)
+			continue
+		}
+		r, err := NewRuleReasoner(rules...)
+		if err != nil {
+			t.Errorf("%s: %v", test.rules, err)
+			continue
+		}
+		g, err := r.Reason(decodeTestTriples(t, testRulesPrologue+test.input))
+		if err != nil {
+			t.Errorf("%s: %v", test.rules, err)
+			continue
+		}
+		if got, want := sortedNTriples(g.Inferred()), sortedNTriples(decodeTestTriples(t, testRulesPrologue+test.want)); got != want {
+			t.Errorf("%s: Inferred() =>\n%s\nwant:\n%s", test.rules, got, want)
+		}
+	}
+}
+
+func TestRuleReasonerInconsistencies(t *testing.T) {
+	rules, err := ParseRules(testRulesPrologue + `
+{ ?x ex:age ?a . ?a math:lessThan 0 } => false .
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRuleReasoner(rules...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Reason(decodeTestTriples(t, testRulesPrologue+`ex:a ex:age -1 . ex:b ex:age 1 .`))
+	want := `inconsistent graph: rule1: <http://example.org/a> <http://example.org/age> "-1"^^<http://www.w3.org/2001/XMLSchema#integer> .`
+	if err == nil || err.Error() != want {
+		t.Errorf("Reason => %v, want %s", err, want)
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	tests := []struct {
+		rules string
+		err   string
+	}{
+		{`{ ?x ex:p ?y } => { ?x ex:q ?z } .`, "rule rule1: variable ?z of head not bound by body"},
+		{`{ ?x ex:p ?y } => { ?x ex:q [] } .`, "rule rule1: blank node in head"},
+		{`{ ?x ex:p ?y . (?y 1) math:sum ?y } => { ?x ex:q ?y } .`, ""},
+		{
+			`{ ?x a ex:A . ?x log:notIncludes { ?x a ex:B } } => { ?x a ex:C } .
+			{ ?x a ex:C } => { ?x a ex:B } .`,
+			"rules are not stratifiable",
+		},
+		{`{ ?x ex:p ?y } { ?x ex:q ?y } .`, "'=>'"},
+		{`{ ?x ex:p ?y } = > { ?x ex:q ?y } .`, "'=>'"},
+		{`{ ?x ex:p/ex:q ?y } => { ?x ex:q ?y } .`, "property paths are not supported"},
+		{`{ ?x ex:p { ?x ex:q ?y } } => { ?x ex:q ?y } .`, "formulas are only supported"},
+		{`{ ?x ex:p ?y . ?y math:sum ?z } => { ?x ex:q ?z } .`, "invalid subject list of"},
+		{`{ ?x ex:p ?y } => { ?x ex:q ?y }`, "expected '.'"},
+	}
+	for _, test := range tests {
+		rules, err := ParseRules(testRulesPrologue + test.rules)
+		if err == nil {
+			_, err = NewRuleReasoner(rules...)
+		}
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: got error %v", test.rules, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want %q", test.rules, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package rdf
+
+import (
+	"fmt"
+	"sort"
+)
+
+const shNS = "http://www.w3.org/ns/shacl#"
+
+// Severities of validation results.
+var (
+	SHACLViolation = IRI{str: shNS + "Violation"}
+	SHACLWarning   = IRI{str: shNS + "Warning"}
+	SHACLInfo      = IRI{str: shNS + "Info"}
+)
+
+var rdfsSubClassOf = IRI{str: rdfsNS + "subClassOf"}
+
+// sh returns the IRI of a term of the SHACL vocabulary.
+func sh(name string) IRI {
+	return IRI{str: shNS + name}
+}
+
+// SHACLValidator validates data graphs against the shapes of a shapes graph,
+// with the constraint components of SHACL Core, and the SPARQL-based
+// constraints and constraint components of SHACL-SPARQL. The triple rules
+// and SPARQL rules of SHACL Advanced Features are executed before the
+// validation.
+//
+// The shapes are the SHACL instances of sh:NodeShape and sh:PropertyShape,
+// the subjects of targets, and the shapes referenced by other shapes. The
+// focus nodes of a shape are given by its sh:targetNode, sh:targetClass,
+// sh:targetSubjectsOf and sh:targetObjectsOf targets, and by its implicit
+// class target if it is also a rdfs:Class. The instances of classes are
+// found by the rdf:type and rdfs:subClassOf triples of the data graph.
+//
+// Recursive shapes are not defined by SHACL: a node validated against a
+// shape while it is already validated against it conforms.
+type SHACLValidator struct {
+	shapes     *Graph
+	parsed     map[string]*shaclShape
+	targets    []*shaclShape
+	components []*shaclComponent
+}
+
+// shaclShape is a node or property shape.
+type shaclShape struct {
+	node        Term
+	path        Path // of a property shape, nil for a node shape
+	severity    IRI
+	messages    []Literal
+	deactivated bool
+	classTarget bool // the shape is a class, and its own target
+	constraints []shaclConstraint
+	properties  []*shaclShape
+	rules       []*shaclRule
+
+	// Targets.
+	targetNodes      []Term
+	targetClasses    []Term
+	targetSubjectsOf []IRI
+	targetObjectsOf  []IRI
+}
+
+// shaclConstraint is a constraint of a shape: a constraint component with
+// the values of its parameters. Its check returns its results, with Value,
+// ResultPath, ResultMessage and SourceConstraint if they differ from the
+// ones of the shape.
+type shaclConstraint struct {
+	component IRI
+	check     shaclCheck
+}
+
+// shaclCheck returns the results of a constraint, given a focus node and its
+// value nodes.
+type shaclCheck func(x *shaclValidation, focus Term, values []Term) []ValidationResult
+
+// ValidationReport is the result of a validation: sh:ValidationReport.
+type ValidationReport struct {
+	// Conforms is true if there is no result, whatever its severity.
+	Conforms bool
+
+	// Results are sorted by focus node, path and source shape.
+	Results []ValidationResult
+}
+
+// ValidationResult is a violation of a constraint by a focus node:
+// sh:ValidationResult.
+type ValidationResult struct {
+	FocusNode Term
+
+	// ResultPath is the path of the property shape, or the predicate of a
+	// sh:closed violation, and nil otherwise.
+	ResultPath Path
+
+	// Value is the value node violating the constraint, if any.
+	Value Term
+
+	SourceShape               Term
+	SourceConstraintComponent IRI
+
+	// ResultSeverity is SHACLViolation, SHACLWarning, SHACLInfo or another
+	// severity, as given by the sh:severity of the shape.
+	ResultSeverity IRI
+
+	// ResultMessage are the messages of the SPARQL-based constraint or
+	// constraint component, if any, and the sh:message of the shape
+	// otherwise.
+	ResultMessage []Literal
+
+	// SourceConstraint is the node of the SPARQL-based constraint, given
+	// by sh:sparql, and nil otherwise.
+	SourceConstraint Term
+}
+
+// NewSHACLValidator returns a validator of the shapes graph read from the
+// decoder. It returns an error if the triples cannot be decoded, or if a
+// shape with a target is ill-formed, such as a shape with an invalid path,
+// a sh:minCount which is not an integer or an invalid SPARQL query.
+func NewSHACLValidator(shapes TripleDecoder) (*SHACLValidator, error) {
+	g := NewGraph()
+	if err := g.Load(shapes); err != nil {
+		return nil, err
+	}
+	return NewSHACLValidatorGraph(g)
+}
+
+// NewSHACLValidatorGraph returns a validator of the shapes graph, as
+// NewSHACLValidator does. The graph must not be modified afterwards.
+func NewSHACLValidatorGraph(shapes *Graph) (*SHACLValidator, error) {
+	v := &SHACLValidator{shapes: shapes, parsed: make(map[string]*shaclShape)}
+	if err := v.parseComponents(); err != nil {
+		return nil, err
+	}
+
+	// The shapes with targets, in a deterministic order.
+	nodes := make(map[string]Term)
+	for _, name := range []string{"targetNode", "targetClass", "targetSubjectsOf", "targetObjectsOf"} {
+		for _, t := range shapes.Match(nil, sh(name), nil) {
+			nodes[termKey(t.Subj)] = t.Subj
+		}
+	}
+	for _, class := range []Term{sh("NodeShape"), sh("PropertyShape")} {
+		for _, t := range shapes.Match(nil, rdfType, class.(Object)) {
+			if v.isClass(t.Subj) {
+				nodes[termKey(t.Subj)] = t.Subj
+			}
+		}
+	}
+	keys := make([]string, 0, len(nodes))
+	for key := range nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s, err := v.shape(nodes[key])
+		if err != nil {
+			return nil, err
+		}
+		v.targets = append(v.targets, s)
+	}
+	return v, nil
+}
+
+// isClass returns true if the node is a rdfs:Class in the shapes graph.
+func (v *SHACLValidator) isClass(node Subject) bool {
+	for _, t := range v.shapes.Match(node, rdfType, nil) {
+		for _, c := range Paths(v.shapes, t.Obj, &ZeroOrMorePath{&LinkPath{rdfsSubClassOf}}) {
+			if c == (IRI{str: rdfsNS + "Class"}) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate validates the data graph read from the decoder, as ValidateGraph
+// does. It returns an error if the triples cannot be decoded.
+func (v *SHACLValidator) Validate(data TripleDecoder) (*ValidationReport, error) {
+	g := NewGraph()
+	if err := g.Load(data); err != nil {
+		return nil, err
+	}
+	return v.ValidateGraph(g)
+}
+
+// ValidateGraph validates the data graph. The rules of the shapes are
+// executed first, as by Infer, so that the inferred triples are added to
+// the data graph and validated along with the others.
+//
+// It returns an error if a SPARQL-based constraint reports a failure, by a
+// solution binding ?failure to true, or if a query cannot be evaluated.
+func (v *SHACLValidator) ValidateGraph(data *Graph) (*ValidationReport, error) {
+	x := newSHACLValidation(data)
+	x.infer(v)
+	var results []ValidationResult
+	for _, s := range v.targets {
+		for _, focus := range x.focusNodes(s) {
+			results = append(results, x.validate(s, focus)...)
+		}
+	}
+	if x.err != nil {
+		return nil, x.err
+	}
+	sortResults(results)
+	return &ValidationReport{Conforms: len(results) == 0, Results: results}, nil
+}
+
+// shaclValidation is the state of the validation of a data graph.
+type shaclValidation struct {
+	data *Graph
+
+	// active are the shapes and focus nodes being validated, by the keys
+	// of the shape and the node.
+	active map[string]bool
+
+	// blanks are the blank nodes of the data graph replacing the ones of the
+	// triples of SPARQL rules, by the keys of the rule, the focus node and
+	// the blank node, numbered by blankN.
+	blanks map[string]Blank
+	blankN int
+
+	// err is the first failure.
+	err error
+}
+
+func newSHACLValidation(data *Graph) *shaclValidation {
+	return &shaclValidation{data: data, active: make(map[string]bool), blanks: make(map[string]Blank)}
+}
+
+// fail records the failure, if it is the first one.
+func (x *shaclValidation) fail(err error) {
+	if x.err == nil {
+		x.err = err
+	}
+}
+
+// focusNodes returns the focus nodes of the targets of the shape.
+func (x *shaclValidation) focusNodes(s *shaclShape) []Term {
+	nodes := append([]Term(nil), s.targetNodes...)
+	classes := s.targetClasses
+	if s.classTarget {
+		classes = append(classes, s.node)
+	}
+	for _, c := range classes {
+		nodes = append(nodes, x.instances(c)...)
+	}
+	for _, p := range s.targetSubjectsOf {
+		for _, t := range x.data.Match(nil, p, nil) {
+			nodes = append(nodes, t.Subj)
+		}
+	}
+	for _, p := range s.targetObjectsOf {
+		for _, t := range x.data.Match(nil, p, nil) {
+			nodes = append(nodes, t.Obj)
+		}
+	}
+	return distinctTerms(nodes)
+}
+
+// instances returns the SHACL instances of the class: the nodes whose type
+// is the class or one of its subclasses.
+func (x *shaclValidation) instances(class Term) []Term {
+	var nodes []Term
+	for _, c := range Paths(x.data, class, &ZeroOrMorePath{&InversePath{&LinkPath{rdfsSubClassOf}}}) {
+		if c, ok := c.(Object); ok {
+			for _, t := range x.data.Match(nil, rdfType, c) {
+				nodes = append(nodes, t.Subj)
+			}
+		}
+	}
+	return nodes
+}
+
+// isInstance returns true if the node is a SHACL instance of the class.
+func (x *shaclValidation) isInstance(node, class Term) bool {
+	subj, ok := node.(Subject)
+	if !ok {
+		return false
+	}
+	key := termKey(class)
+	for _, t := range x.data.Match(subj, rdfType, nil) {
+		for _, c := range Paths(x.data, t.Obj, &ZeroOrMorePath{&LinkPath{rdfsSubClassOf}}) {
+			if termKey(c) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validate returns the results of the validation of the focus node against
+// the shape.
+func (x *shaclValidation) validate(s *shaclShape, focus Term) []ValidationResult {
+	key := termKey(s.node) + " " + termKey(focus)
+	if s.deactivated || x.active[key] {
+		return nil
+	}
+	x.active[key] = true
+	defer delete(x.active, key)
+
+	values := []Term{focus}
+	if s.path != nil {
+		values = Paths(x.data, focus, s.path)
+	}
+	var results []ValidationResult
+	for _, c := range s.constraints {
+		for _, r := range c.check(x, focus, values) {
+			r.FocusNode = focus
+			if r.ResultPath == nil {
+				r.ResultPath = s.path
+			}
+			r.SourceShape = s.node
+			r.SourceConstraintComponent = c.component
+			r.ResultSeverity = s.severity
+			if r.ResultMessage == nil {
+				r.ResultMessage = s.messages
+			}
+			results = append(results, r)
+		}
+	}
+	for _, p := range s.properties {
+		for _, value := range values {
+			results = append(results, x.validate(p, value)...)
+		}
+	}
+	return results
+}
+
+// conforms returns true if the node conforms to the shape: its validation
+// has no result.
+func (x *shaclValidation) conforms(node Term, s *shaclShape) bool {
+	return len(x.validate(s, node)) == 0
+}
+
+// sortResults sorts the results by focus node, path, source shape,
+// component and value.
+func sortResults(results []ValidationResult) {
+	key := func(r ValidationResult) [5]string {
+		k := [5]string{termKey(r.FocusNode), "", termKey(r.SourceShape), r.SourceConstraintComponent.str, ""}
+		if r.ResultPath != nil {
+			k[1] = r.ResultPath.String()
+		}
+		if r.Value != nil {
+			k[4] = termKey(r.Value)
+		}
+		return k
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := key(results[i]), key(results[j])
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+}
+
+// Triples returns the report as RDF: a sh:ValidationReport with a
+// sh:ValidationResult per result. The report, the results and the paths
+// which are not predicates are new blank nodes.
+func (r *ValidationReport) Triples() []Triple {
+	// The blank nodes of the results are kept: the new ones are distinct.
+	used := make(map[Term]bool)
+	for _, res := range r.Results {
+		used[res.FocusNode], used[res.Value], used[res.SourceShape], used[res.SourceConstraint] = true, true, true, true
+	}
+	n := 0
+	blank := func() Blank {
+		for {
+			n++
+			if b := (Blank{id: fmt.Sprintf("_:b%d", n)}); !used[b] {
+				return b
+			}
+		}
+	}
+	report := blank()
+	ts := []Triple{
+		{Subj: report, Pred: rdfType, Obj: sh("ValidationReport")},
+		{Subj: report, Pred: sh("conforms"), Obj: booleanLiteral(r.Conforms)},
+	}
+	for _, res := range r.Results {
+		node := blank()
+		ts = append(ts,
+			Triple{Subj: report, Pred: sh("result"), Obj: node},
+			Triple{Subj: node, Pred: rdfType, Obj: sh("ValidationResult")},
+			Triple{Subj: node, Pred: sh("focusNode"), Obj: res.FocusNode.(Object)},
+		)
+		if res.ResultPath != nil {
+			path, pts := shaclPathTriples(res.ResultPath, blank)
+			ts = append(ts, Triple{Subj: node, Pred: sh("resultPath"), Obj: path})
+			ts = append(ts, pts...)
+		}
+		if res.Value != nil {
+			ts = append(ts, Triple{Subj: node, Pred: sh("value"), Obj: res.Value.(Object)})
+		}
+		ts = append(ts,
+			Triple{Subj: node, Pred: sh("sourceShape"), Obj: res.SourceShape.(Object)},
+			Triple{Subj: node, Pred: sh("sourceConstraintComponent"), Obj: res.SourceConstraintComponent},
+			Triple{Subj: node, Pred: sh("resultSeverity"), Obj: res.ResultSeverity},
+		)
+		for _, msg := range res.ResultMessage {
+			ts = append(ts, Triple{Subj: node, Pred: sh("resultMessage"), Obj: msg})
+		}
+		if res.SourceConstraint != nil {
+			ts = append(ts, Triple{Subj: node, Pred: sh("sourceConstraint"), Obj: res.SourceConstraint.(Object)})
+		}
+	}
+	return ts
+}
+
+// shaclPathTriples returns the node of a SHACL property path, with the
+// triples describing it.
+func shaclPathTriples(p Path, blank func() Blank) (Object, []Triple) {
+	var ts []Triple
+	list := func(paths []Path) Object {
+		var head Object = rdfNil
+		nodes := make([]Blank, len(paths))
+		for i := range paths {
+			nodes[i] = blank()
+		}
+		for i := len(paths) - 1; i >= 0; i-- {
+			member, pts := shaclPathTriples(paths[i], blank)
+			ts = append(ts, Triple{Subj: nodes[i], Pred: rdfFirst, Obj: member}, Triple{Subj: nodes[i], Pred: rdfRest, Obj: head})
+			ts = append(ts, pts...)
+			head = nodes[i]
+		}
+		return head
+	}
+	unary := func(name string, sub Path) Object {
+		node := blank()
+		obj, pts := shaclPathTriples(sub, blank)
+		ts = append(ts, Triple{Subj: node, Pred: sh(name), Obj: obj})
+		ts = append(ts, pts...)
+		return node
+	}
+	var node Object
+	switch p := p.(type) {
+	case *LinkPath:
+		node = p.IRI
+	case *InversePath:
+		node = unary("inversePath", p.Path)
+	case *SequencePath:
+		node = list(p.Paths)
+	case *AlternativePath:
+		b := blank()
+		ts = append(ts, Triple{Subj: b, Pred: sh("alternativePath"), Obj: list(p.Paths)})
+		node = b
+	case *ZeroOrMorePath:
+		node = unary("zeroOrMorePath", p.Path)
+	case *OneOrMorePath:
+		node = unary("oneOrMorePath", p.Path)
+	case *ZeroOrOnePath:
+		node = unary("zeroOrOnePath", p.Path)
+	default:
+		// A negated property set has no SHACL syntax.
+		node = stringLiteral(p.String())
+	}
+	return node, ts
+}
@@ -0,0 +1,764 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// shape returns the shape of the node, parsed once.
+func (v *SHACLValidator) shape(node Term) (*shaclShape, error) {
+	key := termKey(node)
+	if s, ok := v.parsed[key]; ok {
+		return s, nil
+	}
+	s := &shaclShape{node: node, severity: SHACLViolation}
+	v.parsed[key] = s
+	if err := v.parseShape(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// shapeError is an error of an ill-formed shape.
+type shapeError struct {
+	shape Term
+	err   error
+}
+
+func (e *shapeError) Error() string {
+	return fmt.Sprintf("shape %s: %v", termKey(e.shape), e.err)
+}
+
+// objects returns the values of a SHACL parameter of the node, sorted.
+func (v *SHACLValidator) objects(node Term, name string) []Term {
+	return v.values(node, sh(name))
+}
+
+// values returns the objects of the triples of the shapes graph with the
+// node as subject and the predicate, sorted.
+func (v *SHACLValidator) values(node Term, p IRI) []Term {
+	subj, ok := node.(Subject)
+	if !ok {
+		return nil
+	}
+	var objs []Term
+	for _, t := range v.shapes.Match(subj, p, nil) {
+		objs = append(objs, t.Obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return termKey(objs[i]) < termKey(objs[j]) })
+	return objs
+}
+
+// parseShape parses the path, targets, constraints and rules of the shape.
+func (v *SHACLValidator) parseShape(s *shaclShape) error {
+	node := s.node
+	fail := func(format string, args ...interface{}) error {
+		return &shapeError{node, fmt.Errorf(format, args...)}
+	}
+	iri := func(name string, t Term) (IRI, error) {
+		if iri, ok := t.(IRI); ok {
+			return iri, nil
+		}
+		return IRI{}, fail("sh:%s must be an IRI: %s", name, termKey(t))
+	}
+	integer := func(name string, t Term) (int64, error) {
+		if n, ok := numericValue(t); ok && n.typ == numInteger {
+			return n.i, nil
+		}
+		return 0, fail("sh:%s must be an integer: %s", name, termKey(t))
+	}
+	boolean := func(name string, t Term) (bool, error) {
+		if l, ok := t.(Literal); ok && l.DataType == xsdBoolean && (l.str == "true" || l.str == "false") {
+			return l.str == "true", nil
+		}
+		return false, fail("sh:%s must be a boolean: %s", name, termKey(t))
+	}
+	list := func(name string, t Term) ([]Term, error) {
+		if l, ok := readList(v.shapes.Match, t); ok {
+			return l, nil
+		}
+		return nil, fail("sh:%s must be a list: %s", name, termKey(t))
+	}
+	shapes := func(ts []Term) ([]*shaclShape, error) {
+		var ss []*shaclShape
+		for _, t := range ts {
+			s, err := v.shape(t)
+			if err != nil {
+				return nil, err
+			}
+			ss = append(ss, s)
+		}
+		return ss, nil
+	}
+
+	switch paths := v.objects(node, "path"); len(paths) {
+	case 0:
+	case 1:
+		p, err := v.parsePath(paths[0], make(map[string]bool))
+		if err != nil {
+			return fail("%v", err)
+		}
+		s.path = p
+	default:
+		return fail("more than one sh:path")
+	}
+	if subj, ok := node.(Subject); ok {
+		s.classTarget = v.isClass(subj)
+	}
+	for _, t := range v.objects(node, "severity") {
+		sev, err := iri("severity", t)
+		if err != nil {
+			return err
+		}
+		s.severity = sev
+	}
+	for _, t := range v.objects(node, "message") {
+		if l, ok := t.(Literal); ok {
+			s.messages = append(s.messages, l)
+		}
+	}
+	for _, t := range v.objects(node, "deactivated") {
+		b, err := boolean("deactivated", t)
+		if err != nil {
+			return err
+		}
+		s.deactivated = s.deactivated || b
+	}
+
+	// Targets.
+	s.targetNodes = v.objects(node, "targetNode")
+	s.targetClasses = v.objects(node, "targetClass")
+	for _, name := range []string{"targetSubjectsOf", "targetObjectsOf"} {
+		for _, t := range v.objects(node, name) {
+			p, err := iri(name, t)
+			if err != nil {
+				return err
+			}
+			if name == "targetSubjectsOf" {
+				s.targetSubjectsOf = append(s.targetSubjectsOf, p)
+			} else {
+				s.targetObjectsOf = append(s.targetObjectsOf, p)
+			}
+		}
+	}
+
+	// Property shapes.
+	properties, err := shapes(v.objects(node, "property"))
+	if err != nil {
+		return err
+	}
+	for _, p := range properties {
+		if p.path == nil {
+			return fail("sh:property %s has no sh:path", termKey(p.node))
+		}
+	}
+	s.properties = properties
+
+	// Value type constraints.
+	for _, c := range v.objects(node, "class") {
+		c := c
+		s.addPerValue("ClassConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			return x.isInstance(value, c)
+		})
+	}
+	for _, t := range v.objects(node, "datatype") {
+		dt, err := iri("datatype", t)
+		if err != nil {
+			return err
+		}
+		s.addPerValue("DatatypeConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			l, ok := value.(Literal)
+			return ok && l.DataType == dt && isWellFormed(l)
+		})
+	}
+	for _, t := range v.objects(node, "nodeKind") {
+		kind, err := iri("nodeKind", t)
+		if err != nil {
+			return err
+		}
+		kinds, ok := shaclNodeKinds[strings.TrimPrefix(kind.str, shNS)]
+		if !ok || !strings.HasPrefix(kind.str, shNS) {
+			return fail("invalid sh:nodeKind: %s", termKey(t))
+		}
+		s.addPerValue("NodeKindConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			switch value.(type) {
+			case IRI:
+				return strings.Contains(kinds, "I")
+			case Blank:
+				return strings.Contains(kinds, "B")
+			}
+			return strings.Contains(kinds, "L")
+		})
+	}
+
+	// Cardinality constraints.
+	for _, t := range v.objects(node, "minCount") {
+		n, err := integer("minCount", t)
+		if err != nil {
+			return err
+		}
+		s.addCount("MinCountConstraintComponent", func(x *shaclValidation, values []Term) bool {
+			return int64(len(values)) >= n
+		})
+	}
+	for _, t := range v.objects(node, "maxCount") {
+		n, err := integer("maxCount", t)
+		if err != nil {
+			return err
+		}
+		s.addCount("MaxCountConstraintComponent", func(x *shaclValidation, values []Term) bool {
+			return int64(len(values)) <= n
+		})
+	}
+
+	// Value range constraints.
+	for _, r := range []struct {
+		name, component string
+		ok              func(cmp int) bool
+	}{
+		{"minExclusive", "MinExclusiveConstraintComponent", func(cmp int) bool { return cmp > 0 }},
+		{"minInclusive", "MinInclusiveConstraintComponent", func(cmp int) bool { return cmp >= 0 }},
+		{"maxExclusive", "MaxExclusiveConstraintComponent", func(cmp int) bool { return cmp < 0 }},
+		{"maxInclusive", "MaxInclusiveConstraintComponent", func(cmp int) bool { return cmp <= 0 }},
+	} {
+		ok := r.ok
+		for _, t := range v.objects(node, r.name) {
+			bound, isLiteral := t.(Literal)
+			if !isLiteral {
+				return fail("sh:%s must be a literal: %s", r.name, termKey(t))
+			}
+			s.addPerValue(r.component, func(x *shaclValidation, focus, value Term) bool {
+				l, isLiteral := value.(Literal)
+				if !isLiteral {
+					return false
+				}
+				cmp, err := compareLiterals(l, bound)
+				return err == nil && ok(cmp)
+			})
+		}
+	}
+
+	// String-based constraints.
+	for _, t := range v.objects(node, "minLength") {
+		n, err := integer("minLength", t)
+		if err != nil {
+			return err
+		}
+		s.addPerValue("MinLengthConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			str, ok := shaclString(value)
+			return ok && int64(utf8.RuneCountInString(str)) >= n
+		})
+	}
+	for _, t := range v.objects(node, "maxLength") {
+		n, err := integer("maxLength", t)
+		if err != nil {
+			return err
+		}
+		s.addPerValue("MaxLengthConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			str, ok := shaclString(value)
+			return ok && int64(utf8.RuneCountInString(str)) <= n
+		})
+	}
+	var flags string
+	for _, t := range v.objects(node, "flags") {
+		flags = t.String()
+	}
+	for _, t := range v.objects(node, "pattern") {
+		re, err := regexpFlags(t.String(), flags)
+		if err != nil {
+			return fail("invalid sh:pattern: %v", err)
+		}
+		s.addPerValue("PatternConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			str, ok := shaclString(value)
+			return ok && re.MatchString(str)
+		})
+	}
+	for _, t := range v.objects(node, "languageIn") {
+		ranges, err := list("languageIn", t)
+		if err != nil {
+			return err
+		}
+		s.addPerValue("LanguageInConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			l, ok := value.(Literal)
+			if !ok || l.lang == "" {
+				return false
+			}
+			for _, r := range ranges {
+				if langMatches(l.lang, r.String()) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	for _, t := range v.objects(node, "uniqueLang") {
+		b, err := boolean("uniqueLang", t)
+		if err != nil {
+			return err
+		}
+		if !b {
+			continue
+		}
+		s.add("UniqueLangConstraintComponent", func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+			var results []ValidationResult
+			seen := make(map[string]int)
+			for _, value := range values {
+				if l, ok := value.(Literal); ok && l.lang != "" {
+					lang := strings.ToLower(l.lang)
+					if seen[lang]++; seen[lang] == 2 {
+						results = append(results, ValidationResult{})
+					}
+				}
+			}
+			return results
+		})
+	}
+
+	// Property pair constraints.
+	for _, r := range []struct {
+		name, component string
+		ok              func(x *shaclValidation, value Term, others []Term) bool
+	}{
+		{"disjoint", "DisjointConstraintComponent", func(x *shaclValidation, value Term, others []Term) bool {
+			return !containsTerm(others, value)
+		}},
+		{"lessThan", "LessThanConstraintComponent", func(x *shaclValidation, value Term, others []Term) bool {
+			return compareAll(value, others, func(cmp int) bool { return cmp < 0 })
+		}},
+		{"lessThanOrEquals", "LessThanOrEqualsConstraintComponent", func(x *shaclValidation, value Term, others []Term) bool {
+			return compareAll(value, others, func(cmp int) bool { return cmp <= 0 })
+		}},
+	} {
+		ok := r.ok
+		for _, t := range v.objects(node, r.name) {
+			p, err := iri(r.name, t)
+			if err != nil {
+				return err
+			}
+			s.addPerValue(r.component, func(x *shaclValidation, focus, value Term) bool {
+				return ok(x, value, x.objects(focus, p))
+			})
+		}
+	}
+	for _, t := range v.objects(node, "equals") {
+		p, err := iri("equals", t)
+		if err != nil {
+			return err
+		}
+		s.add("EqualsConstraintComponent", func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+			var results []ValidationResult
+			others := x.objects(focus, p)
+			for _, value := range values {
+				if !containsTerm(others, value) {
+					results = append(results, ValidationResult{Value: value})
+				}
+			}
+			for _, value := range others {
+				if !containsTerm(values, value) {
+					results = append(results, ValidationResult{Value: value})
+				}
+			}
+			return results
+		})
+	}
+
+	// Logical constraints.
+	for _, t := range v.objects(node, "not") {
+		not, err := v.shape(t)
+		if err != nil {
+			return err
+		}
+		s.addPerValue("NotConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			return !x.conforms(value, not)
+		})
+	}
+	for _, r := range []struct {
+		name, component string
+		ok              func(conforming, n int) bool
+	}{
+		{"and", "AndConstraintComponent", func(conforming, n int) bool { return conforming == n }},
+		{"or", "OrConstraintComponent", func(conforming, n int) bool { return conforming > 0 }},
+		{"xone", "XoneConstraintComponent", func(conforming, n int) bool { return conforming == 1 }},
+	} {
+		ok := r.ok
+		for _, t := range v.objects(node, r.name) {
+			members, err := list(r.name, t)
+			if err != nil {
+				return err
+			}
+			ss, err := shapes(members)
+			if err != nil {
+				return err
+			}
+			s.addPerValue(r.component, func(x *shaclValidation, focus, value Term) bool {
+				n := 0
+				for _, s := range ss {
+					if x.conforms(value, s) {
+						n++
+					}
+				}
+				return ok(n, len(ss))
+			})
+		}
+	}
+
+	// Shape-based constraints.
+	ss, err := shapes(v.objects(node, "node"))
+	if err != nil {
+		return err
+	}
+	for _, shape := range ss {
+		shape := shape
+		s.addPerValue("NodeConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			return x.conforms(value, shape)
+		})
+	}
+	if err := v.parseQualifiedValueShape(s, integer, boolean); err != nil {
+		return err
+	}
+
+	// Other constraints.
+	for _, t := range v.objects(node, "closed") {
+		b, err := boolean("closed", t)
+		if err != nil {
+			return err
+		}
+		if !b {
+			continue
+		}
+		allowed := make(map[string]bool)
+		for _, t := range v.objects(node, "ignoredProperties") {
+			ignored, err := list("ignoredProperties", t)
+			if err != nil {
+				return err
+			}
+			for _, p := range ignored {
+				allowed[termKey(p)] = true
+			}
+		}
+		for _, p := range s.properties {
+			if link, ok := p.path.(*LinkPath); ok {
+				allowed[termKey(link.IRI)] = true
+			}
+		}
+		s.add("ClosedConstraintComponent", func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+			var results []ValidationResult
+			for _, value := range values {
+				subj, ok := value.(Subject)
+				if !ok {
+					continue
+				}
+				for _, t := range x.data.Match(subj, nil, nil) {
+					if p, ok := t.Pred.(IRI); ok && !allowed[termKey(p)] {
+						results = append(results, ValidationResult{ResultPath: &LinkPath{p}, Value: t.Obj})
+					}
+				}
+			}
+			return results
+		})
+	}
+	for _, t := range v.objects(node, "hasValue") {
+		t := t
+		s.addCount("HasValueConstraintComponent", func(x *shaclValidation, values []Term) bool {
+			return containsTerm(values, t)
+		})
+	}
+	for _, t := range v.objects(node, "in") {
+		members, err := list("in", t)
+		if err != nil {
+			return err
+		}
+		s.addPerValue("InConstraintComponent", func(x *shaclValidation, focus, value Term) bool {
+			return containsTerm(members, value)
+		})
+	}
+
+	for _, parse := range []func(*shaclShape) error{v.parseSPARQLConstraints, v.parseRules} {
+		err := parse(s)
+		if _, ok := err.(*shapeError); err != nil && !ok {
+			err = &shapeError{node, err}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseQualifiedValueShape parses the sh:qualifiedValueShape of the shape,
+// with its sh:qualifiedMinCount, sh:qualifiedMaxCount and
+// sh:qualifiedValueShapesDisjoint parameters.
+func (v *SHACLValidator) parseQualifiedValueShape(s *shaclShape, integer func(string, Term) (int64, error), boolean func(string, Term) (bool, error)) error {
+	for _, t := range v.objects(s.node, "qualifiedValueShape") {
+		q, err := v.shape(t)
+		if err != nil {
+			return err
+		}
+		disjoint := false
+		for _, t := range v.objects(s.node, "qualifiedValueShapesDisjoint") {
+			if disjoint, err = boolean("qualifiedValueShapesDisjoint", t); err != nil {
+				return err
+			}
+		}
+
+		// The sibling shapes are the qualified value shapes of the other
+		// property shapes of the shapes of which it is a property shape.
+		var siblings []*shaclShape
+		if subj, ok := s.node.(Object); ok && disjoint {
+			for _, parent := range v.shapes.Match(nil, sh("property"), subj) {
+				for _, p := range v.objects(parent.Subj, "property") {
+					if termKey(p) == termKey(s.node) {
+						continue
+					}
+					for _, t := range v.objects(p, "qualifiedValueShape") {
+						sibling, err := v.shape(t)
+						if err != nil {
+							return err
+						}
+						siblings = append(siblings, sibling)
+					}
+				}
+			}
+		}
+		count := func(x *shaclValidation, values []Term) int64 {
+			var n int64
+		values:
+			for _, value := range values {
+				if !x.conforms(value, q) {
+					continue
+				}
+				for _, sibling := range siblings {
+					if x.conforms(value, sibling) {
+						continue values
+					}
+				}
+				n++
+			}
+			return n
+		}
+		for _, t := range v.objects(s.node, "qualifiedMinCount") {
+			n, err := integer("qualifiedMinCount", t)
+			if err != nil {
+				return err
+			}
+			s.addCount("QualifiedMinCountConstraintComponent", func(x *shaclValidation, values []Term) bool {
+				return count(x, values) >= n
+			})
+		}
+		for _, t := range v.objects(s.node, "qualifiedMaxCount") {
+			n, err := integer("qualifiedMaxCount", t)
+			if err != nil {
+				return err
+			}
+			s.addCount("QualifiedMaxCountConstraintComponent", func(x *shaclValidation, values []Term) bool {
+				return count(x, values) <= n
+			})
+		}
+	}
+	return nil
+}
+
+// parsePath parses a SHACL property path. The nodes are the ones being
+// parsed, to reject cyclic paths.
+func (v *SHACLValidator) parsePath(node Term, nodes map[string]bool) (Path, error) {
+	switch node := node.(type) {
+	case IRI:
+		return &LinkPath{node}, nil
+	case Blank:
+		key := termKey(node)
+		if nodes[key] {
+			return nil, errors.New("cyclic sh:path")
+		}
+		nodes[key] = true
+		defer delete(nodes, key)
+		parseAll := func(ts []Term) ([]Path, error) {
+			paths := make([]Path, len(ts))
+			for i, t := range ts {
+				p, err := v.parsePath(t, nodes)
+				if err != nil {
+					return nil, err
+				}
+				paths[i] = p
+			}
+			return paths, nil
+		}
+		if members, ok := readList(v.shapes.Match, node); ok && len(members) >= 2 {
+			paths, err := parseAll(members)
+			if err != nil {
+				return nil, err
+			}
+			return &SequencePath{paths}, nil
+		}
+		var path Path
+		n := 0
+		for _, t := range v.shapes.Match(node, nil, nil) {
+			var err error
+			switch t.Pred {
+			case sh("alternativePath"):
+				members, ok := readList(v.shapes.Match, t.Obj)
+				if !ok || len(members) < 2 {
+					return nil, fmt.Errorf("invalid sh:alternativePath: %s", termKey(t.Obj))
+				}
+				var paths []Path
+				paths, err = parseAll(members)
+				path = &AlternativePath{paths}
+			case sh("inversePath"):
+				path, err = v.parsePath(t.Obj, nodes)
+				path = &InversePath{path}
+			case sh("zeroOrMorePath"):
+				path, err = v.parsePath(t.Obj, nodes)
+				path = &ZeroOrMorePath{path}
+			case sh("oneOrMorePath"):
+				path, err = v.parsePath(t.Obj, nodes)
+				path = &OneOrMorePath{path}
+			case sh("zeroOrOnePath"):
+				path, err = v.parsePath(t.Obj, nodes)
+				path = &ZeroOrOnePath{path}
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			n++
+		}
+		if n == 1 {
+			return path, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid sh:path: %s", termKey(node))
+}
+
+// add adds a constraint of the component to the shape.
+func (s *shaclShape) add(component string, check shaclCheck) {
+	s.constraints = append(s.constraints, shaclConstraint{component: sh(component), check: check})
+}
+
+// addPerValue adds a constraint of the component to the shape, with a
+// result for each value node for which ok is false.
+func (s *shaclShape) addPerValue(component string, ok func(x *shaclValidation, focus, value Term) bool) {
+	s.add(component, func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+		var results []ValidationResult
+		for _, value := range values {
+			if !ok(x, focus, value) {
+				results = append(results, ValidationResult{Value: value})
+			}
+		}
+		return results
+	})
+}
+
+// addCount adds a constraint of the component to the shape, with a single
+// result without value if ok is false for the value nodes.
+func (s *shaclShape) addCount(component string, ok func(x *shaclValidation, values []Term) bool) {
+	s.add(component, func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+		if ok(x, values) {
+			return nil
+		}
+		return []ValidationResult{{}}
+	})
+}
+
+// objects returns the objects of the triples of the data graph with the
+// node as subject and the predicate.
+func (x *shaclValidation) objects(node Term, p IRI) []Term {
+	subj, ok := node.(Subject)
+	if !ok {
+		return nil
+	}
+	var objs []Term
+	for _, t := range x.data.Match(subj, p, nil) {
+		objs = append(objs, t.Obj)
+	}
+	return objs
+}
+
+// shaclNodeKinds are the kinds of nodes of the values of sh:nodeKind: I
+// for IRIs, B for blank nodes and L for literals.
+var shaclNodeKinds = map[string]string{
+	"IRI":                "I",
+	"BlankNode":          "B",
+	"Literal":            "L",
+	"BlankNodeOrIRI":     "BI",
+	"BlankNodeOrLiteral": "BL",
+	"IRIOrLiteral":       "IL",
+}
+
+// shaclString returns the string value of an IRI or a literal, as the STR
+// function of SPARQL, and false for a blank node.
+func shaclString(t Term) (string, bool) {
+	switch t := t.(type) {
+	case IRI:
+		return t.str, true
+	case Literal:
+		return t.str, true
+	}
+	return "", false
+}
+
+func containsTerm(ts []Term, t Term) bool {
+	key := termKey(t)
+	for _, u := range ts {
+		if termKey(u) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// compareAll returns true if the comparison of the value with each of the
+// others is ok. Values which cannot be compared are not.
+func compareAll(value Term, others []Term, ok func(cmp int) bool) bool {
+	for _, other := range others {
+		a, ok1 := value.(Literal)
+		b, ok2 := other.(Literal)
+		if !ok1 || !ok2 {
+			return false
+		}
+		cmp, err := compareLiterals(a, b)
+		if err != nil || !ok(cmp) {
+			return false
+		}
+	}
+	return true
+}
+
+// xsdIntegerRanges are the value ranges of the derived integer datatypes.
+var xsdIntegerRanges = map[string][2]int64{
+	"int":                {math.MinInt32, math.MaxInt32},
+	"short":              {math.MinInt16, math.MaxInt16},
+	"byte":               {math.MinInt8, math.MaxInt8},
+	"nonPositiveInteger": {math.MinInt64, 0},
+	"negativeInteger":    {math.MinInt64, -1},
+	"nonNegativeInteger": {0, math.MaxInt64},
+	"positiveInteger":    {1, math.MaxInt64},
+	"unsignedLong":       {0, math.MaxInt64},
+	"unsignedInt":        {0, math.MaxUint32},
+	"unsignedShort":      {0, math.MaxUint16},
+	"unsignedByte":       {0, math.MaxUint8},
+}
+
+// isWellFormed returns true if the lexical form of the literal is valid for
+// its datatype. Literals of the datatypes unknown to the evaluator are.
+func isWellFormed(l Literal) bool {
+	switch {
+	case isNumericType(l.DataType):
+		n, ok := numericValue(l)
+		if r, isRange := xsdIntegerRanges[strings.TrimPrefix(l.DataType.str, xsdNs)]; ok && isRange {
+			return r[0] <= n.i && n.i <= r[1]
+		}
+		return ok
+	case l.DataType == xsdBoolean:
+		return l.str == "true" || l.str == "false" || l.str == "1" || l.str == "0"
+	case l.DataType == xsdDateTime:
+		_, err := parseDateTime(l.str)
+		return err == nil
+	case l.DataType == rdfLangString:
+		return l.lang != ""
+	}
+	return true
+}
@@ -0,0 +1,266 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// shaclRule is a SHACL-AF rule of a shape: a sh:TripleRule, whose triples
+// are given by node expressions, or a sh:SPARQLRule, whose triples are the
+// ones of a CONSTRUCT query.
+type shaclRule struct {
+	node       Term
+	order      float64
+	conditions []*shaclShape
+
+	subject, predicate, object shaclNodeExpr // of a triple rule
+	construct                  *Query        // of a SPARQL rule
+}
+
+// shaclNodeExpr is a node expression of SHACL-AF: it returns nodes of the
+// data graph, given a focus node.
+type shaclNodeExpr func(x *shaclValidation, focus Term) []Term
+
+// parseRules parses the rules of the shape, given by sh:rule, sorted by
+// sh:order.
+func (v *SHACLValidator) parseRules(s *shaclShape) error {
+	for _, node := range v.objects(s.node, "rule") {
+		if v.isDeactivated(node) {
+			continue
+		}
+		r := &shaclRule{node: node}
+		for _, t := range v.objects(node, "order") {
+			n, ok := numericValue(t)
+			if !ok {
+				return fmt.Errorf("sh:order of rule %s must be a number: %s", termKey(node), termKey(t))
+			}
+			r.order = n.f
+		}
+		for _, t := range v.objects(node, "condition") {
+			c, err := v.shape(t)
+			if err != nil {
+				return err
+			}
+			r.conditions = append(r.conditions, c)
+		}
+		if constructs := v.objects(node, "construct"); len(constructs) > 0 {
+			if len(constructs) > 1 {
+				return fmt.Errorf("rule %s has more than one sh:construct", termKey(node))
+			}
+			q, err := v.parseQuery(node, constructs[0].String(), nil)
+			if err != nil {
+				return err
+			}
+			if q.Form != QueryConstruct {
+				return fmt.Errorf("sh:construct of rule %s is not a CONSTRUCT query", termKey(node))
+			}
+			r.construct = q
+		} else {
+			for _, part := range []struct {
+				name string
+				expr *shaclNodeExpr
+			}{{"subject", &r.subject}, {"predicate", &r.predicate}, {"object", &r.object}} {
+				exprs := v.objects(node, part.name)
+				if len(exprs) != 1 {
+					return fmt.Errorf("rule %s without a single sh:%s", termKey(node), part.name)
+				}
+				expr, err := v.parseNodeExpr(exprs[0])
+				if err != nil {
+					return fmt.Errorf("sh:%s of rule %s: %v", part.name, termKey(node), err)
+				}
+				*part.expr = expr
+			}
+		}
+		s.rules = append(s.rules, r)
+	}
+	sort.SliceStable(s.rules, func(i, j int) bool { return s.rules[i].order < s.rules[j].order })
+	return nil
+}
+
+// parseNodeExpr parses a node expression: sh:this, a constant IRI or
+// literal, a path expression with an optional sh:nodes, a filter shape
+// expression, or sh:intersection or sh:union of node expressions.
+func (v *SHACLValidator) parseNodeExpr(node Term) (shaclNodeExpr, error) {
+	switch node := node.(type) {
+	case IRI:
+		if node == sh("this") {
+			return func(x *shaclValidation, focus Term) []Term { return []Term{focus} }, nil
+		}
+		return func(x *shaclValidation, focus Term) []Term { return []Term{node} }, nil
+	case Literal:
+		return func(x *shaclValidation, focus Term) []Term { return []Term{node} }, nil
+	}
+
+	// The nodes of the expression, or the focus node without sh:nodes.
+	nodes := func(x *shaclValidation, focus Term) []Term { return []Term{focus} }
+	if ns := v.objects(node, "nodes"); len(ns) == 1 {
+		expr, err := v.parseNodeExpr(ns[0])
+		if err != nil {
+			return nil, err
+		}
+		nodes = expr
+	}
+	if paths := v.objects(node, "path"); len(paths) == 1 {
+		p, err := v.parsePath(paths[0], make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		return func(x *shaclValidation, focus Term) []Term {
+			var ts []Term
+			for _, n := range nodes(x, focus) {
+				ts = append(ts, Paths(x.data, n, p)...)
+			}
+			return distinctTerms(ts)
+		}, nil
+	}
+	if shapes := v.objects(node, "filterShape"); len(shapes) == 1 {
+		s, err := v.shape(shapes[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(x *shaclValidation, focus Term) []Term {
+			var ts []Term
+			for _, n := range nodes(x, focus) {
+				if x.conforms(n, s) {
+					ts = append(ts, n)
+				}
+			}
+			return ts
+		}, nil
+	}
+	for _, name := range []string{"intersection", "union"} {
+		lists := v.objects(node, name)
+		if len(lists) != 1 {
+			continue
+		}
+		members, ok := readList(v.shapes.Match, lists[0])
+		if !ok || len(members) == 0 {
+			return nil, fmt.Errorf("sh:%s must be a non-empty list: %s", name, termKey(lists[0]))
+		}
+		var exprs []shaclNodeExpr
+		for _, m := range members {
+			expr, err := v.parseNodeExpr(m)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, expr)
+		}
+		union := name == "union"
+		return func(x *shaclValidation, focus Term) []Term {
+			ts := exprs[0](x, focus)
+			for _, expr := range exprs[1:] {
+				other := expr(x, focus)
+				if union {
+					ts = append(ts, other...)
+					continue
+				}
+				var common []Term
+				for _, t := range ts {
+					if containsTerm(other, t) {
+						common = append(common, t)
+					}
+				}
+				ts = common
+			}
+			return distinctTerms(ts)
+		}, nil
+	}
+	return nil, errors.New("invalid node expression " + termKey(node))
+}
+
+// Infer executes the rules of the shapes against the data graph, and adds
+// the inferred triples to it, until no new triple is inferred. It returns
+// the inferred triples.
+//
+// The rules of a shape are executed in the order given by their sh:order,
+// for each focus node of the shape which conforms to their sh:condition
+// shapes. The blank nodes of the triples of a SPARQL rule are replaced by
+// new blank nodes of the data graph, which are the same at each execution
+// of the rule for the focus node.
+//
+// It returns an error if the query of a SPARQL rule cannot be evaluated.
+func (v *SHACLValidator) Infer(data *Graph) ([]Triple, error) {
+	x := newSHACLValidation(data)
+	inferred := x.infer(v)
+	return inferred, x.err
+}
+
+// infer executes the rules of the shapes, and returns the inferred triples.
+func (x *shaclValidation) infer(v *SHACLValidator) []Triple {
+	var inferred []Triple
+	for changed := true; changed && x.err == nil; {
+		changed = false
+		for _, s := range v.targets {
+			if s.deactivated || len(s.rules) == 0 {
+				continue
+			}
+			for _, r := range s.rules {
+			focus:
+				for _, focus := range x.focusNodes(s) {
+					for _, c := range r.conditions {
+						if !x.conforms(focus, c) {
+							continue focus
+						}
+					}
+					for _, t := range x.fire(s, r, focus) {
+						if x.data.Add(t) {
+							inferred = append(inferred, t)
+							changed = true
+						}
+					}
+				}
+			}
+		}
+	}
+	return inferred
+}
+
+// fire returns the triples of the rule for the focus node.
+func (x *shaclValidation) fire(s *shaclShape, r *shaclRule, focus Term) []Triple {
+	if r.construct == nil {
+		var ts []Triple
+		for _, subj := range r.subject(x, focus) {
+			for _, pred := range r.predicate(x, focus) {
+				for _, obj := range r.object(x, focus) {
+					subj, ok1 := subj.(Subject)
+					pred, ok2 := pred.(IRI)
+					obj, ok3 := obj.(Object)
+					if ok1 && ok2 && ok3 {
+						ts = append(ts, Triple{Subj: subj, Pred: pred, Obj: obj})
+					}
+				}
+			}
+		}
+		return ts
+	}
+
+	res, err := x.query(r.construct, Solution{"this": focus, "currentShape": s.node})
+	if err != nil {
+		x.fail(err)
+		return nil
+	}
+	blank := func(t Term) Term {
+		b, ok := t.(Blank)
+		if !ok {
+			return t
+		}
+		key := termKey(r.node) + " " + termKey(focus) + " " + b.id
+		if nb, ok := x.blanks[key]; ok {
+			return nb
+		}
+		for {
+			x.blankN++
+			nb := Blank{id: fmt.Sprintf("_:r%d", x.blankN)}
+			if k := termKey(nb); x.data.spo[k] == nil && x.data.osp[k] == nil {
+				x.blanks[key] = nb
+				return nb
+			}
+		}
+	}
+	ts := res.Triples
+	for i, t := range ts {
+		ts[i] = Triple{Subj: blank(t.Subj).(Subject), Pred: t.Pred, Obj: blank(t.Obj).(Object)}
+	}
+	return ts
+}
@@ -0,0 +1,101 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSHACLRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		shapes string
+		data   string
+		want   string
+	}{
+		{
+			"triple rule",
+			`ex:S sh:targetClass ex:Rectangle ;
+				sh:rule [ a sh:TripleRule ; sh:subject sh:this ; sh:predicate rdf:type ; sh:object ex:Square ;
+					sh:condition [ sh:property [ sh:path ex:width ; sh:equals ex:height ] ] ] .`,
+			`ex:a a ex:Rectangle ; ex:width 2 ; ex:height 2 . ex:b a ex:Rectangle ; ex:width 2 ; ex:height 3 .`,
+			`ex:a a ex:Square .`,
+		},
+		{
+			"node expressions",
+			`ex:S sh:targetSubjectsOf ex:parent ;
+				sh:rule [ sh:subject sh:this ; sh:predicate ex:grandparent ; sh:object [ sh:path ( ex:parent ex:parent ) ] ] ;
+				sh:rule [ sh:subject [ sh:path ex:parent ] ; sh:predicate ex:child ; sh:object sh:this ] ;
+				sh:rule [
+					sh:subject sh:this ; sh:predicate ex:adultParent ;
+					sh:object [ sh:filterShape [ sh:property [ sh:path ex:age ; sh:minInclusive 18 ] ] ; sh:nodes [ sh:path ex:parent ] ]
+				] ;
+				sh:rule [
+					sh:subject sh:this ; sh:predicate ex:known ;
+					sh:object [ sh:intersection ( [ sh:path ex:knows ] [ sh:path ex:parent ] ) ]
+				] .`,
+			`ex:a ex:parent ex:b, ex:c ; ex:knows ex:c . ex:b ex:parent ex:d ; ex:age 17 . ex:c ex:age 40 . ex:d ex:age 70 .`,
+			`ex:a ex:grandparent ex:d ; ex:adultParent ex:c ; ex:known ex:c . ex:b ex:adultParent ex:d .
+			ex:b ex:child ex:a . ex:c ex:child ex:a . ex:d ex:child ex:b .`,
+		},
+		{
+			// The rules are executed until no new triple is inferred, in
+			// order: the second rule uses the triples of the first.
+			"SPARQL rules",
+			`ex:S sh:targetClass ex:Person ;
+				sh:rule [ a sh:SPARQLRule ; sh:order 2 ;
+					sh:construct "CONSTRUCT { $this <http://example.org/ancestor> ?z } WHERE { $this <http://example.org/ancestor>/<http://example.org/ancestor> ?z }" ] ;
+				sh:rule [ a sh:SPARQLRule ; sh:order 1 ;
+					sh:construct "CONSTRUCT { $this <http://example.org/ancestor> ?y } WHERE { $this <http://example.org/parent> ?y }" ] .`,
+			`ex:a a ex:Person ; ex:parent ex:b . ex:b a ex:Person ; ex:parent ex:c . ex:c a ex:Person ; ex:parent ex:d .`,
+			`ex:a ex:ancestor ex:b, ex:c, ex:d . ex:b ex:ancestor ex:c, ex:d . ex:c ex:ancestor ex:d .`,
+		},
+	}
+	for _, test := range tests {
+		v, err := NewSHACLValidatorGraph(NewGraph(decodeTestTriples(t, testSHACLPrologue+test.shapes)...))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		data := NewGraph(decodeTestTriples(t, testSHACLPrologue+test.data)...)
+		n := data.Len()
+		inferred, err := v.Infer(data)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got, want := sortedNTriples(inferred), sortedNTriples(decodeTestTriples(t, testSHACLPrologue+test.want)); got != want {
+			t.Errorf("%s: Infer =>\n%s\nwant:\n%s", test.name, got, want)
+		}
+		if data.Len() != n+len(inferred) {
+			t.Errorf("%s: %d triples in the data graph, want %d", test.name, data.Len(), n+len(inferred))
+		}
+	}
+}
+
+func TestSHACLRulesValidation(t *testing.T) {
+	// The rule infers the blank node of an address, the same at each
+	// execution, which is then validated.
+	v, err := NewSHACLValidatorGraph(NewGraph(decodeTestTriples(t, testSHACLPrologue+`
+ex:S sh:targetClass ex:Person ;
+	sh:rule [ sh:construct "CONSTRUCT { $this <http://example.org/address> [ <http://example.org/city> ?c ] } WHERE { $this <http://example.org/city> ?c }" ] ;
+	sh:property [ sh:path ( ex:address ex:city ) ; sh:maxLength 4 ] .
+`)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := NewGraph(decodeTestTriples(t, testSHACLPrologue+`ex:a a ex:Person ; ex:city "Rome" . ex:b a ex:Person ; ex:city "Paris" .`)...)
+	report, err := v.ValidateGraph(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range report.Results {
+		got = append(got, testSHACLResult(r))
+	}
+	if want := `ex:b ex:address/ex:city sh:MaxLength "Paris"`; strings.Join(got, "\n") != want {
+		t.Errorf("results =>\n%s\nwant:\n%s", strings.Join(got, "\n"), want)
+	}
+	if n := len(data.Match(nil, IRI{str: "http://example.org/address"}, nil)); n != 2 {
+		t.Errorf("%d addresses inferred, want 2", n)
+	}
+}
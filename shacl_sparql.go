@@ -0,0 +1,309 @@
+package rdf
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// shaclComponent is a SPARQL-based constraint component: a
+// sh:ConstraintComponent with parameters, and validators which are SPARQL
+// ASK or SELECT queries.
+type shaclComponent struct {
+	node     IRI
+	params   []shaclParameter
+	messages []Literal
+
+	// The validators of node and property shapes, and the one of both,
+	// which may be nil.
+	nodeValidator, propertyValidator, validator Term
+}
+
+// shaclParameter is a parameter of a constraint component, pre-bound in
+// its validators to the variable of the local name of its path.
+type shaclParameter struct {
+	path     IRI
+	name     string
+	optional bool
+}
+
+// parseComponents parses the SPARQL-based constraint components of the
+// shapes graph.
+func (v *SHACLValidator) parseComponents() error {
+	for _, t := range v.shapes.Match(nil, rdfType, sh("ConstraintComponent")) {
+		node, ok := t.Subj.(IRI)
+		if !ok {
+			return fmt.Errorf("constraint component %s is not an IRI", termKey(t.Subj))
+		}
+		c := &shaclComponent{node: node, messages: literals(v.objects(node, "message"))}
+		for _, p := range v.objects(node, "parameter") {
+			paths := v.objects(p, "path")
+			if len(paths) != 1 {
+				return fmt.Errorf("constraint component %s: parameter without a single sh:path", termKey(node))
+			}
+			path, ok := paths[0].(IRI)
+			if !ok {
+				return fmt.Errorf("constraint component %s: invalid parameter path %s", termKey(node), termKey(paths[0]))
+			}
+			optional := false
+			for _, t := range v.objects(p, "optional") {
+				optional = optional || isTrue(t)
+			}
+			c.params = append(c.params, shaclParameter{path: path, name: localName(path), optional: optional})
+		}
+		for _, name := range []string{"nodeValidator", "propertyValidator", "validator"} {
+			validators := v.objects(node, name)
+			if len(validators) == 0 {
+				continue
+			}
+			switch name {
+			case "nodeValidator":
+				c.nodeValidator = validators[0]
+			case "propertyValidator":
+				c.propertyValidator = validators[0]
+			default:
+				c.validator = validators[0]
+			}
+		}
+		v.components = append(v.components, c)
+	}
+	sort.Slice(v.components, func(i, j int) bool { return v.components[i].node.str < v.components[j].node.str })
+	return nil
+}
+
+// localName returns the part of the IRI after its last '#' or '/'.
+func localName(iri IRI) string {
+	return iri.str[strings.LastIndexAny(iri.str, "#/")+1:]
+}
+
+// literals returns the literals of the terms.
+func literals(ts []Term) []Literal {
+	var ls []Literal
+	for _, t := range ts {
+		if l, ok := t.(Literal); ok {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// isTrue returns true if the term is the boolean true.
+func isTrue(t Term) bool {
+	l, ok := t.(Literal)
+	return ok && l.DataType == xsdBoolean && (l.str == "true" || l.str == "1")
+}
+
+// isDeactivated returns true if the node is deactivated by sh:deactivated.
+func (v *SHACLValidator) isDeactivated(node Term) bool {
+	for _, t := range v.objects(node, "deactivated") {
+		if isTrue(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuery parses the SPARQL query of a constraint, validator or rule,
+// with the prefixes declared by its sh:prefixes. The path of a property
+// shape is substituted for $PATH.
+func (v *SHACLValidator) parseQuery(node Term, query string, path Path) (*Query, error) {
+	var prologue strings.Builder
+	for _, prefixes := range v.objects(node, "prefixes") {
+		for _, decl := range v.objects(prefixes, "declare") {
+			prefix, namespace := v.objects(decl, "prefix"), v.objects(decl, "namespace")
+			if len(prefix) != 1 || len(namespace) != 1 {
+				return nil, fmt.Errorf("invalid prefix declaration %s", termKey(decl))
+			}
+			fmt.Fprintf(&prologue, "PREFIX %s: <%s>\n", prefix[0].String(), namespace[0].String())
+		}
+	}
+	if path != nil {
+		query = strings.Replace(query, "$PATH", path.String(), -1)
+	}
+	q, err := ParseQuery(prologue.String() + query)
+	if err != nil {
+		return nil, fmt.Errorf("query of %s: %v", termKey(node), err)
+	}
+	return q, nil
+}
+
+// parseSPARQLConstraints parses the SPARQL-based constraints of the shape,
+// given by sh:sparql, and the constraints of the SPARQL-based constraint
+// components whose mandatory parameters the shape has.
+func (v *SHACLValidator) parseSPARQLConstraints(s *shaclShape) error {
+	for _, c := range v.objects(s.node, "sparql") {
+		if v.isDeactivated(c) {
+			continue
+		}
+		selects := v.objects(c, "select")
+		if len(selects) != 1 {
+			return fmt.Errorf("sh:sparql %s without a single sh:select", termKey(c))
+		}
+		q, err := v.parseQuery(c, selects[0].String(), s.path)
+		if err != nil {
+			return err
+		}
+		if q.Form != QuerySelect {
+			return fmt.Errorf("sh:select of %s is not a SELECT query", termKey(c))
+		}
+		s.add("SPARQLConstraintComponent", v.selectCheck(s, c, q, literals(v.objects(c, "message")), nil))
+	}
+
+	for _, c := range v.components {
+		validator := c.propertyValidator
+		if s.path == nil {
+			validator = c.nodeValidator
+		}
+		if validator == nil {
+			validator = c.validator
+		}
+		if validator == nil {
+			continue
+		}
+
+		// A constraint for each combination of the values of the
+		// parameters.
+		combinations := []Solution{{}}
+		for _, p := range c.params {
+			values := v.values(s.node, p.path)
+			if len(values) == 0 {
+				if !p.optional {
+					combinations = nil
+					break
+				}
+				continue
+			}
+			var next []Solution
+			for _, sol := range combinations {
+				for _, value := range values {
+					sol := sol.merge(nil)
+					sol[p.name] = value
+					next = append(next, sol)
+				}
+			}
+			combinations = next
+		}
+		if combinations == nil {
+			continue
+		}
+		messages := literals(v.objects(validator, "message"))
+		if messages == nil {
+			messages = c.messages
+		}
+		asks, selects := v.objects(validator, "ask"), v.objects(validator, "select")
+		var check func(params Solution) shaclCheck
+		switch {
+		case len(asks) == 1 && len(selects) == 0:
+			q, err := v.parseQuery(validator, asks[0].String(), s.path)
+			if err != nil {
+				return err
+			}
+			if q.Form != QueryAsk {
+				return fmt.Errorf("sh:ask of %s is not an ASK query", termKey(validator))
+			}
+			check = func(params Solution) shaclCheck {
+				return v.askCheck(s, q, messages, params)
+			}
+		case len(selects) == 1 && len(asks) == 0:
+			q, err := v.parseQuery(validator, selects[0].String(), s.path)
+			if err != nil {
+				return err
+			}
+			if q.Form != QuerySelect {
+				return fmt.Errorf("sh:select of %s is not a SELECT query", termKey(validator))
+			}
+			check = func(params Solution) shaclCheck {
+				return v.selectCheck(s, nil, q, messages, params)
+			}
+		default:
+			return fmt.Errorf("validator %s of %s without a single sh:ask or sh:select", termKey(validator), termKey(c.node))
+		}
+		for _, params := range combinations {
+			s.constraints = append(s.constraints, shaclConstraint{component: c.node, check: check(params)})
+		}
+	}
+	return nil
+}
+
+// selectCheck returns the check of a SPARQL-based constraint, or of a
+// SELECT validator: each solution of the query is a result, for the focus
+// node pre-bound to $this.
+func (v *SHACLValidator) selectCheck(s *shaclShape, constraint Term, q *Query, messages []Literal, params Solution) shaclCheck {
+	return func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+		bindings := params.merge(Solution{"this": focus, "currentShape": s.node})
+		res, err := x.query(q, bindings)
+		if err != nil {
+			x.fail(err)
+			return nil
+		}
+		var results []ValidationResult
+		for _, sol := range res.Solutions {
+			if isTrue(sol["failure"]) {
+				x.fail(fmt.Errorf("shape %s: failure of the SPARQL query for %s", termKey(s.node), termKey(focus)))
+				continue
+			}
+			r := ValidationResult{SourceConstraint: constraint, ResultMessage: substituteMessages(messages, bindings.merge(sol))}
+			if p, ok := sol["path"].(IRI); ok {
+				r.ResultPath = &LinkPath{p}
+			}
+			if value, ok := sol["value"]; ok {
+				r.Value = value
+			} else if s.path == nil {
+				r.Value = focus
+			}
+			if m, ok := sol["message"].(Literal); ok {
+				r.ResultMessage = []Literal{m}
+			}
+			results = append(results, r)
+		}
+		return results
+	}
+}
+
+// askCheck returns the check of an ASK validator: each value node, pre-bound
+// to $value, for which the query is false is a result.
+func (v *SHACLValidator) askCheck(s *shaclShape, q *Query, messages []Literal, params Solution) shaclCheck {
+	return func(x *shaclValidation, focus Term, values []Term) []ValidationResult {
+		var results []ValidationResult
+		for _, value := range values {
+			bindings := params.merge(Solution{"this": focus, "currentShape": s.node, "value": value})
+			res, err := x.query(q, bindings)
+			if err != nil {
+				x.fail(err)
+				return nil
+			}
+			if !res.Boolean {
+				results = append(results, ValidationResult{Value: value, ResultMessage: substituteMessages(messages, bindings)})
+			}
+		}
+		return results
+	}
+}
+
+// query evaluates the query against the data graph, with the variables
+// pre-bound to the bindings.
+func (x *shaclValidation) query(q *Query, bindings Solution) (*Results, error) {
+	return q.eval(&evalDataset{def: x.data, graph: func(Context) *Graph { return nil }}, EvalOptions{Bindings: bindings})
+}
+
+var rgxpMessageVar = regexp.MustCompile(`\{[?$]([A-Za-z0-9_]+)\}`)
+
+// substituteMessages returns the messages with the {?var} and {$var}
+// templates replaced by the values of the variables.
+func substituteMessages(messages []Literal, sol Solution) []Literal {
+	var ms []Literal
+	for _, m := range messages {
+		m.str = rgxpMessageVar.ReplaceAllStringFunc(m.str, func(s string) string {
+			if t, ok := sol[s[2:len(s)-1]]; ok {
+				if b, ok := t.(Blank); ok {
+					return b.id
+				}
+				return t.String()
+			}
+			return s
+		})
+		ms = append(ms, m)
+	}
+	return ms
+}
@@ -0,0 +1,151 @@
+package rdf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSHACLSPARQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		shapes string
+		data   string
+		want   []string
+	}{
+		{
+			"SPARQL constraint",
+			`ex:S sh:targetClass ex:Person ;
+				sh:sparql [
+					a sh:SPARQLConstraint ;
+					sh:message "{$this} is its own parent: {?value}" ;
+					sh:prefixes ex:prefixes ;
+					sh:select "SELECT $this ?value WHERE { $this p:parent ?value . FILTER (?value = $this) }"
+				] .
+			ex:prefixes sh:declare [ sh:prefix "p" ; sh:namespace "http://example.org/"^^xsd:anyURI ] .`,
+			`ex:a a ex:Person ; ex:parent ex:a . ex:b a ex:Person ; ex:parent ex:a .`,
+			[]string{`ex:a - sh:SPARQL ex:a "http://example.org/a is its own parent: http://example.org/a"`},
+		},
+		{
+			"SPARQL constraint of a property shape",
+			`ex:S sh:targetNode ex:a ;
+				sh:property [
+					sh:path ex:child ;
+					sh:sparql [ sh:prefixes ex:prefixes ; sh:select """SELECT $this ?value ?path WHERE {
+						$this $PATH ?value . ?value ex:age ?age . $this ex:age ?mine
+						FILTER (?age >= ?mine) BIND (ex:age AS ?path) }""" ]
+				] .
+			ex:prefixes sh:declare [ sh:prefix "ex" ; sh:namespace "http://example.org/"^^xsd:anyURI ] .`,
+			`ex:a ex:age 40 ; ex:child ex:b, ex:c . ex:b ex:age 10 . ex:c ex:age 50 .`,
+			[]string{`ex:a ex:age sh:SPARQL ex:c`},
+		},
+		{
+			"ASK and SELECT validators",
+			`ex:MaxLengthComponent a sh:ConstraintComponent ;
+				sh:parameter [ sh:path ex:maxLen ] ;
+				sh:message "longer than {$maxLen}" ;
+				sh:validator [ a sh:SPARQLAskValidator ; sh:ask "ASK { FILTER (STRLEN(STR($value)) <= $maxLen) }" ] .
+			ex:LanguageComponent a sh:ConstraintComponent ;
+				sh:parameter [ sh:path ex:lang ] ;
+				sh:parameter [ sh:path ex:strict ; sh:optional true ] ;
+				sh:propertyValidator [ sh:select "SELECT $this ?value WHERE { $this $PATH ?value FILTER (LANG(?value) != $lang) }" ] .
+			ex:S sh:targetNode ex:a ;
+				sh:property [ sh:path ex:label ; ex:maxLen 3 ; ex:lang "en" ] ;
+				ex:lang "fr" .`,
+			`ex:a ex:label "abc"@en, "abcd"@fr .`,
+			[]string{
+				`ex:a ex:label ex:LanguageComponent "abcd"@fr`,
+				`ex:a ex:label ex:MaxLengthComponent "abcd"@fr "longer than 3"`,
+			},
+		},
+		{
+			"deactivated SPARQL constraint",
+			`ex:S sh:targetNode ex:a ; sh:sparql [ sh:deactivated true ; sh:select "SELECT $this WHERE {}" ] .`,
+			`ex:a ex:p ex:b .`,
+			nil,
+		},
+	}
+	for _, test := range tests {
+		v, err := NewSHACLValidator(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.shapes), Turtle))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		report, err := v.Validate(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.data), Turtle))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var got []string
+		for _, r := range report.Results {
+			s := testSHACLResult(r)
+			for _, m := range r.ResultMessage {
+				s += ` "` + m.str + `"`
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: results =>\n%s\nwant:\n%s", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+	}
+}
+
+func TestSHACLSPARQLSourceConstraint(t *testing.T) {
+	v, err := NewSHACLValidatorGraph(NewGraph(decodeTestTriples(t, testSHACLPrologue+`
+ex:S sh:targetNode ex:a ; sh:sparql ex:C .
+ex:C sh:select "SELECT $this WHERE { $this <http://example.org/p> ?x }" .
+`)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := v.ValidateGraph(NewGraph(decodeTestTriples(t, testSHACLPrologue+`ex:a ex:p 1 .`)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decodeTestTriples(t, testSHACLPrologue+`
+[] a sh:ValidationReport ; sh:conforms false ; sh:result [
+	a sh:ValidationResult ;
+	sh:focusNode ex:a ;
+	sh:value ex:a ;
+	sh:sourceShape ex:S ;
+	sh:sourceConstraint ex:C ;
+	sh:sourceConstraintComponent sh:SPARQLConstraintComponent ;
+	sh:resultSeverity sh:Violation
+] .
+`)
+	if got := report.Triples(); !Isomorphic(got, want) {
+		t.Errorf("Triples() =>\n%s\nwant:\n%s", sortedNTriples(got), sortedNTriples(want))
+	}
+}
+
+func TestSHACLSPARQLErrors(t *testing.T) {
+	tests := []struct {
+		shapes string
+		data   string
+		err    string
+	}{
+		{`ex:S sh:targetNode ex:a ; sh:sparql [ sh:select "SELECT WHERE" ] .`, "", "shape <http://example.org/S>: query of _:"},
+		{`ex:S sh:targetNode ex:a ; sh:sparql [ sh:select "ASK {}" ] .`, "", "is not a SELECT query"},
+		{`ex:S sh:targetNode ex:a ; sh:sparql [ sh:message "m" ] .`, "", "without a single sh:select"},
+		{
+			`ex:C a sh:ConstraintComponent ; sh:parameter [ sh:path ex:p ] ; sh:validator [ sh:ask "SELECT * {}" ] .
+			ex:S sh:targetNode ex:a ; ex:p 1 .`,
+			"",
+			"is not an ASK query",
+		},
+		{
+			`ex:S sh:targetNode ex:a ; sh:sparql [ sh:select "SELECT $this ?failure WHERE { BIND (true AS ?failure) }" ] .`,
+			`ex:a ex:p ex:b .`,
+			"shape <http://example.org/S>: failure of the SPARQL query for <http://example.org/a>",
+		},
+	}
+	for _, test := range tests {
+		v, err := NewSHACLValidator(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.shapes), Turtle))
+		if err == nil {
+			_, err = v.Validate(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.data), Turtle))
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.shapes, err, test.err)
+		}
+	}
+}
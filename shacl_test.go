@@ -0,0 +1,237 @@
+package rdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testSHACLPrologue = `@prefix rdf: <http://www.w3.org/1999/02/22-rdf-syntax-ns#> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+@prefix sh: <http://www.w3.org/ns/shacl#> .
+@prefix ex: <http://example.org/> .
+`
+
+// testSHACLResult returns the result as "focus path component value", with
+// the IRIs of the ex: and sh: namespaces abbreviated, and blank values as [].
+func testSHACLResult(r ValidationResult) string {
+	short := func(s string) string {
+		s = strings.Replace(s, "<http://example.org/", "ex:", -1)
+		s = strings.Replace(s, "http://example.org/", "ex:", -1)
+		s = strings.Replace(s, "<"+shNS, "sh:", -1)
+		return strings.Replace(s, ">", "", -1)
+	}
+	path, value := "-", "-"
+	if r.ResultPath != nil {
+		path = r.ResultPath.String()
+	}
+	switch r.Value.(type) {
+	case nil:
+	case Blank:
+		value = "[]"
+	default:
+		value = termKey(r.Value)
+	}
+	return short(fmt.Sprintf("%s %s %s %s", termKey(r.FocusNode), path, strings.TrimSuffix(termKey(r.SourceConstraintComponent), "ConstraintComponent>"), value))
+}
+
+func TestSHACLValidator(t *testing.T) {
+	tests := []struct {
+		name   string
+		shapes string
+		data   string
+		want   []string
+	}{
+		{
+			"cardinality and datatype",
+			`ex:PersonShape a sh:NodeShape ; sh:targetClass ex:Person ;
+				sh:property [ sh:path ex:name ; sh:minCount 1 ; sh:maxCount 1 ; sh:datatype xsd:string ] ;
+				sh:property [ sh:path ex:age ; sh:datatype xsd:nonNegativeInteger ] .`,
+			`ex:Student rdfs:subClassOf ex:Person .
+			ex:alice a ex:Person ; ex:name "Alice" ; ex:age "30"^^xsd:nonNegativeInteger .
+			ex:bob a ex:Student ; ex:name "Bob", "Robert" ; ex:age "-1"^^xsd:nonNegativeInteger .
+			ex:carl a ex:Person ; ex:name 1 .`,
+			[]string{
+				`ex:bob ex:age sh:Datatype "-1"^^<http://www.w3.org/2001/XMLSchema#nonNegativeInteger`,
+				`ex:bob ex:name sh:MaxCount -`,
+				`ex:carl ex:name sh:Datatype "1"^^<http://www.w3.org/2001/XMLSchema#integer`,
+			},
+		},
+		{
+			"class and node kind",
+			`ex:S sh:targetNode ex:a, ex:b ; sh:property [ sh:path ex:knows ; sh:class ex:Person ; sh:nodeKind sh:IRI ] .`,
+			`ex:a ex:knows ex:p, [ a ex:Person ] . ex:p a ex:Person .
+			ex:b ex:knows "p" .`,
+			[]string{
+				`ex:a ex:knows sh:NodeKind []`,
+				`ex:b ex:knows sh:Class "p"`,
+				`ex:b ex:knows sh:NodeKind "p"`,
+			},
+		},
+		{
+			"value ranges and strings",
+			`ex:S sh:targetSubjectsOf ex:age ;
+				sh:property [ sh:path ex:age ; sh:minInclusive 0 ; sh:maxExclusive 150 ] ;
+				sh:property [ sh:path ex:code ; sh:pattern "^[a-z]+$" ; sh:flags "i" ; sh:minLength 2 ; sh:maxLength 3 ] ;
+				sh:property [ sh:path ex:label ; sh:languageIn ( "en" "fr" ) ; sh:uniqueLang true ] .`,
+			`ex:a ex:age 20 ; ex:code "AB" ; ex:label "a"@en-GB, "b"@fr .
+			ex:b ex:age 150 ; ex:code "a1", "abcd" ; ex:label "a"@de, "b"@en, "c"@en .
+			ex:c ex:age "x" .`,
+			[]string{
+				`ex:b ex:age sh:MaxExclusive "150"^^<http://www.w3.org/2001/XMLSchema#integer`,
+				`ex:b ex:code sh:MaxLength "abcd"`,
+				`ex:b ex:code sh:Pattern "a1"`,
+				`ex:b ex:label sh:LanguageIn "a"@de`,
+				`ex:b ex:label sh:UniqueLang -`,
+				`ex:c ex:age sh:MaxExclusive "x"`,
+				`ex:c ex:age sh:MinInclusive "x"`,
+			},
+		},
+		{
+			"property pairs",
+			`ex:S sh:targetNode ex:a ;
+				sh:property [ sh:path ex:start ; sh:lessThan ex:end ] ;
+				sh:property [ sh:path ex:given ; sh:disjoint ex:family ; sh:equals ex:first ] .`,
+			`ex:a ex:start 5 ; ex:end 3 ; ex:given "X", "Y" ; ex:family "X" ; ex:first "Y", "Z" .`,
+			[]string{
+				`ex:a ex:given sh:Disjoint "X"`,
+				`ex:a ex:given sh:Equals "X"`,
+				`ex:a ex:given sh:Equals "Z"`,
+				`ex:a ex:start sh:LessThan "5"^^<http://www.w3.org/2001/XMLSchema#integer`,
+			},
+		},
+		{
+			"logical constraints",
+			`ex:HasName sh:property [ sh:path ex:name ; sh:minCount 1 ] .
+			ex:HasID sh:property [ sh:path ex:id ; sh:minCount 1 ] .
+			ex:S sh:targetNode ex:a, ex:b, ex:c, ex:d ;
+				sh:or ( ex:HasName ex:HasID ) ;
+				sh:xone ( ex:HasName ex:HasID ) ;
+				sh:not [ sh:property [ sh:path ex:banned ; sh:hasValue true ] ] .`,
+			`ex:a ex:name "A" . ex:b ex:name "B" ; ex:id 1 . ex:d ex:id 2 ; ex:banned true .`,
+			[]string{
+				`ex:b - sh:Xone ex:b`,
+				`ex:c - sh:Or ex:c`,
+				`ex:c - sh:Xone ex:c`,
+				`ex:d - sh:Not ex:d`,
+			},
+		},
+		{
+			"node, in and paths",
+			`ex:Address sh:property [ sh:path ex:city ; sh:in ( "Paris" "Rome" ) ] .
+			ex:S sh:targetNode ex:a ;
+				sh:property [ sh:path ( ex:address ex:city ) ; sh:minCount 3 ] ;
+				sh:property [ sh:path [ sh:inversePath ex:parent ] ; sh:maxCount 1 ] ;
+				sh:property [ sh:path ex:address ; sh:node ex:Address ] ;
+				sh:property [ sh:path [ sh:alternativePath ( ex:x ex:y ) ] ; sh:hasValue 1 ] .`,
+			`ex:a ex:address ex:h1, ex:h2 ; ex:x 2 . ex:h1 ex:city "Paris" . ex:h2 ex:city "Oslo" .
+			ex:c1 ex:parent ex:a . ex:c2 ex:parent ex:a .`,
+			[]string{
+				`ex:a ex:address sh:Node ex:h2`,
+				`ex:a ex:address/ex:city sh:MinCount -`,
+				`ex:a ex:x|ex:y sh:HasValue -`,
+				`ex:a ^ex:parent sh:MaxCount -`,
+			},
+		},
+		{
+			"closed, qualified value shapes and severity",
+			`ex:S sh:targetNode ex:a ; sh:closed true ; sh:ignoredProperties ( rdf:type ) ; sh:severity sh:Warning ;
+				sh:property ex:P1, ex:P2 .
+			ex:P1 sh:path ex:part ; sh:qualifiedValueShape [ sh:class ex:Wheel ] ; sh:qualifiedMinCount 4 ; sh:qualifiedValueShapesDisjoint true .
+			ex:P2 sh:path ex:part ; sh:qualifiedValueShape [ sh:class ex:Spare ] ; sh:qualifiedMaxCount 0 ; sh:qualifiedValueShapesDisjoint true .`,
+			`ex:a a ex:Car ; ex:part ex:w1, ex:w2, ex:w3, ex:w4, ex:s ; ex:color "red" .
+			ex:w1 a ex:Wheel . ex:w2 a ex:Wheel . ex:w3 a ex:Wheel . ex:w4 a ex:Wheel, ex:Spare . ex:s a ex:Spare .`,
+			[]string{
+				`ex:a ex:color sh:Closed "red"`,
+				`ex:a ex:part sh:QualifiedMinCount -`,
+				`ex:a ex:part sh:QualifiedMaxCount -`,
+			},
+		},
+		{
+			"implicit class target, deactivated and recursion",
+			`ex:Person a rdfs:Class, sh:NodeShape ; sh:property [ sh:path ex:knows ; sh:node ex:Person ] ;
+				sh:property [ sh:path ex:name ; sh:minCount 1 ] .
+			ex:Off sh:targetNode ex:a ; sh:deactivated true ; sh:property [ sh:path ex:name ; sh:maxCount 0 ] .`,
+			`ex:a a ex:Person ; ex:name "A" ; ex:knows ex:b . ex:b ex:knows ex:a .`,
+			[]string{
+				`ex:a ex:knows sh:Node ex:b`,
+			},
+		},
+	}
+	for _, test := range tests {
+		v, err := NewSHACLValidator(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.shapes), Turtle))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		report, err := v.Validate(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.data), Turtle))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var got []string
+		for _, r := range report.Results {
+			got = append(got, testSHACLResult(r))
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: results =>\n%s\nwant:\n%s", test.name, strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+		}
+		if report.Conforms != (len(test.want) == 0) {
+			t.Errorf("%s: Conforms => %v", test.name, report.Conforms)
+		}
+	}
+}
+
+func TestValidationReportTriples(t *testing.T) {
+	v, err := NewSHACLValidatorGraph(NewGraph(decodeTestTriples(t, testSHACLPrologue+`
+ex:S sh:targetNode ex:a ; sh:property _:p .
+_:p sh:path [ sh:oneOrMorePath ex:p ] ; sh:datatype xsd:string ; sh:message "not a string"@en .
+`)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := v.ValidateGraph(NewGraph(decodeTestTriples(t, testSHACLPrologue+`ex:a ex:p 1 .`)...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := report.Triples()
+	want := decodeTestTriples(t, testSHACLPrologue+`
+[] a sh:ValidationReport ; sh:conforms false ; sh:result [
+	a sh:ValidationResult ;
+	sh:focusNode ex:a ;
+	sh:resultPath [ sh:oneOrMorePath ex:p ] ;
+	sh:value 1 ;
+	sh:sourceShape _:p ;
+	sh:sourceConstraintComponent sh:DatatypeConstraintComponent ;
+	sh:resultSeverity sh:Violation ;
+	sh:resultMessage "not a string"@en
+] .
+`)
+	if !Isomorphic(got, want) {
+		t.Errorf("Triples() =>\n%s\nwant:\n%s", sortedNTriples(got), sortedNTriples(want))
+	}
+}
+
+func TestSHACLValidatorErrors(t *testing.T) {
+	tests := []struct {
+		shapes string
+		err    string
+	}{
+		{`ex:S sh:targetNode ex:a ; sh:property [ sh:path ex:p ; sh:minCount "one" ] .`, `sh:minCount must be an integer: "one"`},
+		{`ex:S sh:targetNode ex:a ; sh:property [ sh:path [ ex:p ex:q ] ] .`, "invalid sh:path"},
+		{`ex:S sh:targetNode ex:a ; sh:property [ sh:path ex:p, ex:q ] .`, "more than one sh:path"},
+		{`ex:S sh:targetNode ex:a ; sh:pattern "(" .`, "invalid sh:pattern"},
+		{`ex:S sh:targetNode ex:a ; sh:in ex:list .`, "sh:in must be a list: <http://example.org/list>"},
+		{`ex:S sh:targetNode ex:a ; sh:nodeKind sh:Thing .`, "invalid sh:nodeKind"},
+		{`ex:S sh:targetNode ex:a ; sh:property ex:P .`, "shape <http://example.org/S>: sh:property <http://example.org/P> has no sh:path"},
+		{`ex:S sh:targetNode ex:a ; sh:node [ sh:datatype "x" ] .`, "sh:datatype must be an IRI"},
+	}
+	for _, test := range tests {
+		_, err := NewSHACLValidator(NewTripleDecoder(bytes.NewBufferString(testSHACLPrologue+test.shapes), Turtle))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want %q", test.shapes, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,672 @@
+package rdf
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatternTerm is a term of a SPARQL pattern or expression: either a Term
+// or a Var.
+type PatternTerm interface {
+	// Serialize returns the term in the specified serialization format.
+	Serialize(Format) string
+
+	// String returns the term as it is stored, without any modifications.
+	String() string
+}
+
+// Var is a SPARQL variable, named without the leading '?' or '$'.
+//
+// Var is a PatternTerm, which can take the place of terms in triple
+// patterns, but not a Term, so it is never part of a Triple.
+type Var string
+
+// Serialize returns the variable in SPARQL syntax.
+func (v Var) Serialize(f Format) string {
+	return "?" + string(v)
+}
+
+// String returns the name of the variable.
+func (v Var) String() string {
+	return string(v)
+}
+
+// isVar returns true if the term is a variable.
+func isVar(t PatternTerm) bool {
+	_, ok := t.(Var)
+	return ok
+}
+
+// QueryForm is the form of a SPARQL query.
+type QueryForm int
+
+// Exported SPARQL query forms.
+const (
+	QuerySelect QueryForm = iota
+	QueryConstruct
+	QueryAsk
+	QueryDescribe
+)
+
+// Query is a SPARQL 1.1 query, as parsed by ParseQuery. Prefixed names and
+// relative IRIs are resolved when parsing, so all IRIs of the query are
+// absolute.
+type Query struct {
+	Form     QueryForm
+	Base     string            // base IRI, if declared
+	Prefixes map[string]string // declared prefix labels, mapped to their namespaces
+
+	Distinct bool
+	Reduced  bool
+	Select   []SelectItem    // projection of a SELECT query, or nil for SELECT *
+	Template []TriplePattern // template of a CONSTRUCT query
+	Describe []PatternTerm   // IRIs and variables of a DESCRIBE query, or nil for DESCRIBE *
+
+	From      []IRI // graphs merged into the default graph of the dataset
+	FromNamed []IRI // named graphs of the dataset
+
+	Where   *GroupPattern // nil only for a DESCRIBE query without a WHERE clause
+	GroupBy []GroupCondition
+	Having  []Expr
+	OrderBy []OrderCondition
+	Limit   int            // maximum number of solutions, or -1 for no limit
+	Offset  int            // number of solutions skipped
+	Values  *ValuesPattern // trailing VALUES clause, if any
+}
+
+// SelectItem is a projected variable of a SELECT query, optionally bound to
+// the value of an expression: (Expr AS ?Var).
+type SelectItem struct {
+	Var  Var
+	Expr Expr // nil for a plain variable
+}
+
+// GroupCondition is a GROUP BY condition, optionally binding its value to a
+// variable: (Expr AS ?Var).
+type GroupCondition struct {
+	Expr Expr
+	Var  Var // "" if not bound
+}
+
+// OrderCondition is an ORDER BY condition.
+type OrderCondition struct {
+	Expr Expr
+	Desc bool
+}
+
+// TriplePattern is a triple where any term may be a variable. The
+// predicate is nil if the pattern has a property path.
+type TriplePattern struct {
+	Subj, Pred, Obj PatternTerm
+	Path            Path
+}
+
+// Pattern is a SPARQL graph pattern; one of *GroupPattern, *BasicPattern,
+// *OptionalPattern, *UnionPattern, *MinusPattern, *NamedGraphPattern,
+// *ServicePattern, *FilterPattern, *BindPattern, *ValuesPattern and
+// *SubQueryPattern.
+type Pattern interface {
+	format(w *sparqlWriter)
+}
+
+// GroupPattern is a group graph pattern: { ... }.
+type GroupPattern struct {
+	Patterns []Pattern
+}
+
+// BasicPattern is a block of triple patterns.
+type BasicPattern struct {
+	Triples []TriplePattern
+}
+
+// OptionalPattern is an OPTIONAL { ... } pattern.
+type OptionalPattern struct {
+	Pattern *GroupPattern
+}
+
+// UnionPattern is a union of two or more alternatives:
+// { ... } UNION { ... }.
+type UnionPattern struct {
+	Patterns []*GroupPattern
+}
+
+// MinusPattern is a MINUS { ... } pattern.
+type MinusPattern struct {
+	Pattern *GroupPattern
+}
+
+// NamedGraphPattern is a GRAPH pattern, where Name is an IRI or a variable.
+type NamedGraphPattern struct {
+	Name    PatternTerm
+	Pattern *GroupPattern
+}
+
+// ServicePattern is a SERVICE pattern, evaluated by a remote SPARQL
+// endpoint, where Endpoint is an IRI or a variable. The errors of a SILENT
+// service are ignored.
+type ServicePattern struct {
+	Silent   bool
+	Endpoint PatternTerm
+	Pattern  *GroupPattern
+}
+
+// FilterPattern is a FILTER constraint, applying to its whole group.
+type FilterPattern struct {
+	Expr Expr
+}
+
+// BindPattern is a BIND(Expr AS ?Var) assignment.
+type BindPattern struct {
+	Expr Expr
+	Var  Var
+}
+
+// ValuesPattern is inline data given by VALUES. A nil term in a row is
+// UNDEF.
+type ValuesPattern struct {
+	Vars []Var
+	Rows [][]Term
+}
+
+// SubQueryPattern is a subquery: { SELECT ... }.
+type SubQueryPattern struct {
+	Query *Query
+}
+
+// Expr is a SPARQL expression; one of *TermExpr, *BinaryExpr, *UnaryExpr,
+// *InExpr, *CallExpr, *ExistsExpr and *AggregateExpr.
+type Expr interface {
+	format(w *sparqlWriter, nested bool)
+}
+
+// TermExpr is an IRI, a literal or a variable in an expression.
+type TermExpr struct {
+	Term PatternTerm
+}
+
+// BinaryExpr is a binary operation. Op is one of "||", "&&", "=", "!=",
+// "<", ">", "<=", ">=", "+", "-", "*" and "/".
+type BinaryExpr struct {
+	Op          string
+	Left, Right Expr
+}
+
+// UnaryExpr is a unary operation. Op is one of "!", "+" and "-".
+type UnaryExpr struct {
+	Op  string
+	Arg Expr
+}
+
+// InExpr is an IN or NOT IN expression.
+type InExpr struct {
+	Arg  Expr
+	Not  bool
+	List []Expr
+}
+
+// CallExpr is a call of a builtin function, such as STR or REGEX, or of a
+// function identified by an IRI, such as xsd:integer.
+type CallExpr struct {
+	Name string // name of the builtin function in upper case, or "" for an IRI function
+	IRI  IRI    // IRI of the function, if not builtin
+	Args []Expr
+}
+
+// ExistsExpr is an EXISTS or NOT EXISTS expression.
+type ExistsExpr struct {
+	Not     bool
+	Pattern *GroupPattern
+}
+
+// AggregateExpr is an aggregate; one of COUNT, SUM, MIN, MAX, AVG, SAMPLE
+// and GROUP_CONCAT.
+type AggregateExpr struct {
+	Name      string
+	Distinct  bool
+	Arg       Expr   // nil for COUNT(*)
+	Separator string // separator of GROUP_CONCAT; a single space by default
+}
+
+// String returns the query in SPARQL syntax.
+func (q *Query) String() string {
+	w := newSPARQLWriter(q.Prefixes)
+	w.prologue(q.Base, q.Prefixes)
+	q.format(w)
+	return w.buf.String()
+}
+
+// format writes the query, without its prologue.
+func (q *Query) format(w *sparqlWriter) {
+	var head bytes.Buffer
+	switch q.Form {
+	case QuerySelect:
+		head.WriteString("SELECT")
+		if q.Distinct {
+			head.WriteString(" DISTINCT")
+		}
+		if q.Reduced {
+			head.WriteString(" REDUCED")
+		}
+		if q.Select == nil {
+			head.WriteString(" *")
+		}
+		for _, item := range q.Select {
+			head.WriteByte(' ')
+			if item.Expr == nil {
+				head.WriteString("?" + string(item.Var))
+				continue
+			}
+			head.WriteString("(" + w.expr(item.Expr, false) + " AS ?" + string(item.Var) + ")")
+		}
+		w.line(head.String())
+	case QueryConstruct:
+		w.line("CONSTRUCT {")
+		w.indent++
+		for _, tp := range q.Template {
+			w.line(w.triple(tp))
+		}
+		w.indent--
+		w.line("}")
+	case QueryAsk:
+		w.line("ASK")
+	case QueryDescribe:
+		head.WriteString("DESCRIBE")
+		if q.Describe == nil {
+			head.WriteString(" *")
+		}
+		for _, t := range q.Describe {
+			head.WriteString(" " + w.term(t))
+		}
+		w.line(head.String())
+	}
+	for _, iri := range q.From {
+		w.line("FROM " + w.term(iri))
+	}
+	for _, iri := range q.FromNamed {
+		w.line("FROM NAMED " + w.term(iri))
+	}
+	if q.Where != nil {
+		w.group("WHERE ", q.Where)
+	}
+	if len(q.GroupBy) > 0 {
+		var conds []string
+		for _, c := range q.GroupBy {
+			switch {
+			case c.Var != "":
+				conds = append(conds, "("+w.expr(c.Expr, false)+" AS ?"+string(c.Var)+")")
+			case isConstraint(c.Expr):
+				conds = append(conds, w.expr(c.Expr, false))
+			default:
+				conds = append(conds, "("+w.expr(c.Expr, false)+")")
+			}
+		}
+		w.line("GROUP BY " + strings.Join(conds, " "))
+	}
+	if len(q.Having) > 0 {
+		var conds []string
+		for _, e := range q.Having {
+			conds = append(conds, w.constraint(e))
+		}
+		w.line("HAVING " + strings.Join(conds, " "))
+	}
+	if len(q.OrderBy) > 0 {
+		var conds []string
+		for _, c := range q.OrderBy {
+			if c.Desc {
+				conds = append(conds, "DESC("+w.expr(c.Expr, false)+")")
+				continue
+			}
+			if te, ok := c.Expr.(*TermExpr); ok && isVar(te.Term) {
+				conds = append(conds, w.expr(c.Expr, false))
+				continue
+			}
+			conds = append(conds, w.constraint(c.Expr))
+		}
+		w.line("ORDER BY " + strings.Join(conds, " "))
+	}
+	if q.Limit >= 0 {
+		w.line("LIMIT " + strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		w.line("OFFSET " + strconv.Itoa(q.Offset))
+	}
+	if q.Values != nil {
+		q.Values.format(w)
+	}
+}
+
+// isConstraint returns true if the expression can be written without
+// brackets as a constraint; a variable, or a function call.
+func isConstraint(e Expr) bool {
+	switch e := e.(type) {
+	case *TermExpr:
+		_, ok := e.Term.(Var)
+		return ok
+	case *CallExpr, *AggregateExpr:
+		return true
+	}
+	return false
+}
+
+// sparqlWriter writes SPARQL syntax, with IRIs abbreviated by the declared
+// prefixes.
+type sparqlWriter struct {
+	buf    bytes.Buffer
+	ns     map[string]string // namespace -> prefix label
+	indent int
+	inline bool // true when writing lines on a single line
+}
+
+func newSPARQLWriter(prefixes map[string]string) *sparqlWriter {
+	w := &sparqlWriter{ns: make(map[string]string)}
+	for label, ns := range prefixes {
+		if other, ok := w.ns[ns]; !ok || label < other {
+			w.ns[ns] = label
+		}
+	}
+	return w
+}
+
+// prologue writes the BASE and PREFIX declarations.
+func (w *sparqlWriter) prologue(base string, prefixes map[string]string) {
+	if base != "" {
+		w.line("BASE <" + base + ">")
+	}
+	labels := make([]string, 0, len(prefixes))
+	for label := range prefixes {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		w.line("PREFIX " + label + ": <" + prefixes[label] + ">")
+	}
+}
+
+// line writes an indented line.
+func (w *sparqlWriter) line(s string) {
+	if w.inline {
+		if w.buf.Len() > 0 {
+			w.buf.WriteByte(' ')
+		}
+		w.buf.WriteString(s)
+		return
+	}
+	for i := 0; i < w.indent; i++ {
+		w.buf.WriteByte('\t')
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteByte('\n')
+}
+
+// group writes a group graph pattern, starting with the given keywords.
+func (w *sparqlWriter) group(keywords string, g *GroupPattern) {
+	w.line(keywords + "{")
+	w.indent++
+	if len(g.Patterns) == 1 {
+		if sq, ok := g.Patterns[0].(*SubQueryPattern); ok {
+			// A subquery forms the whole group.
+			sq.Query.format(w)
+			w.indent--
+			w.line("}")
+			return
+		}
+	}
+	for _, p := range g.Patterns {
+		p.format(w)
+	}
+	w.indent--
+	w.line("}")
+}
+
+// rgxpPNLocal matches the local names written as prefixed names.
+var rgxpPNLocal = regexp.MustCompile(`^([A-Za-z0-9_]([A-Za-z0-9_.-]*[A-Za-z0-9_-])?)?$`)
+
+// term returns a term in SPARQL syntax.
+func (w *sparqlWriter) term(t PatternTerm) string {
+	switch t := t.(type) {
+	case IRI:
+		return w.iri(t)
+	case Literal:
+		return w.literal(t)
+	}
+	return t.Serialize(NTriples)
+}
+
+// iri returns an IRI, as a prefixed name if possible.
+func (w *sparqlWriter) iri(iri IRI) string {
+	best, found := "", false
+	for ns := range w.ns {
+		if !strings.HasPrefix(iri.str, ns) || !rgxpPNLocal.MatchString(iri.str[len(ns):]) {
+			continue
+		}
+		if !found || len(ns) > len(best) {
+			best, found = ns, true
+		}
+	}
+	if found {
+		return w.ns[best] + ":" + iri.str[len(best):]
+	}
+	return iri.Serialize(NTriples)
+}
+
+var (
+	rgxpInteger = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	rgxpDecimal = regexp.MustCompile(`^[+-]?[0-9]*\.[0-9]+$`)
+	rgxpDouble  = regexp.MustCompile(`^[+-]?([0-9]+\.[0-9]*|\.[0-9]+|[0-9]+)[eE][+-]?[0-9]+$`)
+)
+
+// literal returns a literal, in the short syntax of numbers and booleans
+// when its lexical form allows it.
+func (w *sparqlWriter) literal(l Literal) string {
+	switch {
+	case l.DataType == xsdInteger && rgxpInteger.MatchString(l.str),
+		l.DataType == xsdDecimal && rgxpDecimal.MatchString(l.str),
+		l.DataType == xsdDouble && rgxpDouble.MatchString(l.str),
+		l.DataType == xsdBoolean && (l.str == "true" || l.str == "false"):
+		return l.str
+	case l.DataType == rdfLangString:
+		return "\"" + escapeLiteral(l.str) + "\"@" + l.lang
+	case l.DataType == xsdString || l.DataType == (IRI{}):
+		return "\"" + escapeLiteral(l.str) + "\""
+	}
+	return "\"" + escapeLiteral(l.str) + "\"^^" + w.iri(l.DataType)
+}
+
+// triple returns a triple pattern, terminated by a dot.
+func (w *sparqlWriter) triple(tp TriplePattern) string {
+	var p string
+	switch {
+	case tp.Path != nil:
+		p = tp.Path.format(w, pathCtxAlternative)
+	case tp.Pred == rdfType:
+		p = "a"
+	default:
+		p = w.term(tp.Pred)
+	}
+	return w.term(tp.Subj) + " " + p + " " + w.term(tp.Obj) + " ."
+}
+
+// expr returns an expression. Nested binary expressions are bracketed.
+func (w *sparqlWriter) expr(e Expr, nested bool) string {
+	ew := &sparqlWriter{ns: w.ns}
+	e.format(ew, nested)
+	return ew.buf.String()
+}
+
+// constraint returns an expression as a FILTER, HAVING or ORDER BY
+// constraint, in brackets unless it is a function call.
+func (w *sparqlWriter) constraint(e Expr) string {
+	if isConstraint(e) {
+		if te, ok := e.(*TermExpr); !ok || !isVar(te.Term) {
+			return w.expr(e, false)
+		}
+	}
+	return "(" + w.expr(e, false) + ")"
+}
+
+func (g *GroupPattern) format(w *sparqlWriter) {
+	w.group("", g)
+}
+
+func (p *BasicPattern) format(w *sparqlWriter) {
+	for _, tp := range p.Triples {
+		w.line(w.triple(tp))
+	}
+}
+
+func (p *OptionalPattern) format(w *sparqlWriter) {
+	w.group("OPTIONAL ", p.Pattern)
+}
+
+func (p *UnionPattern) format(w *sparqlWriter) {
+	for i, g := range p.Patterns {
+		if i > 0 {
+			w.line("UNION")
+		}
+		w.group("", g)
+	}
+}
+
+func (p *MinusPattern) format(w *sparqlWriter) {
+	w.group("MINUS ", p.Pattern)
+}
+
+func (p *NamedGraphPattern) format(w *sparqlWriter) {
+	w.group("GRAPH "+w.term(p.Name)+" ", p.Pattern)
+}
+
+func (p *ServicePattern) format(w *sparqlWriter) {
+	keyword := "SERVICE "
+	if p.Silent {
+		keyword = "SERVICE SILENT "
+	}
+	w.group(keyword+w.term(p.Endpoint)+" ", p.Pattern)
+}
+
+func (p *FilterPattern) format(w *sparqlWriter) {
+	w.line("FILTER " + w.constraint(p.Expr))
+}
+
+func (p *BindPattern) format(w *sparqlWriter) {
+	w.line("BIND(" + w.expr(p.Expr, false) + " AS ?" + string(p.Var) + ")")
+}
+
+func (p *ValuesPattern) format(w *sparqlWriter) {
+	var vars []string
+	for _, v := range p.Vars {
+		vars = append(vars, "?"+string(v))
+	}
+	w.line("VALUES (" + strings.Join(vars, " ") + ") {")
+	w.indent++
+	for _, row := range p.Rows {
+		var vals []string
+		for _, t := range row {
+			if t == nil {
+				vals = append(vals, "UNDEF")
+				continue
+			}
+			vals = append(vals, w.term(t))
+		}
+		w.line("(" + strings.Join(vals, " ") + ")")
+	}
+	w.indent--
+	w.line("}")
+}
+
+func (p *SubQueryPattern) format(w *sparqlWriter) {
+	w.group("", &GroupPattern{Patterns: []Pattern{p}})
+}
+
+func (e *TermExpr) format(w *sparqlWriter, nested bool) {
+	w.buf.WriteString(w.term(e.Term))
+}
+
+func (e *BinaryExpr) format(w *sparqlWriter, nested bool) {
+	if nested {
+		w.buf.WriteByte('(')
+	}
+	e.Left.format(w, true)
+	w.buf.WriteString(" " + e.Op + " ")
+	e.Right.format(w, true)
+	if nested {
+		w.buf.WriteByte(')')
+	}
+}
+
+func (e *UnaryExpr) format(w *sparqlWriter, nested bool) {
+	w.buf.WriteString(e.Op)
+	if e.Op == "!" && isConstraint(e.Arg) {
+		e.Arg.format(w, true)
+		return
+	}
+	// Brackets keep the sign apart from a numeric literal.
+	w.buf.WriteByte('(')
+	e.Arg.format(w, false)
+	w.buf.WriteByte(')')
+}
+
+func (e *InExpr) format(w *sparqlWriter, nested bool) {
+	if nested {
+		w.buf.WriteByte('(')
+	}
+	e.Arg.format(w, true)
+	if e.Not {
+		w.buf.WriteString(" NOT")
+	}
+	w.buf.WriteString(" IN (")
+	for i, arg := range e.List {
+		if i > 0 {
+			w.buf.WriteString(", ")
+		}
+		arg.format(w, false)
+	}
+	w.buf.WriteByte(')')
+	if nested {
+		w.buf.WriteByte(')')
+	}
+}
+
+func (e *CallExpr) format(w *sparqlWriter, nested bool) {
+	if e.Name != "" {
+		w.buf.WriteString(e.Name)
+	} else {
+		w.buf.WriteString(w.iri(e.IRI))
+	}
+	w.buf.WriteByte('(')
+	for i, arg := range e.Args {
+		if i > 0 {
+			w.buf.WriteString(", ")
+		}
+		arg.format(w, false)
+	}
+	w.buf.WriteByte(')')
+}
+
+func (e *ExistsExpr) format(w *sparqlWriter, nested bool) {
+	if e.Not {
+		w.buf.WriteString("NOT ")
+	}
+	w.buf.WriteString("EXISTS ")
+	pw := &sparqlWriter{ns: w.ns, inline: true}
+	pw.group("", e.Pattern)
+	w.buf.WriteString(pw.buf.String())
+}
+
+func (e *AggregateExpr) format(w *sparqlWriter, nested bool) {
+	w.buf.WriteString(e.Name + "(")
+	if e.Distinct {
+		w.buf.WriteString("DISTINCT ")
+	}
+	if e.Arg == nil {
+		w.buf.WriteByte('*')
+	} else {
+		e.Arg.format(w, false)
+	}
+	if e.Name == "GROUP_CONCAT" && e.Separator != " " {
+		w.buf.WriteString("; SEPARATOR=\"" + escapeLiteral(e.Separator) + "\"")
+	}
+	w.buf.WriteByte(')')
+}
@@ -0,0 +1,649 @@
+package rdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Op is an operator of the SPARQL algebra, as given by Query.Algebra. The
+// String method returns the operator as an S-expression, in the style of
+// the SPARQL Syntax Expressions (SSE) used by the SPARQL test suites.
+type Op interface {
+	String() string
+	sse(w *sparqlWriter)
+}
+
+// OpBGP is a basic graph pattern. The empty BGP is the identity of joins,
+// giving a single solution without bindings.
+type OpBGP struct {
+	Triples []TriplePattern
+}
+
+// OpPath matches a property path between a subject and an object, where
+// either may be a variable.
+type OpPath struct {
+	Subj PatternTerm
+	Path Path
+	Obj  PatternTerm
+}
+
+// OpJoin is the join of two operators.
+type OpJoin struct {
+	Left, Right Op
+}
+
+// OpLeftJoin is the left join of two operators, as given by OPTIONAL, where
+// the optional Expr filters the joined solutions.
+type OpLeftJoin struct {
+	Left, Right Op
+	Expr        Expr // nil if the join is not filtered
+}
+
+// OpFilter filters the solutions of Arg by its expressions.
+type OpFilter struct {
+	Exprs []Expr
+	Arg   Op
+}
+
+// OpUnion is the union of two operators.
+type OpUnion struct {
+	Left, Right Op
+}
+
+// OpGraph evaluates Arg against a named graph, where Name is an IRI or a
+// variable.
+type OpGraph struct {
+	Name PatternTerm
+	Arg  Op
+}
+
+// OpService evaluates Pattern at a remote SPARQL endpoint, where Endpoint
+// is an IRI or a variable. Arg is the translation of the pattern.
+type OpService struct {
+	Silent   bool
+	Endpoint PatternTerm
+	Pattern  *GroupPattern
+	Arg      Op
+}
+
+// OpExtend binds a variable to the value of an expression, as given by BIND
+// and by the expressions of a projection.
+type OpExtend struct {
+	Arg  Op
+	Var  Var
+	Expr Expr
+}
+
+// OpMinus removes the compatible solutions of Right from Left.
+type OpMinus struct {
+	Left, Right Op
+}
+
+// OpTable is inline data, as given by VALUES. A nil term in a row is unbound.
+type OpTable struct {
+	Vars []Var
+	Rows [][]Term
+}
+
+// OpGroup groups the solutions of Arg by its keys, and binds the variables
+// of the aggregates for each group. Without keys, all solutions form a
+// single group.
+type OpGroup struct {
+	Arg        Op
+	Keys       []Expr
+	Aggregates []AggregateBinding
+}
+
+// AggregateBinding binds the value of an aggregate to a variable.
+type AggregateBinding struct {
+	Var       Var
+	Aggregate *AggregateExpr
+}
+
+// OpOrderBy sorts the solutions of Arg.
+type OpOrderBy struct {
+	Conds []OrderCondition
+	Arg   Op
+}
+
+// OpProject restricts the solutions of Arg to the given variables.
+type OpProject struct {
+	Vars []Var
+	Arg  Op
+}
+
+// OpDistinct removes duplicate solutions.
+type OpDistinct struct {
+	Arg Op
+}
+
+// OpReduced permits the removal of duplicate solutions.
+type OpReduced struct {
+	Arg Op
+}
+
+// OpSlice skips the first Offset solutions of Arg, and returns at most Limit
+// solutions, where -1 is no limit.
+type OpSlice struct {
+	Offset, Limit int
+	Arg           Op
+}
+
+// Algebra translates the query to the SPARQL algebra, following section 18.2
+// of the SPARQL 1.1 specification. Aggregates are replaced by the variables
+// ?.1, ?.2, etc, bound by an OpGroup.
+//
+// The operator gives the solutions of the query; the template of a CONSTRUCT
+// query, and the resources of a DESCRIBE query, are not part of the algebra.
+func (q *Query) Algebra() Op {
+	var op Op = &OpBGP{}
+	if q.Where != nil {
+		op = translateGroup(q.Where)
+	}
+
+	// Grouping and aggregates
+	aggs := &aggregateVars{}
+	selectExprs := make([]Expr, len(q.Select))
+	for i, item := range q.Select {
+		if item.Expr != nil {
+			selectExprs[i] = aggs.replace(item.Expr)
+		}
+	}
+	having := make([]Expr, len(q.Having))
+	for i, e := range q.Having {
+		having[i] = aggs.replace(e)
+	}
+	orderBy := make([]OrderCondition, len(q.OrderBy))
+	for i, c := range q.OrderBy {
+		orderBy[i] = OrderCondition{Expr: aggs.replace(c.Expr), Desc: c.Desc}
+	}
+	if q.GroupBy != nil || aggs.bindings != nil {
+		g := &OpGroup{Aggregates: aggs.bindings}
+		for _, c := range q.GroupBy {
+			if c.Var == "" {
+				g.Keys = append(g.Keys, c.Expr)
+				continue
+			}
+			op = &OpExtend{Arg: op, Var: c.Var, Expr: c.Expr}
+			g.Keys = append(g.Keys, &TermExpr{Term: c.Var})
+		}
+		g.Arg = op
+		op = g
+	}
+	if len(having) > 0 {
+		op = &OpFilter{Exprs: having, Arg: op}
+	}
+	if q.Values != nil {
+		op = &OpJoin{Left: op, Right: q.Values.algebra()}
+	}
+
+	// Projection and solution modifiers
+	for i, item := range q.Select {
+		if item.Expr != nil {
+			op = &OpExtend{Arg: op, Var: item.Var, Expr: selectExprs[i]}
+		}
+	}
+	if len(orderBy) > 0 {
+		op = &OpOrderBy{Conds: orderBy, Arg: op}
+	}
+	if q.Form == QuerySelect {
+		op = &OpProject{Vars: q.projection(), Arg: op}
+	}
+	if q.Distinct {
+		op = &OpDistinct{Arg: op}
+	} else if q.Reduced {
+		op = &OpReduced{Arg: op}
+	}
+	if q.Limit >= 0 || q.Offset > 0 {
+		op = &OpSlice{Offset: q.Offset, Limit: q.Limit, Arg: op}
+	}
+	return op
+}
+
+// projection returns the projected variables of a SELECT query; for SELECT *
+// these are the variables in scope of the WHERE clause.
+func (q *Query) projection() []Var {
+	if q.Select != nil {
+		vars := make([]Var, len(q.Select))
+		for i, item := range q.Select {
+			vars[i] = item.Var
+		}
+		return vars
+	}
+	var vars []Var
+	seen := make(map[Var]bool)
+	add := func(v Var) {
+		if !seen[v] {
+			seen[v] = true
+			vars = append(vars, v)
+		}
+	}
+	if q.Where != nil {
+		scopeVars(q.Where, add)
+	}
+	if q.Values != nil {
+		for _, v := range q.Values.Vars {
+			add(v)
+		}
+	}
+	return vars
+}
+
+// scopeVars calls add with the variables in scope of the pattern, in order
+// of appearance.
+func scopeVars(p Pattern, add func(Var)) {
+	addTerm := func(t PatternTerm) {
+		if v, ok := t.(Var); ok {
+			add(v)
+		}
+	}
+	switch p := p.(type) {
+	case *GroupPattern:
+		for _, p := range p.Patterns {
+			scopeVars(p, add)
+		}
+	case *BasicPattern:
+		for _, tp := range p.Triples {
+			addTerm(tp.Subj)
+			addTerm(tp.Pred)
+			addTerm(tp.Obj)
+		}
+	case *OptionalPattern:
+		scopeVars(p.Pattern, add)
+	case *UnionPattern:
+		for _, g := range p.Patterns {
+			scopeVars(g, add)
+		}
+	case *NamedGraphPattern:
+		addTerm(p.Name)
+		scopeVars(p.Pattern, add)
+	case *ServicePattern:
+		addTerm(p.Endpoint)
+		scopeVars(p.Pattern, add)
+	case *BindPattern:
+		add(p.Var)
+	case *ValuesPattern:
+		for _, v := range p.Vars {
+			add(v)
+		}
+	case *SubQueryPattern:
+		for _, v := range p.Query.projection() {
+			add(v)
+		}
+	}
+}
+
+// translateGroup translates a group graph pattern. The filters of the group
+// apply to the whole group, and OPTIONAL { P FILTER(e) } becomes a left join
+// filtered by e.
+func translateGroup(g *GroupPattern) Op {
+	var filters []Expr
+	var op Op = &OpBGP{}
+	for _, p := range g.Patterns {
+		switch p := p.(type) {
+		case *FilterPattern:
+			filters = append(filters, p.Expr)
+		case *OptionalPattern:
+			lj := &OpLeftJoin{Left: op, Right: translateGroup(p.Pattern)}
+			if f, ok := lj.Right.(*OpFilter); ok {
+				lj.Right, lj.Expr = f.Arg, andExprs(f.Exprs)
+			}
+			op = lj
+		case *MinusPattern:
+			op = &OpMinus{Left: op, Right: translateGroup(p.Pattern)}
+		case *BindPattern:
+			op = &OpExtend{Arg: op, Var: p.Var, Expr: p.Expr}
+		default:
+			op = join(op, translatePattern(p))
+		}
+	}
+	if len(filters) > 0 {
+		op = &OpFilter{Exprs: filters, Arg: op}
+	}
+	return op
+}
+
+// translatePattern translates a pattern, other than those handled by
+// translateGroup.
+func translatePattern(p Pattern) Op {
+	switch p := p.(type) {
+	case *GroupPattern:
+		return translateGroup(p)
+	case *BasicPattern:
+		return translateBasic(p.Triples)
+	case *UnionPattern:
+		op := translateGroup(p.Patterns[0])
+		for _, g := range p.Patterns[1:] {
+			op = &OpUnion{Left: op, Right: translateGroup(g)}
+		}
+		return op
+	case *NamedGraphPattern:
+		return &OpGraph{Name: p.Name, Arg: translateGroup(p.Pattern)}
+	case *ServicePattern:
+		return &OpService{Silent: p.Silent, Endpoint: p.Endpoint, Pattern: p.Pattern, Arg: translateGroup(p.Pattern)}
+	case *ValuesPattern:
+		return p.algebra()
+	case *SubQueryPattern:
+		return p.Query.Algebra()
+	}
+	panic("rdf: cannot translate pattern: " + strings.TrimSpace(patternString(p)))
+}
+
+// translateBasic translates triple patterns to a BGP, joined with the
+// property paths among them. An inverted predicate becomes a triple
+// pattern with subject and object exchanged.
+func translateBasic(tps []TriplePattern) Op {
+	var op Op = &OpBGP{}
+	bgp := &OpBGP{}
+	for _, tp := range tps {
+		if inv, ok := tp.Path.(*InversePath); ok {
+			if link, ok := inv.Path.(*LinkPath); ok {
+				tp = TriplePattern{Subj: tp.Obj, Pred: link.IRI, Obj: tp.Subj}
+			}
+		}
+		if tp.Path == nil {
+			bgp.Triples = append(bgp.Triples, tp)
+			continue
+		}
+		op = join(join(op, bgp), &OpPath{Subj: tp.Subj, Path: tp.Path, Obj: tp.Obj})
+		bgp = &OpBGP{}
+	}
+	return join(op, bgp)
+}
+
+// patternString returns the pattern in SPARQL syntax.
+func patternString(p Pattern) string {
+	w := newSPARQLWriter(nil)
+	p.format(w)
+	return w.buf.String()
+}
+
+// join joins two operators, where the empty BGP is the identity.
+func join(left, right Op) Op {
+	if isIdentity(left) {
+		return right
+	}
+	if isIdentity(right) {
+		return left
+	}
+	return &OpJoin{Left: left, Right: right}
+}
+
+// isIdentity returns true if the operator is the empty BGP.
+func isIdentity(op Op) bool {
+	bgp, ok := op.(*OpBGP)
+	return ok && len(bgp.Triples) == 0
+}
+
+// andExprs returns the conjunction of the expressions.
+func andExprs(es []Expr) Expr {
+	e := es[0]
+	for _, r := range es[1:] {
+		e = &BinaryExpr{Op: "&&", Left: e, Right: r}
+	}
+	return e
+}
+
+func (p *ValuesPattern) algebra() Op {
+	return &OpTable{Vars: p.Vars, Rows: p.Rows}
+}
+
+// aggregateVars replaces aggregates by variables, with identical aggregates
+// sharing a variable.
+type aggregateVars struct {
+	bindings []AggregateBinding
+	keys     []string
+}
+
+// replace returns a copy of the expression, with its aggregates replaced.
+func (a *aggregateVars) replace(e Expr) Expr {
+	switch e := e.(type) {
+	case *AggregateExpr:
+		k := exprSSE(e)
+		for i, key := range a.keys {
+			if key == k {
+				return &TermExpr{Term: a.bindings[i].Var}
+			}
+		}
+		v := Var("." + strconv.Itoa(len(a.bindings)+1))
+		a.bindings = append(a.bindings, AggregateBinding{Var: v, Aggregate: e})
+		a.keys = append(a.keys, k)
+		return &TermExpr{Term: v}
+	case *BinaryExpr:
+		return &BinaryExpr{Op: e.Op, Left: a.replace(e.Left), Right: a.replace(e.Right)}
+	case *UnaryExpr:
+		return &UnaryExpr{Op: e.Op, Arg: a.replace(e.Arg)}
+	case *InExpr:
+		in := &InExpr{Arg: a.replace(e.Arg), Not: e.Not, List: make([]Expr, len(e.List))}
+		for i, x := range e.List {
+			in.List[i] = a.replace(x)
+		}
+		return in
+	case *CallExpr:
+		c := &CallExpr{Name: e.Name, IRI: e.IRI, Args: make([]Expr, len(e.Args))}
+		for i, x := range e.Args {
+			c.Args[i] = a.replace(x)
+		}
+		return c
+	}
+	return e
+}
+
+// String methods, in SSE syntax:
+
+func opString(op Op) string {
+	w := newSPARQLWriter(nil)
+	op.sse(w)
+	return w.buf.String()
+}
+
+func (op *OpBGP) String() string      { return opString(op) }
+func (op *OpPath) String() string     { return opString(op) }
+func (op *OpJoin) String() string     { return opString(op) }
+func (op *OpLeftJoin) String() string { return opString(op) }
+func (op *OpFilter) String() string   { return opString(op) }
+func (op *OpUnion) String() string    { return opString(op) }
+func (op *OpGraph) String() string    { return opString(op) }
+func (op *OpService) String() string  { return opString(op) }
+func (op *OpExtend) String() string   { return opString(op) }
+func (op *OpMinus) String() string    { return opString(op) }
+func (op *OpTable) String() string    { return opString(op) }
+func (op *OpGroup) String() string    { return opString(op) }
+func (op *OpOrderBy) String() string  { return opString(op) }
+func (op *OpProject) String() string  { return opString(op) }
+func (op *OpDistinct) String() string { return opString(op) }
+func (op *OpReduced) String() string  { return opString(op) }
+func (op *OpSlice) String() string    { return opString(op) }
+
+func (op *OpBGP) sse(w *sparqlWriter) {
+	w.buf.WriteString("(bgp")
+	for _, tp := range op.Triples {
+		w.buf.WriteString(" (triple " + w.term(tp.Subj) + " " + w.term(tp.Pred) + " " + w.term(tp.Obj) + ")")
+	}
+	w.buf.WriteByte(')')
+}
+
+func (op *OpPath) sse(w *sparqlWriter) {
+	w.buf.WriteString("(path " + w.term(op.Subj) + " " + pathSSE(op.Path) + " " + w.term(op.Obj) + ")")
+}
+
+// sseOp writes an operator with its arguments.
+func (w *sparqlWriter) sseOp(name string, args ...interface{}) {
+	w.buf.WriteString("(" + name)
+	for _, arg := range args {
+		w.buf.WriteByte(' ')
+		switch arg := arg.(type) {
+		case Op:
+			arg.sse(w)
+		case Expr:
+			w.buf.WriteString(exprSSE(arg))
+		case string:
+			w.buf.WriteString(arg)
+		}
+	}
+	w.buf.WriteByte(')')
+}
+
+func (op *OpJoin) sse(w *sparqlWriter) { w.sseOp("join", op.Left, op.Right) }
+
+func (op *OpLeftJoin) sse(w *sparqlWriter) {
+	if op.Expr == nil {
+		w.sseOp("leftjoin", op.Left, op.Right)
+		return
+	}
+	w.sseOp("leftjoin", op.Left, op.Right, op.Expr)
+}
+
+func (op *OpFilter) sse(w *sparqlWriter) {
+	if len(op.Exprs) == 1 {
+		w.sseOp("filter", op.Exprs[0], op.Arg)
+		return
+	}
+	w.sseOp("filter", exprsSSE("exprs", op.Exprs), op.Arg)
+}
+
+func (op *OpUnion) sse(w *sparqlWriter) { w.sseOp("union", op.Left, op.Right) }
+
+func (op *OpGraph) sse(w *sparqlWriter) { w.sseOp("graph", w.term(op.Name), op.Arg) }
+
+func (op *OpService) sse(w *sparqlWriter) {
+	if op.Silent {
+		w.sseOp("service", "silent", w.term(op.Endpoint), op.Arg)
+		return
+	}
+	w.sseOp("service", w.term(op.Endpoint), op.Arg)
+}
+
+func (op *OpExtend) sse(w *sparqlWriter) {
+	w.sseOp("extend", "(("+w.term(op.Var)+" "+exprSSE(op.Expr)+"))", op.Arg)
+}
+
+func (op *OpMinus) sse(w *sparqlWriter) { w.sseOp("minus", op.Left, op.Right) }
+
+func (op *OpTable) sse(w *sparqlWriter) {
+	vars := make([]string, len(op.Vars))
+	for i, v := range op.Vars {
+		vars[i] = w.term(v)
+	}
+	args := []interface{}{"(vars" + prefixSpace(vars) + ")"}
+	for _, row := range op.Rows {
+		var bindings []string
+		for i, t := range row {
+			if t != nil {
+				bindings = append(bindings, "["+w.term(op.Vars[i])+" "+w.term(t)+"]")
+			}
+		}
+		args = append(args, "(row"+prefixSpace(bindings)+")")
+	}
+	w.sseOp("table", args...)
+}
+
+func (op *OpGroup) sse(w *sparqlWriter) {
+	keys := make([]string, len(op.Keys))
+	for i, k := range op.Keys {
+		keys[i] = exprSSE(k)
+	}
+	aggs := make([]string, len(op.Aggregates))
+	for i, a := range op.Aggregates {
+		aggs[i] = "(" + w.term(a.Var) + " " + exprSSE(a.Aggregate) + ")"
+	}
+	if len(aggs) == 0 {
+		w.sseOp("group", "("+strings.Join(keys, " ")+")", op.Arg)
+		return
+	}
+	w.sseOp("group", "("+strings.Join(keys, " ")+")", "("+strings.Join(aggs, " ")+")", op.Arg)
+}
+
+func (op *OpOrderBy) sse(w *sparqlWriter) {
+	conds := make([]string, len(op.Conds))
+	for i, c := range op.Conds {
+		conds[i] = exprSSE(c.Expr)
+		if c.Desc {
+			conds[i] = "(desc " + conds[i] + ")"
+		}
+	}
+	w.sseOp("order", "("+strings.Join(conds, " ")+")", op.Arg)
+}
+
+func (op *OpProject) sse(w *sparqlWriter) {
+	vars := make([]string, len(op.Vars))
+	for i, v := range op.Vars {
+		vars[i] = w.term(v)
+	}
+	w.sseOp("project", "("+strings.Join(vars, " ")+")", op.Arg)
+}
+
+func (op *OpDistinct) sse(w *sparqlWriter) { w.sseOp("distinct", op.Arg) }
+
+func (op *OpReduced) sse(w *sparqlWriter) { w.sseOp("reduced", op.Arg) }
+
+func (op *OpSlice) sse(w *sparqlWriter) {
+	offset, limit := "_", "_"
+	if op.Offset > 0 {
+		offset = strconv.Itoa(op.Offset)
+	}
+	if op.Limit >= 0 {
+		limit = strconv.Itoa(op.Limit)
+	}
+	w.sseOp("slice", offset, limit, op.Arg)
+}
+
+// prefixSpace joins the strings, each preceded by a space.
+func prefixSpace(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	return " " + strings.Join(ss, " ")
+}
+
+// exprsSSE returns an S-expression of the given name and expressions.
+func exprsSSE(name string, es []Expr) string {
+	args := make([]string, len(es))
+	for i, e := range es {
+		args[i] = exprSSE(e)
+	}
+	return "(" + name + prefixSpace(args) + ")"
+}
+
+// exprSSE returns the expression as an S-expression.
+func exprSSE(e Expr) string {
+	w := newSPARQLWriter(nil)
+	switch e := e.(type) {
+	case *TermExpr:
+		return w.term(e.Term)
+	case *BinaryExpr:
+		return exprsSSE(e.Op, []Expr{e.Left, e.Right})
+	case *UnaryExpr:
+		return exprsSSE(e.Op, []Expr{e.Arg})
+	case *InExpr:
+		name := "in"
+		if e.Not {
+			name = "notin"
+		}
+		return exprsSSE(name, append([]Expr{e.Arg}, e.List...))
+	case *CallExpr:
+		if e.Name == "" {
+			return exprsSSE(w.iri(e.IRI), e.Args)
+		}
+		return exprsSSE(strings.ToLower(e.Name), e.Args)
+	case *ExistsExpr:
+		name := "exists"
+		if e.Not {
+			name = "notexists"
+		}
+		return "(" + name + " " + translateGroup(e.Pattern).String() + ")"
+	case *AggregateExpr:
+		var args []string
+		if e.Distinct {
+			args = append(args, "distinct")
+		}
+		if e.Name == "GROUP_CONCAT" && e.Separator != " " {
+			args = append(args, "(separator "+w.literal(Literal{str: e.Separator, DataType: xsdString})+")")
+		}
+		if e.Arg != nil {
+			args = append(args, exprSSE(e.Arg))
+		}
+		return "(" + strings.ToLower(e.Name) + prefixSpace(args) + ")"
+	}
+	return ""
+}
@@ -0,0 +1,363 @@
+package rdf
+
+import (
+	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// QueryBuilder builds a SELECT query from the terms of the package and
+// variables, such that the query text is correctly escaped:
+//
+//	q, err := rdf.Select(rdf.Var("name")).
+//		Where(rdf.NewTriplePattern(rdf.Var("p"), foafName, rdf.Var("name"))).
+//		Optional(rdf.NewTriplePattern(rdf.Var("p"), foafAge, rdf.Var("age"))).
+//		Filter(rdf.Or(rdf.Not(rdf.Bound(rdf.Var("age"))), rdf.Greater(rdf.Var("age"), age))).
+//		OrderBy(rdf.Asc(rdf.Var("name"))).
+//		Limit(10).
+//		Build()
+//
+// Literals are written with the escaping of N-Triples, and IRIs as by
+// IRI.Serialize, or as prefixed names when a prefix is declared. The terms,
+// variables and functions are checked when the query is built; invalid ones,
+// such as the zero IRI or a blank node label with spaces, are reported as
+// errors by Query and Build.
+type QueryBuilder struct {
+	q *Query
+}
+
+// Select returns a QueryBuilder of a SELECT query, projecting the given
+// variables, or all variables if none are given.
+func Select(vars ...Var) *QueryBuilder {
+	q := &Query{Form: QuerySelect, Where: &GroupPattern{}, Limit: -1}
+	for _, v := range vars {
+		q.Select = append(q.Select, SelectItem{Var: v})
+	}
+	return &QueryBuilder{q: q}
+}
+
+// Distinct removes duplicate solutions.
+func (b *QueryBuilder) Distinct() *QueryBuilder {
+	b.q.Distinct = true
+	return b
+}
+
+// Prefix declares a prefix label, used to abbreviate the IRIs of the query.
+func (b *QueryBuilder) Prefix(label string, namespace IRI) *QueryBuilder {
+	if b.q.Prefixes == nil {
+		b.q.Prefixes = make(map[string]string)
+	}
+	b.q.Prefixes[label] = namespace.str
+	return b
+}
+
+// Where adds triple patterns to the WHERE clause.
+func (b *QueryBuilder) Where(tps ...TriplePattern) *QueryBuilder {
+	g := b.q.Where
+	if n := len(g.Patterns); n > 0 {
+		if bp, ok := g.Patterns[n-1].(*BasicPattern); ok {
+			bp.Triples = append(bp.Triples, tps...)
+			return b
+		}
+	}
+	g.Patterns = append(g.Patterns, &BasicPattern{Triples: tps})
+	return b
+}
+
+// Optional adds an OPTIONAL pattern of the triple patterns to the WHERE
+// clause.
+func (b *QueryBuilder) Optional(tps ...TriplePattern) *QueryBuilder {
+	b.q.Where.Patterns = append(b.q.Where.Patterns, &OptionalPattern{
+		Pattern: &GroupPattern{Patterns: []Pattern{&BasicPattern{Triples: tps}}},
+	})
+	return b
+}
+
+// Filter adds a FILTER constraint to the WHERE clause.
+func (b *QueryBuilder) Filter(e Expr) *QueryBuilder {
+	b.q.Where.Patterns = append(b.q.Where.Patterns, &FilterPattern{Expr: e})
+	return b
+}
+
+// OrderBy sorts the solutions by the given conditions.
+func (b *QueryBuilder) OrderBy(conds ...OrderCondition) *QueryBuilder {
+	b.q.OrderBy = append(b.q.OrderBy, conds...)
+	return b
+}
+
+// Limit sets the maximum number of solutions.
+func (b *QueryBuilder) Limit(n int) *QueryBuilder {
+	b.q.Limit = n
+	return b
+}
+
+// Offset sets the number of solutions skipped.
+func (b *QueryBuilder) Offset(n int) *QueryBuilder {
+	b.q.Offset = n
+	return b
+}
+
+// Query returns the query, or an error if it is invalid.
+func (b *QueryBuilder) Query() (*Query, error) {
+	if err := checkQuery(b.q); err != nil {
+		return nil, err
+	}
+	return b.q, nil
+}
+
+// Build returns the query in SPARQL syntax, or an error if it is invalid.
+func (b *QueryBuilder) Build() (string, error) {
+	q, err := b.Query()
+	if err != nil {
+		return "", err
+	}
+	return q.String(), nil
+}
+
+// NewTriplePattern returns a triple pattern, where any term may be a
+// variable.
+func NewTriplePattern(subj, pred, obj PatternTerm) TriplePattern {
+	return TriplePattern{Subj: subj, Pred: pred, Obj: obj}
+}
+
+// Asc returns an ascending order condition. The operand is a Term, a Var
+// or an Expr.
+func Asc(x interface{}) OrderCondition {
+	return OrderCondition{Expr: operand(x)}
+}
+
+// Desc returns a descending order condition. The operand is a Term, a Var
+// or an Expr.
+func Desc(x interface{}) OrderCondition {
+	return OrderCondition{Expr: operand(x), Desc: true}
+}
+
+// Equal returns the expression a = b. The operands of the expression
+// functions are Terms, Vars or Exprs.
+func Equal(a, b interface{}) Expr { return binary("=", a, b) }
+
+// NotEqual returns the expression a != b.
+func NotEqual(a, b interface{}) Expr { return binary("!=", a, b) }
+
+// Less returns the expression a < b.
+func Less(a, b interface{}) Expr { return binary("<", a, b) }
+
+// LessOrEqual returns the expression a <= b.
+func LessOrEqual(a, b interface{}) Expr { return binary("<=", a, b) }
+
+// Greater returns the expression a > b.
+func Greater(a, b interface{}) Expr { return binary(">", a, b) }
+
+// GreaterOrEqual returns the expression a >= b.
+func GreaterOrEqual(a, b interface{}) Expr { return binary(">=", a, b) }
+
+// And returns the conjunction of the expressions.
+func And(exprs ...Expr) Expr { return fold("&&", exprs) }
+
+// Or returns the disjunction of the expressions.
+func Or(exprs ...Expr) Expr { return fold("||", exprs) }
+
+// Not returns the negation of the expression.
+func Not(e Expr) Expr { return &UnaryExpr{Op: "!", Arg: e} }
+
+// Bound returns the expression BOUND(v).
+func Bound(v Var) Expr { return &CallExpr{Name: "BOUND", Args: []Expr{&TermExpr{Term: v}}} }
+
+// Call returns a call of a builtin function, such as STRLEN or REGEX.
+func Call(name string, args ...interface{}) Expr {
+	c := &CallExpr{Name: strings.ToUpper(name)}
+	for _, arg := range args {
+		c.Args = append(c.Args, operand(arg))
+	}
+	return c
+}
+
+// In returns the expression x IN (list).
+func In(x interface{}, list ...interface{}) Expr {
+	e := &InExpr{Arg: operand(x)}
+	for _, y := range list {
+		e.List = append(e.List, operand(y))
+	}
+	return e
+}
+
+func binary(op string, a, b interface{}) Expr {
+	return &BinaryExpr{Op: op, Left: operand(a), Right: operand(b)}
+}
+
+// fold returns the expressions joined by the operator, or true if there
+// are none.
+func fold(op string, exprs []Expr) Expr {
+	if len(exprs) == 0 {
+		return &TermExpr{Term: Literal{str: "true", DataType: xsdBoolean}}
+	}
+	e := exprs[0]
+	for _, x := range exprs[1:] {
+		e = &BinaryExpr{Op: op, Left: e, Right: x}
+	}
+	return e
+}
+
+// invalidOperand is an operand which is neither a Term, a Var nor an Expr,
+// reported when the query is built.
+type invalidOperand struct {
+	v interface{}
+}
+
+func (e *invalidOperand) format(w *sparqlWriter, nested bool) {}
+
+// operand returns a Term, a Var or an Expr as an expression.
+func operand(x interface{}) Expr {
+	switch x := x.(type) {
+	case Expr:
+		return x
+	case Term:
+		return &TermExpr{Term: x}
+	case Var:
+		return &TermExpr{Term: x}
+	}
+	return &invalidOperand{v: x}
+}
+
+var (
+	rgxpVarName    = regexp.MustCompile(`^[\pL\pN_][\pL\pN_\x{00B7}\x{0300}-\x{036F}\x{203F}-\x{2040}]*$`)
+	rgxpBlankLabel = regexp.MustCompile(`^_:[\pL\pN_]([\pL\pN_.-]*[\pL\pN_-])?$`)
+	rgxpPNPrefix   = regexp.MustCompile(`^(\pL([\pL\pN_.-]*[\pL\pN_-])?)?$`)
+)
+
+// checkQuery returns an error if the query has invalid terms, variables,
+// prefixes or functions.
+func checkQuery(q *Query) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if _, ok := r.(runtime.Error); ok {
+			// Don't recover from runtime errors.
+			panic(r)
+		}
+		err = r.(error)
+	}()
+	for label, ns := range q.Prefixes {
+		if !rgxpPNPrefix.MatchString(label) {
+			invalid("prefix label: %q", label)
+		}
+		checkTerm(IRI{str: ns})
+	}
+	for _, item := range q.Select {
+		checkTerm(item.Var)
+	}
+	checkPattern(q.Where)
+	for _, c := range q.OrderBy {
+		checkExpr(c.Expr)
+	}
+	if q.Limit < -1 {
+		invalid("negative limit: %d", q.Limit)
+	}
+	if q.Offset < 0 {
+		invalid("negative offset: %d", q.Offset)
+	}
+	return nil
+}
+
+// invalid formats the error of an invalid query, and terminates checking.
+func invalid(format string, args ...interface{}) {
+	panic(fmt.Errorf("invalid "+format, args...))
+}
+
+func checkPattern(p Pattern) {
+	switch p := p.(type) {
+	case *GroupPattern:
+		for _, p := range p.Patterns {
+			checkPattern(p)
+		}
+	case *BasicPattern:
+		for _, tp := range p.Triples {
+			switch tp.Subj.(type) {
+			case Var, IRI, Blank:
+			default:
+				invalid("subject: %v", tp.Subj)
+			}
+			switch tp.Pred.(type) {
+			case Var, IRI:
+			default:
+				invalid("predicate: %v", tp.Pred)
+			}
+			switch tp.Obj.(type) {
+			case Var, IRI, Blank, Literal:
+			default:
+				invalid("object: %v", tp.Obj)
+			}
+			checkTerm(tp.Subj)
+			checkTerm(tp.Pred)
+			checkTerm(tp.Obj)
+		}
+	case *OptionalPattern:
+		checkPattern(p.Pattern)
+	case *FilterPattern:
+		checkExpr(p.Expr)
+	}
+}
+
+func checkExpr(e Expr) {
+	switch e := e.(type) {
+	case nil:
+		invalid("expression: <nil>")
+	case *invalidOperand:
+		invalid("operand of type %T", e.v)
+	case *TermExpr:
+		if e.Term == nil {
+			invalid("operand: <nil>")
+		}
+		checkTerm(e.Term)
+	case *BinaryExpr:
+		checkExpr(e.Left)
+		checkExpr(e.Right)
+	case *UnaryExpr:
+		checkExpr(e.Arg)
+	case *InExpr:
+		checkExpr(e.Arg)
+		for _, x := range e.List {
+			checkExpr(x)
+		}
+	case *CallExpr:
+		if e.Name == "" {
+			checkTerm(e.IRI)
+		} else if arity, ok := sparqlBuiltins[e.Name]; !ok {
+			invalid("function: %s", e.Name)
+		} else if len(e.Args) < arity[0] || (arity[1] >= 0 && len(e.Args) > arity[1]) {
+			invalid("number of arguments to %s: %d", e.Name, len(e.Args))
+		}
+		for _, x := range e.Args {
+			checkExpr(x)
+		}
+	}
+}
+
+func checkTerm(t PatternTerm) {
+	switch t := t.(type) {
+	case nil:
+		invalid("term: <nil>")
+	case Var:
+		if !rgxpVarName.MatchString(string(t)) {
+			invalid("variable name: %q", string(t))
+		}
+	case IRI:
+		if _, err := NewIRI(t.str); err != nil {
+			invalid("IRI %q: %v", t.str, err)
+		}
+	case Blank:
+		if !rgxpBlankLabel.MatchString(t.id) {
+			invalid("blank node label: %q", t.id)
+		}
+	case Literal:
+		if t.DataType == rdfLangString && !rgxpLangTag.MatchString(t.lang) {
+			invalid("language tag: %q", t.lang)
+		}
+		if t.DataType != (IRI{}) {
+			checkTerm(t.DataType)
+		}
+	}
+}
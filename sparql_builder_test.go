@@ -0,0 +1,119 @@
+package rdf
+
+import (
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	foaf := IRI{str: "http://xmlns.com/foaf/0.1/"}
+	foafName := IRI{str: foaf.str + "name"}
+	foafAge := IRI{str: foaf.str + "age"}
+	nasty := Literal{str: "x\" } ; DROP ALL # \\ \n", DataType: xsdString}
+
+	tests := []struct {
+		b    *QueryBuilder
+		want string
+	}{
+		{
+			Select(Var("name")).
+				Prefix("foaf", foaf).
+				Where(NewTriplePattern(Var("p"), foafName, Var("name"))).
+				Optional(NewTriplePattern(Var("p"), foafAge, Var("age"))).
+				Filter(Or(Not(Bound(Var("age"))), Greater(Var("age"), NewTypedLiteral("26", xsdInteger)))).
+				OrderBy(Asc(Var("name"))).
+				Limit(10),
+			`PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+SELECT ?name
+WHERE {
+	?p foaf:name ?name .
+	OPTIONAL {
+		?p foaf:age ?age .
+	}
+	FILTER (!BOUND(?age) || (?age > 26))
+}
+ORDER BY ?name
+LIMIT 10
+`,
+		},
+		{
+			Select().Distinct().
+				Where(NewTriplePattern(Blank{id: "_:b"}, foafName, nasty)).
+				Where(NewTriplePattern(Blank{id: "_:b"}, foafName, Literal{str: "chat", lang: "fr", DataType: rdfLangString})).
+				Filter(And(In(Var("x"), IRI{str: "http://example.org/a"}, IRI{str: "http://example.org/b"}), Equal(Call("strlen", Var("y")), Literal{str: "3", DataType: xsdInteger}))).
+				OrderBy(Desc(Call("STR", Var("x")))).
+				Offset(5),
+			`SELECT DISTINCT *
+WHERE {
+	_:b <http://xmlns.com/foaf/0.1/name> "x\" } ; DROP ALL # \\ \n" .
+	_:b <http://xmlns.com/foaf/0.1/name> "chat"@fr .
+	FILTER ((?x IN (<http://example.org/a>, <http://example.org/b>)) && (STRLEN(?y) = 3))
+}
+ORDER BY DESC(STR(?x))
+OFFSET 5
+`,
+		},
+	}
+	for i, test := range tests {
+		got, err := test.b.Build()
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("#%d: got:\n%s\nwant:\n%s", i, got, test.want)
+			continue
+		}
+		q, err := ParseQuery(got)
+		if err != nil {
+			t.Errorf("#%d: ParseQuery: %v", i, err)
+			continue
+		}
+		if q.String() != got {
+			t.Errorf("#%d: ParseQuery(Build()).String() =>\n%s\nwant:\n%s", i, q.String(), got)
+		}
+	}
+
+	// The literal matches the data, and nothing else.
+	q, err := Select(Var("s")).Where(NewTriplePattern(Var("s"), foafName, nasty)).Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewGraph()
+	g.Add(Triple{Subj: IRI{str: "http://example.org/a"}, Pred: foafName, Obj: nasty})
+	g.Add(Triple{Subj: IRI{str: "http://example.org/b"}, Pred: foafName, Obj: Literal{str: "x", DataType: xsdString}})
+	res, err := q.EvalGraph(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := solutionsString(res, false); got != "s=<http://example.org/a>" {
+		t.Errorf("got solutions:\n%s", got)
+	}
+}
+
+func TestQueryBuilderErrors(t *testing.T) {
+	p := IRI{str: "http://example.org/p"}
+	tests := []struct {
+		b    *QueryBuilder
+		want string
+	}{
+		{Select(Var("x y")), `invalid variable name: "x y"`},
+		{Select().Where(NewTriplePattern(IRI{}, p, Var("o"))), `invalid IRI "": empty IRI`},
+		{Select().Where(NewTriplePattern(IRI{str: "http://example.org/> . ?s ?p ?o"}, p, Var("o"))), `invalid IRI "http://example.org/> . ?s ?p ?o": disallowed character: '>'`},
+		{Select().Where(NewTriplePattern(Blank{id: "_:a } #"}, p, Var("o"))), `invalid blank node label: "_:a } #"`},
+		{Select().Where(NewTriplePattern(Literal{str: "a", DataType: xsdString}, p, Var("o"))), `invalid subject: a`},
+		{Select().Where(NewTriplePattern(Var("s"), Blank{id: "_:p"}, Var("o"))), `invalid predicate: p`},
+		{Select().Where(NewTriplePattern(Var("s"), p, nil)), `invalid object: <nil>`},
+		{Select().Where(NewTriplePattern(Var("s"), p, Literal{str: "a", lang: "en } #", DataType: rdfLangString})), `invalid language tag: "en } #"`},
+		{Select().Filter(Equal(Var("s"), 42)), `invalid operand of type int`},
+		{Select().Filter(Call("EVAL", Var("s"))), `invalid function: EVAL`},
+		{Select().Filter(Call("STRLEN")), `invalid number of arguments to STRLEN: 0`},
+		{Select().Prefix("a b", p), `invalid prefix label: "a b"`},
+		{Select().Offset(-1), `invalid negative offset: -1`},
+	}
+	for _, test := range tests {
+		_, err := test.b.Build()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("got error %v, want %q", err, test.want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package rdf
+
+import (
+	stdcontext "context" // the Turtle decoder has its own context type
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SPARQLClient sends queries and updates to a remote SPARQL endpoint, as
+// specified by the SPARQL 1.1 Protocol.
+//
+// Queries are sent URL-encoded by POST, and updates as
+// application/sparql-update. A response with a status other than 2xx is
+// returned as an error, with the body of the response as message.
+type SPARQLClient struct {
+	// Endpoint is the URL of the query endpoint.
+	Endpoint string
+
+	// UpdateEndpoint is the URL of the update endpoint. If empty,
+	// Endpoint is used.
+	UpdateEndpoint string
+
+	// Client is the HTTP client used to perform requests. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Timeout limits the time of each request, including the reading of
+	// its response. Zero means no timeout, other than that of the context.
+	Timeout time.Duration
+}
+
+// NewSPARQLClient returns a new SPARQLClient, for the endpoint with the
+// given URL.
+func NewSPARQLClient(endpoint string) *SPARQLClient {
+	return &SPARQLClient{Endpoint: endpoint}
+}
+
+// Accept headers of the requests, in order of preference.
+const (
+	acceptResults = "application/sparql-results+json, application/sparql-results+xml;q=0.9, " +
+		"text/tab-separated-values;q=0.8, text/csv;q=0.5"
+	acceptBoolean = "application/sparql-results+json, application/sparql-results+xml;q=0.9"
+	acceptGraph   = "text/turtle, application/n-triples;q=0.9, application/rdf+xml;q=0.8, " +
+		"application/ld+json;q=0.7, text/plain;q=0.1"
+)
+
+// resultsFormats maps media types to formats of query results.
+var resultsFormats = map[string]ResultsFormat{
+	"application/sparql-results+json": ResultsJSON,
+	"application/json":                ResultsJSON,
+	"application/sparql-results+xml":  ResultsXML,
+	"application/xml":                 ResultsXML,
+	"text/xml":                        ResultsXML,
+	"text/csv":                        ResultsCSV,
+	"text/tab-separated-values":       ResultsTSV,
+}
+
+// graphFormats maps media types to formats of graphs.
+var graphFormats = map[string]Format{
+	"text/turtle":           Turtle,
+	"application/x-turtle":  Turtle,
+	"application/n-triples": NTriples,
+	"text/plain":            NTriples,
+	"application/rdf+xml":   RDFXML,
+	"application/ld+json":   JSONLD,
+}
+
+// RemoteResults streams the solutions of a SELECT query from a remote
+// endpoint. Close must be called when done reading them, to release the
+// connection.
+type RemoteResults struct {
+	*ResultsDecoder
+	body   io.ReadCloser
+	cancel stdcontext.CancelFunc
+}
+
+// Close closes the response of the endpoint.
+func (r *RemoteResults) Close() error {
+	defer r.cancel()
+	return r.body.Close()
+}
+
+// Select sends a SELECT query, and returns its solutions, which are decoded
+// as they are read.
+func (c *SPARQLClient) Select(ctx stdcontext.Context, query string) (*RemoteResults, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	resp, err := c.query(ctx, query, acceptResults)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	f, ok := resultsFormats[mediaType(resp)]
+	if !ok {
+		resp.Body.Close()
+		cancel()
+		return nil, unsupportedContentType(resp)
+	}
+	return &RemoteResults{
+		ResultsDecoder: NewResultsDecoder(resp.Body, f),
+		body:           resp.Body,
+		cancel:         cancel,
+	}, nil
+}
+
+// Ask sends an ASK query, and returns its result.
+func (c *SPARQLClient) Ask(ctx stdcontext.Context, query string) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.query(ctx, query, acceptBoolean)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	f, ok := resultsFormats[mediaType(resp)]
+	if !ok {
+		return false, unsupportedContentType(resp)
+	}
+	res, err := NewResultsDecoder(resp.Body, f).DecodeAll()
+	if err != nil {
+		return false, err
+	}
+	if res.Form != QueryAsk {
+		return false, fmt.Errorf("response without boolean result")
+	}
+	return res.Boolean, nil
+}
+
+// Construct sends a CONSTRUCT or DESCRIBE query, and returns the triples of
+// the resulting graph, decoded by a TripleDecoder for the content type of
+// the response.
+func (c *SPARQLClient) Construct(ctx stdcontext.Context, query string) ([]Triple, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	resp, err := c.query(ctx, query, acceptGraph)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	f, ok := graphFormats[mediaType(resp)]
+	if !ok {
+		return nil, unsupportedContentType(resp)
+	}
+	return NewTripleDecoder(resp.Body, f).DecodeAll()
+}
+
+// Update sends an update.
+func (c *SPARQLClient) Update(ctx stdcontext.Context, update string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	endpoint := c.UpdateEndpoint
+	if endpoint == "" {
+		endpoint = c.Endpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(update))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mediaTypeUpdate+"; charset=utf-8")
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// withTimeout returns the context of a request, with the timeout of the
+// client.
+func (c *SPARQLClient) withTimeout(ctx stdcontext.Context) (stdcontext.Context, stdcontext.CancelFunc) {
+	if c.Timeout > 0 {
+		return stdcontext.WithTimeout(ctx, c.Timeout)
+	}
+	return stdcontext.WithCancel(ctx)
+}
+
+// query sends a query, accepting the given media types.
+func (c *SPARQLClient) query(ctx stdcontext.Context, query, accept string) (*http.Response, error) {
+	body := url.Values{"query": {query}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, "POST", c.Endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mediaTypeForm)
+	req.Header.Set("Accept", accept)
+	return c.do(req)
+}
+
+// do performs a request, and returns its response if its status is 2xx.
+func (c *SPARQLClient) do(req *http.Request) (*http.Response, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		if s := strings.TrimSpace(string(msg)); s != "" {
+			return nil, fmt.Errorf("HTTP status %s: %s", resp.Status, s)
+		}
+		return nil, fmt.Errorf("HTTP status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// mediaType returns the media type of the response, without parameters.
+func mediaType(resp *http.Response) string {
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mt
+}
+
+func unsupportedContentType(resp *http.Response) error {
+	return fmt.Errorf("unsupported content type: %q", resp.Header.Get("Content-Type"))
+}
@@ -0,0 +1,158 @@
+package rdf
+
+import (
+	stdcontext "context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSPARQLClient(t *testing.T) {
+	srv := httptest.NewServer(newTestSPARQLHandler(t))
+	defer srv.Close()
+	c := NewSPARQLClient(srv.URL)
+	ctx := stdcontext.Background()
+
+	res, err := c.Select(ctx, `SELECT ?s ?o WHERE { ?s ?p ?o } ORDER BY ?s`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, err := res.Vars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sols []Solution
+	for {
+		sol, err := res.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		sols = append(sols, sol)
+	}
+	if err := res.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got := solutionsString(&Results{Vars: vars, Solutions: sols}, true)
+	want := "s=<http://example.org/a> o=\"a\"\ns=<http://example.org/b> o=\"b\"@en"
+	if got != want {
+		t.Errorf("Select: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	ok, err := c.Ask(ctx, `ASK { ?s ?p "a" }`)
+	if err != nil || !ok {
+		t.Errorf("Ask: got %v, %v; want true, <nil>", ok, err)
+	}
+
+	if err := c.Update(ctx, `INSERT DATA { <http://example.org/d> <http://example.org/p> <http://example.org/a> }`); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := c.Construct(ctx, `CONSTRUCT WHERE { ?s ?p <http://example.org/a> }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 1 || ts[0].Serialize(NTriples) != "<http://example.org/d> <http://example.org/p> <http://example.org/a> .\n" {
+		t.Errorf("Construct: got %v", ts)
+	}
+
+	if _, err := c.Ask(ctx, `ASK {`); err == nil || !strings.HasPrefix(err.Error(), "HTTP status 400 Bad Request: ") {
+		t.Errorf("Ask of a bad query: got error %v", err)
+	}
+}
+
+func TestSPARQLClientContentTypes(t *testing.T) {
+	var contentType, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	c := NewSPARQLClient(srv.URL)
+	ctx := stdcontext.Background()
+
+	contentType = "application/rdf+xml"
+	body = `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:ex="http://example.org/">
+	<rdf:Description rdf:about="http://example.org/a"><ex:p>a</ex:p></rdf:Description>
+</rdf:RDF>`
+	ts, err := c.Construct(ctx, "DESCRIBE <http://example.org/a>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ts) != 1 || ts[0].Serialize(NTriples) != "<http://example.org/a> <http://example.org/p> \"a\" .\n" {
+		t.Errorf("Construct from RDF/XML: got %v", ts)
+	}
+
+	contentType = "text/csv; charset=utf-8"
+	body = "x\r\nhttp://example.org/a\r\n"
+	res, err := c.Select(ctx, "SELECT ?x {}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sol, err := res.Decode()
+	res.Close()
+	if err != nil || sol["x"] != (IRI{str: "http://example.org/a"}) {
+		t.Errorf("Select from CSV: got %v, %v", sol, err)
+	}
+
+	contentType = "text/html"
+	if _, err := c.Construct(ctx, "CONSTRUCT {} WHERE {}"); err == nil || err.Error() != `unsupported content type: "text/html"` {
+		t.Errorf("Construct from HTML: got error %v", err)
+	}
+}
+
+// roundTripFunc is an adapter to allow the use of ordinary functions as
+// http.RoundTrippers.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSPARQLClientHTTPClient(t *testing.T) {
+	var got *http.Request
+	c := &SPARQLClient{
+		Endpoint:       "http://example.org/sparql",
+		UpdateEndpoint: "http://example.org/update",
+		Client: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			got = r
+			return &http.Response{StatusCode: 204, Status: "204 No Content", Body: http.NoBody}, nil
+		})},
+	}
+	if err := c.Update(stdcontext.Background(), "CLEAR ALL"); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.String() != "http://example.org/update" || got.Header.Get("Content-Type") != "application/sparql-update; charset=utf-8" {
+		t.Errorf("got request %s %s", got.URL, got.Header.Get("Content-Type"))
+	}
+}
+
+func TestSPARQLClientTimeout(t *testing.T) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(done)
+
+	c := NewSPARQLClient(srv.URL)
+	c.Timeout = 10 * time.Millisecond
+	if _, err := c.Ask(stdcontext.Background(), "ASK {}"); !errors.Is(err, stdcontext.DeadlineExceeded) {
+		t.Errorf("Ask with timeout: got error %v; want deadline exceeded", err)
+	}
+
+	c.Timeout = 0
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := c.Select(ctx, "SELECT * {}"); !errors.Is(err, stdcontext.Canceled) {
+		t.Errorf("Select with cancelled context: got error %v; want context canceled", err)
+	}
+}
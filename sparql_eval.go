@@ -0,0 +1,1004 @@
+package rdf
+
+import (
+	stdcontext "context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Solution is a solution of a SPARQL query; the terms bound to the
+// variables, keyed by variable name. Unbound variables are absent.
+type Solution map[string]Term
+
+// Results are the results of a SPARQL query.
+type Results struct {
+	Form      QueryForm
+	Vars      []string   // projected variables of a SELECT query, in order
+	Solutions []Solution // solutions of a SELECT query
+	Boolean   bool       // result of an ASK query
+	Triples   []Triple   // graph of a CONSTRUCT or DESCRIBE query
+}
+
+// EvalOptions are options of the evaluation of a query.
+type EvalOptions struct {
+	// Context is the context of the requests of SERVICE patterns. If
+	// nil, context.Background() is used.
+	Context stdcontext.Context
+
+	// ResolveService returns the client of the endpoint of a SERVICE
+	// pattern. If nil, a client with the default settings is used, with
+	// the endpoint IRI as URL.
+	ResolveService func(endpoint IRI) (*SPARQLClient, error)
+
+	// ServiceBatchSize is the maximum number of solutions sent in a
+	// single request of a SERVICE pattern. If zero, 100 is used.
+	ServiceBatchSize int
+
+	// Bindings are the values of pre-bound variables, which are substituted
+	// for the variables throughout the query, subqueries included, as in
+	// SHACL-SPARQL. The projected pre-bound variables are bound in the
+	// solutions.
+	Bindings Solution
+}
+
+// Eval evaluates the query against the dataset.
+//
+// The default graph of the query is the default graph of the dataset,
+// unless the query has FROM clauses, in which case it is the merge of the
+// given named graphs. Likewise, FROM NAMED clauses restrict the named graphs
+// of the query to those given.
+//
+// The triples of a CONSTRUCT query are the template instantiated by each
+// solution, without duplicates. Triples which would not be valid RDF, such
+// as those with a literal subject or an unbound variable, are left out. The
+// triples of a DESCRIBE query are the Concise Bounded Descriptions of the
+// resources, from the default graph.
+//
+// SERVICE patterns are evaluated as by EvalWithOptions, with the default
+// options.
+func (q *Query) Eval(d *Dataset) (*Results, error) {
+	return q.eval(newEvalDataset(d, q.From, q.FromNamed), EvalOptions{})
+}
+
+// EvalWithOptions evaluates the query against the dataset, as Eval does,
+// with the given options.
+//
+// A SERVICE pattern is evaluated by its endpoint, as a SELECT query. The
+// solutions of the patterns preceding it are sent along in batches, as
+// VALUES of the variables they bind, and the remote solutions are joined
+// with them; a bound join. Each batch is a request.
+func (q *Query) EvalWithOptions(d *Dataset, opts EvalOptions) (*Results, error) {
+	return q.eval(newEvalDataset(d, q.From, q.FromNamed), opts)
+}
+
+// newEvalDataset returns the dataset of a query against d, as given by its
+// FROM and FROM NAMED clauses (or USING and USING NAMED, in updates).
+func newEvalDataset(d *Dataset, from, fromNamed []IRI) *evalDataset {
+	ds := &evalDataset{names: d.Graphs(), graph: d.Graph}
+	switch {
+	case from != nil:
+		gs := make(mergedGraph, 0, len(from))
+		for _, iri := range from {
+			if g := d.namedGraph(iri); g != nil {
+				gs = append(gs, g)
+			}
+		}
+		ds.def = gs
+		ds.names = nil
+	default:
+		ds.def = d.Graph(nil)
+	}
+	if fromNamed != nil {
+		ds.names = nil
+		for _, iri := range fromNamed {
+			if d.namedGraph(iri) != nil {
+				ds.names = append(ds.names, iri)
+			}
+		}
+	}
+	return ds
+}
+
+// EvalGraph evaluates the query against the graph, as the default graph of
+// a dataset without named graphs. FROM and FROM NAMED clauses are ignored.
+func (q *Query) EvalGraph(g *Graph) (*Results, error) {
+	return q.eval(&evalDataset{def: g, graph: func(Context) *Graph { return nil }}, EvalOptions{})
+}
+
+// namedGraph returns the named graph of the dataset, or nil if there is no
+// such graph. Unlike Graph, it never returns the default graph.
+func (d *Dataset) namedGraph(name Context) *Graph {
+	if ng, ok := d.graphs[termKey(name)]; ok {
+		return ng.g
+	}
+	return nil
+}
+
+// tripleMatcher is implemented by Graph and UnionGraph.
+type tripleMatcher interface {
+	Match(subj Subject, pred Predicate, obj Object) []Triple
+}
+
+// mergedGraph is the merge of graphs, where a triple in more than one graph
+// is only matched once.
+type mergedGraph []*Graph
+
+func (m mergedGraph) Match(subj Subject, pred Predicate, obj Object) []Triple {
+	if len(m) == 1 {
+		return m[0].Match(subj, pred, obj)
+	}
+	var ts []Triple
+	seen := make(map[string]bool)
+	for _, g := range m {
+		for _, t := range g.Match(subj, pred, obj) {
+			k := termKey(t.Subj) + " " + termKey(t.Pred) + " " + termKey(t.Obj)
+			if !seen[k] {
+				seen[k] = true
+				ts = append(ts, t)
+			}
+		}
+	}
+	return ts
+}
+
+// evalDataset is the RDF dataset of a query.
+type evalDataset struct {
+	def   tripleMatcher        // default graph
+	names []Context            // names of the named graphs
+	graph func(Context) *Graph // named graph by name
+}
+
+// evaluator evaluates the algebra of a query.
+type evaluator struct {
+	ds     *evalDataset
+	active tripleMatcher // active graph
+	base   string        // base IRI, for the IRI function
+	now    time.Time     // value of NOW, constant during a query
+	bnodeN int           // blank node counter
+	bnodes map[string]Blank
+	opts   EvalOptions
+}
+
+// errorf formats the error and terminates evaluation.
+func (e *evaluator) errorf(format string, args ...interface{}) {
+	panic(fmt.Errorf(format, args...))
+}
+
+// recover catches non-runtime panics and binds the panic error to the given
+// error pointer.
+func (e *evaluator) recover(errp *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if _, ok := r.(runtime.Error); ok {
+		// Don't recover from runtime errors.
+		panic(r)
+	}
+	*errp = r.(error)
+}
+
+// lookup returns the term bound to the variable by the solution, or
+// pre-bound by the options.
+func (e *evaluator) lookup(sol Solution, name string) (Term, bool) {
+	if t, ok := sol[name]; ok {
+		return t, true
+	}
+	t, ok := e.opts.Bindings[name]
+	return t, ok
+}
+
+// blank returns a fresh blank node.
+func (e *evaluator) blank() Blank {
+	e.bnodeN++
+	return Blank{id: fmt.Sprintf("_:b%d", e.bnodeN)}
+}
+
+func (q *Query) eval(ds *evalDataset, opts EvalOptions) (res *Results, err error) {
+	e := &evaluator{ds: ds, active: ds.def, base: q.Base, now: time.Now(), opts: opts}
+	defer e.recover(&err)
+	sols := e.eval(q.Algebra(), nil)
+	res = &Results{Form: q.Form}
+	switch q.Form {
+	case QuerySelect:
+		for _, v := range q.projection() {
+			res.Vars = append(res.Vars, string(v))
+			if t, ok := opts.Bindings[string(v)]; ok {
+				for _, sol := range sols {
+					sol[string(v)] = t
+				}
+			}
+		}
+		res.Solutions = sols
+	case QueryAsk:
+		res.Boolean = len(sols) > 0
+	case QueryConstruct:
+		res.Triples = e.construct(q.Template, sols)
+	case QueryDescribe:
+		res.Triples = e.describe(q.Describe, sols)
+	}
+	return res, nil
+}
+
+// construct instantiates the template with each solution.
+func (e *evaluator) construct(template []TriplePattern, sols []Solution) []Triple {
+	var ts []Triple
+	seen := make(map[string]bool)
+	for _, sol := range sols {
+		bnodes := make(map[string]Blank)
+		inst := func(t PatternTerm) Term {
+			switch t := t.(type) {
+			case Var:
+				b, _ := e.lookup(sol, string(t))
+				return b
+			case Blank:
+				b, ok := bnodes[t.id]
+				if !ok {
+					b = e.blank()
+					bnodes[t.id] = b
+				}
+				return b
+			}
+			return t.(Term)
+		}
+		for _, tp := range template {
+			s, ok1 := inst(tp.Subj).(Subject)
+			p, ok2 := inst(tp.Pred).(Predicate)
+			o, ok3 := inst(tp.Obj).(Object)
+			if !ok1 || !ok2 || !ok3 {
+				continue
+			}
+			k := termKey(s) + " " + termKey(p) + " " + termKey(o)
+			if !seen[k] {
+				seen[k] = true
+				ts = append(ts, Triple{Subj: s, Pred: p, Obj: o})
+			}
+		}
+	}
+	return ts
+}
+
+// describe returns the Concise Bounded Descriptions of the resources, being
+// the IRIs of the query and the terms bound to its variables; or to all the
+// variables of the solutions for DESCRIBE *.
+func (e *evaluator) describe(terms []PatternTerm, sols []Solution) []Triple {
+	var resources []Subject
+	seen := make(map[string]bool)
+	add := func(t Term) {
+		if s, ok := t.(Subject); ok && !seen[termKey(s)] {
+			seen[termKey(s)] = true
+			resources = append(resources, s)
+		}
+	}
+	for _, t := range terms {
+		if t, ok := t.(Term); ok {
+			add(t)
+		}
+	}
+	for _, sol := range sols {
+		if terms == nil {
+			for _, k := range sortedVars(sol) {
+				add(sol[k])
+			}
+			continue
+		}
+		for _, t := range terms {
+			if v, ok := t.(Var); ok {
+				add(sol[string(v)])
+			}
+		}
+	}
+
+	var ts []Triple
+	described := make(map[string]bool)
+	for len(resources) > 0 {
+		s := resources[0]
+		resources = resources[1:]
+		if described[termKey(s)] {
+			continue
+		}
+		described[termKey(s)] = true
+		for _, t := range e.ds.def.Match(s, nil, nil) {
+			ts = append(ts, t)
+			if b, ok := t.Obj.(Blank); ok {
+				resources = append(resources, b)
+			}
+		}
+	}
+	return ts
+}
+
+// sortedVars returns the variables of the solution, sorted by name.
+func sortedVars(sol Solution) []string {
+	vars := make([]string, 0, len(sol))
+	for v := range sol {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// compatible returns true if the solutions agree on their shared variables.
+func (s Solution) compatible(o Solution) bool {
+	if len(o) < len(s) {
+		s, o = o, s
+	}
+	for v, t := range s {
+		if u, ok := o[v]; ok && termKey(t) != termKey(u) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge returns the union of two compatible solutions.
+func (s Solution) merge(o Solution) Solution {
+	m := make(Solution, len(s)+len(o))
+	for v, t := range s {
+		m[v] = t
+	}
+	for v, t := range o {
+		m[v] = t
+	}
+	return m
+}
+
+// key returns a key identifying the solution.
+func (s Solution) key() string {
+	var k strings.Builder
+	for _, v := range sortedVars(s) {
+		k.WriteString(v + "=" + termKey(s[v]) + "\x00")
+	}
+	return k.String()
+}
+
+// eval returns the solutions of the operator. The solutions are compatible
+// with the seed, which binds the variables of an enclosing EXISTS.
+func (e *evaluator) eval(op Op, seed Solution) []Solution {
+	switch op := op.(type) {
+	case *OpBGP, *OpPath:
+		return dropBlanks(e.evalBasic(op, seed), seed)
+	case *OpJoin:
+		if isBasic(op) {
+			return dropBlanks(e.evalBasic(op, seed), seed)
+		}
+		left := e.eval(op.Left, seed)
+		if path, ok := op.Right.(*OpPath); ok {
+			// Evaluate the path from the terms bound on the left.
+			var sols []Solution
+			for _, l := range left {
+				sols = append(sols, e.evalPath(path, l)...)
+			}
+			return dropBlanks(sols, seed)
+		}
+		if svc, ok := op.Right.(*OpService); ok {
+			return e.evalService(svc, left)
+		}
+		return e.join(left, e.eval(op.Right, seed))
+	case *OpLeftJoin:
+		return e.leftJoin(e.eval(op.Left, seed), e.eval(op.Right, seed), op.Expr)
+	case *OpFilter:
+		var sols []Solution
+		for _, sol := range e.eval(op.Arg, seed) {
+			if e.filter(op.Exprs, sol) {
+				sols = append(sols, sol)
+			}
+		}
+		return sols
+	case *OpUnion:
+		return append(e.eval(op.Left, seed), e.eval(op.Right, seed)...)
+	case *OpGraph:
+		return e.evalGraph(op, seed)
+	case *OpService:
+		return e.evalService(op, []Solution{seed.merge(nil)})
+	case *OpExtend:
+		sols := e.eval(op.Arg, seed)
+		for i, sol := range sols {
+			if _, ok := sol[string(op.Var)]; ok {
+				continue
+			}
+			if t, err := e.expr(op.Expr, sol); err == nil {
+				sol = sol.merge(nil)
+				sol[string(op.Var)] = t
+				sols[i] = sol
+			}
+		}
+		return sols
+	case *OpMinus:
+		return e.minus(e.eval(op.Left, seed), e.eval(op.Right, nil))
+	case *OpTable:
+		var sols []Solution
+		for _, row := range op.Rows {
+			sol := make(Solution)
+			for i, t := range row {
+				if t != nil {
+					sol[string(op.Vars[i])] = t
+				}
+			}
+			if seed.compatible(sol) {
+				sols = append(sols, sol)
+			}
+		}
+		return sols
+	case *OpGroup:
+		return e.group(op, e.eval(op.Arg, seed))
+	case *OpOrderBy:
+		sols := e.eval(op.Arg, seed)
+		e.orderBy(op.Conds, sols)
+		return sols
+	case *OpProject:
+		// The variables of a subquery are in a scope of their own.
+		var sols []Solution
+		for _, sol := range e.eval(op.Arg, nil) {
+			p := make(Solution, len(op.Vars))
+			for _, v := range op.Vars {
+				if t, ok := sol[string(v)]; ok {
+					p[string(v)] = t
+				}
+			}
+			if seed.compatible(p) {
+				sols = append(sols, p)
+			}
+		}
+		return sols
+	case *OpDistinct:
+		return distinct(e.eval(op.Arg, seed))
+	case *OpReduced:
+		return distinct(e.eval(op.Arg, seed))
+	case *OpSlice:
+		sols := e.eval(op.Arg, seed)
+		if op.Offset >= len(sols) {
+			return nil
+		}
+		sols = sols[op.Offset:]
+		if op.Limit >= 0 && op.Limit < len(sols) {
+			sols = sols[:op.Limit]
+		}
+		return sols
+	}
+	e.errorf("cannot evaluate %T", op)
+	return nil
+}
+
+// isBasic returns true if the operator is a basic graph pattern joined with
+// the property paths among it, as translated from a block of triples.
+func isBasic(op Op) bool {
+	switch op := op.(type) {
+	case *OpBGP, *OpPath:
+		return true
+	case *OpJoin:
+		return isBasic(op.Left) && isBasic(op.Right)
+	}
+	return false
+}
+
+// evalBasic returns the extensions of the solution matching a basic
+// operator. The right operand of a join is evaluated from the terms bound on
+// the left, so that the blank nodes shared by triple patterns and paths join
+// like variables.
+func (e *evaluator) evalBasic(op Op, seed Solution) []Solution {
+	switch op := op.(type) {
+	case *OpBGP:
+		return e.evalBGP(op.Triples, seed)
+	case *OpPath:
+		return e.evalPath(op, seed)
+	case *OpJoin:
+		var sols []Solution
+		for _, l := range e.evalBasic(op.Left, seed) {
+			sols = append(sols, e.evalBasic(op.Right, l)...)
+		}
+		return sols
+	}
+	e.errorf("cannot evaluate %T", op)
+	return nil
+}
+
+// dropBlanks removes the bindings of the blank nodes of a pattern from its
+// solutions, once the pattern is evaluated. The bindings of the seed are
+// kept.
+func dropBlanks(sols []Solution, seed Solution) []Solution {
+	for _, sol := range sols {
+		for v := range sol {
+			if _, ok := seed[v]; !ok && strings.HasPrefix(v, "_:") {
+				delete(sol, v)
+			}
+		}
+	}
+	return sols
+}
+
+// evalBGP matches the triple patterns against the active graph, starting
+// with the pattern with the most bound terms. Blank nodes match as
+// variables.
+func (e *evaluator) evalBGP(tps []TriplePattern, seed Solution) []Solution {
+	sols := []Solution{seed.merge(nil)}
+	bound := make(map[string]bool)
+	for _, sol := range [2]Solution{seed, e.opts.Bindings} {
+		for v := range sol {
+			bound[v] = true
+		}
+	}
+	remaining := append([]TriplePattern(nil), tps...)
+	for len(remaining) > 0 && len(sols) > 0 {
+		best, bestScore := 0, -1
+		for i, tp := range remaining {
+			score := 0
+			for _, t := range []PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+				if name, ok := patternVar(t); !ok || bound[name] {
+					score++
+				}
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		tp := remaining[best]
+		remaining = append(remaining[:best], remaining[best+1:]...)
+		var next []Solution
+		for _, sol := range sols {
+			next = append(next, e.matchPattern(tp, sol)...)
+		}
+		sols = next
+		for _, t := range []PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+			if name, ok := patternVar(t); ok {
+				bound[name] = true
+			}
+		}
+	}
+	return sols
+}
+
+// patternVar returns the name of the variable if the term of a triple
+// pattern is a variable or a blank node.
+func patternVar(t PatternTerm) (string, bool) {
+	switch t := t.(type) {
+	case Var:
+		return string(t), true
+	case Blank:
+		return t.id, true
+	}
+	return "", false
+}
+
+// matchPattern returns the extensions of the solution matching the triple
+// pattern.
+func (e *evaluator) matchPattern(tp TriplePattern, sol Solution) []Solution {
+	var terms [3]Term
+	var vars [3]string
+	for i, t := range []PatternTerm{tp.Subj, tp.Pred, tp.Obj} {
+		if name, ok := patternVar(t); ok {
+			if b, ok := e.lookup(sol, name); ok {
+				terms[i] = b
+			} else {
+				vars[i] = name
+			}
+			continue
+		}
+		terms[i] = t.(Term)
+	}
+	var subj Subject
+	var pred Predicate
+	var obj Object
+	var ok bool
+	if terms[0] != nil {
+		if subj, ok = terms[0].(Subject); !ok {
+			return nil
+		}
+	}
+	if terms[1] != nil {
+		if pred, ok = terms[1].(Predicate); !ok {
+			return nil
+		}
+	}
+	if terms[2] != nil {
+		if obj, ok = terms[2].(Object); !ok {
+			return nil
+		}
+	}
+	var sols []Solution
+outer:
+	for _, t := range e.active.Match(subj, pred, obj) {
+		ext := sol.merge(nil)
+		for i, term := range []Term{t.Subj, t.Pred, t.Obj} {
+			if vars[i] == "" {
+				continue
+			}
+			if b, ok := ext[vars[i]]; ok {
+				// The variable occurs more than once in the pattern.
+				if termKey(b) != termKey(term) {
+					continue outer
+				}
+				continue
+			}
+			ext[vars[i]] = term
+		}
+		sols = append(sols, ext)
+	}
+	return sols
+}
+
+// evalPath returns the extensions of the solution matching the property
+// path. The path is evaluated from the subject if it is bound, else from the
+// object, else from each node of the active graph.
+func (e *evaluator) evalPath(op *OpPath, seed Solution) []Solution {
+	bind := func(sol Solution, t PatternTerm) (Term, string) {
+		name, ok := patternVar(t)
+		if !ok {
+			return t.(Term), ""
+		}
+		if b, ok := e.lookup(sol, name); ok {
+			return b, ""
+		}
+		return nil, name
+	}
+	var sols []Solution
+	subj, subjVar := bind(seed, op.Subj)
+	switch obj, objVar := bind(seed, op.Obj); {
+	case subj != nil:
+		for _, end := range pathEnds(e.active, subj, op.Path) {
+			if obj != nil {
+				if termKey(end) == termKey(obj) {
+					sols = append(sols, seed.merge(nil))
+				}
+				continue
+			}
+			sol := seed.merge(nil)
+			sol[objVar] = end
+			sols = append(sols, sol)
+		}
+	case obj != nil:
+		for _, end := range pathEnds(e.active, obj, reversePath(op.Path)) {
+			sol := seed.merge(nil)
+			sol[subjVar] = end
+			sols = append(sols, sol)
+		}
+	default:
+		for _, n := range graphNodes(e.active) {
+			sol := seed.merge(nil)
+			sol[subjVar] = n
+			sols = append(sols, e.evalPath(op, sol)...)
+		}
+	}
+	return sols
+}
+
+// graphNodes returns the distinct subjects and objects of the graph.
+func graphNodes(g tripleMatcher) []Term {
+	var nodes []Term
+	for _, t := range g.Match(nil, nil, nil) {
+		nodes = append(nodes, t.Subj, t.Obj)
+	}
+	return distinctTerms(nodes)
+}
+
+// evalGraph evaluates the argument against the named graph, or against each
+// named graph in turn when the name is a variable.
+func (e *evaluator) evalGraph(op *OpGraph, seed Solution) []Solution {
+	defer func(active tripleMatcher) { e.active = active }(e.active)
+	v, isVar := op.Name.(Var)
+	if !isVar {
+		for _, name := range e.ds.names {
+			if termKey(name) == termKey(op.Name.(Term)) {
+				e.active = e.ds.graph(name)
+				return e.eval(op.Arg, seed)
+			}
+		}
+		return nil
+	}
+	var sols []Solution
+	for _, name := range e.ds.names {
+		if b, ok := e.lookup(seed, string(v)); ok && termKey(b) != termKey(name) {
+			continue
+		}
+		e.active = e.ds.graph(name)
+		for _, sol := range e.eval(op.Arg, seed) {
+			if b, ok := sol[string(v)]; ok {
+				if termKey(b) != termKey(name) {
+					continue
+				}
+			} else {
+				sol = sol.merge(nil)
+				sol[string(v)] = name
+			}
+			sols = append(sols, sol)
+		}
+	}
+	return sols
+}
+
+// join returns the merges of the compatible solutions.
+func (e *evaluator) join(left, right []Solution) []Solution {
+	var sols []Solution
+	for _, l := range left {
+		for _, r := range right {
+			if l.compatible(r) {
+				sols = append(sols, l.merge(r))
+			}
+		}
+	}
+	return sols
+}
+
+// leftJoin returns the merges of the compatible solutions for which the
+// expression is true, and the solutions of left without any such merge.
+func (e *evaluator) leftJoin(left, right []Solution, expr Expr) []Solution {
+	var sols []Solution
+	for _, l := range left {
+		matched := false
+		for _, r := range right {
+			if !l.compatible(r) {
+				continue
+			}
+			m := l.merge(r)
+			if expr != nil && !e.filter([]Expr{expr}, m) {
+				continue
+			}
+			sols = append(sols, m)
+			matched = true
+		}
+		if !matched {
+			sols = append(sols, l)
+		}
+	}
+	return sols
+}
+
+// minus returns the solutions of left, which are not compatible with any
+// solution of right sharing a variable.
+func (e *evaluator) minus(left, right []Solution) []Solution {
+	var sols []Solution
+outer:
+	for _, l := range left {
+		for _, r := range right {
+			if l.compatible(r) && sharesVar(l, r) {
+				continue outer
+			}
+		}
+		sols = append(sols, l)
+	}
+	return sols
+}
+
+// sharesVar returns true if the solutions bind a common variable.
+func sharesVar(a, b Solution) bool {
+	for v := range a {
+		if _, ok := b[v]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns true if the effective boolean value of all the expressions
+// is true for the solution.
+func (e *evaluator) filter(exprs []Expr, sol Solution) bool {
+	for _, x := range exprs {
+		t, err := e.expr(x, sol)
+		if err != nil {
+			return false
+		}
+		if b, err := ebv(t); err != nil || !b {
+			return false
+		}
+	}
+	return true
+}
+
+// distinct removes duplicate solutions, keeping the first of each.
+func distinct(sols []Solution) []Solution {
+	var res []Solution
+	seen := make(map[string]bool)
+	for _, sol := range sols {
+		k := sol.key()
+		if !seen[k] {
+			seen[k] = true
+			res = append(res, sol)
+		}
+	}
+	return res
+}
+
+// orderBy sorts the solutions by the conditions. Terms are ordered as by
+// compareOrder, with unbound values first.
+func (e *evaluator) orderBy(conds []OrderCondition, sols []Solution) {
+	keys := make([][]Term, len(sols))
+	for i, sol := range sols {
+		keys[i] = make([]Term, len(conds))
+		for j, c := range conds {
+			if t, err := e.expr(c.Expr, sol); err == nil {
+				keys[i][j] = t
+			}
+		}
+	}
+	idx := make([]int, len(sols))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		for j, c := range conds {
+			cmp := compareOrder(keys[idx[a]][j], keys[idx[b]][j])
+			if cmp == 0 {
+				continue
+			}
+			if c.Desc {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+	sorted := make([]Solution, len(sols))
+	for i, j := range idx {
+		sorted[i] = sols[j]
+	}
+	copy(sols, sorted)
+}
+
+// compareOrder compares two terms, possibly nil, for ORDER BY: unbound
+// values come first, then blank nodes, IRIs and literals. Comparable
+// literals are compared by value, and other terms by their N-Triples
+// serialization.
+func compareOrder(a, b Term) int {
+	rank := func(t Term) int {
+		if t == nil {
+			return 0
+		}
+		switch t.Type() {
+		case TermBlank:
+			return 1
+		case TermIRI:
+			return 2
+		}
+		return 3
+	}
+	if ra, rb := rank(a), rank(b); ra != rb || ra == 0 {
+		return ra - rb
+	}
+	if la, ok := a.(Literal); ok {
+		if cmp, err := compareLiterals(la, b.(Literal)); err == nil && cmp != 0 {
+			return cmp
+		}
+	}
+	return strings.Compare(termKey(a), termKey(b))
+}
+
+// group groups the solutions by the values of the keys, and computes the
+// aggregates of each group.
+func (e *evaluator) group(op *OpGroup, sols []Solution) []Solution {
+	type group struct {
+		key  Solution
+		sols []Solution
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, sol := range sols {
+		key := make(Solution)
+		var k strings.Builder
+		for i, x := range op.Keys {
+			t, err := e.expr(x, sol)
+			if err != nil {
+				k.WriteString("\x00")
+				continue
+			}
+			k.WriteString(termKey(t) + "\x00")
+			if te, ok := x.(*TermExpr); ok {
+				if v, ok := te.Term.(Var); ok {
+					key[string(v)] = t
+				}
+			} else {
+				key[fmt.Sprintf(".key%d", i)] = t
+			}
+		}
+		g, ok := byKey[k.String()]
+		if !ok {
+			g = &group{key: key}
+			byKey[k.String()] = g
+			groups = append(groups, g)
+		}
+		g.sols = append(g.sols, sol)
+	}
+	if len(op.Keys) == 0 && len(groups) == 0 {
+		// Aggregates over no solutions form a single group.
+		groups = append(groups, &group{key: make(Solution)})
+	}
+
+	res := make([]Solution, 0, len(groups))
+	for _, g := range groups {
+		sol := make(Solution)
+		for v, t := range g.key {
+			if !strings.HasPrefix(v, ".key") {
+				sol[v] = t
+			}
+		}
+		for _, a := range op.Aggregates {
+			if t, err := e.aggregate(a.Aggregate, g.sols); err == nil {
+				sol[string(a.Var)] = t
+			}
+		}
+		res = append(res, sol)
+	}
+	return res
+}
+
+// aggregate computes the aggregate over the solutions of a group.
+func (e *evaluator) aggregate(a *AggregateExpr, sols []Solution) (Term, error) {
+	if a.Arg == nil {
+		// COUNT(*)
+		if a.Distinct {
+			sols = distinct(sols)
+		}
+		return integerLiteral(int64(len(sols))), nil
+	}
+	var vals []Term
+	var firstErr error
+	seen := make(map[string]bool)
+	for _, sol := range sols {
+		t, err := e.expr(a.Arg, sol)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if a.Distinct {
+			if seen[termKey(t)] {
+				continue
+			}
+			seen[termKey(t)] = true
+		}
+		vals = append(vals, t)
+	}
+	switch a.Name {
+	case "COUNT":
+		return integerLiteral(int64(len(vals))), nil
+	case "SAMPLE":
+		if len(vals) == 0 {
+			return nil, errUnbound
+		}
+		return vals[0], nil
+	}
+	if firstErr != nil {
+		// An error in any solution is an error of the aggregate.
+		return nil, firstErr
+	}
+	switch a.Name {
+	case "SUM", "AVG":
+		var sum Term = integerLiteral(0)
+		for _, t := range vals {
+			var err error
+			if sum, err = arithmetic("+", sum, t); err != nil {
+				return nil, err
+			}
+		}
+		if a.Name == "SUM" || len(vals) == 0 {
+			return sum, nil
+		}
+		return arithmetic("/", sum, integerLiteral(int64(len(vals))))
+	case "MIN", "MAX":
+		if len(vals) == 0 {
+			return nil, errUnbound
+		}
+		m := vals[0]
+		for _, t := range vals[1:] {
+			cmp := compareOrder(t, m)
+			if (a.Name == "MIN" && cmp < 0) || (a.Name == "MAX" && cmp > 0) {
+				m = t
+			}
+		}
+		return m, nil
+	case "GROUP_CONCAT":
+		strs := make([]string, len(vals))
+		for i, t := range vals {
+			l, ok := t.(Literal)
+			if !ok || !isStringLiteral(l) {
+				return nil, errTypeMismatch
+			}
+			strs[i] = l.str
+		}
+		return stringLiteral(strings.Join(strs, a.Separator)), nil
+	}
+	return nil, fmt.Errorf("unknown aggregate: %s", a.Name)
+}
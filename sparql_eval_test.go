@@ -0,0 +1,420 @@
+package rdf
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testSPARQLData = `@prefix : <http://example.org/> .
+@prefix foaf: <http://xmlns.com/foaf/0.1/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+
+:alice a foaf:Person ; foaf:name "Alice" ; foaf:age 30 ; foaf:knows :bob, :carol ;
+	foaf:mbox <mailto:alice@example.org> .
+:bob a foaf:Person ; foaf:name "Bob"@en ; foaf:age 25 ; foaf:knows :carol .
+:carol a foaf:Person ; foaf:name "Carol" ; foaf:age "41"^^xsd:int ;
+	:address [ :city "Oslo" ; :zip "0150" ] .
+:dave a :Robot ; foaf:name "Dave" .
+`
+
+// solutionsString returns each solution on a line, with the variables in
+// the order of the projection and the terms in SPARQL syntax. The lines are
+// sorted unless ordered is true.
+func solutionsString(res *Results, ordered bool) string {
+	w := newSPARQLWriter(nil)
+	lines := make([]string, len(res.Solutions))
+	for i, sol := range res.Solutions {
+		var vals []string
+		for _, v := range res.Vars {
+			if t, ok := sol[v]; ok {
+				vals = append(vals, v+"="+w.term(t))
+			}
+		}
+		lines[i] = strings.Join(vals, " ")
+	}
+	if !ordered {
+		sort.Strings(lines)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func loadTestGraph(t *testing.T, input string) *Graph {
+	g := NewGraph()
+	if err := g.Load(NewTripleDecoder(bytes.NewBufferString(input), Turtle)); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestEvalSelect(t *testing.T) {
+	g := loadTestGraph(t, testSPARQLData)
+	prologue := "PREFIX : <http://example.org/>\nPREFIX foaf: <http://xmlns.com/foaf/0.1/>\nPREFIX xsd: <http://www.w3.org/2001/XMLSchema#>\n"
+	tests := []struct {
+		query   string
+		ordered bool
+		want    string
+	}{
+		{
+			`SELECT ?p WHERE { ?p a foaf:Person }`, false,
+			`p=<http://example.org/alice>
+p=<http://example.org/bob>
+p=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?name WHERE { ?p foaf:name ?name FILTER(isLiteral(?name) && lang(?name) = "en") }`, false,
+			`name="Bob"@en`,
+		},
+		{
+			`SELECT ?p ?age WHERE { ?p foaf:age ?age FILTER(?age > 26) } ORDER BY DESC(?age)`, true,
+			`p=<http://example.org/carol> age="41"^^<http://www.w3.org/2001/XMLSchema#int>
+p=<http://example.org/alice> age=30`,
+		},
+		{
+			`SELECT ?p ?m WHERE { ?p a foaf:Person OPTIONAL { ?p foaf:mbox ?m } } ORDER BY ?p`, true,
+			`p=<http://example.org/alice> m=<mailto:alice@example.org>
+p=<http://example.org/bob>
+p=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?p ?o WHERE { ?p a foaf:Person OPTIONAL { ?p foaf:knows ?o FILTER(?o != :carol) } }`, false,
+			`p=<http://example.org/alice> o=<http://example.org/bob>
+p=<http://example.org/bob>
+p=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?x WHERE { { ?x a :Robot } UNION { ?x foaf:mbox ?m } }`, false,
+			`x=<http://example.org/alice>
+x=<http://example.org/dave>`,
+		},
+		{
+			`SELECT ?p WHERE { ?p a foaf:Person MINUS { ?p foaf:knows :carol } }`, false,
+			`p=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?p WHERE { ?p a foaf:Person FILTER NOT EXISTS { ?p foaf:knows ?o } }`, false,
+			`p=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?p ?next WHERE { ?p foaf:age ?age BIND(?age + 1 AS ?next) } ORDER BY ?next`, true,
+			`p=<http://example.org/bob> next=26
+p=<http://example.org/alice> next=31
+p=<http://example.org/carol> next=42`,
+		},
+		{
+			`SELECT ?p ?label WHERE { ?p foaf:name ?n VALUES (?p ?label) { (:alice "A") (:dave UNDEF) (:eve "E") } }`, false,
+			`p=<http://example.org/alice> label="A"
+p=<http://example.org/dave>`,
+		},
+		{
+			`SELECT ?city WHERE { :carol :address [ :city ?city ] }`, false,
+			`city="Oslo"`,
+		},
+		{
+			`SELECT ?p ?n WHERE { ?p foaf:knows ?o { SELECT ?o (COUNT(*) AS ?n) WHERE { ?s foaf:knows ?o } GROUP BY ?o } }`, false,
+			`p=<http://example.org/alice> n=1
+p=<http://example.org/alice> n=2
+p=<http://example.org/bob> n=2`,
+		},
+		{
+			`SELECT ?type (COUNT(?p) AS ?n) (SUM(?age) AS ?sum) (AVG(?age) AS ?avg) (MIN(?age) AS ?min) (MAX(?age) AS ?max)
+			WHERE { ?p a ?type OPTIONAL { ?p foaf:age ?age } } GROUP BY ?type ORDER BY ?type`, true,
+			`type=<http://example.org/Robot> n=1
+type=<http://xmlns.com/foaf/0.1/Person> n=3 sum=96 avg=32.0 min=25 max="41"^^<http://www.w3.org/2001/XMLSchema#int>`,
+		},
+		{
+			`SELECT (GROUP_CONCAT(?n; SEPARATOR=", ") AS ?names) (COUNT(DISTINCT ?type) AS ?types)
+			WHERE { VALUES (?n ?type) { ("a" 1) ("b" 2) ("c" 1) } }`, false,
+			`names="a, b, c" types=2`,
+		},
+		{
+			`SELECT ?o (COUNT(*) AS ?n) WHERE { ?s foaf:knows ?o } GROUP BY ?o HAVING (COUNT(*) > 1)`, false,
+			`o=<http://example.org/carol> n=2`,
+		},
+		{
+			`SELECT (COUNT(*) AS ?n) (SUM(?x) AS ?s) WHERE { ?p :missing ?x }`, false,
+			`n=0 s=0`,
+		},
+		{
+			`SELECT DISTINCT ?o WHERE { ?s foaf:knows ?o } ORDER BY ?o`, true,
+			`o=<http://example.org/bob>
+o=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?n WHERE { ?p foaf:name ?n } ORDER BY ?n LIMIT 2 OFFSET 1`, true,
+			`n="Bob"@en
+n="Carol"`,
+		},
+		{
+			`SELECT ?x WHERE { ?p foaf:name ?n BIND(UCASE(SUBSTR(STR(?n), 1, 3)) AS ?x) FILTER(REGEX(?n, "^b", "i")) }`, false,
+			`x="BOB"`,
+		},
+		{
+			`SELECT ?a ?b ?c ?d ?e ?f WHERE {
+				BIND(CONCAT("a", "b") AS ?a)
+				BIND(STRLEN("héllo") AS ?b)
+				BIND(xsd:integer("12") * 2 AS ?c)
+				BIND(7 / 2 AS ?d)
+				BIND(IF(BOUND(?z), "yes", COALESCE(?z, "no")) AS ?e)
+				BIND(REPLACE("abc", "(b)", "[$1]") AS ?f)
+			}`, false,
+			`a="ab" b=5 c=24 d=3.5 e="no" f="a[b]c"`,
+		},
+		{
+			`SELECT ?x ?y WHERE { BIND(2-1 AS ?x) BIND(?x+1-3 AS ?y) }`, false,
+			`x=1 y=-1`,
+		},
+		{
+			`SELECT ?x WHERE { VALUES ?x { 1 2 3 "a" } FILTER(?x IN (1, 3, "a")) }`, false,
+			`x="a"
+x=1
+x=3`,
+		},
+		{
+			`SELECT * WHERE { :alice foaf:knows ?x . ?x foaf:age ?age }`, false,
+			`x=<http://example.org/bob> age=25
+x=<http://example.org/carol> age="41"^^<http://www.w3.org/2001/XMLSchema#int>`,
+		},
+		{
+			`SELECT ?x WHERE { :alice foaf:knows+ ?x }`, false,
+			`x=<http://example.org/bob>
+x=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?s ?o WHERE { ?s foaf:knows/foaf:knows ?o }`, false,
+			`s=<http://example.org/alice> o=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?s WHERE { ?s foaf:knows* :carol }`, false,
+			`s=<http://example.org/alice>
+s=<http://example.org/bob>
+s=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?p ?city WHERE { ?p a foaf:Person ; :address/:city ?city }`, false,
+			`p=<http://example.org/carol> city="Oslo"`,
+		},
+		{
+			`SELECT ?x ?n WHERE { :bob ^foaf:knows ?x . ?x foaf:name|foaf:mbox ?n }`, false,
+			`x=<http://example.org/alice> n="Alice"
+x=<http://example.org/alice> n=<mailto:alice@example.org>`,
+		},
+		{
+			`SELECT ?s ?o WHERE { ?s foaf:knows+ ?o }`, false,
+			`s=<http://example.org/alice> o=<http://example.org/bob>
+s=<http://example.org/alice> o=<http://example.org/carol>
+s=<http://example.org/bob> o=<http://example.org/carol>`,
+		},
+		{
+			`SELECT ?v WHERE { :dave !(a|foaf:name) ?v }`, false,
+			``,
+		},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(prologue + test.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) => %v", test.query, err)
+			continue
+		}
+		res, err := q.EvalGraph(g)
+		if err != nil {
+			t.Errorf("EvalGraph(%q) => %v", test.query, err)
+			continue
+		}
+		if got := solutionsString(res, test.ordered); got != test.want {
+			t.Errorf("EvalGraph(%q) =>\n%s\nwant:\n%s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestEvalAskConstructDescribe(t *testing.T) {
+	g := loadTestGraph(t, testSPARQLData)
+	prologue := "PREFIX : <http://example.org/>\nPREFIX foaf: <http://xmlns.com/foaf/0.1/>\n"
+
+	for query, want := range map[string]bool{
+		`ASK { :alice foaf:knows :bob }`:                     true,
+		`ASK { :bob foaf:knows :alice }`:                     false,
+		`ASK { ?p foaf:age ?a FILTER(?a > 40) }`:             true,
+		`ASK { ?p foaf:name ?n FILTER(STRSTARTS(?n, "Z")) }`: false,
+	} {
+		q, err := ParseQuery(prologue + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := q.EvalGraph(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Boolean != want {
+			t.Errorf("EvalGraph(%q) => %v, want %v", query, res.Boolean, want)
+		}
+	}
+
+	q, err := ParseQuery(prologue + `CONSTRUCT { ?o :knownBy ?s . ?s :rel [ :to ?o ] } WHERE { ?s foaf:knows ?o FILTER(?s = :alice) }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := q.EvalGraph(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<http://example.org/bob> <http://example.org/knownBy> <http://example.org/alice> .
+<http://example.org/carol> <http://example.org/knownBy> <http://example.org/alice> .
+<http://example.org/alice> <http://example.org/rel> _:a .
+_:a <http://example.org/to> <http://example.org/bob> .
+<http://example.org/alice> <http://example.org/rel> _:b .
+_:b <http://example.org/to> <http://example.org/carol> .
+`
+	wantTriples, err := NewTripleDecoder(bytes.NewBufferString(want), NTriples).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Isomorphic(res.Triples, wantTriples) {
+		t.Errorf("CONSTRUCT =>\n%s\nwant:\n%s", sortedNTriples(res.Triples), want)
+	}
+
+	var buf bytes.Buffer
+	enc := NewTripleEncoder(&buf, NTriples)
+	if err := enc.EncodeAll(res.Triples); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "\n") != 6 {
+		t.Errorf("encoding CONSTRUCT triples =>\n%s", buf.String())
+	}
+
+	q, err = ParseQuery(prologue + `DESCRIBE ?p WHERE { ?p foaf:name "Carol" }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err = q.EvalGraph(g); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Triples) != 6 {
+		t.Errorf("DESCRIBE =>\n%s\nwant 6 triples", sortedNTriples(res.Triples))
+	}
+}
+
+func TestEvalDataset(t *testing.T) {
+	input := `@prefix : <http://example.org/> .
+:a :p 1 .
+:g1 { :a :p 2 . :b :p 3 . }
+:g2 { :a :p 4 . :a :p 1 . }
+`
+	d := NewDataset()
+	if err := d.Load(NewQuadDecoder(bytes.NewBufferString(input), TriG)); err != nil {
+		t.Fatal(err)
+	}
+	prologue := "PREFIX : <http://example.org/>\n"
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT ?o { :a :p ?o }`, `o=1`},
+		{`SELECT ?g ?o { GRAPH ?g { :a :p ?o } }`, `g=<http://example.org/g1> o=2
+g=<http://example.org/g2> o=1
+g=<http://example.org/g2> o=4`},
+		{`SELECT ?o { GRAPH :g1 { ?s :p ?o } }`, `o=2
+o=3`},
+		{`SELECT ?o { GRAPH :missing { ?s :p ?o } }`, ``},
+		{`SELECT ?o FROM :g1 FROM :g2 { :a :p ?o }`, `o=1
+o=2
+o=4`},
+		{`SELECT ?g ?o FROM NAMED :g2 { GRAPH ?g { ?s :p ?o } }`, `g=<http://example.org/g2> o=1
+g=<http://example.org/g2> o=4`},
+		{`SELECT ?s { ?s :p ?o FILTER EXISTS { GRAPH ?g { ?s :p 3 } } }`, ``},
+		{`SELECT ?g { GRAPH ?g { :a :p 1 } }`, `g=<http://example.org/g2>`},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(prologue + test.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) => %v", test.query, err)
+			continue
+		}
+		res, err := q.Eval(d)
+		if err != nil {
+			t.Errorf("Eval(%q) => %v", test.query, err)
+			continue
+		}
+		if got := solutionsString(res, false); got != test.want {
+			t.Errorf("Eval(%q) =>\n%s\nwant:\n%s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestEvalExpressions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // "" for an error
+	}{
+		{`1 + 2 * 3`, `7`},
+		{`1.5 + 1`, `2.5`},
+		{`1e0 + 1`, `2.0E0`},
+		{`-(2) - -3`, `1`},
+		{`1 / 0`, ``},
+		{`1.0e0 / 0`, `"INF"^^<http://www.w3.org/2001/XMLSchema#double>`},
+		{`"a" + 1`, ``},
+		{`1 = 1.0`, `true`},
+		{`"a" = "a"@en`, `false`},
+		{`"a"^^<http://example.org/t> = "b"^^<http://example.org/t>`, ``},
+		{`"a" < "b"`, `true`},
+		{`true && ?unbound`, ``},
+		{`false && ?unbound`, `false`},
+		{`true || ?unbound`, `true`},
+		{`!"x"`, `false`},
+		{`2 NOT IN (1, ?unbound)`, ``},
+		{`1 IN (1, ?unbound)`, `true`},
+		{`STR(<http://example.org/>)`, `"http://example.org/"`},
+		{`LANG("a"@en-GB)`, `"en-GB"`},
+		{`LANGMATCHES("en-GB", "en")`, `true`},
+		{`DATATYPE(1.5)`, `<http://www.w3.org/2001/XMLSchema#decimal>`},
+		{`IRI("foo")`, `<http://example.org/base/foo>`},
+		{`ABS(-2.5)`, `2.5`},
+		{`ROUND(2.5)`, `3.0`},
+		{`CEIL(-1.5)`, `-1.0`},
+		{`CONCAT("a"@en, "b"@en)`, `"ab"@en`},
+		{`CONCAT("a"@en, "b")`, `"ab"`},
+		{`SUBSTR("foobar", 4)`, `"bar"`},
+		{`STRBEFORE("abc"@en, "b")`, `"a"@en`},
+		{`STRAFTER("abc", "x")`, `""`},
+		{`CONTAINS("abc"@en, "b"@fr)`, ``},
+		{`ENCODE_FOR_URI("Los Angeles")`, `"Los%20Angeles"`},
+		{`YEAR("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `2011`},
+		{`SECONDS("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `13.815`},
+		{`TIMEZONE("2011-01-10T14:45:13.815-05:00"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `"-PT5H"^^<http://www.w3.org/2001/XMLSchema#dayTimeDuration>`},
+		{`TZ("2011-01-10T14:45:13Z"^^<http://www.w3.org/2001/XMLSchema#dateTime>)`, `"Z"`},
+		{`MD5("abc")`, `"900150983cd24fb0d6963f7d28e17f72"`},
+		{`SHA1("abc")`, `"a9993e364706816aba3e25717850c26c9cd0d89d"`},
+		{`STRDT("1", <http://www.w3.org/2001/XMLSchema#integer>) = 1`, `true`},
+		{`STRLANG("chat", "fr")`, `"chat"@fr`},
+		{`isNumeric("1"^^<http://www.w3.org/2001/XMLSchema#integer>)`, `true`},
+		{`isNumeric("x"^^<http://www.w3.org/2001/XMLSchema#integer>)`, `false`},
+		{`<http://www.w3.org/2001/XMLSchema#boolean>("1")`, `true`},
+		{`<http://www.w3.org/2001/XMLSchema#decimal>(1)`, `1.0`},
+		{`<http://www.w3.org/2001/XMLSchema#string>(1.5)`, `"1.5"`},
+		{`<http://www.w3.org/2001/XMLSchema#integer>("1.5")`, ``},
+		{`<http://example.org/unknown>(1)`, ``},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(`BASE <http://example.org/base/> SELECT ?x { BIND(` + test.expr + ` AS ?x) }`)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) => %v", test.expr, err)
+			continue
+		}
+		res, err := q.EvalGraph(NewGraph())
+		if err != nil {
+			t.Errorf("EvalGraph(%q) => %v", test.expr, err)
+			continue
+		}
+		var got string
+		if x, ok := res.Solutions[0]["x"]; ok {
+			got = newSPARQLWriter(nil).term(x)
+		}
+		if got != test.want {
+			t.Errorf("%s => %s, want %s", test.expr, got, test.want)
+		}
+	}
+}
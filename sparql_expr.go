@@ -0,0 +1,964 @@
+package rdf
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math"
+	mathrand "math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Errors of expression evaluation. A FILTER with an error is false, and a
+// BIND with an error leaves its variable unbound.
+var (
+	errUnbound      = errors.New("unbound variable")
+	errTypeMismatch = errors.New("type mismatch")
+)
+
+// xsdNs is the namespace of the XML schema datatypes.
+const xsdNs = "http://www.w3.org/2001/XMLSchema#"
+
+var (
+	xsdDayTimeDur  = IRI{str: xsdNs + "dayTimeDuration"}
+	xsdIntegerSubs = map[string]bool{
+		"integer": true, "long": true, "int": true, "short": true, "byte": true,
+		"nonPositiveInteger": true, "negativeInteger": true,
+		"nonNegativeInteger": true, "positiveInteger": true,
+		"unsignedLong": true, "unsignedInt": true, "unsignedShort": true, "unsignedByte": true,
+	}
+)
+
+// Numeric types, in order of type promotion.
+const (
+	numInteger = iota
+	numDecimal
+	numFloat
+	numDouble
+)
+
+// numeric is the value of a numeric literal.
+type numeric struct {
+	typ int
+	i   int64 // value of an integer
+	f   float64
+}
+
+// numericValue returns the value of a numeric literal.
+func numericValue(t Term) (numeric, bool) {
+	l, ok := t.(Literal)
+	if !ok || !strings.HasPrefix(l.DataType.str, xsdNs) {
+		return numeric{}, false
+	}
+	s := strings.TrimSpace(l.str)
+	switch name := l.DataType.str[len(xsdNs):]; {
+	case xsdIntegerSubs[name]:
+		i, err := strconv.ParseInt(strings.TrimPrefix(s, "+"), 10, 64)
+		if err != nil {
+			return numeric{}, false
+		}
+		return numeric{typ: numInteger, i: i, f: float64(i)}, true
+	case name == "decimal":
+		if !rgxpDecimalValue.MatchString(s) {
+			return numeric{}, false
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return numeric{typ: numDecimal, f: f}, true
+	case name == "float", name == "double":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return numeric{}, false
+		}
+		typ := numDouble
+		if name == "float" {
+			typ = numFloat
+		}
+		return numeric{typ: typ, f: f}, true
+	}
+	return numeric{}, false
+}
+
+var rgxpDecimalValue = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+
+// literal returns the numeric as a literal in canonical form.
+func (n numeric) literal() Literal {
+	switch n.typ {
+	case numInteger:
+		return integerLiteral(n.i)
+	case numDecimal:
+		s := strconv.FormatFloat(n.f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return Literal{str: s, DataType: xsdDecimal}
+	case numFloat:
+		return Literal{str: formatDouble(n.f), DataType: xsdFloat}
+	}
+	return Literal{str: formatDouble(n.f), DataType: xsdDouble}
+}
+
+// formatDouble formats a double in the canonical form of xsd:double, such
+// as 1.5E2.
+func formatDouble(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+	s := strconv.FormatFloat(f, 'E', -1, 64)
+	i := strings.IndexByte(s, 'E')
+	mant, exp := s[:i], s[i+1:]
+	if !strings.Contains(mant, ".") {
+		mant += ".0"
+	}
+	n, _ := strconv.Atoi(exp)
+	return mant + "E" + strconv.Itoa(n)
+}
+
+func integerLiteral(i int64) Literal {
+	return Literal{str: strconv.FormatInt(i, 10), DataType: xsdInteger}
+}
+
+func stringLiteral(s string) Literal {
+	return Literal{str: s, DataType: xsdString}
+}
+
+func booleanLiteral(b bool) Literal {
+	return Literal{str: strconv.FormatBool(b), DataType: xsdBoolean}
+}
+
+// isStringLiteral returns true if the literal is a simple literal, an
+// xsd:string or a language-tagged string.
+func isStringLiteral(l Literal) bool {
+	return l.DataType == xsdString || l.DataType == rdfLangString
+}
+
+// ebv returns the effective boolean value of a term.
+func ebv(t Term) (bool, error) {
+	l, ok := t.(Literal)
+	if !ok {
+		return false, errTypeMismatch
+	}
+	switch {
+	case l.DataType == xsdBoolean:
+		return l.str == "true" || l.str == "1", nil
+	case l.DataType == xsdString:
+		return l.str != "", nil
+	}
+	if n, ok := numericValue(l); ok {
+		return n.f != 0 && !math.IsNaN(n.f), nil
+	}
+	if isNumericType(l.DataType) {
+		// An invalid lexical form
+		return false, nil
+	}
+	return false, errTypeMismatch
+}
+
+// isNumericType returns true if the datatype is numeric.
+func isNumericType(dt IRI) bool {
+	if !strings.HasPrefix(dt.str, xsdNs) {
+		return false
+	}
+	name := dt.str[len(xsdNs):]
+	return xsdIntegerSubs[name] || name == "decimal" || name == "float" || name == "double"
+}
+
+// arithmetic applies a numeric operator, promoting the operands to a
+// common type. The division of integers is a decimal.
+func arithmetic(op string, a, b Term) (Term, error) {
+	x, ok1 := numericValue(a)
+	y, ok2 := numericValue(b)
+	if !ok1 || !ok2 {
+		return nil, errTypeMismatch
+	}
+	typ := x.typ
+	if y.typ > typ {
+		typ = y.typ
+	}
+	if typ == numInteger && op != "/" {
+		switch op {
+		case "+":
+			return integerLiteral(x.i + y.i), nil
+		case "-":
+			return integerLiteral(x.i - y.i), nil
+		case "*":
+			return integerLiteral(x.i * y.i), nil
+		}
+	}
+	if typ == numInteger {
+		typ = numDecimal
+	}
+	r := numeric{typ: typ}
+	switch op {
+	case "+":
+		r.f = x.f + y.f
+	case "-":
+		r.f = x.f - y.f
+	case "*":
+		r.f = x.f * y.f
+	case "/":
+		if y.f == 0 && typ == numDecimal {
+			return nil, errors.New("division by zero")
+		}
+		r.f = x.f / y.f
+	}
+	return r.literal(), nil
+}
+
+// compareLiterals compares literals of comparable types: numerics, strings,
+// booleans and dateTimes. It returns an error for other literals.
+func compareLiterals(a, b Literal) (int, error) {
+	if x, ok := numericValue(a); ok {
+		y, ok := numericValue(b)
+		if !ok {
+			return 0, errTypeMismatch
+		}
+		if x.typ == numInteger && y.typ == numInteger {
+			return compareInts(x.i, y.i), nil
+		}
+		switch {
+		case math.IsNaN(x.f) || math.IsNaN(y.f):
+			return 0, errTypeMismatch
+		case x.f < y.f:
+			return -1, nil
+		case x.f > y.f:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	switch {
+	case a.DataType == xsdString && b.DataType == xsdString:
+		return strings.Compare(a.str, b.str), nil
+	case a.DataType == xsdBoolean && b.DataType == xsdBoolean:
+		x, y := a.str == "true" || a.str == "1", b.str == "true" || b.str == "1"
+		switch {
+		case x == y:
+			return 0, nil
+		case !x:
+			return -1, nil
+		}
+		return 1, nil
+	case a.DataType == xsdDateTime && b.DataType == xsdDateTime:
+		x, err1 := parseDateTime(a.str)
+		y, err2 := parseDateTime(b.str)
+		if err1 != nil || err2 != nil {
+			return 0, errTypeMismatch
+		}
+		switch {
+		case x.Before(y):
+			return -1, nil
+		case x.After(y):
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, errTypeMismatch
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// equal implements the = operator; value equality of comparable literals,
+// and term equality otherwise. Literals of unknown datatypes, which are not
+// the same term, are an error, as their values may still be equal.
+func equal(a, b Term) (bool, error) {
+	la, ok1 := a.(Literal)
+	lb, ok2 := b.(Literal)
+	if ok1 && ok2 {
+		if cmp, err := compareLiterals(la, lb); err == nil {
+			return cmp == 0, nil
+		}
+	}
+	if termKey(a) == termKey(b) {
+		return true, nil
+	}
+	if ok1 && ok2 && !isKnownType(la.DataType) && !isKnownType(lb.DataType) {
+		return false, errTypeMismatch
+	}
+	return false, nil
+}
+
+// isKnownType returns true if the datatype has a value space known to the
+// evaluator.
+func isKnownType(dt IRI) bool {
+	return isNumericType(dt) || dt == xsdString || dt == rdfLangString || dt == xsdBoolean || dt == xsdDateTime
+}
+
+// langMatches returns true if the language tag matches the language range,
+// by the basic filtering of RFC 4647.
+func langMatches(tag, rng string) bool {
+	if rng == "*" {
+		return tag != ""
+	}
+	t, r := strings.ToLower(tag), strings.ToLower(rng)
+	return t == r || strings.HasPrefix(t, r+"-")
+}
+
+// dateTimeLayouts are the layouts of xsd:dateTime, with and without a
+// timezone.
+var dateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+
+// parseDateTime parses an xsd:dateTime.
+func parseDateTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range dateTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// expr evaluates the expression for the solution.
+func (e *evaluator) expr(x Expr, sol Solution) (Term, error) {
+	switch x := x.(type) {
+	case *TermExpr:
+		if v, ok := x.Term.(Var); ok {
+			if t, ok := e.lookup(sol, string(v)); ok {
+				return t, nil
+			}
+			return nil, errUnbound
+		}
+		return x.Term.(Term), nil
+	case *BinaryExpr:
+		return e.binary(x, sol)
+	case *UnaryExpr:
+		t, err := e.expr(x.Arg, sol)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Op {
+		case "!":
+			b, err := ebv(t)
+			if err != nil {
+				return nil, err
+			}
+			return booleanLiteral(!b), nil
+		case "-":
+			return arithmetic("*", t, integerLiteral(-1))
+		}
+		if _, ok := numericValue(t); !ok {
+			return nil, errTypeMismatch
+		}
+		return t, nil
+	case *InExpr:
+		t, err := e.expr(x.Arg, sol)
+		if err != nil {
+			return nil, err
+		}
+		var firstErr error
+		for _, item := range x.List {
+			u, err := e.expr(item, sol)
+			if err == nil {
+				var eq bool
+				if eq, err = equal(t, u); err == nil && eq {
+					return booleanLiteral(!x.Not), nil
+				}
+			}
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return booleanLiteral(x.Not), nil
+	case *CallExpr:
+		if x.Name == "" {
+			return e.cast(x, sol)
+		}
+		return e.call(x, sol)
+	case *ExistsExpr:
+		exists := len(e.eval(translateGroup(x.Pattern), sol)) > 0
+		return booleanLiteral(exists != x.Not), nil
+	}
+	return nil, fmt.Errorf("cannot evaluate %T", x)
+}
+
+// binary evaluates a binary expression, where || and && are true or false
+// if either operand determines the result, even if the other is an error.
+func (e *evaluator) binary(x *BinaryExpr, sol Solution) (Term, error) {
+	if x.Op == "||" || x.Op == "&&" {
+		want := x.Op == "||" // value determining the result
+		var firstErr error
+		for _, arg := range []Expr{x.Left, x.Right} {
+			t, err := e.expr(arg, sol)
+			var b bool
+			if err == nil {
+				b, err = ebv(t)
+			}
+			if err != nil {
+				firstErr = err
+				continue
+			}
+			if b == want {
+				return booleanLiteral(want), nil
+			}
+		}
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return booleanLiteral(!want), nil
+	}
+	a, err := e.expr(x.Left, sol)
+	if err != nil {
+		return nil, err
+	}
+	b, err := e.expr(x.Right, sol)
+	if err != nil {
+		return nil, err
+	}
+	switch x.Op {
+	case "=", "!=":
+		eq, err := equal(a, b)
+		if err != nil {
+			return nil, err
+		}
+		return booleanLiteral(eq == (x.Op == "=")), nil
+	case "<", ">", "<=", ">=":
+		la, ok1 := a.(Literal)
+		lb, ok2 := b.(Literal)
+		if !ok1 || !ok2 {
+			return nil, errTypeMismatch
+		}
+		cmp, err := compareLiterals(la, lb)
+		if err != nil {
+			return nil, err
+		}
+		switch x.Op {
+		case "<":
+			return booleanLiteral(cmp < 0), nil
+		case ">":
+			return booleanLiteral(cmp > 0), nil
+		case "<=":
+			return booleanLiteral(cmp <= 0), nil
+		}
+		return booleanLiteral(cmp >= 0), nil
+	}
+	return arithmetic(x.Op, a, b)
+}
+
+// cast evaluates a call of an IRI function; the XSD constructor functions.
+func (e *evaluator) cast(x *CallExpr, sol Solution) (Term, error) {
+	if len(x.Args) != 1 {
+		return nil, fmt.Errorf("unknown function: %s", x.IRI.str)
+	}
+	t, err := e.expr(x.Args[0], sol)
+	if err != nil {
+		return nil, err
+	}
+	if t.Type() == TermBlank {
+		return nil, errTypeMismatch
+	}
+	var s string
+	if l, ok := t.(Literal); ok {
+		if l.DataType == rdfLangString {
+			return nil, errTypeMismatch
+		}
+		s = strings.TrimSpace(l.str)
+	} else if x.IRI != xsdString {
+		return nil, errTypeMismatch
+	} else {
+		s = t.String()
+	}
+	n, isNum := numericValue(t)
+	switch x.IRI {
+	case xsdString:
+		return stringLiteral(t.String()), nil
+	case xsdBoolean:
+		switch {
+		case isNum:
+			return booleanLiteral(n.f != 0 && !math.IsNaN(n.f)), nil
+		case s == "true" || s == "1":
+			return booleanLiteral(true), nil
+		case s == "false" || s == "0":
+			return booleanLiteral(false), nil
+		}
+	case xsdInteger:
+		if isNum {
+			if math.IsNaN(n.f) || math.IsInf(n.f, 0) {
+				return nil, errTypeMismatch
+			}
+			if n.typ == numInteger {
+				return integerLiteral(n.i), nil
+			}
+			return integerLiteral(int64(n.f)), nil
+		}
+		if t.(Literal).DataType == xsdBoolean {
+			if s == "true" || s == "1" {
+				return integerLiteral(1), nil
+			}
+			return integerLiteral(0), nil
+		}
+		if i, err := strconv.ParseInt(strings.TrimPrefix(s, "+"), 10, 64); err == nil {
+			return integerLiteral(i), nil
+		}
+	case xsdDecimal, xsdFloat, xsdDouble:
+		typ := map[IRI]int{xsdDecimal: numDecimal, xsdFloat: numFloat, xsdDouble: numDouble}[x.IRI]
+		switch {
+		case isNum:
+			if typ == numDecimal && (math.IsNaN(n.f) || math.IsInf(n.f, 0)) {
+				return nil, errTypeMismatch
+			}
+			return numeric{typ: typ, f: n.f}.literal(), nil
+		case t.(Literal).DataType == xsdBoolean:
+			f := 0.0
+			if s == "true" || s == "1" {
+				f = 1
+			}
+			return numeric{typ: typ, f: f}.literal(), nil
+		}
+		if v, ok := numericValue(Literal{str: s, DataType: x.IRI}); ok {
+			return v.literal(), nil
+		}
+	case xsdDateTime:
+		if _, err := parseDateTime(s); err == nil {
+			return Literal{str: s, DataType: xsdDateTime}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown function: %s", x.IRI.str)
+	}
+	return nil, errTypeMismatch
+}
+
+// stringArg returns the string literal argument of a string function.
+func stringArg(t Term) (Literal, error) {
+	l, ok := t.(Literal)
+	if !ok || !isStringLiteral(l) {
+		return Literal{}, errTypeMismatch
+	}
+	return l, nil
+}
+
+// compatibleArgs returns true if the string literals are compatible
+// arguments of functions like CONTAINS: both simple literals or xsd:strings,
+// both with the same language tag, or the first with a language tag.
+func compatibleArgs(a, b Literal) bool {
+	return b.DataType == xsdString || (a.lang == b.lang)
+}
+
+// withLang returns a string literal with the language tag of l.
+func withLang(s string, l Literal) Literal {
+	if l.DataType == rdfLangString {
+		return Literal{str: s, lang: l.lang, DataType: rdfLangString}
+	}
+	return stringLiteral(s)
+}
+
+// regexpFlags compiles a regular expression with XPath flags.
+func regexpFlags(pattern, flags string) (*regexp.Regexp, error) {
+	var goFlags string
+	for _, f := range flags {
+		switch f {
+		case 'i', 's', 'm':
+			goFlags += string(f)
+		case 'x':
+			pattern = strings.Map(func(r rune) rune {
+				if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+					return -1
+				}
+				return r
+			}, pattern)
+		case 'q':
+			pattern = regexp.QuoteMeta(pattern)
+		default:
+			return nil, fmt.Errorf("invalid regular expression flag: %q", f)
+		}
+	}
+	if goFlags != "" {
+		pattern = "(?" + goFlags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// rgxpGroupRef matches the group references of a REPLACE replacement.
+var rgxpGroupRef = regexp.MustCompile(`\$([0-9]+)`)
+
+// rgxpTimezone matches the timezone of an xsd:dateTime.
+var rgxpTimezone = regexp.MustCompile(`(Z|[+-][0-9]{2}:[0-9]{2})$`)
+
+// call evaluates a call of a builtin function.
+func (e *evaluator) call(x *CallExpr, sol Solution) (Term, error) {
+	// Functions evaluating their arguments lazily:
+	switch x.Name {
+	case "BOUND":
+		_, ok := e.lookup(sol, string(x.Args[0].(*TermExpr).Term.(Var)))
+		return booleanLiteral(ok), nil
+	case "IF":
+		t, err := e.expr(x.Args[0], sol)
+		if err != nil {
+			return nil, err
+		}
+		b, err := ebv(t)
+		if err != nil {
+			return nil, err
+		}
+		if b {
+			return e.expr(x.Args[1], sol)
+		}
+		return e.expr(x.Args[2], sol)
+	case "COALESCE":
+		for _, arg := range x.Args {
+			if t, err := e.expr(arg, sol); err == nil {
+				return t, nil
+			}
+		}
+		return nil, errUnbound
+	}
+
+	args := make([]Term, len(x.Args))
+	for i, arg := range x.Args {
+		t, err := e.expr(arg, sol)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = t
+	}
+
+	switch x.Name {
+	case "STR":
+		if args[0].Type() == TermBlank {
+			return nil, errTypeMismatch
+		}
+		return stringLiteral(args[0].String()), nil
+	case "LANG":
+		l, ok := args[0].(Literal)
+		if !ok {
+			return nil, errTypeMismatch
+		}
+		return stringLiteral(l.lang), nil
+	case "LANGMATCHES":
+		tag, err1 := stringArg(args[0])
+		rng, err2 := stringArg(args[1])
+		if err1 != nil || err2 != nil {
+			return nil, errTypeMismatch
+		}
+		return booleanLiteral(langMatches(tag.str, rng.str)), nil
+	case "DATATYPE":
+		l, ok := args[0].(Literal)
+		if !ok {
+			return nil, errTypeMismatch
+		}
+		return l.DataType, nil
+	case "IRI", "URI":
+		switch t := args[0].(type) {
+		case IRI:
+			return t, nil
+		case Literal:
+			if t.DataType == xsdString {
+				return IRI{str: resolveIRI(e.base, t.str)}, nil
+			}
+		}
+		return nil, errTypeMismatch
+	case "BNODE":
+		if len(args) == 0 {
+			return e.blank(), nil
+		}
+		l, ok := args[0].(Literal)
+		if !ok || l.DataType != xsdString {
+			return nil, errTypeMismatch
+		}
+		if e.bnodes == nil {
+			e.bnodes = make(map[string]Blank)
+		}
+		k := sol.key() + "\x00" + l.str
+		b, ok := e.bnodes[k]
+		if !ok {
+			b = e.blank()
+			e.bnodes[k] = b
+		}
+		return b, nil
+	case "RAND":
+		return Literal{str: formatDouble(mathrand.Float64()), DataType: xsdDouble}, nil
+	case "ABS", "CEIL", "FLOOR", "ROUND":
+		n, ok := numericValue(args[0])
+		if !ok {
+			return nil, errTypeMismatch
+		}
+		if n.typ == numInteger {
+			if x.Name == "ABS" && n.i < 0 {
+				n.i = -n.i
+			}
+			return integerLiteral(n.i), nil
+		}
+		switch x.Name {
+		case "ABS":
+			n.f = math.Abs(n.f)
+		case "CEIL":
+			n.f = math.Ceil(n.f)
+		case "FLOOR":
+			n.f = math.Floor(n.f)
+		case "ROUND":
+			n.f = math.Floor(n.f + 0.5)
+		}
+		return n.literal(), nil
+	case "CONCAT":
+		var b strings.Builder
+		lang, sameLang := "", true
+		for i, arg := range args {
+			l, err := stringArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				lang = l.lang
+			} else if l.lang != lang {
+				sameLang = false
+			}
+			b.WriteString(l.str)
+		}
+		if sameLang && lang != "" {
+			return Literal{str: b.String(), lang: lang, DataType: rdfLangString}, nil
+		}
+		return stringLiteral(b.String()), nil
+	case "SUBSTR":
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		start, ok := numericValue(args[1])
+		if !ok {
+			return nil, errTypeMismatch
+		}
+		runes := []rune(l.str)
+		from := int(math.Floor(start.f + 0.5))
+		to := len(runes) + 1
+		if len(args) == 3 {
+			length, ok := numericValue(args[2])
+			if !ok {
+				return nil, errTypeMismatch
+			}
+			to = from + int(math.Floor(length.f+0.5))
+		}
+		if from < 1 {
+			from = 1
+		}
+		if to > len(runes)+1 {
+			to = len(runes) + 1
+		}
+		if from >= to {
+			return withLang("", l), nil
+		}
+		return withLang(string(runes[from-1:to-1]), l), nil
+	case "STRLEN":
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return integerLiteral(int64(utf8.RuneCountInString(l.str))), nil
+	case "UCASE", "LCASE":
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if x.Name == "UCASE" {
+			return withLang(strings.ToUpper(l.str), l), nil
+		}
+		return withLang(strings.ToLower(l.str), l), nil
+	case "ENCODE_FOR_URI":
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for _, c := range []byte(l.str) {
+			if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+				c == '-' || c == '_' || c == '.' || c == '~' {
+				b.WriteByte(c)
+				continue
+			}
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+		return stringLiteral(b.String()), nil
+	case "CONTAINS", "STRSTARTS", "STRENDS", "STRBEFORE", "STRAFTER":
+		a, err1 := stringArg(args[0])
+		b, err2 := stringArg(args[1])
+		if err1 != nil || err2 != nil || !compatibleArgs(a, b) {
+			return nil, errTypeMismatch
+		}
+		switch x.Name {
+		case "CONTAINS":
+			return booleanLiteral(strings.Contains(a.str, b.str)), nil
+		case "STRSTARTS":
+			return booleanLiteral(strings.HasPrefix(a.str, b.str)), nil
+		case "STRENDS":
+			return booleanLiteral(strings.HasSuffix(a.str, b.str)), nil
+		}
+		i := strings.Index(a.str, b.str)
+		switch {
+		case i < 0:
+			return stringLiteral(""), nil
+		case x.Name == "STRBEFORE":
+			return withLang(a.str[:i], a), nil
+		}
+		return withLang(a.str[i+len(b.str):], a), nil
+	case "REGEX", "REPLACE":
+		l, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		pattern, ok := args[1].(Literal)
+		if !ok || pattern.DataType != xsdString {
+			return nil, errTypeMismatch
+		}
+		var flags Literal
+		if n := len(args); (x.Name == "REGEX" && n == 3) || n == 4 {
+			if flags, ok = args[n-1].(Literal); !ok || flags.DataType != xsdString {
+				return nil, errTypeMismatch
+			}
+		}
+		re, err := regexpFlags(pattern.str, flags.str)
+		if err != nil {
+			return nil, err
+		}
+		if x.Name == "REGEX" {
+			return booleanLiteral(re.MatchString(l.str)), nil
+		}
+		repl, ok := args[2].(Literal)
+		if !ok || repl.DataType != xsdString {
+			return nil, errTypeMismatch
+		}
+		r := rgxpGroupRef.ReplaceAllString(repl.str, "$${$1}")
+		return withLang(re.ReplaceAllString(l.str, r), l), nil
+	case "YEAR", "MONTH", "DAY", "HOURS", "MINUTES", "SECONDS", "TIMEZONE", "TZ":
+		l, ok := args[0].(Literal)
+		if !ok || l.DataType != xsdDateTime {
+			return nil, errTypeMismatch
+		}
+		t, err := parseDateTime(l.str)
+		if err != nil {
+			return nil, errTypeMismatch
+		}
+		tz := rgxpTimezone.FindString(l.str)
+		switch x.Name {
+		case "YEAR":
+			return integerLiteral(int64(t.Year())), nil
+		case "MONTH":
+			return integerLiteral(int64(t.Month())), nil
+		case "DAY":
+			return integerLiteral(int64(t.Day())), nil
+		case "HOURS":
+			return integerLiteral(int64(t.Hour())), nil
+		case "MINUTES":
+			return integerLiteral(int64(t.Minute())), nil
+		case "SECONDS":
+			secs := float64(t.Second()) + float64(t.Nanosecond())/1e9
+			return numeric{typ: numDecimal, f: secs}.literal(), nil
+		case "TZ":
+			return stringLiteral(tz), nil
+		}
+		if tz == "" {
+			return nil, errTypeMismatch
+		}
+		return Literal{str: dayTimeDuration(tz), DataType: xsdDayTimeDur}, nil
+	case "NOW":
+		return Literal{str: e.now.Format(time.RFC3339Nano), DataType: xsdDateTime}, nil
+	case "UUID", "STRUUID":
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		u := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		if x.Name == "UUID" {
+			return IRI{str: "urn:uuid:" + u}, nil
+		}
+		return stringLiteral(u), nil
+	case "MD5", "SHA1", "SHA256", "SHA384", "SHA512":
+		l, ok := args[0].(Literal)
+		if !ok || l.DataType != xsdString {
+			return nil, errTypeMismatch
+		}
+		var sum []byte
+		switch x.Name {
+		case "MD5":
+			s := md5.Sum([]byte(l.str))
+			sum = s[:]
+		case "SHA1":
+			s := sha1.Sum([]byte(l.str))
+			sum = s[:]
+		case "SHA256":
+			s := sha256.Sum256([]byte(l.str))
+			sum = s[:]
+		case "SHA384":
+			s := sha512.Sum384([]byte(l.str))
+			sum = s[:]
+		case "SHA512":
+			s := sha512.Sum512([]byte(l.str))
+			sum = s[:]
+		}
+		return stringLiteral(fmt.Sprintf("%x", sum)), nil
+	case "STRLANG":
+		l, ok1 := args[0].(Literal)
+		lang, ok2 := args[1].(Literal)
+		if !ok1 || !ok2 || l.DataType != xsdString || lang.DataType != xsdString || lang.str == "" {
+			return nil, errTypeMismatch
+		}
+		return Literal{str: l.str, lang: lang.str, DataType: rdfLangString}, nil
+	case "STRDT":
+		l, ok1 := args[0].(Literal)
+		dt, ok2 := args[1].(IRI)
+		if !ok1 || !ok2 || l.DataType != xsdString {
+			return nil, errTypeMismatch
+		}
+		return Literal{str: l.str, DataType: dt}, nil
+	case "SAMETERM":
+		return booleanLiteral(termKey(args[0]) == termKey(args[1])), nil
+	case "ISIRI", "ISURI":
+		return booleanLiteral(args[0].Type() == TermIRI), nil
+	case "ISBLANK":
+		return booleanLiteral(args[0].Type() == TermBlank), nil
+	case "ISLITERAL":
+		return booleanLiteral(args[0].Type() == TermLiteral), nil
+	case "ISNUMERIC":
+		_, ok := numericValue(args[0])
+		return booleanLiteral(ok), nil
+	}
+	return nil, fmt.Errorf("unknown function: %s", x.Name)
+}
+
+// dayTimeDuration returns the timezone of a dateTime as an
+// xsd:dayTimeDuration.
+func dayTimeDuration(tz string) string {
+	if tz == "Z" {
+		return "PT0S"
+	}
+	sign := ""
+	if tz[0] == '-' {
+		sign = "-"
+	}
+	h, _ := strconv.Atoi(tz[1:3])
+	m, _ := strconv.Atoi(tz[4:6])
+	d := sign + "PT"
+	if h == 0 && m == 0 {
+		return "PT0S"
+	}
+	if h > 0 {
+		d += strconv.Itoa(h) + "H"
+	}
+	if m > 0 {
+		d += strconv.Itoa(m) + "M"
+	}
+	return d
+}
@@ -0,0 +1,1119 @@
+package rdf
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ParseQuery parses a SPARQL 1.1 query.
+func ParseQuery(query string) (q *Query, err error) {
+	p := newSPARQLParser(query)
+	defer p.recover(&err)
+	q = p.parseQuery()
+	return q, nil
+}
+
+// sparqlBuiltins maps the names of the builtin functions to their minimum
+// and maximum number of arguments, where -1 is no maximum.
+var sparqlBuiltins = map[string][2]int{
+	"STR": {1, 1}, "LANG": {1, 1}, "LANGMATCHES": {2, 2}, "DATATYPE": {1, 1},
+	"BOUND": {1, 1}, "IRI": {1, 1}, "URI": {1, 1}, "BNODE": {0, 1},
+	"RAND": {0, 0}, "ABS": {1, 1}, "CEIL": {1, 1}, "FLOOR": {1, 1}, "ROUND": {1, 1},
+	"CONCAT": {0, -1}, "SUBSTR": {2, 3}, "STRLEN": {1, 1}, "REPLACE": {3, 4},
+	"UCASE": {1, 1}, "LCASE": {1, 1}, "ENCODE_FOR_URI": {1, 1},
+	"CONTAINS": {2, 2}, "STRSTARTS": {2, 2}, "STRENDS": {2, 2},
+	"STRBEFORE": {2, 2}, "STRAFTER": {2, 2},
+	"YEAR": {1, 1}, "MONTH": {1, 1}, "DAY": {1, 1}, "HOURS": {1, 1},
+	"MINUTES": {1, 1}, "SECONDS": {1, 1}, "TIMEZONE": {1, 1}, "TZ": {1, 1},
+	"NOW": {0, 0}, "UUID": {0, 0}, "STRUUID": {0, 0},
+	"MD5": {1, 1}, "SHA1": {1, 1}, "SHA256": {1, 1}, "SHA384": {1, 1}, "SHA512": {1, 1},
+	"COALESCE": {0, -1}, "IF": {3, 3}, "STRLANG": {2, 2}, "STRDT": {2, 2},
+	"SAMETERM": {2, 2}, "ISIRI": {1, 1}, "ISURI": {1, 1}, "ISBLANK": {1, 1},
+	"ISLITERAL": {1, 1}, "ISNUMERIC": {1, 1}, "REGEX": {2, 3},
+}
+
+// sparqlAggregates is the set of aggregate names.
+var sparqlAggregates = map[string]bool{
+	"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true,
+	"SAMPLE": true, "GROUP_CONCAT": true,
+}
+
+// sparqlParser is a recursive descent parser for SPARQL.
+type sparqlParser struct {
+	l         *lexer
+	tokens    [3]token          // 3 token lookahead
+	peekCount int               // number of tokens peeked at
+	base      string            // base IRI
+	ns        map[string]string // map[prefix]namespace
+	bnodeN    int               // anonymous blank node counter
+}
+
+func newSPARQLParser(s string) *sparqlParser {
+	return &sparqlParser{
+		l:  newSPARQLLexer(strings.NewReader(s)),
+		ns: make(map[string]string),
+	}
+}
+
+// next returns the next token.
+func (p *sparqlParser) next() token {
+	if p.peekCount > 0 {
+		p.peekCount--
+	} else {
+		p.tokens[0] = p.l.nextToken()
+	}
+	return p.tokens[p.peekCount]
+}
+
+// peek returns but does not consume the next token.
+func (p *sparqlParser) peek() token {
+	if p.peekCount > 0 {
+		return p.tokens[p.peekCount-1]
+	}
+	p.peekCount = 1
+	p.tokens[0] = p.l.nextToken()
+	return p.tokens[0]
+}
+
+// backup backs the input stream up one token.
+func (p *sparqlParser) backup() {
+	p.peekCount++
+}
+
+// errorf formats the error and terminates parsing.
+func (p *sparqlParser) errorf(format string, args ...interface{}) {
+	panic(fmt.Errorf(format, args...))
+}
+
+// unexpected complains about the given token and terminates parsing.
+func (p *sparqlParser) unexpected(t token, context string) {
+	switch t.typ {
+	case tokenError:
+		p.errorf("%d:%d: syntax error: %s", t.line, t.col, t.text)
+	case tokenEOF:
+		p.errorf("%d:%d: unexpected end of input, expected %s", t.line, t.col, context)
+	}
+	p.errorf("%d:%d: unexpected %q, expected %s", t.line, t.col, t.text, context)
+}
+
+// recover catches non-runtime panics and binds the panic error to the given
+// error pointer. The remaining tokens are drained, to stop the lexer.
+func (p *sparqlParser) recover(errp *error) {
+	e := recover()
+	if e == nil {
+		return
+	}
+	if _, ok := e.(runtime.Error); ok {
+		// Don't recover from runtime errors.
+		panic(e)
+	}
+	for p.l.nextToken().typ != tokenEOF {
+	}
+	*errp = e.(error)
+}
+
+// isKeyword returns true if the token is the given keyword, regardless of
+// case.
+func isKeyword(t token, kw string) bool {
+	return t.typ == tokenKeyword && strings.EqualFold(t.text, kw)
+}
+
+// isSymbol returns true if the token is the given symbol.
+func isSymbol(t token, s string) bool {
+	return t.typ == tokenSymbol && t.text == s
+}
+
+// acceptKeyword consumes the next token if it is the given keyword.
+func (p *sparqlParser) acceptKeyword(kw string) bool {
+	if isKeyword(p.peek(), kw) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// acceptSymbol consumes the next token if it is the given symbol.
+func (p *sparqlParser) acceptSymbol(s string) bool {
+	if isSymbol(p.peek(), s) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expectKeyword consumes the next token, which must be the given keyword.
+func (p *sparqlParser) expectKeyword(kw string) {
+	if t := p.next(); !isKeyword(t, kw) {
+		p.unexpected(t, kw)
+	}
+}
+
+// expectSymbol consumes the next token, which must be the given symbol.
+func (p *sparqlParser) expectSymbol(s string) {
+	if t := p.next(); !isSymbol(t, s) {
+		p.unexpected(t, "'"+s+"'")
+	}
+}
+
+// expectVar consumes the next token, which must be a variable.
+func (p *sparqlParser) expectVar() Var {
+	t := p.next()
+	if t.typ != tokenVariable {
+		p.unexpected(t, "variable")
+	}
+	return Var(t.text)
+}
+
+// blank returns a new anonymous blank node.
+func (p *sparqlParser) blank() Blank {
+	p.bnodeN++
+	return Blank{id: fmt.Sprintf("_:_b%d", p.bnodeN)}
+}
+
+// parseQuery parses a query, until the end of input.
+func (p *sparqlParser) parseQuery() *Query {
+	p.parsePrologue()
+	q := &Query{Limit: -1}
+	t := p.next()
+	switch {
+	case isKeyword(t, "SELECT"):
+		q.Form = QuerySelect
+		p.parseSelectClause(q)
+		p.parseDatasetClauses(q)
+		q.Where = p.parseWhereClause()
+	case isKeyword(t, "CONSTRUCT"):
+		q.Form = QueryConstruct
+		if isSymbol(p.peek(), "{") {
+			q.Template = p.parseTemplate()
+			p.parseDatasetClauses(q)
+			q.Where = p.parseWhereClause()
+			break
+		}
+		// The short form; the template is the WHERE pattern.
+		p.parseDatasetClauses(q)
+		p.expectKeyword("WHERE")
+		q.Template = p.parseTemplate()
+		q.Where = &GroupPattern{}
+		if len(q.Template) > 0 {
+			q.Where.Patterns = []Pattern{&BasicPattern{Triples: q.Template}}
+		}
+	case isKeyword(t, "ASK"):
+		q.Form = QueryAsk
+		p.parseDatasetClauses(q)
+		q.Where = p.parseWhereClause()
+	case isKeyword(t, "DESCRIBE"):
+		q.Form = QueryDescribe
+		if !p.acceptSymbol("*") {
+			for {
+				t := p.peek()
+				if t.typ != tokenVariable && t.typ != tokenIRIAbs && t.typ != tokenIRIRel && t.typ != tokenPrefixLabel {
+					break
+				}
+				q.Describe = append(q.Describe, p.parseVarOrIRI())
+			}
+			if q.Describe == nil {
+				p.unexpected(p.peek(), "variable or IRI to describe")
+			}
+		}
+		p.parseDatasetClauses(q)
+		if isKeyword(p.peek(), "WHERE") || isSymbol(p.peek(), "{") {
+			q.Where = p.parseWhereClause()
+		}
+	default:
+		p.unexpected(t, "SELECT, CONSTRUCT, ASK or DESCRIBE")
+	}
+	p.parseSolutionModifier(q)
+	if p.acceptKeyword("VALUES") {
+		q.Values = p.parseDataBlock()
+	}
+	if t := p.next(); t.typ != tokenEOF {
+		p.unexpected(t, "end of query")
+	}
+	q.Base = p.base
+	if len(p.ns) > 0 {
+		q.Prefixes = p.ns
+	}
+	return q
+}
+
+// parsePrologue parses the BASE and PREFIX declarations.
+func (p *sparqlParser) parsePrologue() {
+	for {
+		switch {
+		case p.acceptKeyword("BASE"):
+			p.parseBaseDecl()
+		case p.acceptKeyword("PREFIX"):
+			p.parsePrefixDecl()
+		default:
+			return
+		}
+	}
+}
+
+// parseBaseDecl parses the IRI of a base declaration.
+func (p *sparqlParser) parseBaseDecl() {
+	t := p.next()
+	switch t.typ {
+	case tokenIRIAbs:
+		p.base = t.text
+	case tokenIRIRel:
+		p.base = resolveIRI(p.base, t.text)
+	default:
+		p.unexpected(t, "base IRI")
+	}
+}
+
+// parsePrefixDecl parses the label and IRI of a prefix declaration.
+func (p *sparqlParser) parsePrefixDecl() {
+	t := p.next()
+	if t.typ != tokenPrefixLabel {
+		p.unexpected(t, "prefix label")
+	}
+	if suf := p.next(); suf.typ != tokenIRISuffix || suf.text != "" {
+		p.unexpected(suf, "prefix IRI")
+	}
+	p.ns[prefixLabel(t)] = p.parseIRI().str
+}
+
+// prefixLabel returns the label of a prefix label token, where the empty
+// prefix is lexed as ':'.
+func prefixLabel(t token) string {
+	if t.text == ":" {
+		return ""
+	}
+	return t.text
+}
+
+// parseSelectClause parses the modifiers and projection of a SELECT clause.
+func (p *sparqlParser) parseSelectClause(q *Query) {
+	if p.acceptKeyword("DISTINCT") {
+		q.Distinct = true
+	} else if p.acceptKeyword("REDUCED") {
+		q.Reduced = true
+	}
+	if p.acceptSymbol("*") {
+		return
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.typ == tokenVariable:
+			p.next()
+			q.Select = append(q.Select, SelectItem{Var: Var(t.text)})
+			continue
+		case isSymbol(t, "("):
+			p.next()
+			e := p.parseExpression()
+			p.expectKeyword("AS")
+			v := p.expectVar()
+			p.expectSymbol(")")
+			q.Select = append(q.Select, SelectItem{Var: v, Expr: e})
+			continue
+		}
+		break
+	}
+	if q.Select == nil {
+		p.unexpected(p.peek(), "projection")
+	}
+}
+
+// parseDatasetClauses parses the FROM and FROM NAMED clauses.
+func (p *sparqlParser) parseDatasetClauses(q *Query) {
+	for p.acceptKeyword("FROM") {
+		if p.acceptKeyword("NAMED") {
+			q.FromNamed = append(q.FromNamed, p.parseIRI())
+		} else {
+			q.From = append(q.From, p.parseIRI())
+		}
+	}
+}
+
+// parseWhereClause parses a WHERE clause, where the keyword is optional.
+func (p *sparqlParser) parseWhereClause() *GroupPattern {
+	p.acceptKeyword("WHERE")
+	return p.parseGroupGraphPattern()
+}
+
+// parseSolutionModifier parses the GROUP BY, HAVING, ORDER BY, LIMIT and
+// OFFSET clauses.
+func (p *sparqlParser) parseSolutionModifier(q *Query) {
+	if p.acceptKeyword("GROUP") {
+		p.expectKeyword("BY")
+		for {
+			var c GroupCondition
+			t := p.peek()
+			switch {
+			case t.typ == tokenVariable:
+				p.next()
+				c.Expr = &TermExpr{Term: Var(t.text)}
+			case isSymbol(t, "("):
+				p.next()
+				c.Expr = p.parseExpression()
+				if p.acceptKeyword("AS") {
+					c.Var = p.expectVar()
+				}
+				p.expectSymbol(")")
+			case p.startsCall(t):
+				c.Expr = p.parsePrimary()
+			}
+			if c.Expr == nil {
+				break
+			}
+			q.GroupBy = append(q.GroupBy, c)
+		}
+		if q.GroupBy == nil {
+			p.unexpected(p.peek(), "GROUP BY condition")
+		}
+	}
+	if p.acceptKeyword("HAVING") {
+		for p.startsConstraint(p.peek()) {
+			q.Having = append(q.Having, p.parseConstraint())
+		}
+		if q.Having == nil {
+			p.unexpected(p.peek(), "HAVING condition")
+		}
+	}
+	if p.acceptKeyword("ORDER") {
+		p.expectKeyword("BY")
+		for {
+			t := p.peek()
+			var c OrderCondition
+			switch {
+			case isKeyword(t, "ASC"), isKeyword(t, "DESC"):
+				p.next()
+				c.Desc = isKeyword(t, "DESC")
+				p.expectSymbol("(")
+				c.Expr = p.parseExpression()
+				p.expectSymbol(")")
+			case t.typ == tokenVariable:
+				p.next()
+				c.Expr = &TermExpr{Term: Var(t.text)}
+			case p.startsConstraint(t):
+				c.Expr = p.parseConstraint()
+			}
+			if c.Expr == nil {
+				break
+			}
+			q.OrderBy = append(q.OrderBy, c)
+		}
+		if q.OrderBy == nil {
+			p.unexpected(p.peek(), "ORDER BY condition")
+		}
+	}
+	for i := 0; i < 2; i++ {
+		switch {
+		case p.acceptKeyword("LIMIT"):
+			if q.Limit >= 0 {
+				p.errorf("duplicate LIMIT clause")
+			}
+			q.Limit = p.parseInteger()
+		case p.acceptKeyword("OFFSET"):
+			if q.Offset > 0 {
+				p.errorf("duplicate OFFSET clause")
+			}
+			q.Offset = p.parseInteger()
+		}
+	}
+}
+
+// parseInteger parses a non-negative integer.
+func (p *sparqlParser) parseInteger() int {
+	t := p.next()
+	if t.typ != tokenLiteralInteger {
+		p.unexpected(t, "integer")
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < 0 {
+		p.errorf("%d:%d: invalid integer: %s", t.line, t.col, t.text)
+	}
+	return n
+}
+
+// startsCall returns true if the token starts a builtin call, or a call of
+// an IRI function.
+func (p *sparqlParser) startsCall(t token) bool {
+	switch t.typ {
+	case tokenKeyword:
+		name := strings.ToUpper(t.text)
+		_, builtin := sparqlBuiltins[name]
+		return builtin || sparqlAggregates[name] || name == "EXISTS" || name == "NOT"
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return true
+	}
+	return false
+}
+
+// startsConstraint returns true if the token starts a constraint.
+func (p *sparqlParser) startsConstraint(t token) bool {
+	return isSymbol(t, "(") || p.startsCall(t)
+}
+
+// parseConstraint parses a bracketed expression or a function call.
+func (p *sparqlParser) parseConstraint() Expr {
+	t := p.peek()
+	if isSymbol(t, "(") {
+		p.next()
+		e := p.parseExpression()
+		p.expectSymbol(")")
+		return e
+	}
+	if !p.startsCall(t) {
+		p.unexpected(t, "constraint")
+	}
+	e := p.parsePrimary()
+	if _, ok := e.(*TermExpr); ok {
+		p.unexpected(t, "function call")
+	}
+	return e
+}
+
+// parseTemplate parses a CONSTRUCT template.
+func (p *sparqlParser) parseTemplate() []TriplePattern {
+	p.expectSymbol("{")
+	var ts []TriplePattern
+	for !p.acceptSymbol("}") {
+		p.parseTemplateTriples(&ts)
+		if !p.acceptSymbol(".") {
+			p.expectSymbol("}")
+			break
+		}
+	}
+	return ts
+}
+
+// parseTemplateTriples parses triples with the same subject, adding them
+// to ts. Unlike in graph patterns, property paths are not allowed.
+func (p *sparqlParser) parseTemplateTriples(ts *[]TriplePattern) {
+	t := p.peek()
+	n := len(*ts)
+	p.parseTriplesSameSubject(ts)
+	for _, tp := range (*ts)[n:] {
+		if tp.Path != nil {
+			p.errorf("%d:%d: property paths are not allowed in templates", t.line, t.col)
+		}
+	}
+}
+
+// parseGroupGraphPattern parses a group graph pattern, or a subquery.
+func (p *sparqlParser) parseGroupGraphPattern() *GroupPattern {
+	p.expectSymbol("{")
+	g := &GroupPattern{}
+	if isKeyword(p.peek(), "SELECT") {
+		p.next()
+		g.Patterns = []Pattern{&SubQueryPattern{Query: p.parseSubSelect()}}
+		p.expectSymbol("}")
+		return g
+	}
+	afterTriples := false // triples must be followed by a '.' before more triples
+	for {
+		t := p.peek()
+		var pat Pattern
+		switch {
+		case isSymbol(t, "}"):
+			p.next()
+			return g
+		case isSymbol(t, "."):
+			p.next()
+			afterTriples = false
+			continue
+		case isSymbol(t, "{"):
+			alt := p.parseGroupGraphPattern()
+			if !isKeyword(p.peek(), "UNION") {
+				pat = alt
+				break
+			}
+			u := &UnionPattern{Patterns: []*GroupPattern{alt}}
+			for p.acceptKeyword("UNION") {
+				u.Patterns = append(u.Patterns, p.parseGroupGraphPattern())
+			}
+			pat = u
+		case isKeyword(t, "OPTIONAL"):
+			p.next()
+			pat = &OptionalPattern{Pattern: p.parseGroupGraphPattern()}
+		case isKeyword(t, "MINUS"):
+			p.next()
+			pat = &MinusPattern{Pattern: p.parseGroupGraphPattern()}
+		case isKeyword(t, "GRAPH"):
+			p.next()
+			name := p.parseVarOrIRI()
+			pat = &NamedGraphPattern{Name: name, Pattern: p.parseGroupGraphPattern()}
+		case isKeyword(t, "FILTER"):
+			p.next()
+			pat = &FilterPattern{Expr: p.parseConstraint()}
+		case isKeyword(t, "BIND"):
+			p.next()
+			p.expectSymbol("(")
+			e := p.parseExpression()
+			p.expectKeyword("AS")
+			v := p.expectVar()
+			p.expectSymbol(")")
+			pat = &BindPattern{Expr: e, Var: v}
+		case isKeyword(t, "VALUES"):
+			p.next()
+			pat = p.parseDataBlock()
+		case isKeyword(t, "SERVICE"):
+			p.next()
+			silent := p.acceptKeyword("SILENT")
+			endpoint := p.parseVarOrIRI()
+			pat = &ServicePattern{Silent: silent, Endpoint: endpoint, Pattern: p.parseGroupGraphPattern()}
+		default:
+			if afterTriples {
+				p.unexpected(t, "'.' or '}'")
+			}
+			var ts []TriplePattern
+			p.parseTriplesSameSubject(&ts)
+			afterTriples = true
+			if n := len(g.Patterns); n > 0 {
+				if bp, ok := g.Patterns[n-1].(*BasicPattern); ok {
+					bp.Triples = append(bp.Triples, ts...)
+					continue
+				}
+			}
+			pat = &BasicPattern{Triples: ts}
+		}
+		if _, ok := pat.(*BasicPattern); !ok {
+			afterTriples = false
+		}
+		g.Patterns = append(g.Patterns, pat)
+	}
+}
+
+// parseSubSelect parses a subquery, after the SELECT keyword.
+func (p *sparqlParser) parseSubSelect() *Query {
+	q := &Query{Form: QuerySelect, Limit: -1}
+	p.parseSelectClause(q)
+	q.Where = p.parseWhereClause()
+	p.parseSolutionModifier(q)
+	if p.acceptKeyword("VALUES") {
+		q.Values = p.parseDataBlock()
+	}
+	return q
+}
+
+// parseDataBlock parses the data of a VALUES clause.
+func (p *sparqlParser) parseDataBlock() *ValuesPattern {
+	vp := &ValuesPattern{}
+	if t := p.peek(); t.typ == tokenVariable {
+		// A single variable, with a value per row.
+		vp.Vars = []Var{p.expectVar()}
+		p.expectSymbol("{")
+		for !p.acceptSymbol("}") {
+			vp.Rows = append(vp.Rows, []Term{p.parseDataBlockValue()})
+		}
+		return vp
+	}
+	p.expectSymbol("(")
+	for !p.acceptSymbol(")") {
+		vp.Vars = append(vp.Vars, p.expectVar())
+	}
+	p.expectSymbol("{")
+	for !p.acceptSymbol("}") {
+		p.expectSymbol("(")
+		row := []Term{}
+		for !p.acceptSymbol(")") {
+			row = append(row, p.parseDataBlockValue())
+		}
+		if len(row) != len(vp.Vars) {
+			t := p.peek()
+			p.errorf("%d:%d: VALUES row has %d values, want %d", t.line, t.col, len(row), len(vp.Vars))
+		}
+		vp.Rows = append(vp.Rows, row)
+	}
+	return vp
+}
+
+// parseDataBlockValue parses an IRI, a literal or UNDEF (returned as nil).
+func (p *sparqlParser) parseDataBlockValue() Term {
+	if p.acceptKeyword("UNDEF") {
+		return nil
+	}
+	t := p.peek()
+	switch t.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		return p.parseIRI()
+	}
+	if l, ok := p.parseLiteral(); ok {
+		return l
+	}
+	p.unexpected(t, "IRI, literal or UNDEF")
+	return nil
+}
+
+// parseVarOrIRI parses a variable or an IRI.
+func (p *sparqlParser) parseVarOrIRI() PatternTerm {
+	if t := p.peek(); t.typ == tokenVariable {
+		p.next()
+		return Var(t.text)
+	}
+	return p.parseIRI()
+}
+
+// parseIRI parses an IRI, or a prefixed name.
+func (p *sparqlParser) parseIRI() IRI {
+	t := p.next()
+	switch t.typ {
+	case tokenIRIAbs:
+		return IRI{str: t.text}
+	case tokenIRIRel:
+		return IRI{str: resolveIRI(p.base, t.text)}
+	case tokenPrefixLabel:
+		ns, ok := p.ns[prefixLabel(t)]
+		if !ok {
+			p.errorf("%d:%d: missing namespace for prefix: '%s'", t.line, t.col, prefixLabel(t))
+		}
+		suf := p.next()
+		if suf.typ != tokenIRISuffix {
+			p.unexpected(suf, "IRI suffix")
+		}
+		return IRI{str: ns + suf.text}
+	}
+	p.unexpected(t, "IRI")
+	return IRI{}
+}
+
+// parseLiteral parses a literal, if the next token starts one.
+func (p *sparqlParser) parseLiteral() (Literal, bool) {
+	t := p.next()
+	switch t.typ {
+	case tokenLiteral, tokenLiteral3:
+		l := Literal{str: t.text, DataType: xsdString}
+		switch p.peek().typ {
+		case tokenLangMarker:
+			p.next()
+			lang := p.next()
+			if lang.typ != tokenLang {
+				p.unexpected(lang, "language tag")
+			}
+			l.lang = lang.text
+			l.DataType = rdfLangString
+		case tokenDataTypeMarker:
+			p.next()
+			l.DataType = p.parseIRI()
+		}
+		return l, true
+	case tokenLiteralInteger:
+		return Literal{str: t.text, DataType: xsdInteger}, true
+	case tokenLiteralDecimal:
+		return Literal{str: t.text, DataType: xsdDecimal}, true
+	case tokenLiteralDouble:
+		return Literal{str: t.text, DataType: xsdDouble}, true
+	case tokenKeyword:
+		if strings.EqualFold(t.text, "true") || strings.EqualFold(t.text, "false") {
+			return Literal{str: strings.ToLower(t.text), DataType: xsdBoolean}, true
+		}
+	}
+	p.backup()
+	return Literal{}, false
+}
+
+// parseTriplesSameSubject parses triples with the same subject, adding them
+// to ts.
+func (p *sparqlParser) parseTriplesSameSubject(ts *[]TriplePattern) {
+	t := p.peek()
+	subj := p.parseGraphNode(ts)
+	if isSymbol(t, "[") || (isSymbol(t, "(") && subj != rdfNil) {
+		// A blank node property list or a collection; the property list
+		// is optional.
+		if !p.startsVerb(p.peek()) {
+			return
+		}
+	}
+	p.parsePropertyList(subj, ts)
+}
+
+// startsVerb returns true if the token starts a predicate or a property
+// path.
+func (p *sparqlParser) startsVerb(t token) bool {
+	switch t.typ {
+	case tokenVariable, tokenIRIAbs, tokenIRIRel, tokenPrefixLabel, tokenRDFType:
+		return true
+	}
+	return isSymbol(t, "^") || isSymbol(t, "!") || isSymbol(t, "(")
+}
+
+// parsePropertyList parses the predicates and objects of the subject.
+func (p *sparqlParser) parsePropertyList(subj PatternTerm, ts *[]TriplePattern) {
+	for {
+		pred, path := p.parseVerb()
+		for {
+			obj := p.parseGraphNode(ts)
+			*ts = append(*ts, TriplePattern{Subj: subj, Pred: pred, Obj: obj, Path: path})
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if !p.acceptSymbol(";") {
+			return
+		}
+		for p.acceptSymbol(";") {
+		}
+		if !p.startsVerb(p.peek()) {
+			return
+		}
+	}
+}
+
+// parseVerb parses a predicate, which is a variable or a property path. A
+// path of a single IRI is returned as the predicate.
+func (p *sparqlParser) parseVerb() (PatternTerm, Path) {
+	t := p.peek()
+	if t.typ == tokenVariable {
+		p.next()
+		return Var(t.text), nil
+	}
+	if !p.startsVerb(t) {
+		p.unexpected(t, "predicate")
+	}
+	path := p.parsePath()
+	if link, ok := path.(*LinkPath); ok {
+		return link.IRI, nil
+	}
+	return nil, path
+}
+
+// Property paths:
+
+// parsePath parses a path, of alternatives separated by '|'.
+func (p *sparqlParser) parsePath() Path {
+	paths := []Path{p.parsePathSequence()}
+	for p.acceptSymbol("|") {
+		paths = append(paths, p.parsePathSequence())
+	}
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	return &AlternativePath{Paths: paths}
+}
+
+// parsePathSequence parses a sequence of paths, separated by '/'.
+func (p *sparqlParser) parsePathSequence() Path {
+	paths := []Path{p.parsePathEltOrInverse()}
+	for p.acceptSymbol("/") {
+		paths = append(paths, p.parsePathEltOrInverse())
+	}
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	return &SequencePath{Paths: paths}
+}
+
+// parsePathEltOrInverse parses a path, optionally inverted by '^'.
+func (p *sparqlParser) parsePathEltOrInverse() Path {
+	if p.acceptSymbol("^") {
+		return &InversePath{Path: p.parsePathElt()}
+	}
+	return p.parsePathElt()
+}
+
+// parsePathElt parses a path, optionally followed by '*', '+' or '?'.
+func (p *sparqlParser) parsePathElt() Path {
+	path := p.parsePathPrimary()
+	switch t := p.peek(); {
+	case isSymbol(t, "*"):
+		p.next()
+		return &ZeroOrMorePath{Path: path}
+	case isSymbol(t, "+"):
+		p.next()
+		return &OneOrMorePath{Path: path}
+	case isSymbol(t, "?"):
+		p.next()
+		return &ZeroOrOnePath{Path: path}
+	}
+	return path
+}
+
+// parsePathPrimary parses an IRI, 'a', a negated property set or a path in
+// brackets.
+func (p *sparqlParser) parsePathPrimary() Path {
+	switch t := p.peek(); {
+	case t.typ == tokenRDFType:
+		p.next()
+		return &LinkPath{IRI: rdfType}
+	case isSymbol(t, "!"):
+		p.next()
+		neg := &NegatedPath{}
+		if !p.acceptSymbol("(") {
+			p.parsePathOneInPropertySet(neg)
+			return neg
+		}
+		if p.acceptSymbol(")") {
+			return neg
+		}
+		p.parsePathOneInPropertySet(neg)
+		for p.acceptSymbol("|") {
+			p.parsePathOneInPropertySet(neg)
+		}
+		p.expectSymbol(")")
+		return neg
+	case isSymbol(t, "("):
+		p.next()
+		path := p.parsePath()
+		p.expectSymbol(")")
+		return path
+	}
+	return &LinkPath{IRI: p.parseIRI()}
+}
+
+// parsePathOneInPropertySet parses an IRI or 'a', optionally inverted by
+// '^', adding it to the negated property set.
+func (p *sparqlParser) parsePathOneInPropertySet(neg *NegatedPath) {
+	inverse := p.acceptSymbol("^")
+	var iri IRI
+	if p.peek().typ == tokenRDFType {
+		p.next()
+		iri = rdfType
+	} else {
+		iri = p.parseIRI()
+	}
+	if inverse {
+		neg.Inverse = append(neg.Inverse, iri)
+	} else {
+		neg.IRIs = append(neg.IRIs, iri)
+	}
+}
+
+// parseGraphNode parses a variable, a term, a blank node property list or a
+// collection, adding the triples of the latter to ts.
+func (p *sparqlParser) parseGraphNode(ts *[]TriplePattern) PatternTerm {
+	t := p.peek()
+	switch {
+	case t.typ == tokenVariable:
+		p.next()
+		return Var(t.text)
+	case t.typ == tokenIRIAbs, t.typ == tokenIRIRel, t.typ == tokenPrefixLabel:
+		return p.parseIRI()
+	case t.typ == tokenBNode:
+		p.next()
+		return Blank{id: t.text}
+	case isSymbol(t, "["):
+		p.next()
+		b := p.blank()
+		if !p.acceptSymbol("]") {
+			p.parsePropertyList(b, ts)
+			p.expectSymbol("]")
+		}
+		return b
+	case isSymbol(t, "("):
+		p.next()
+		if p.acceptSymbol(")") {
+			return rdfNil
+		}
+		head := p.blank()
+		for node := head; ; {
+			item := p.parseGraphNode(ts)
+			*ts = append(*ts, TriplePattern{Subj: node, Pred: rdfFirst, Obj: item})
+			if p.acceptSymbol(")") {
+				*ts = append(*ts, TriplePattern{Subj: node, Pred: rdfRest, Obj: rdfNil})
+				return head
+			}
+			rest := p.blank()
+			*ts = append(*ts, TriplePattern{Subj: node, Pred: rdfRest, Obj: rest})
+			node = rest
+		}
+	}
+	if l, ok := p.parseLiteral(); ok {
+		return l
+	}
+	p.unexpected(p.next(), "subject or object")
+	return nil
+}
+
+// Expressions:
+
+// parseExpression parses an expression.
+func (p *sparqlParser) parseExpression() Expr {
+	e := p.parseAnd()
+	for p.acceptSymbol("||") {
+		e = &BinaryExpr{Op: "||", Left: e, Right: p.parseAnd()}
+	}
+	return e
+}
+
+func (p *sparqlParser) parseAnd() Expr {
+	e := p.parseRelational()
+	for p.acceptSymbol("&&") {
+		e = &BinaryExpr{Op: "&&", Left: e, Right: p.parseRelational()}
+	}
+	return e
+}
+
+func (p *sparqlParser) parseRelational() Expr {
+	e := p.parseAdditive()
+	t := p.peek()
+	switch {
+	case t.typ == tokenSymbol && (t.text == "=" || t.text == "!=" || t.text == "<" || t.text == ">" || t.text == "<=" || t.text == ">="):
+		p.next()
+		return &BinaryExpr{Op: t.text, Left: e, Right: p.parseAdditive()}
+	case isKeyword(t, "IN"):
+		p.next()
+		return &InExpr{Arg: e, List: p.parseExpressionList()}
+	case isKeyword(t, "NOT"):
+		p.next()
+		p.expectKeyword("IN")
+		return &InExpr{Arg: e, Not: true, List: p.parseExpressionList()}
+	}
+	return e
+}
+
+func (p *sparqlParser) parseAdditive() Expr {
+	e := p.parseMultiplicative()
+	for {
+		t := p.peek()
+		switch {
+		case isSymbol(t, "+"), isSymbol(t, "-"):
+			p.next()
+			e = &BinaryExpr{Op: t.text, Left: e, Right: p.parseMultiplicative()}
+		case (t.typ == tokenLiteralInteger || t.typ == tokenLiteralDecimal || t.typ == tokenLiteralDouble) &&
+			(t.text[0] == '+' || t.text[0] == '-'):
+			// A signed number is an addition or subtraction: ?x -1
+			p.next()
+			l, _ := p.parseSignedNumber(t)
+			var right Expr = &TermExpr{Term: l}
+			right = p.parseMultiplicativeRest(right)
+			e = &BinaryExpr{Op: t.text[:1], Left: e, Right: right}
+		default:
+			return e
+		}
+	}
+}
+
+// parseSignedNumber returns the number of the token, without its sign.
+func (p *sparqlParser) parseSignedNumber(t token) (Literal, bool) {
+	l := Literal{str: t.text[1:]}
+	switch t.typ {
+	case tokenLiteralInteger:
+		l.DataType = xsdInteger
+	case tokenLiteralDecimal:
+		l.DataType = xsdDecimal
+	default:
+		l.DataType = xsdDouble
+	}
+	return l, true
+}
+
+func (p *sparqlParser) parseMultiplicative() Expr {
+	return p.parseMultiplicativeRest(p.parseUnary())
+}
+
+func (p *sparqlParser) parseMultiplicativeRest(e Expr) Expr {
+	for {
+		t := p.peek()
+		if !isSymbol(t, "*") && !isSymbol(t, "/") {
+			return e
+		}
+		p.next()
+		e = &BinaryExpr{Op: t.text, Left: e, Right: p.parseUnary()}
+	}
+}
+
+func (p *sparqlParser) parseUnary() Expr {
+	t := p.peek()
+	if isSymbol(t, "!") || isSymbol(t, "+") || isSymbol(t, "-") {
+		p.next()
+		return &UnaryExpr{Op: t.text, Arg: p.parsePrimary()}
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses a bracketed expression, a function call, a term or
+// a variable.
+func (p *sparqlParser) parsePrimary() Expr {
+	t := p.peek()
+	switch t.typ {
+	case tokenSymbol:
+		if t.text == "(" {
+			p.next()
+			e := p.parseExpression()
+			p.expectSymbol(")")
+			return e
+		}
+	case tokenVariable:
+		p.next()
+		return &TermExpr{Term: Var(t.text)}
+	case tokenIRIAbs, tokenIRIRel, tokenPrefixLabel:
+		iri := p.parseIRI()
+		if !isSymbol(p.peek(), "(") {
+			return &TermExpr{Term: iri}
+		}
+		return &CallExpr{IRI: iri, Args: p.parseExpressionList()}
+	case tokenKeyword:
+		name := strings.ToUpper(t.text)
+		switch {
+		case name == "TRUE" || name == "FALSE":
+			l, _ := p.parseLiteral()
+			return &TermExpr{Term: l}
+		case name == "EXISTS":
+			p.next()
+			return &ExistsExpr{Pattern: p.parseGroupGraphPattern()}
+		case name == "NOT":
+			p.next()
+			p.expectKeyword("EXISTS")
+			return &ExistsExpr{Not: true, Pattern: p.parseGroupGraphPattern()}
+		case sparqlAggregates[name]:
+			p.next()
+			return p.parseAggregate(name)
+		}
+		arity, ok := sparqlBuiltins[name]
+		if !ok {
+			p.errorf("%d:%d: unknown function: %s", t.line, t.col, t.text)
+		}
+		p.next()
+		args := p.parseExpressionList()
+		if len(args) < arity[0] || (arity[1] >= 0 && len(args) > arity[1]) {
+			p.errorf("%d:%d: wrong number of arguments to %s: %d", t.line, t.col, name, len(args))
+		}
+		if name == "BOUND" {
+			if te, ok := args[0].(*TermExpr); !ok || !isVar(te.Term) {
+				p.errorf("%d:%d: BOUND requires a variable", t.line, t.col)
+			}
+		}
+		return &CallExpr{Name: name, Args: args}
+	}
+	if l, ok := p.parseLiteral(); ok {
+		return &TermExpr{Term: l}
+	}
+	p.unexpected(p.next(), "expression")
+	return nil
+}
+
+// parseExpressionList parses a bracketed, comma-separated list of
+// expressions.
+func (p *sparqlParser) parseExpressionList() []Expr {
+	p.expectSymbol("(")
+	args := []Expr{}
+	if p.acceptSymbol(")") {
+		return args
+	}
+	for {
+		args = append(args, p.parseExpression())
+		if p.acceptSymbol(")") {
+			return args
+		}
+		p.expectSymbol(",")
+	}
+}
+
+// parseAggregate parses the arguments of an aggregate.
+func (p *sparqlParser) parseAggregate(name string) Expr {
+	p.expectSymbol("(")
+	agg := &AggregateExpr{Name: name, Separator: " "}
+	agg.Distinct = p.acceptKeyword("DISTINCT")
+	if name == "COUNT" && p.acceptSymbol("*") {
+		p.expectSymbol(")")
+		return agg
+	}
+	agg.Arg = p.parseExpression()
+	if name == "GROUP_CONCAT" && p.acceptSymbol(";") {
+		p.expectKeyword("SEPARATOR")
+		p.expectSymbol("=")
+		t := p.next()
+		if t.typ != tokenLiteral && t.typ != tokenLiteral3 {
+			p.unexpected(t, "separator string")
+		}
+		agg.Separator = t.text
+	}
+	p.expectSymbol(")")
+	return agg
+}
@@ -0,0 +1,352 @@
+package rdf
+
+import "strings"
+
+// Path is a SPARQL 1.1 property path; one of *LinkPath, *InversePath,
+// *SequencePath, *AlternativePath, *ZeroOrMorePath, *OneOrMorePath,
+// *ZeroOrOnePath and *NegatedPath.
+type Path interface {
+	// String returns the path in SPARQL syntax.
+	String() string
+
+	format(w *sparqlWriter, ctx int) string
+}
+
+// LinkPath is a path of a single predicate.
+type LinkPath struct {
+	IRI IRI
+}
+
+// InversePath is a path traversed from object to subject: ^path.
+type InversePath struct {
+	Path Path
+}
+
+// SequencePath is a sequence of paths: path1/path2.
+type SequencePath struct {
+	Paths []Path
+}
+
+// AlternativePath is a choice of paths: path1|path2.
+type AlternativePath struct {
+	Paths []Path
+}
+
+// ZeroOrMorePath is a path repeated any number of times: path*.
+type ZeroOrMorePath struct {
+	Path Path
+}
+
+// OneOrMorePath is a path repeated at least once: path+.
+type OneOrMorePath struct {
+	Path Path
+}
+
+// ZeroOrOnePath is an optional path: path?.
+type ZeroOrOnePath struct {
+	Path Path
+}
+
+// NegatedPath is a negated property set: !(iri1|^iri2). It matches a
+// single triple, whose predicate is not in IRIs, or a single triple
+// traversed from object to subject, whose predicate is not in Inverse.
+type NegatedPath struct {
+	IRIs    []IRI
+	Inverse []IRI
+}
+
+// Paths returns the distinct nodes reachable from the start node by the
+// path, in the order found. Repeated paths are evaluated breadth first,
+// visiting each node once, so they terminate on cyclic graphs.
+func Paths(g *Graph, start Term, p Path) []Term {
+	return distinctTerms(pathEnds(g, start, p))
+}
+
+// ParsePath parses a property path in SPARQL syntax, with prefixed names
+// resolved by the given prefixes.
+func ParsePath(path string, prefixes map[string]string) (pp Path, err error) {
+	p := newSPARQLParser(path)
+	defer p.recover(&err)
+	for label, ns := range prefixes {
+		p.ns[label] = ns
+	}
+	pp = p.parsePath()
+	if t := p.next(); t.typ != tokenEOF {
+		p.unexpected(t, "end of path")
+	}
+	return pp, nil
+}
+
+// Precedence contexts of path formatting.
+const (
+	pathCtxAlternative = iota
+	pathCtxSequence
+	pathCtxInverse
+	pathCtxModified
+	pathCtxPrimary
+)
+
+// bracket returns the path in brackets, if it binds less tightly than
+// required by the context.
+func bracket(s string, prec, ctx int) string {
+	if ctx > prec {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func pathString(p Path) string {
+	return p.format(newSPARQLWriter(nil), pathCtxAlternative)
+}
+
+func (p *LinkPath) String() string        { return pathString(p) }
+func (p *InversePath) String() string     { return pathString(p) }
+func (p *SequencePath) String() string    { return pathString(p) }
+func (p *AlternativePath) String() string { return pathString(p) }
+func (p *ZeroOrMorePath) String() string  { return pathString(p) }
+func (p *OneOrMorePath) String() string   { return pathString(p) }
+func (p *ZeroOrOnePath) String() string   { return pathString(p) }
+func (p *NegatedPath) String() string     { return pathString(p) }
+
+func (p *LinkPath) format(w *sparqlWriter, ctx int) string {
+	return pathIRI(w, p.IRI)
+}
+
+func (p *InversePath) format(w *sparqlWriter, ctx int) string {
+	return bracket("^"+p.Path.format(w, pathCtxModified), pathCtxInverse, ctx)
+}
+
+func (p *SequencePath) format(w *sparqlWriter, ctx int) string {
+	parts := make([]string, len(p.Paths))
+	for i, sub := range p.Paths {
+		parts[i] = sub.format(w, pathCtxInverse)
+	}
+	return bracket(strings.Join(parts, "/"), pathCtxSequence, ctx)
+}
+
+func (p *AlternativePath) format(w *sparqlWriter, ctx int) string {
+	parts := make([]string, len(p.Paths))
+	for i, sub := range p.Paths {
+		parts[i] = sub.format(w, pathCtxSequence)
+	}
+	return bracket(strings.Join(parts, "|"), pathCtxAlternative, ctx)
+}
+
+func (p *ZeroOrMorePath) format(w *sparqlWriter, ctx int) string {
+	return bracket(p.Path.format(w, pathCtxPrimary)+"*", pathCtxModified, ctx)
+}
+
+func (p *OneOrMorePath) format(w *sparqlWriter, ctx int) string {
+	return bracket(p.Path.format(w, pathCtxPrimary)+"+", pathCtxModified, ctx)
+}
+
+func (p *ZeroOrOnePath) format(w *sparqlWriter, ctx int) string {
+	return bracket(p.Path.format(w, pathCtxPrimary)+"?", pathCtxModified, ctx)
+}
+
+func (p *NegatedPath) format(w *sparqlWriter, ctx int) string {
+	var parts []string
+	for _, iri := range p.IRIs {
+		parts = append(parts, pathIRI(w, iri))
+	}
+	for _, iri := range p.Inverse {
+		parts = append(parts, "^"+pathIRI(w, iri))
+	}
+	if len(parts) == 1 {
+		return "!" + parts[0]
+	}
+	return "!(" + strings.Join(parts, "|") + ")"
+}
+
+// pathIRI returns an IRI of a path, where rdf:type is written as 'a'.
+func pathIRI(w *sparqlWriter, iri IRI) string {
+	if iri == rdfType {
+		return "a"
+	}
+	return w.iri(iri)
+}
+
+// pathSSE returns the path as an S-expression.
+func pathSSE(p Path) string {
+	w := newSPARQLWriter(nil)
+	list := func(name string, ps []Path) string {
+		parts := make([]string, len(ps))
+		for i, sub := range ps {
+			parts[i] = pathSSE(sub)
+		}
+		return "(" + name + " " + strings.Join(parts, " ") + ")"
+	}
+	switch p := p.(type) {
+	case *LinkPath:
+		return w.iri(p.IRI)
+	case *InversePath:
+		return "(reverse " + pathSSE(p.Path) + ")"
+	case *SequencePath:
+		return list("seq", p.Paths)
+	case *AlternativePath:
+		return list("alt", p.Paths)
+	case *ZeroOrMorePath:
+		return "(path* " + pathSSE(p.Path) + ")"
+	case *OneOrMorePath:
+		return "(path+ " + pathSSE(p.Path) + ")"
+	case *ZeroOrOnePath:
+		return "(path? " + pathSSE(p.Path) + ")"
+	case *NegatedPath:
+		var parts []string
+		for _, iri := range p.IRIs {
+			parts = append(parts, w.iri(iri))
+		}
+		for _, iri := range p.Inverse {
+			parts = append(parts, "(reverse "+w.iri(iri)+")")
+		}
+		return "(notoneof " + strings.Join(parts, " ") + ")"
+	}
+	return ""
+}
+
+// reversePath returns the inverse of the path.
+func reversePath(p Path) Path {
+	reverseAll := func(ps []Path, backwards bool) []Path {
+		rs := make([]Path, len(ps))
+		for i, sub := range ps {
+			if backwards {
+				rs[len(ps)-1-i] = reversePath(sub)
+			} else {
+				rs[i] = reversePath(sub)
+			}
+		}
+		return rs
+	}
+	switch p := p.(type) {
+	case *InversePath:
+		return p.Path
+	case *SequencePath:
+		return &SequencePath{Paths: reverseAll(p.Paths, true)}
+	case *AlternativePath:
+		return &AlternativePath{Paths: reverseAll(p.Paths, false)}
+	case *ZeroOrMorePath:
+		return &ZeroOrMorePath{Path: reversePath(p.Path)}
+	case *OneOrMorePath:
+		return &OneOrMorePath{Path: reversePath(p.Path)}
+	case *ZeroOrOnePath:
+		return &ZeroOrOnePath{Path: reversePath(p.Path)}
+	case *NegatedPath:
+		return &NegatedPath{IRIs: p.Inverse, Inverse: p.IRIs}
+	}
+	return &InversePath{Path: p}
+}
+
+// pathEnds returns the nodes reachable from the start node by the path.
+// Nodes reached by more than one route are repeated, except for the
+// repeated and optional paths, which give distinct nodes.
+func pathEnds(g tripleMatcher, start Term, p Path) []Term {
+	switch p := p.(type) {
+	case *LinkPath:
+		subj, ok := start.(Subject)
+		if !ok {
+			return nil
+		}
+		var ends []Term
+		for _, t := range g.Match(subj, p.IRI, nil) {
+			ends = append(ends, t.Obj)
+		}
+		return ends
+	case *InversePath:
+		link, ok := p.Path.(*LinkPath)
+		if !ok {
+			return pathEnds(g, start, reversePath(p.Path))
+		}
+		obj, ok := start.(Object)
+		if !ok {
+			return nil
+		}
+		var ends []Term
+		for _, t := range g.Match(nil, link.IRI, obj) {
+			ends = append(ends, t.Subj)
+		}
+		return ends
+	case *SequencePath:
+		nodes := []Term{start}
+		for _, sub := range p.Paths {
+			var next []Term
+			for _, n := range nodes {
+				next = append(next, pathEnds(g, n, sub)...)
+			}
+			nodes = next
+		}
+		return nodes
+	case *AlternativePath:
+		var ends []Term
+		for _, sub := range p.Paths {
+			ends = append(ends, pathEnds(g, start, sub)...)
+		}
+		return ends
+	case *ZeroOrMorePath:
+		return closure(g, []Term{start}, p.Path)
+	case *OneOrMorePath:
+		return closure(g, pathEnds(g, start, p.Path), p.Path)
+	case *ZeroOrOnePath:
+		return distinctTerms(append([]Term{start}, pathEnds(g, start, p.Path)...))
+	case *NegatedPath:
+		var ends []Term
+		if subj, ok := start.(Subject); ok && (len(p.IRIs) > 0 || len(p.Inverse) == 0) {
+			for _, t := range g.Match(subj, nil, nil) {
+				if !containsIRI(p.IRIs, t.Pred) {
+					ends = append(ends, t.Obj)
+				}
+			}
+		}
+		if obj, ok := start.(Object); ok && len(p.Inverse) > 0 {
+			for _, t := range g.Match(nil, nil, obj) {
+				if !containsIRI(p.Inverse, t.Pred) {
+					ends = append(ends, t.Subj)
+				}
+			}
+		}
+		return ends
+	}
+	return nil
+}
+
+// closure returns the given nodes, and the nodes reachable from them by
+// repeating the path, breadth first.
+func closure(g tripleMatcher, nodes []Term, p Path) []Term {
+	var ends []Term
+	seen := make(map[string]bool)
+	for len(nodes) > 0 {
+		var next []Term
+		for _, n := range nodes {
+			if seen[termKey(n)] {
+				continue
+			}
+			seen[termKey(n)] = true
+			ends = append(ends, n)
+			next = append(next, pathEnds(g, n, p)...)
+		}
+		nodes = next
+	}
+	return ends
+}
+
+// containsIRI returns true if the term is one of the IRIs.
+func containsIRI(iris []IRI, t Term) bool {
+	for _, iri := range iris {
+		if termKey(iri) == termKey(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// distinctTerms removes duplicate terms, keeping the first of each.
+func distinctTerms(ts []Term) []Term {
+	var res []Term
+	seen := make(map[string]bool)
+	for _, t := range ts {
+		if !seen[termKey(t)] {
+			seen[termKey(t)] = true
+			res = append(res, t)
+		}
+	}
+	return res
+}
@@ -0,0 +1,137 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	prefixes := map[string]string{"ex": "http://example.org/"}
+	tests := []struct {
+		input string
+		want  string
+		sse   string
+	}{
+		{"ex:p", "<http://example.org/p>", "<http://example.org/p>"},
+		{"a", "a", "<http://www.w3.org/1999/02/22-rdf-syntax-ns#type>"},
+		{"^ex:p", "^<http://example.org/p>", "(reverse <http://example.org/p>)"},
+		{"<p>/<q>|<r>", "<p>/<q>|<r>", "(alt (seq <p> <q>) <r>)"},
+		{"<p>/(<q>|<r>)", "<p>/(<q>|<r>)", "(seq <p> (alt <q> <r>))"},
+		{"(<p>/<q>)*", "(<p>/<q>)*", "(path* (seq <p> <q>))"},
+		{"^<p>+/<q>?", "^<p>+/<q>?", "(seq (reverse (path+ <p>)) (path? <q>))"},
+		{"(^<p>)*", "(^<p>)*", "(path* (reverse <p>))"},
+		{"!<p>", "!<p>", "(notoneof <p>)"},
+		{"!(a|^<p>)", "!(a|^<p>)", "(notoneof <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> (reverse <p>))"},
+	}
+	for _, test := range tests {
+		p, err := ParsePath(test.input, prefixes)
+		if err != nil {
+			t.Errorf("ParsePath(%q) => %v", test.input, err)
+			continue
+		}
+		if got := p.String(); got != test.want {
+			t.Errorf("ParsePath(%q).String() => %s, want %s", test.input, got, test.want)
+		}
+		if got := pathSSE(p); got != test.sse {
+			t.Errorf("ParsePath(%q) SSE => %s, want %s", test.input, got, test.sse)
+		}
+	}
+
+	for _, input := range []string{"", "<p>/", "<p> <q>", "!(<p>", "?x", "ex2:p"} {
+		if _, err := ParsePath(input, prefixes); err == nil {
+			t.Errorf("ParsePath(%q) => no error", input)
+		}
+	}
+}
+
+func TestPaths(t *testing.T) {
+	g := loadTestGraph(t, `@prefix : <http://example.org/> .
+:a :p :b .
+:b :p :c .
+:c :p :a .
+:c :q :d .
+:d :r "x" .
+`)
+	prefixes := map[string]string{"": "http://example.org/"}
+	tests := []struct {
+		start string
+		path  string
+		want  string
+	}{
+		{":a", ":p", ":b"},
+		{":a", ":p*", ":a :b :c"},
+		{":a", ":p+", ":b :c :a"},
+		{":a", ":p?", ":a :b"},
+		{":a", ":p/:p", ":c"},
+		{":a", ":p+/:q", ":d"},
+		{":a", "^:p", ":c"},
+		{":d", "^(:p*/:q)", ":c :b :a"},
+		{":a", ":p/:p|^:p", ":c"},
+		{":c", "!:p", ":d"},
+		{":d", "!(^:r)", ":c"},
+		{":d", "!(:q|^:q)", `"x"`},
+		{":e", ":p*", ":e"},
+		{":d", ":r/:p", ""},
+	}
+	for _, test := range tests {
+		p, err := ParsePath(test.path, prefixes)
+		if err != nil {
+			t.Fatalf("ParsePath(%q) => %v", test.path, err)
+		}
+		start := IRI{str: "http://example.org/" + test.start[1:]}
+		w := newSPARQLWriter(map[string]string{"": "http://example.org/"})
+		var ends []string
+		for _, end := range Paths(g, start, p) {
+			ends = append(ends, w.term(end))
+		}
+		if got := strings.Join(ends, " "); got != test.want {
+			t.Errorf("Paths(%s, %s) => %s, want %s", test.start, test.path, got, test.want)
+		}
+	}
+}
+
+func TestEvalPathBlankNodes(t *testing.T) {
+	g := loadTestGraph(t, `@prefix ex: <http://example.org/> .
+ex:a ex:p ex:b .
+ex:b ex:p ex:c .
+ex:c ex:p ex:d .
+ex:c ex:n "c" .
+ex:d ex:n "d" .
+`)
+	// The blank nodes shared by triple patterns and paths join as variables.
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			`SELECT ?o { ?s ex:p [ ex:p+ ?o ] }`,
+			`o=<http://example.org/c>
+o=<http://example.org/d>
+o=<http://example.org/d>`,
+		},
+		{
+			`SELECT ?s ?o { ?s ex:p/ex:p [ ex:n ?o ] }`,
+			`s=<http://example.org/a> o="c"
+s=<http://example.org/b> o="d"`,
+		},
+		{
+			`SELECT ?o { ex:a ex:p _:x . _:x ex:p/ex:p ?o }`,
+			`o=<http://example.org/d>`,
+		},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery("PREFIX ex: <http://example.org/>\n" + test.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) => %v", test.query, err)
+			continue
+		}
+		res, err := q.EvalGraph(g)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got := solutionsString(res, false); got != test.want {
+			t.Errorf("%s =>\n%s\nwant:\n%s", test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package rdf
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SPARQLHandler is an http.Handler serving a Dataset as a SPARQL endpoint,
+// as specified by the SPARQL 1.1 Protocol.
+//
+// Queries are sent by GET, or by POST either URL-encoded or directly in the
+// body as application/sparql-query. Updates are sent by POST, either
+// URL-encoded or as application/sparql-update. The default-graph-uri and
+// named-graph-uri parameters of queries, and the using-graph-uri and
+// using-named-graph-uri parameters of updates, override the dataset given
+// in the request.
+//
+// The format of the response is negotiated from the Accept header of the
+// request. The results of SELECT and ASK queries are written in the JSON
+// (the default), XML, CSV or TSV results formats, and the graphs of
+// CONSTRUCT and DESCRIBE queries in Turtle (the default), N-Triples or
+// N-Quads. A successful update has an empty response, with status 204.
+//
+// Queries are evaluated concurrently, but updates are applied one at a time,
+// while no query is evaluated. The dataset must not be modified other than
+// through the handler while it is in use.
+//
+// As the requests are those of remote clients, the handler neither sends
+// requests to the endpoints of SERVICE patterns, nor reads the documents of
+// LOAD operations, unless EvalOptions.ResolveService and Load are set. Both
+// are refused otherwise.
+type SPARQLHandler struct {
+	Dataset *Dataset
+
+	// ReadOnly disables updates, which are refused with status 403.
+	ReadOnly bool
+
+	// EvalOptions are the options of the evaluation of queries, and of the
+	// WHERE patterns of updates. The context is the one of the request.
+	// SERVICE patterns are refused if ResolveService is nil.
+	EvalOptions EvalOptions
+
+	// Load returns the document of the source IRI of a LOAD operation, as
+	// ExecOptions.Load. If nil, LOAD operations are refused with status
+	// 403; local files are never read.
+	Load func(source IRI) (io.ReadCloser, Format, error)
+
+	// MaxRequestSize is the maximum size of the body of a request, in
+	// bytes. If zero, 10 MB is used.
+	MaxRequestSize int64
+
+	mu sync.RWMutex
+}
+
+// defaultMaxRequestSize is the default of SPARQLHandler.MaxRequestSize, the
+// limit of http.Request.ParseForm.
+const defaultMaxRequestSize = 10 << 20
+
+// errServiceRefused is the error of the SERVICE patterns of the requests,
+// if the handler has no ResolveService.
+var errServiceRefused = errors.New("SERVICE is not allowed by this endpoint")
+
+// NewSPARQLHandler returns a new SPARQLHandler, serving the given dataset.
+func NewSPARQLHandler(d *Dataset) *SPARQLHandler {
+	return &SPARQLHandler{Dataset: d}
+}
+
+// Media types of the SPARQL protocol.
+const (
+	mediaTypeForm   = "application/x-www-form-urlencoded"
+	mediaTypeQuery  = "application/sparql-query"
+	mediaTypeUpdate = "application/sparql-update"
+)
+
+// resultsMediaTypes are the media types of query results, in order of
+// preference.
+var resultsMediaTypes = []struct {
+	mediaType string
+	format    ResultsFormat
+}{
+	{"application/sparql-results+json", ResultsJSON},
+	{"application/sparql-results+xml", ResultsXML},
+	{"text/csv", ResultsCSV},
+	{"text/tab-separated-values", ResultsTSV},
+	{"application/json", ResultsJSON},
+	{"application/xml", ResultsXML},
+}
+
+// graphMediaTypes are the media types of graphs, in order of preference.
+var graphMediaTypes = []struct {
+	mediaType string
+	format    Format
+}{
+	{"text/turtle", Turtle},
+	{"application/n-triples", NTriples},
+	{"application/n-quads", NQuads},
+}
+
+// ServeHTTP serves a SPARQL query or update request.
+func (h *SPARQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var text string // the query, or the update
+	var isUpdate bool
+	var params map[string][]string
+	switch r.Method {
+	case "GET", "HEAD":
+		params = r.URL.Query()
+		if _, ok := params["update"]; ok {
+			http.Error(w, "updates must be sent by POST", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if text, err = singleParam(params, "query"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case "POST":
+		max := h.MaxRequestSize
+		if max <= 0 {
+			max = defaultMaxRequestSize
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case mediaTypeForm:
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), requestErrorStatus(err))
+				return
+			}
+			params = r.Form
+			_, isQuery := params["query"]
+			_, isUpdate = params["update"]
+			var err error
+			switch {
+			case isQuery && isUpdate:
+				err = errors.New("both query and update parameters")
+			case isUpdate:
+				text, err = singleParam(params, "update")
+			default:
+				text, err = singleParam(params, "query")
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case mediaTypeQuery, mediaTypeUpdate:
+			params = r.URL.Query()
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), requestErrorStatus(err))
+				return
+			}
+			text, isUpdate = string(body), mediaType == mediaTypeUpdate
+		default:
+			http.Error(w, "unsupported media type: "+strconv.Quote(mediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if isUpdate {
+		if h.ReadOnly {
+			http.Error(w, "updates are not allowed by this endpoint", http.StatusForbidden)
+			return
+		}
+		h.serveUpdate(w, r, text, params)
+		return
+	}
+	h.serveQuery(w, r, text, params)
+}
+
+// requestErrorStatus returns the status of an error reading the body of a
+// request: 413 if it is too large, 400 otherwise.
+func requestErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// evalOptions returns the options of the evaluation of the request.
+func (h *SPARQLHandler) evalOptions(r *http.Request) EvalOptions {
+	opts := h.EvalOptions
+	opts.Context = r.Context()
+	if opts.ResolveService == nil {
+		opts.ResolveService = func(IRI) (*SPARQLClient, error) { return nil, errServiceRefused }
+	}
+	return opts
+}
+
+// singleParam returns the value of a parameter given exactly once.
+func singleParam(params map[string][]string, name string) (string, error) {
+	switch len(params[name]) {
+	case 0:
+		return "", errors.New("missing " + name + " parameter")
+	case 1:
+		return params[name][0], nil
+	}
+	return "", errors.New("more than one " + name + " parameter")
+}
+
+// paramIRIs returns the IRIs given by a parameter.
+func paramIRIs(params map[string][]string, name string) []IRI {
+	var iris []IRI
+	for _, v := range params[name] {
+		iris = append(iris, IRI{str: v})
+	}
+	return iris
+}
+
+func (h *SPARQLHandler) serveQuery(w http.ResponseWriter, r *http.Request, query string, params map[string][]string) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, fromNamed := paramIRIs(params, "default-graph-uri"), paramIRIs(params, "named-graph-uri")
+	if from != nil || fromNamed != nil {
+		q.From, q.FromNamed = from, fromNamed
+		if q.From == nil {
+			q.From = []IRI{}
+		}
+		if q.FromNamed == nil {
+			q.FromNamed = []IRI{}
+		}
+	}
+
+	// Negotiate the format of the response before evaluating the query.
+	w.Header().Add("Vary", "Accept")
+	accept := r.Header.Get("Accept")
+	var offers []string
+	switch q.Form {
+	case QuerySelect, QueryAsk:
+		for _, mt := range resultsMediaTypes {
+			if q.Form == QueryAsk && (mt.format == ResultsCSV || mt.format == ResultsTSV) {
+				continue
+			}
+			offers = append(offers, mt.mediaType)
+		}
+	default:
+		for _, mt := range graphMediaTypes {
+			offers = append(offers, mt.mediaType)
+		}
+	}
+	mediaType := negotiate(accept, offers)
+	if mediaType == "" {
+		http.Error(w, "not acceptable: "+strconv.Quote(accept)+", available: "+strings.Join(offers, ", "), http.StatusNotAcceptable)
+		return
+	}
+
+	h.mu.RLock()
+	res, err := q.EvalWithOptions(h.Dataset, h.evalOptions(r))
+	h.mu.RUnlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType+"; charset=utf-8")
+	for _, mt := range resultsMediaTypes {
+		if mt.mediaType == mediaType {
+			enc := NewResultsEncoder(w, mt.format)
+			if err := enc.EncodeResults(res); err != nil {
+				return
+			}
+			enc.Close()
+			return
+		}
+	}
+	for _, mt := range graphMediaTypes {
+		if mt.mediaType != mediaType {
+			continue
+		}
+		if mt.format == NQuads {
+			enc := NewQuadEncoder(w, NQuads)
+			for _, t := range res.Triples {
+				if err := enc.Encode(Quad{Triple: t}); err != nil {
+					return
+				}
+			}
+			enc.Close()
+			return
+		}
+		enc := NewTripleEncoder(w, mt.format)
+		if err := enc.EncodeAll(res.Triples); err != nil {
+			return
+		}
+		enc.Close()
+		return
+	}
+}
+
+func (h *SPARQLHandler) serveUpdate(w http.ResponseWriter, r *http.Request, update string, params map[string][]string) {
+	u, err := ParseUpdate(update)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.Load == nil {
+		for _, op := range u.Operations {
+			if _, ok := op.(*LoadOperation); ok {
+				http.Error(w, "LOAD is not allowed by this endpoint", http.StatusForbidden)
+				return
+			}
+		}
+	}
+	using, usingNamed := paramIRIs(params, "using-graph-uri"), paramIRIs(params, "using-named-graph-uri")
+	if using != nil || usingNamed != nil {
+		for _, op := range u.Operations {
+			op, ok := op.(*ModifyOperation)
+			if !ok {
+				continue
+			}
+			if op.With != (IRI{}) || op.Using != nil || op.UsingNamed != nil {
+				http.Error(w, "using-graph-uri and using-named-graph-uri conflict with WITH, USING and USING NAMED", http.StatusBadRequest)
+				return
+			}
+			op.Using, op.UsingNamed = using, usingNamed
+			if op.Using == nil {
+				op.Using = []IRI{}
+			}
+			if op.UsingNamed == nil {
+				op.UsingNamed = []IRI{}
+			}
+		}
+	}
+
+	h.mu.Lock()
+	err = u.ExecWithOptions(h.Dataset, ExecOptions{EvalOptions: h.evalOptions(r), Load: h.Load})
+	h.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// negotiate returns the offered media type most acceptable according to the
+// Accept header, or "" if none is acceptable. Offers of equal quality are
+// chosen in order; the first one if the header is empty.
+func negotiate(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+	var ranges []mediaRange
+	for _, s := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		r := mediaRange{q: 1}
+		if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+			r.typ, r.subtype = mediaType[:i], mediaType[i+1:]
+		} else if mediaType == "*" {
+			r.typ, r.subtype = "*", "*"
+		} else {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			r.q = q
+		}
+		ranges = append(ranges, r)
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		i := strings.IndexByte(offer, '/')
+		typ, subtype := offer[:i], offer[i+1:]
+		// The quality of the most specific matching range.
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,309 @@
+package rdf
+
+import (
+	"bytes"
+	stdcontext "context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestSPARQLHandler(t *testing.T) *SPARQLHandler {
+	var d Dataset
+	dec := NewQuadDecoder(strings.NewReader(`
+<http://example.org/a> <http://example.org/p> "a" .
+<http://example.org/b> <http://example.org/p> "b"@en .
+<http://example.org/c> <http://example.org/p> "c" <http://example.org/g> .
+`), NQuads)
+	if err := d.Load(dec); err != nil {
+		t.Fatal(err)
+	}
+	return NewSPARQLHandler(&d)
+}
+
+func TestSPARQLHandler(t *testing.T) {
+	const selectAll = "SELECT ?s ?o WHERE { ?s ?p ?o } ORDER BY ?s"
+	tests := []struct {
+		method      string
+		target      string
+		contentType string
+		body        string
+		accept      string
+
+		status   int
+		respType string
+		resp     string
+	}{
+		{
+			"GET", "/sparql?query=" + url.QueryEscape(selectAll), "", "", "",
+			200, "application/sparql-results+json; charset=utf-8",
+			`{"head":{"vars":["s","o"]},
+"results":{"bindings":[
+{"s":{"type":"uri","value":"http://example.org/a"},"o":{"type":"literal","value":"a"}},
+{"s":{"type":"uri","value":"http://example.org/b"},"o":{"type":"literal","value":"b","xml:lang":"en"}}
+]}}
+`,
+		},
+		{
+			"GET", "/sparql?query=" + url.QueryEscape(selectAll), "", "", "text/csv;q=0.5, text/tab-separated-values",
+			200, "text/tab-separated-values; charset=utf-8",
+			"?s\t?o\n<http://example.org/a>\t\"a\"\n<http://example.org/b>\t\"b\"@en\n",
+		},
+		{
+			"POST", "/sparql", "application/x-www-form-urlencoded",
+			"query=" + url.QueryEscape(selectAll) + "&named-graph-uri=http%3A%2F%2Fexample.org%2Fg&default-graph-uri=http%3A%2F%2Fexample.org%2Fg",
+			"text/csv",
+			200, "text/csv; charset=utf-8",
+			"s,o\r\nhttp://example.org/c,c\r\n",
+		},
+		{
+			"POST", "/sparql", "application/sparql-query", "ASK { ?s ?p \"c\" }", "application/sparql-results+xml",
+			200, "application/sparql-results+xml; charset=utf-8",
+			`<?xml version="1.0"?>
+<sparql xmlns="http://www.w3.org/2005/sparql-results#">
+	<head/>
+	<boolean>false</boolean>
+</sparql>
+`,
+		},
+		{
+			"GET", "/sparql?query=" + url.QueryEscape("CONSTRUCT WHERE { ?s ?p \"a\" }"), "", "", "text/*;q=0.1, application/n-triples",
+			200, "application/n-triples; charset=utf-8",
+			"<http://example.org/a> <http://example.org/p> \"a\" .\n",
+		},
+		{
+			"GET", "/sparql?query=" + url.QueryEscape("CONSTRUCT WHERE { ?s ?p \"a\" }"), "", "", "",
+			200, "text/turtle; charset=utf-8",
+			"@prefix ns0:\t<http://example.org/> .\nns0:a\tns0:p\t\"a\" .",
+		},
+		{
+			"GET", "/sparql?query=" + url.QueryEscape("CONSTRUCT WHERE { ?s ?p \"a\" }"), "", "", "application/n-quads",
+			200, "application/n-quads; charset=utf-8",
+			"<http://example.org/a> <http://example.org/p> \"a\"  .\n",
+		},
+
+		// Errors.
+		{
+			"GET", "/sparql?query=" + url.QueryEscape("ASK {}"), "", "", "text/csv",
+			406, "text/plain; charset=utf-8",
+			"not acceptable: \"text/csv\", available: application/sparql-results+json, application/sparql-results+xml, application/json, application/xml\n",
+		},
+		{
+			"GET", "/sparql", "", "", "",
+			400, "text/plain; charset=utf-8", "missing query parameter\n",
+		},
+		{
+			"GET", "/sparql?query=ASK%7B%7D&query=ASK%7B%7D", "", "", "",
+			400, "text/plain; charset=utf-8", "more than one query parameter\n",
+		},
+		{
+			"GET", "/sparql?query=SELECT", "", "", "",
+			400, "text/plain; charset=utf-8", "0:0: unexpected end of input, expected projection\n",
+		},
+		{
+			"GET", "/sparql?update=CLEAR+ALL", "", "", "",
+			400, "text/plain; charset=utf-8", "updates must be sent by POST\n",
+		},
+		{
+			"POST", "/sparql", "application/x-www-form-urlencoded", "query=ASK%7B%7D&update=CLEAR+ALL", "",
+			400, "text/plain; charset=utf-8", "both query and update parameters\n",
+		},
+		{
+			"POST", "/sparql", "text/plain", "ASK {}", "",
+			415, "text/plain; charset=utf-8", "unsupported media type: \"text/plain\"\n",
+		},
+		{
+			"PUT", "/sparql", "", "", "",
+			405, "text/plain; charset=utf-8", "method not allowed\n",
+		},
+		{
+			"POST", "/sparql?using-graph-uri=http%3A%2F%2Fexample.org%2Fg", "application/sparql-update",
+			"WITH <http://example.org/g> DELETE { ?s ?p ?o } WHERE { ?s ?p ?o }", "",
+			400, "text/plain; charset=utf-8", "using-graph-uri and using-named-graph-uri conflict with WITH, USING and USING NAMED\n",
+		},
+		{
+			"POST", "/sparql", "application/sparql-update", "CREATE GRAPH <http://example.org/g>", "",
+			500, "text/plain; charset=utf-8", "graph already exists: <http://example.org/g>\n",
+		},
+	}
+	for _, test := range tests {
+		h := newTestSPARQLHandler(t)
+		req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+		if test.contentType != "" {
+			req.Header.Set("Content-Type", test.contentType)
+		}
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != test.status || rec.Header().Get("Content-Type") != test.respType || rec.Body.String() != test.resp {
+			t.Errorf("%s %s %q:\ngot  %d %s %q\nwant %d %s %q", test.method, test.target, test.body,
+				rec.Code, rec.Header().Get("Content-Type"), rec.Body, test.status, test.respType, test.resp)
+		}
+	}
+}
+
+func TestSPARQLHandlerUpdate(t *testing.T) {
+	srv := httptest.NewServer(newTestSPARQLHandler(t))
+	defer srv.Close()
+
+	post := func(contentType, body string) *http.Response {
+		resp, err := http.Post(srv.URL, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := post("application/sparql-update", `INSERT DATA { <http://example.org/d> <http://example.org/p> "d" }`); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("INSERT DATA: got status %d; want 204", resp.StatusCode)
+	}
+	form := url.Values{
+		"update":          {`DELETE { ?s ?p ?o } INSERT { ?s ?p "changed" } WHERE { ?s ?p ?o FILTER(?o = "c") }`},
+		"using-graph-uri": {"http://example.org/g"},
+	}
+	if resp := post("application/x-www-form-urlencoded", form.Encode()); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE/INSERT: got status %d; want 204", resp.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	enc := NewQuadEncoder(&buf, NQuads)
+	h := srv.Config.Handler.(*SPARQLHandler)
+	for _, q := range h.Dataset.Quads() {
+		enc.Encode(q)
+	}
+	enc.Close()
+	got := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := map[string]bool{
+		`<http://example.org/a> <http://example.org/p> "a"  .`:                       true,
+		`<http://example.org/b> <http://example.org/p> "b"@en  .`:                    true,
+		`<http://example.org/c> <http://example.org/p> "c" <http://example.org/g> .`: true,
+		`<http://example.org/c> <http://example.org/p> "changed"  .`:                 true,
+		`<http://example.org/d> <http://example.org/p> "d"  .`:                       true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got quads:\n%s\nwant %d quads", buf.String(), len(want))
+	}
+	for _, q := range got {
+		if !want[q] {
+			t.Errorf("unexpected quad: %s", q)
+		}
+	}
+}
+
+func TestSPARQLHandlerRestrictions(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret.nt")
+	if err := os.WriteFile(secret, []byte("<http://example.org/s> <http://example.org/p> \"secret\" .\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var remoteRequests int
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteRequests++
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	defer remote.Close()
+
+	loadGraph := "LOAD <file://" + filepath.ToSlash(secret) + "> INTO GRAPH <http://example.org/x>"
+	tests := []struct {
+		name   string
+		h      func(h *SPARQLHandler)
+		query  bool
+		text   string
+		status int
+		resp   string
+		quads  int
+	}{
+		{
+			"LOAD of a local file", nil, false, loadGraph,
+			403, "LOAD is not allowed by this endpoint\n", 3,
+		},
+		{
+			"LOAD of a path", nil, false, "LOAD SILENT <" + filepath.ToSlash(secret) + ">",
+			403, "LOAD is not allowed by this endpoint\n", 3,
+		},
+		{
+			"LOAD with a loader",
+			func(h *SPARQLHandler) {
+				h.Load = func(source IRI) (io.ReadCloser, Format, error) {
+					if source.str != "http://example.org/doc" {
+						return nil, 0, errors.New("unknown document")
+					}
+					return ioutil.NopCloser(strings.NewReader(`<http://example.org/d> <http://example.org/p> "d" .`)), NTriples, nil
+				}
+			},
+			// The loader is used for file: IRIs too.
+			false, "LOAD <http://example.org/doc> INTO GRAPH <http://example.org/x> ; " + loadGraph,
+			500, "cannot load <file://" + filepath.ToSlash(secret) + ">: unknown document\n", 4,
+		},
+		{
+			"read-only", func(h *SPARQLHandler) { h.ReadOnly = true }, false,
+			`INSERT DATA { <http://example.org/d> <http://example.org/p> "d" }`,
+			403, "updates are not allowed by this endpoint\n", 3,
+		},
+		{
+			"SERVICE", nil, true,
+			"SELECT * { SERVICE <" + remote.URL + "> { ?s ?p ?o } }",
+			500, "SERVICE <" + remote.URL + ">: SERVICE is not allowed by this endpoint\n", 3,
+		},
+		{
+			"SERVICE in an update", nil, false,
+			"INSERT { ?s ?p ?o } WHERE { SERVICE <" + remote.URL + "> { ?s ?p ?o } }",
+			500, "SERVICE <" + remote.URL + ">: SERVICE is not allowed by this endpoint\n", 3,
+		},
+		{
+			"SERVICE SILENT", nil, true,
+			"SELECT ?o { <http://example.org/a> ?p ?o SERVICE SILENT <" + remote.URL + "> { ?s ?p ?o } }",
+			200, "?o\n\"a\"\n", 3,
+		},
+		{
+			"request too large", func(h *SPARQLHandler) { h.MaxRequestSize = 16 }, true,
+			"SELECT * { ?s ?p ?o }",
+			413, "http: request body too large\n", 3,
+		},
+	}
+	for _, test := range tests {
+		h := newTestSPARQLHandler(t)
+		if test.h != nil {
+			test.h(h)
+		}
+		contentType := "application/sparql-update"
+		if test.query {
+			contentType = "application/sparql-query"
+		}
+		req := httptest.NewRequest("POST", "/sparql", strings.NewReader(test.text))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Accept", "text/tab-separated-values")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != test.status || rec.Body.String() != test.resp {
+			t.Errorf("%s: got %d %q, want %d %q", test.name, rec.Code, rec.Body, test.status, test.resp)
+		}
+		if n := len(h.Dataset.Quads()); n != test.quads {
+			t.Errorf("%s: %d quads in the dataset, want %d", test.name, n, test.quads)
+		}
+	}
+	if remoteRequests != 0 {
+		t.Errorf("%d requests sent to the SERVICE endpoint, want 0", remoteRequests)
+	}
+
+	// The requests of SERVICE patterns are canceled with the request.
+	h := newTestSPARQLHandler(t)
+	h.EvalOptions.ResolveService = func(endpoint IRI) (*SPARQLClient, error) { return NewSPARQLClient(endpoint.str), nil }
+	ctx, cancel := stdcontext.WithCancel(stdcontext.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/sparql?query="+url.QueryEscape("SELECT * { SERVICE <"+remote.URL+"> { ?s ?p ?o } }"), nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 500 || !strings.Contains(rec.Body.String(), "context canceled") || remoteRequests != 0 {
+		t.Errorf("SERVICE of a canceled request: got %d %q, %d requests", rec.Code, rec.Body, remoteRequests)
+	}
+}
@@ -0,0 +1,481 @@
+package rdf
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ResultsFormat is a serialization format of SPARQL query results.
+type ResultsFormat int
+
+// Supported formats of SPARQL query results.
+const (
+	ResultsJSON ResultsFormat = iota // SPARQL 1.1 Query Results JSON Format
+	ResultsXML                       // SPARQL Query Results XML Format
+	ResultsCSV                       // SPARQL 1.1 Query Results CSV Format
+	ResultsTSV                       // SPARQL 1.1 Query Results TSV Format
+)
+
+// sparqlResultsNS is the namespace of the XML results format.
+const sparqlResultsNS = "http://www.w3.org/2005/sparql-results#"
+
+// ResultsDecoder parses the results of a SELECT or ASK query, in one of the
+// formats JSON, XML, CSV and TSV.
+//
+// The solutions of a SELECT query are streamed: use Decode to decode a
+// single solution at a time, or DecodeAll to read all the results in one go.
+//
+// JSON, XML and TSV keep the datatypes and language tags of literals. The
+// CSV format does not; its values are decoded as blank nodes if they start
+// with "_:", as IRIs if they are absolute IRIs, and else as plain string
+// literals. Boolean results are not defined for CSV and TSV.
+type ResultsDecoder struct {
+	format ResultsFormat
+	line   int        // line number of CSV and TSV results
+	vars   []string   // variables of the head
+	head   bool       // true when the head has been read
+	bool   *bool      // result of an ASK query, if read
+	buf    []Solution // solutions read ahead of the head
+
+	json      *json.Decoder
+	jsonState int
+	xml       *xml.Decoder
+	csv       *csv.Reader
+	tsv       *bufio.Reader
+}
+
+// NewResultsDecoder returns a new ResultsDecoder, reading results from the
+// given io.Reader in the given format.
+func NewResultsDecoder(r io.Reader, f ResultsFormat) *ResultsDecoder {
+	d := &ResultsDecoder{format: f}
+	switch f {
+	case ResultsJSON:
+		d.json = json.NewDecoder(r)
+	case ResultsXML:
+		d.xml = xml.NewDecoder(r)
+	case ResultsCSV:
+		d.csv = csv.NewReader(r)
+		d.csv.FieldsPerRecord = -1
+	case ResultsTSV:
+		d.tsv = bufio.NewReader(r)
+	default:
+		panic(fmt.Errorf("Decoder for results format %v not implemented", f))
+	}
+	return d
+}
+
+// Vars returns the variables of the results, from their head. It returns
+// nil for the results of an ASK query.
+func (d *ResultsDecoder) Vars() ([]string, error) {
+	for !d.head {
+		sol, err := d.read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sol != nil {
+			d.buf = append(d.buf, sol)
+		}
+	}
+	return d.vars, nil
+}
+
+// Decode returns the next solution. It returns io.EOF when all the
+// solutions have been read.
+func (d *ResultsDecoder) Decode() (Solution, error) {
+	if len(d.buf) > 0 {
+		sol := d.buf[0]
+		d.buf = d.buf[1:]
+		return sol, nil
+	}
+	for {
+		sol, err := d.read()
+		if sol != nil || err != nil {
+			return sol, err
+		}
+	}
+}
+
+// DecodeAll returns all the results; the solutions of a SELECT query, or
+// the boolean of an ASK query.
+func (d *ResultsDecoder) DecodeAll() (*Results, error) {
+	res := &Results{Form: QuerySelect}
+	for {
+		sol, err := d.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		res.Solutions = append(res.Solutions, sol)
+	}
+	if d.bool != nil {
+		res.Form = QueryAsk
+		res.Boolean = *d.bool
+		res.Solutions = nil
+		return res, nil
+	}
+	res.Vars = d.vars
+	return res, nil
+}
+
+// read reads the results until the next solution, which it returns, or
+// until the end of the head, in which case it returns a nil solution.
+func (d *ResultsDecoder) read() (Solution, error) {
+	switch d.format {
+	case ResultsJSON:
+		return d.readJSON()
+	case ResultsXML:
+		return d.readXML()
+	case ResultsCSV:
+		return d.readCSV()
+	}
+	return d.readTSV()
+}
+
+// JSON:
+
+// Positions of the JSON decoder in the document.
+const (
+	jsonStart    = iota // before the document
+	jsonTop             // in the top level object
+	jsonResults         // in the "results" object
+	jsonBindings        // in the "bindings" array
+	jsonEnd             // after the document
+)
+
+// jsonTerm is an RDF term in the JSON results format.
+type jsonTerm struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Lang     string `json:"xml:lang,omitempty"`
+	Datatype string `json:"datatype,omitempty"`
+}
+
+func (d *ResultsDecoder) readJSON() (Solution, error) {
+	for {
+		switch d.jsonState {
+		case jsonStart:
+			if err := d.expectDelim('{'); err != nil {
+				return nil, err
+			}
+			d.jsonState = jsonTop
+		case jsonTop:
+			if !d.json.More() {
+				if err := d.expectDelim('}'); err != nil {
+					return nil, err
+				}
+				d.jsonState = jsonEnd
+				continue
+			}
+			key, err := d.jsonKey()
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "head":
+				var head struct {
+					Vars []string `json:"vars"`
+				}
+				if err := d.json.Decode(&head); err != nil {
+					return nil, err
+				}
+				d.vars, d.head = head.Vars, true
+				return nil, nil
+			case "results":
+				if err := d.expectDelim('{'); err != nil {
+					return nil, err
+				}
+				d.jsonState = jsonResults
+			case "boolean":
+				var b bool
+				if err := d.json.Decode(&b); err != nil {
+					return nil, err
+				}
+				d.bool = &b
+			default:
+				var skip json.RawMessage
+				if err := d.json.Decode(&skip); err != nil {
+					return nil, err
+				}
+			}
+		case jsonResults:
+			if !d.json.More() {
+				if err := d.expectDelim('}'); err != nil {
+					return nil, err
+				}
+				d.jsonState = jsonTop
+				continue
+			}
+			key, err := d.jsonKey()
+			if err != nil {
+				return nil, err
+			}
+			if key != "bindings" {
+				var skip json.RawMessage
+				if err := d.json.Decode(&skip); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if err := d.expectDelim('['); err != nil {
+				return nil, err
+			}
+			d.jsonState = jsonBindings
+		case jsonBindings:
+			if !d.json.More() {
+				if err := d.expectDelim(']'); err != nil {
+					return nil, err
+				}
+				d.jsonState = jsonResults
+				continue
+			}
+			var binding map[string]jsonTerm
+			if err := d.json.Decode(&binding); err != nil {
+				return nil, err
+			}
+			sol := make(Solution, len(binding))
+			for v, jt := range binding {
+				t, err := jt.term()
+				if err != nil {
+					return nil, err
+				}
+				sol[v] = t
+			}
+			return sol, nil
+		case jsonEnd:
+			d.head = true
+			return nil, io.EOF
+		}
+	}
+}
+
+// expectDelim reads the next JSON token, which must be the given delimiter.
+func (d *ResultsDecoder) expectDelim(delim json.Delim) error {
+	t, err := d.json.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("unexpected %v, expected '%v'", t, delim)
+	}
+	return nil
+}
+
+// jsonKey reads the next key of a JSON object.
+func (d *ResultsDecoder) jsonKey() (string, error) {
+	t, err := d.json.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := t.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected %v, expected object key", t)
+	}
+	return key, nil
+}
+
+func (jt jsonTerm) term() (Term, error) {
+	switch jt.Type {
+	case "uri":
+		return IRI{str: jt.Value}, nil
+	case "bnode":
+		return Blank{id: "_:" + jt.Value}, nil
+	case "literal", "typed-literal":
+		return resultsLiteral(jt.Value, jt.Lang, jt.Datatype), nil
+	}
+	return nil, fmt.Errorf("unknown term type: %q", jt.Type)
+}
+
+// resultsLiteral returns a literal, with a language tag or a datatype.
+func resultsLiteral(value, lang, datatype string) Literal {
+	switch {
+	case lang != "":
+		return Literal{str: value, lang: lang, DataType: rdfLangString}
+	case datatype != "":
+		return Literal{str: value, DataType: IRI{str: datatype}}
+	}
+	return Literal{str: value, DataType: xsdString}
+}
+
+// XML:
+
+// xmlBinding is a binding of the XML results format.
+type xmlBinding struct {
+	Name    string  `xml:"name,attr"`
+	URI     *string `xml:"uri"`
+	BNode   *string `xml:"bnode"`
+	Literal *struct {
+		Value    string `xml:",chardata"`
+		Lang     string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+		Datatype string `xml:"datatype,attr"`
+	} `xml:"literal"`
+}
+
+func (d *ResultsDecoder) readXML() (Solution, error) {
+	for {
+		tok, err := d.xml.Token()
+		if err == io.EOF {
+			d.head = true
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "head":
+			var head struct {
+				Variables []struct {
+					Name string `xml:"name,attr"`
+				} `xml:"variable"`
+			}
+			if err := d.xml.DecodeElement(&head, &start); err != nil {
+				return nil, err
+			}
+			d.vars = []string{}
+			for _, v := range head.Variables {
+				d.vars = append(d.vars, v.Name)
+			}
+			d.head = true
+			return nil, nil
+		case "result":
+			var result struct {
+				Bindings []xmlBinding `xml:"binding"`
+			}
+			if err := d.xml.DecodeElement(&result, &start); err != nil {
+				return nil, err
+			}
+			sol := make(Solution, len(result.Bindings))
+			for _, b := range result.Bindings {
+				switch {
+				case b.URI != nil:
+					sol[b.Name] = IRI{str: strings.TrimSpace(*b.URI)}
+				case b.BNode != nil:
+					sol[b.Name] = Blank{id: "_:" + strings.TrimSpace(*b.BNode)}
+				case b.Literal != nil:
+					sol[b.Name] = resultsLiteral(b.Literal.Value, b.Literal.Lang, b.Literal.Datatype)
+				default:
+					return nil, fmt.Errorf("binding of %s without a term", b.Name)
+				}
+			}
+			return sol, nil
+		case "boolean":
+			var s string
+			if err := d.xml.DecodeElement(&s, &start); err != nil {
+				return nil, err
+			}
+			b := strings.TrimSpace(s) == "true"
+			d.bool = &b
+		}
+	}
+}
+
+// CSV and TSV:
+
+func (d *ResultsDecoder) readCSV() (Solution, error) {
+	record, err := d.csv.Read()
+	if err == io.EOF {
+		d.head = true
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.line++
+	if !d.head {
+		d.vars, d.head = record, true
+		return nil, nil
+	}
+	if len(record) != len(d.vars) {
+		return nil, fmt.Errorf("line %d: %d values, want %d", d.line, len(record), len(d.vars))
+	}
+	sol := make(Solution, len(record))
+	for i, s := range record {
+		switch {
+		case s == "":
+			continue
+		case strings.HasPrefix(s, "_:"):
+			sol[d.vars[i]] = Blank{id: s}
+		case isAbsoluteIRI(s) && !strings.ContainsAny(s, " \t\r\n\"<>"):
+			sol[d.vars[i]] = IRI{str: s}
+		default:
+			sol[d.vars[i]] = Literal{str: s, DataType: xsdString}
+		}
+	}
+	return sol, nil
+}
+
+func (d *ResultsDecoder) readTSV() (Solution, error) {
+	line, err := d.tsv.ReadString('\n')
+	if err == io.EOF && line == "" {
+		d.head = true
+		return nil, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	d.line++
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if !d.head {
+		d.vars, d.head = []string{}, true
+		if line != "" {
+			for _, v := range strings.Split(line, "\t") {
+				if !strings.HasPrefix(v, "?") && !strings.HasPrefix(v, "$") {
+					return nil, fmt.Errorf("line %d: bad variable: %q", d.line, v)
+				}
+				d.vars = append(d.vars, v[1:])
+			}
+		}
+		return nil, nil
+	}
+	sol := make(Solution)
+	if len(d.vars) == 0 && line == "" {
+		return sol, nil
+	}
+	values := strings.Split(line, "\t")
+	if len(values) != len(d.vars) {
+		return nil, fmt.Errorf("line %d: %d values, want %d", d.line, len(values), len(d.vars))
+	}
+	for i, s := range values {
+		if s == "" {
+			continue
+		}
+		t, err := parseResultsTerm(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", d.line, err)
+		}
+		sol[d.vars[i]] = t
+	}
+	return sol, nil
+}
+
+// parseResultsTerm parses an RDF term in SPARQL syntax, as in the TSV
+// format.
+func parseResultsTerm(s string) (t Term, err error) {
+	p := newSPARQLParser(s)
+	defer p.recover(&err)
+	if tok := p.peek(); tok.typ == tokenBNode {
+		p.next()
+		t = Blank{id: tok.text}
+	} else if l, ok := p.parseLiteral(); ok {
+		t = l
+	} else {
+		t = p.parseIRI()
+	}
+	if tok := p.next(); tok.typ != tokenEOF {
+		p.unexpected(tok, "end of term")
+	}
+	return t, nil
+}
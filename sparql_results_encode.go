@@ -0,0 +1,283 @@
+package rdf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ResultsEncoder serializes the results of a SELECT or ASK query, in one of
+// the formats JSON, XML, CSV and TSV.
+//
+// The results of a SELECT query are encoded by EncodeVars, followed by one
+// call to Encode per solution; the results of an ASK query by
+// EncodeBoolean. Close must be called to finish the document.
+type ResultsEncoder struct {
+	w      *errWriter
+	csv    *csv.Writer
+	format ResultsFormat
+	vars   []string
+	head   bool // true when the head has been encoded
+	n      int  // number of encoded solutions
+}
+
+// NewResultsEncoder returns a new ResultsEncoder, writing results to the
+// given io.Writer in the given format.
+func NewResultsEncoder(w io.Writer, f ResultsFormat) *ResultsEncoder {
+	switch f {
+	case ResultsJSON, ResultsXML, ResultsCSV, ResultsTSV:
+	default:
+		panic(fmt.Errorf("Encoder for results format %v not implemented", f))
+	}
+	e := &ResultsEncoder{w: &errWriter{w: bufio.NewWriter(w)}, format: f}
+	if f == ResultsCSV {
+		e.csv = csv.NewWriter(e.w.w)
+		e.csv.UseCRLF = true
+	}
+	return e
+}
+
+// EncodeVars encodes the head of the results of a SELECT query, with the
+// given projected variables.
+func (e *ResultsEncoder) EncodeVars(vars []string) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if e.head {
+		return errors.New("results head already encoded")
+	}
+	e.vars, e.head = vars, true
+	switch e.format {
+	case ResultsJSON:
+		e.w.write([]byte(`{"head":{"vars":[`))
+		for i, v := range vars {
+			if i > 0 {
+				e.w.write([]byte(","))
+			}
+			e.w.write(marshalJSON(v))
+		}
+		e.w.write([]byte("]},\n\"results\":{\"bindings\":["))
+	case ResultsXML:
+		e.w.write([]byte("<?xml version=\"1.0\"?>\n<sparql xmlns=\"" + sparqlResultsNS + "\">\n\t<head>\n"))
+		for _, v := range vars {
+			e.w.write([]byte("\t\t<variable name=\"" + xmlAttrEscaper.Replace(v) + "\"/>\n"))
+		}
+		e.w.write([]byte("\t</head>\n\t<results>\n"))
+	case ResultsCSV:
+		e.csv.Write(vars)
+		return e.csv.Error()
+	case ResultsTSV:
+		for i, v := range vars {
+			if i > 0 {
+				e.w.write([]byte("\t"))
+			}
+			e.w.write([]byte("?" + v))
+		}
+		e.w.write([]byte("\n"))
+	}
+	return e.w.err
+}
+
+// Encode encodes a solution of a SELECT query. Only the variables of the
+// head are encoded; unbound variables are left out.
+func (e *ResultsEncoder) Encode(sol Solution) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if !e.head {
+		return errors.New("results head not encoded")
+	}
+	if e.n < 0 {
+		return errors.New("cannot encode solutions of boolean results")
+	}
+	e.n++
+	switch e.format {
+	case ResultsJSON:
+		if e.n > 1 {
+			e.w.write([]byte(","))
+		}
+		e.w.write([]byte("\n{"))
+		i := 0
+		for _, v := range e.vars {
+			t, ok := sol[v]
+			if !ok || t == nil {
+				continue
+			}
+			if i > 0 {
+				e.w.write([]byte(","))
+			}
+			i++
+			e.w.write(marshalJSON(v))
+			e.w.write([]byte(":"))
+			e.w.write(marshalJSON(newJSONTerm(t)))
+		}
+		e.w.write([]byte("}"))
+	case ResultsXML:
+		e.w.write([]byte("\t\t<result>\n"))
+		for _, v := range e.vars {
+			t, ok := sol[v]
+			if !ok || t == nil {
+				continue
+			}
+			e.w.write([]byte("\t\t\t<binding name=\"" + xmlAttrEscaper.Replace(v) + "\">"))
+			e.w.write([]byte(xmlResultsTerm(t)))
+			e.w.write([]byte("</binding>\n"))
+		}
+		e.w.write([]byte("\t\t</result>\n"))
+	case ResultsCSV:
+		record := make([]string, len(e.vars))
+		for i, v := range e.vars {
+			switch t := sol[v].(type) {
+			case IRI:
+				record[i] = t.str
+			case Literal:
+				record[i] = t.str
+			case Blank:
+				record[i] = t.id
+			}
+		}
+		e.csv.Write(record)
+		return e.csv.Error()
+	case ResultsTSV:
+		for i, v := range e.vars {
+			if i > 0 {
+				e.w.write([]byte("\t"))
+			}
+			if t, ok := sol[v]; ok && t != nil {
+				e.w.write([]byte(strings.Replace(t.Serialize(NTriples), "\t", `\t`, -1)))
+			}
+		}
+		e.w.write([]byte("\n"))
+	}
+	return e.w.err
+}
+
+// EncodeBoolean encodes the result of an ASK query. Boolean results are
+// not defined for the CSV and TSV formats.
+func (e *ResultsEncoder) EncodeBoolean(b bool) error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if e.head {
+		return errors.New("results head already encoded")
+	}
+	e.head, e.n = true, -1
+	switch e.format {
+	case ResultsJSON:
+		fmt.Fprintf(e.w.w, "{\"head\":{},\n\"boolean\":%t", b)
+	case ResultsXML:
+		fmt.Fprintf(e.w.w, "<?xml version=\"1.0\"?>\n<sparql xmlns=\"%s\">\n\t<head/>\n\t<boolean>%t</boolean>\n", sparqlResultsNS, b)
+	default:
+		return fmt.Errorf("boolean results cannot be encoded in format %v", e.format)
+	}
+	return e.w.err
+}
+
+// EncodeResults encodes the results of a SELECT or ASK query.
+func (e *ResultsEncoder) EncodeResults(res *Results) error {
+	switch res.Form {
+	case QuerySelect:
+		if err := e.EncodeVars(res.Vars); err != nil {
+			return err
+		}
+		for _, sol := range res.Solutions {
+			if err := e.Encode(sol); err != nil {
+				return err
+			}
+		}
+		return nil
+	case QueryAsk:
+		return e.EncodeBoolean(res.Boolean)
+	}
+	return fmt.Errorf("cannot encode results of a %v query", res.Form)
+}
+
+// Close finishes the document and flushes the underlying writer. The
+// encoder cannot be used after it is closed.
+func (e *ResultsEncoder) Close() error {
+	if e.w == nil {
+		return ErrEncoderClosed
+	}
+	if !e.head {
+		if err := e.EncodeVars([]string{}); err != nil {
+			return err
+		}
+	}
+	switch e.format {
+	case ResultsJSON:
+		if e.n >= 0 {
+			if e.n > 0 {
+				e.w.write([]byte("\n"))
+			}
+			e.w.write([]byte("]}"))
+		}
+		e.w.write([]byte("}\n"))
+	case ResultsXML:
+		if e.n >= 0 {
+			e.w.write([]byte("\t</results>\n"))
+		}
+		e.w.write([]byte("</sparql>\n"))
+	case ResultsCSV:
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if e.w.err != nil {
+		return e.w.err
+	}
+	err := e.w.w.Flush()
+	e.w = nil
+	return err
+}
+
+// newJSONTerm returns the JSON results representation of an RDF term.
+func newJSONTerm(t Term) jsonTerm {
+	switch t := t.(type) {
+	case IRI:
+		return jsonTerm{Type: "uri", Value: t.str}
+	case Blank:
+		return jsonTerm{Type: "bnode", Value: t.String()}
+	case Literal:
+		jt := jsonTerm{Type: "literal", Value: t.str, Lang: t.lang}
+		if t.lang == "" && t.DataType != (IRI{}) && t.DataType != xsdString {
+			jt.Datatype = t.DataType.String()
+		}
+		return jt
+	}
+	return jsonTerm{}
+}
+
+// marshalJSON returns the JSON encoding of v, without escaping of HTML
+// characters.
+func marshalJSON(v interface{}) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// xmlResultsTerm returns the XML results representation of an RDF term.
+func xmlResultsTerm(t Term) string {
+	switch t := t.(type) {
+	case IRI:
+		return "<uri>" + xmlTextEscaper.Replace(t.str) + "</uri>"
+	case Blank:
+		return "<bnode>" + xmlTextEscaper.Replace(t.String()) + "</bnode>"
+	case Literal:
+		switch {
+		case t.lang != "":
+			return "<literal xml:lang=\"" + xmlAttrEscaper.Replace(t.lang) + "\">" + xmlTextEscaper.Replace(t.str) + "</literal>"
+		case t.DataType != (IRI{}) && t.DataType != xsdString:
+			return "<literal datatype=\"" + xmlAttrEscaper.Replace(t.DataType.String()) + "\">" + xmlTextEscaper.Replace(t.str) + "</literal>"
+		}
+		return "<literal>" + xmlTextEscaper.Replace(t.str) + "</literal>"
+	}
+	return ""
+}
@@ -0,0 +1,219 @@
+package rdf
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testResults() *Results {
+	return &Results{
+		Form: QuerySelect,
+		Vars: []string{"s", "o"},
+		Solutions: []Solution{
+			{"s": IRI{str: "http://example.org/a"}, "o": Literal{str: "plain", DataType: xsdString}},
+			{"s": Blank{id: "_:b0"}, "o": Literal{str: "chat", lang: "fr", DataType: rdfLangString}},
+			{"s": IRI{str: "http://example.org/b"}, "o": Literal{str: "42", DataType: xsdInteger}},
+			{"o": Literal{str: "tab\tquote\" new\nline <&>", DataType: xsdString}},
+			{"s": IRI{str: "http://example.org/c"}, "o": IRI{str: "http://example.org/d?x=1&y=2"}},
+			{},
+		},
+	}
+}
+
+func encodeResults(t *testing.T, res *Results, f ResultsFormat) string {
+	var buf bytes.Buffer
+	enc := NewResultsEncoder(&buf, f)
+	if err := enc.EncodeResults(res); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	ask := &Results{Form: QueryAsk, Boolean: true}
+	for _, f := range []ResultsFormat{ResultsJSON, ResultsXML, ResultsTSV} {
+		for _, want := range []*Results{testResults(), ask, {Form: QuerySelect, Vars: []string{}}} {
+			if f == ResultsTSV && want.Form == QueryAsk {
+				continue
+			}
+			s := encodeResults(t, want, f)
+			got, err := NewResultsDecoder(strings.NewReader(s), f).DecodeAll()
+			if err != nil {
+				t.Errorf("format %v: decoding %q: %v", f, s, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("format %v: round trip of %q:\ngot  %#v\nwant %#v", f, s, got, want)
+			}
+		}
+	}
+}
+
+func TestResultsCSV(t *testing.T) {
+	s := encodeResults(t, testResults(), ResultsCSV)
+	want := "s,o\r\n" +
+		"http://example.org/a,plain\r\n" +
+		"_:b0,chat\r\n" +
+		"http://example.org/b,42\r\n" +
+		",\"tab\tquote\"\" new\r\nline <&>\"\r\n" +
+		"http://example.org/c,http://example.org/d?x=1&y=2\r\n" +
+		",\r\n"
+	if s != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", s, want)
+	}
+
+	// Datatypes and language tags are lost.
+	res, err := NewResultsDecoder(strings.NewReader(s), ResultsCSV).DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := solutionsString(res, true)
+	wantSols := `s=<http://example.org/a> o="plain"
+s=_:b0 o="chat"
+s=<http://example.org/b> o="42"
+o="tab	quote\" new\nline <&>"
+s=<http://example.org/c> o=<http://example.org/d?x=1&y=2>
+`
+	if got != wantSols {
+		t.Errorf("got:\n%s\nwant:\n%s", got, wantSols)
+	}
+
+	if err := NewResultsEncoder(io.Discard, ResultsCSV).EncodeBoolean(true); err == nil {
+		t.Error("encoding a boolean as CSV: want error")
+	}
+}
+
+func TestResultsTSV(t *testing.T) {
+	s := encodeResults(t, testResults(), ResultsTSV)
+	want := "?s\t?o\n" +
+		"<http://example.org/a>\t\"plain\"\n" +
+		"_:b0\t\"chat\"@fr\n" +
+		"<http://example.org/b>\t\"42\"^^<http://www.w3.org/2001/XMLSchema#integer>\n" +
+		"\t\"tab\\tquote\\\" new\\nline <&>\"\n" +
+		"<http://example.org/c>\t<http://example.org/d?x=1&y=2>\n" +
+		"\t\n"
+	if s != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", s, want)
+	}
+}
+
+func TestDecodeResults(t *testing.T) {
+	tests := []struct {
+		format ResultsFormat
+		input  string
+		want   string
+	}{
+		{ResultsJSON, `{
+  "head": {"vars": ["x", "y"], "link": ["http://example.org/meta"]},
+  "results": {
+    "bindings": [
+      {"x": {"type": "bnode", "value": "r1"}, "y": {"type": "literal", "value": "1", "datatype": "http://www.w3.org/2001/XMLSchema#integer"}},
+      {"x": {"type": "uri", "value": "http://example.org/a"}, "y": {"type": "literal", "value": "Bob", "xml:lang": "en"}},
+      {"y": {"type": "typed-literal", "value": "true", "datatype": "http://www.w3.org/2001/XMLSchema#boolean"}}
+    ]
+  }
+}`, `x=_:r1 y=1
+x=<http://example.org/a> y="Bob"@en
+y=true`},
+		// Results ahead of the head.
+		{ResultsJSON, `{"results": {"bindings": [{"x": {"type": "literal", "value": "a"}}]}, "head": {"vars": ["x"]}}`,
+			`x="a"`},
+		{ResultsXML, `<?xml version="1.0"?>
+<sparql xmlns="http://www.w3.org/2005/sparql-results#">
+  <head>
+    <variable name="x"/>
+    <variable name="y"/>
+    <link href="meta.rq"/>
+  </head>
+  <results>
+    <result>
+      <binding name="x"><bnode>r1</bnode></binding>
+      <binding name="y"><literal datatype="http://www.w3.org/2001/XMLSchema#integer">1</literal></binding>
+    </result>
+    <result>
+      <binding name="x"><uri>http://example.org/a</uri></binding>
+      <binding name="y"><literal xml:lang="en">Bob</literal></binding>
+    </result>
+    <result>
+      <binding name="y"><literal>a &amp; b</literal></binding>
+    </result>
+  </results>
+</sparql>`, `x=_:r1 y=1
+x=<http://example.org/a> y="Bob"@en
+y="a & b"`},
+		{ResultsTSV, "?x\t?y\n_:r1\t1\n<http://example.org/a>\t\"Bob\"@en\n\t\"a\\tb\"\n",
+			`x=_:r1 y=1
+x=<http://example.org/a> y="Bob"@en
+y="a	b"`},
+		{ResultsCSV, "x,y\r\n_:r1,1\r\nhttp://example.org/a,Bob\r\n,not an <iri>\r\n",
+			`x=_:r1 y="1"
+x=<http://example.org/a> y="Bob"
+y="not an <iri>"`},
+	}
+	for _, test := range tests {
+		res, err := NewResultsDecoder(strings.NewReader(test.input), test.format).DecodeAll()
+		if err != nil {
+			t.Errorf("format %v: decoding %q: %v", test.format, test.input, err)
+			continue
+		}
+		if got := solutionsString(res, true); got != test.want {
+			t.Errorf("format %v: decoding %q:\ngot:\n%s\nwant:\n%s", test.format, test.input, got, test.want)
+		}
+	}
+}
+
+func TestDecodeResultsStream(t *testing.T) {
+	s := encodeResults(t, testResults(), ResultsJSON)
+	dec := NewResultsDecoder(strings.NewReader(s), ResultsJSON)
+	vars, err := dec.Vars()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(vars, []string{"s", "o"}) {
+		t.Errorf("got vars %v; want [s o]", vars)
+	}
+	n := 0
+	for {
+		_, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != len(testResults().Solutions) {
+		t.Errorf("decoded %d solutions; want %d", n, len(testResults().Solutions))
+	}
+}
+
+func TestDecodeResultsErrors(t *testing.T) {
+	tests := []struct {
+		format ResultsFormat
+		input  string
+		errStr string
+	}{
+		{ResultsJSON, `{"head": {"vars": ["x"]}, "results": {"bindings": [{"x": {"type": "iri", "value": "a"}}]}}`,
+			`unknown term type: "iri"`},
+		{ResultsJSON, `["head"]`, `unexpected [, expected '{'`},
+		{ResultsXML, `<sparql><results><result><binding name="x"></binding></result></results></sparql>`,
+			"binding of x without a term"},
+		{ResultsTSV, "?x\t?y\n<a>\n", "line 2: 1 values, want 2"},
+		{ResultsTSV, "x\n", `line 1: bad variable: "x"`},
+		{ResultsTSV, "?x\n<a> <b>\n", `line 2: 1:5: unexpected "b", expected end of term`},
+		{ResultsCSV, "x,y\r\na\r\n", "line 2: 1 values, want 2"},
+	}
+	for _, test := range tests {
+		_, err := NewResultsDecoder(strings.NewReader(test.input), test.format).DecodeAll()
+		if err == nil || err.Error() != test.errStr {
+			t.Errorf("format %v: decoding %q: got error %v; want %q", test.format, test.input, err, test.errStr)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package rdf
+
+import (
+	stdcontext "context"
+	"strings"
+)
+
+// defaultServiceBatchSize is the default of EvalOptions.ServiceBatchSize.
+const defaultServiceBatchSize = 100
+
+// evalService evaluates a SERVICE pattern, joined with the solutions on its
+// left. Solutions are sent to the endpoint they bind, if it is a variable.
+// The errors of a silent service are ignored, as if the service returned a
+// single solution without bindings.
+func (e *evaluator) evalService(op *OpService, left []Solution) []Solution {
+	var vars []Var
+	seen := make(map[Var]bool)
+	scopeVars(op.Pattern, func(v Var) {
+		if !seen[v] {
+			seen[v] = true
+			vars = append(vars, v)
+		}
+	})
+	size := e.opts.ServiceBatchSize
+	if size <= 0 {
+		size = defaultServiceBatchSize
+	}
+
+	// Group the solutions by endpoint, in order of appearance.
+	var sols []Solution
+	var endpoints []IRI
+	batches := make(map[IRI][]Solution)
+	for _, l := range left {
+		endpoint, ok := op.Endpoint.(IRI)
+		if v, isVar := op.Endpoint.(Var); isVar {
+			endpoint, ok = l[string(v)].(IRI)
+		}
+		if !ok {
+			if op.Silent {
+				sols = append(sols, l)
+				continue
+			}
+			e.errorf("SERVICE endpoint %s is not bound to an IRI", newSPARQLWriter(nil).term(op.Endpoint))
+		}
+		if _, ok := batches[endpoint]; !ok {
+			endpoints = append(endpoints, endpoint)
+		}
+		batches[endpoint] = append(batches[endpoint], l)
+	}
+
+	for _, endpoint := range endpoints {
+		batch := batches[endpoint]
+		for len(batch) > 0 {
+			n := size
+			if n > len(batch) {
+				n = len(batch)
+			}
+			remote, err := e.service(endpoint, op.Pattern, vars, batch[:n])
+			switch {
+			case err == nil:
+				sols = append(sols, e.join(batch[:n], remote)...)
+			case op.Silent:
+				sols = append(sols, batch[:n]...)
+			default:
+				e.errorf("SERVICE %s: %v", endpoint.Serialize(NTriples), err)
+			}
+			batch = batch[n:]
+		}
+	}
+	return sols
+}
+
+// service returns the solutions of the pattern at the endpoint, restricted
+// to the bindings of the given solutions to the variables. Blank nodes
+// cannot be sent, and are left unbound in the VALUES of the request.
+func (e *evaluator) service(endpoint IRI, pattern *GroupPattern, vars []Var, sols []Solution) ([]Solution, error) {
+	q := &Query{Form: QuerySelect, Where: pattern, Limit: -1}
+	var values []Var
+	for _, v := range vars {
+		for _, sol := range sols {
+			if sendable(sol[string(v)]) {
+				values = append(values, v)
+				break
+			}
+		}
+	}
+	if len(values) > 0 {
+		q.Values = &ValuesPattern{Vars: values}
+		rows := make(map[string]bool)
+		for _, sol := range sols {
+			row := make([]Term, len(values))
+			var key strings.Builder
+			for i, v := range values {
+				if t := sol[string(v)]; sendable(t) {
+					row[i] = t
+					key.WriteString(termKey(t))
+				}
+				key.WriteByte(0)
+			}
+			if !rows[key.String()] {
+				rows[key.String()] = true
+				q.Values.Rows = append(q.Values.Rows, row)
+			}
+		}
+	}
+
+	var client *SPARQLClient
+	if e.opts.ResolveService != nil {
+		var err error
+		if client, err = e.opts.ResolveService(endpoint); err != nil {
+			return nil, err
+		}
+	} else {
+		client = NewSPARQLClient(endpoint.str)
+	}
+	ctx := e.opts.Context
+	if ctx == nil {
+		ctx = stdcontext.Background()
+	}
+	res, err := client.Select(ctx, q.String())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	all, err := res.DecodeAll()
+	if err != nil {
+		return nil, err
+	}
+	return all.Solutions, nil
+}
+
+// sendable returns true if the term can be sent in the VALUES of a request.
+func sendable(t Term) bool {
+	switch t.(type) {
+	case IRI, Literal:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+package rdf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestService returns a server of a SPARQL endpoint with the given
+// N-Quads, and records the queries it receives.
+func newTestService(t *testing.T, data string) (*httptest.Server, *[]string) {
+	var d Dataset
+	if err := d.Load(NewQuadDecoder(strings.NewReader(data), NQuads)); err != nil {
+		t.Fatal(err)
+	}
+	h := NewSPARQLHandler(&d)
+	var mu sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		queries = append(queries, r.Form.Get("query"))
+		mu.Unlock()
+		h.ServeHTTP(w, r)
+	}))
+	return srv, &queries
+}
+
+func TestEvalService(t *testing.T) {
+	remote, queries := newTestService(t, `
+<http://example.org/alice> <http://xmlns.com/foaf/0.1/name> "Alice" .
+<http://example.org/bob> <http://xmlns.com/foaf/0.1/name> "Bob"@en .
+<http://example.org/carol> <http://xmlns.com/foaf/0.1/name> "Carol" .
+<http://example.org/dave> <http://xmlns.com/foaf/0.1/name> "Dave" .
+`)
+	defer remote.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	g := loadTestGraph(t, testSPARQLData)
+	var d Dataset
+	for _, tr := range g.Triples() {
+		d.Add(Quad{Triple: tr})
+	}
+	opts := EvalOptions{
+		ResolveService: func(endpoint IRI) (*SPARQLClient, error) {
+			switch endpoint.str {
+			case "http://remote.example.org/sparql":
+				return NewSPARQLClient(remote.URL), nil
+			case "http://down.example.org/sparql":
+				return NewSPARQLClient(down.URL), nil
+			}
+			return nil, errors.New("unknown endpoint")
+		},
+		ServiceBatchSize: 2,
+	}
+	prologue := "PREFIX : <http://example.org/>\nPREFIX foaf: <http://xmlns.com/foaf/0.1/>\n"
+	tests := []struct {
+		query    string
+		want     string
+		requests int
+	}{
+		{
+			`SELECT ?p ?name { ?p a foaf:Person SERVICE <http://remote.example.org/sparql> { ?p foaf:name ?name } }`,
+			`p=<http://example.org/alice> name="Alice"
+p=<http://example.org/bob> name="Bob"@en
+p=<http://example.org/carol> name="Carol"`,
+			2,
+		},
+		{
+			`SELECT ?name { SERVICE <http://remote.example.org/sparql> { ?p foaf:name ?name FILTER(?p != :alice) } }`,
+			`name="Bob"@en
+name="Carol"
+name="Dave"`,
+			1,
+		},
+		{
+			// Blank nodes are not sent, and match no remote term.
+			`SELECT ?city ?name { :carol :address ?a . ?a :city ?city SERVICE <http://remote.example.org/sparql> { ?a foaf:name ?name } }`,
+			``,
+			1,
+		},
+		{
+			`SELECT ?e ?p ?name { VALUES (?e ?p) { (<http://remote.example.org/sparql> :alice) (<http://down.example.org/sparql> :bob) (<http://unknown.example.org/> :carol) } SERVICE SILENT ?e { ?p foaf:name ?name } }`,
+			`e=<http://down.example.org/sparql> p=<http://example.org/bob>
+e=<http://remote.example.org/sparql> p=<http://example.org/alice> name="Alice"
+e=<http://unknown.example.org/> p=<http://example.org/carol>`,
+			1,
+		},
+		{
+			`SELECT ?p { ?p a :Robot SERVICE SILENT <http://down.example.org/sparql> { ?p foaf:name ?name } }`,
+			`p=<http://example.org/dave>`,
+			0,
+		},
+	}
+	for _, test := range tests {
+		*queries = nil
+		q, err := ParseQuery(prologue + test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		res, err := q.EvalWithOptions(&d, opts)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got := solutionsString(res, false); got != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.query, got, test.want)
+		}
+		if len(*queries) != test.requests {
+			t.Errorf("%s: got %d requests, want %d:\n%s", test.query, len(*queries), test.requests, strings.Join(*queries, "\n"))
+		}
+	}
+}
+
+func TestEvalServiceErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT * { SERVICE <http://unknown.example.org/> { ?s ?p ?o } }`, "SERVICE <http://unknown.example.org/>: unknown endpoint"},
+		{`SELECT * { SERVICE ?e { ?s ?p ?o } }`, "SERVICE endpoint ?e is not bound to an IRI"},
+		{`SELECT * { SERVICE <http://down.example.org/sparql> { ?s ?p ?o } }`, "SERVICE <http://down.example.org/sparql>: HTTP status 503 Service Unavailable: down for maintenance"},
+	}
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down for maintenance", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	opts := EvalOptions{
+		ResolveService: func(endpoint IRI) (*SPARQLClient, error) {
+			if endpoint.str == "http://down.example.org/sparql" {
+				return NewSPARQLClient(down.URL), nil
+			}
+			return nil, errors.New("unknown endpoint")
+		},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		_, err = q.EvalWithOptions(&Dataset{}, opts)
+		if err == nil || err.Error() != test.want {
+			t.Errorf("%s: got error %v, want %q", test.query, err, test.want)
+		}
+	}
+}
+
+func TestEvalBindings(t *testing.T) {
+	g := loadTestGraph(t, testSPARQLData)
+	var d Dataset
+	for _, tr := range g.Triples() {
+		d.Add(Quad{Triple: tr})
+	}
+	opts := EvalOptions{Bindings: Solution{"p": IRI{str: "http://example.org/alice"}}}
+	prologue := "PREFIX : <http://example.org/>\nPREFIX foaf: <http://xmlns.com/foaf/0.1/>\n"
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT ?p ?name { ?p foaf:name ?name }`, `p=<http://example.org/alice> name="Alice"`},
+		{`SELECT ?name { { SELECT ?name { ?p foaf:name ?name } } }`, `name="Alice"`},
+		{`SELECT ?b { BIND (BOUND(?p) AS ?b) }`, `b=true`},
+		{`SELECT ?x { ?p foaf:knows ?x }`, "x=<http://example.org/bob>\nx=<http://example.org/carol>"},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(prologue + test.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", test.query, err)
+		}
+		res, err := q.EvalWithOptions(&d, opts)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got := solutionsString(res, false); got != test.want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.query, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package rdf
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			`PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+SELECT ?name ?mbox WHERE { ?x foaf:name ?name ; foaf:mbox ?mbox }`,
+			`PREFIX foaf: <http://xmlns.com/foaf/0.1/>
+SELECT ?name ?mbox
+WHERE {
+	?x foaf:name ?name .
+	?x foaf:mbox ?mbox .
+}
+`,
+		},
+		{
+			`BASE <http://example.org/>
+PREFIX : <ns#>
+select distinct * { ?s a :C , <D> ; :p "x"@en, 'y', 1, -2.5, 1e3, true, "z"^^:T . }
+order by desc(?s) ?o limit 5`,
+			`BASE <http://example.org/>
+PREFIX : <http://example.org/ns#>
+SELECT DISTINCT *
+WHERE {
+	?s a :C .
+	?s a <http://example.org/D> .
+	?s :p "x"@en .
+	?s :p "y" .
+	?s :p 1 .
+	?s :p -2.5 .
+	?s :p 1e3 .
+	?s :p true .
+	?s :p "z"^^:T .
+}
+ORDER BY DESC(?s) ?o
+LIMIT 5
+`,
+		},
+		{
+			`SELECT * { ?s <p> [ <q> ( 1 ?x ) ] }`,
+			`SELECT *
+WHERE {
+	_:_b2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> 1 .
+	_:_b2 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> _:_b3 .
+	_:_b3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#first> ?x .
+	_:_b3 <http://www.w3.org/1999/02/22-rdf-syntax-ns#rest> <http://www.w3.org/1999/02/22-rdf-syntax-ns#nil> .
+	_:_b1 <q> _:_b2 .
+	?s <p> _:_b1 .
+}
+`,
+		},
+		{
+			`PREFIX ex: <http://example.org/>
+SELECT ?x (COUNT(DISTINCT ?y) AS ?n) (GROUP_CONCAT(?y; separator=",") AS ?ys)
+FROM ex:g FROM NAMED ex:h
+WHERE {
+	?x ex:p ?y OPTIONAL { ?y ex:q ?z FILTER(?z > 1) }
+	{ ?x ex:r ?y } UNION { ?x ex:s ?y } UNION { GRAPH ?g { ?x ex:t ?y } }
+	MINUS { ?x ex:u ex:v }
+	BIND(STRLEN(STR(?y)) * 2 AS ?len)
+	FILTER (?len IN (2, 4) && !BOUND(?z) || NOT EXISTS { ?x ex:w ?y })
+}
+GROUP BY ?x HAVING (COUNT(DISTINCT ?y) > 1) OFFSET 2 LIMIT 3`,
+			`PREFIX ex: <http://example.org/>
+SELECT ?x (COUNT(DISTINCT ?y) AS ?n) (GROUP_CONCAT(?y; SEPARATOR=",") AS ?ys)
+FROM ex:g
+FROM NAMED ex:h
+WHERE {
+	?x ex:p ?y .
+	OPTIONAL {
+		?y ex:q ?z .
+		FILTER (?z > 1)
+	}
+	{
+		?x ex:r ?y .
+	}
+	UNION
+	{
+		?x ex:s ?y .
+	}
+	UNION
+	{
+		GRAPH ?g {
+			?x ex:t ?y .
+		}
+	}
+	MINUS {
+		?x ex:u ex:v .
+	}
+	BIND(STRLEN(STR(?y)) * 2 AS ?len)
+	FILTER (((?len IN (2, 4)) && !BOUND(?z)) || NOT EXISTS { ?x ex:w ?y . })
+}
+GROUP BY ?x
+HAVING (COUNT(DISTINCT ?y) > 1)
+LIMIT 3
+OFFSET 2
+`,
+		},
+		{
+			`SELECT * { ?s <p> ?o . SERVICE <http://example.org/sparql> { ?o <q> ?x } service silent ?e { ?x <r> 1 } }`,
+			`SELECT *
+WHERE {
+	?s <p> ?o .
+	SERVICE <http://example.org/sparql> {
+		?o <q> ?x .
+	}
+	SERVICE SILENT ?e {
+		?x <r> 1 .
+	}
+}
+`,
+		},
+		{
+			`CONSTRUCT { ?s <p> ?o } WHERE { { SELECT ?s (MAX(?v) AS ?o) { ?s <q> ?v } GROUP BY ?s } }`,
+			`CONSTRUCT {
+	?s <p> ?o .
+}
+WHERE {
+	{
+		SELECT ?s (MAX(?v) AS ?o)
+		WHERE {
+			?s <q> ?v .
+		}
+		GROUP BY ?s
+	}
+}
+`,
+		},
+		{
+			`CONSTRUCT WHERE { ?s <p> ?o }`,
+			`CONSTRUCT {
+	?s <p> ?o .
+}
+WHERE {
+	?s <p> ?o .
+}
+`,
+		},
+		{
+			`ASK { VALUES (?x ?y) { (1 UNDEF) (UNDEF "a") } ?x <p> ?y }`,
+			`ASK
+WHERE {
+	VALUES (?x ?y) {
+		(1 UNDEF)
+		(UNDEF "a")
+	}
+	?x <p> ?y .
+}
+`,
+		},
+		{
+			`DESCRIBE <http://example.org/a> ?x { ?x <p> <http://example.org/a> } VALUES ?x { <b> }`,
+			`DESCRIBE <http://example.org/a> ?x
+WHERE {
+	?x <p> <http://example.org/a> .
+}
+VALUES (?x) {
+	(<b>)
+}
+`,
+		},
+		{
+			`DESCRIBE *`,
+			"DESCRIBE *\n",
+		},
+		{
+			`SELECT * { ?s<p>?o FILTER(?o<=5&&?o>=5||?o<1&&?o>0) }`,
+			`SELECT *
+WHERE {
+	?s <p> ?o .
+	FILTER (((?o <= 5) && (?o >= 5)) || ((?o < 1) && (?o > 0)))
+}
+`,
+		},
+		{
+			`PREFIX ex: <http://example.org/>
+SELECT * { ?s a/ex:p* ?o ; ^ex:q|!(ex:r|^a) ?x ; (ex:p/ex:q)+ [ ex:p? ?y ] }`,
+			`PREFIX ex: <http://example.org/>
+SELECT *
+WHERE {
+	?s a/ex:p* ?o .
+	?s ^ex:q|!(ex:r|^a) ?x .
+	_:_b1 ex:p? ?y .
+	?s (ex:p/ex:q)+ _:_b1 .
+}
+`,
+		},
+	}
+	for i, test := range tests {
+		q, err := ParseQuery(test.input)
+		if err != nil {
+			t.Errorf("#%d: ParseQuery() => %v", i, err)
+			continue
+		}
+		got := q.String()
+		if got != test.want {
+			t.Errorf("#%d: String() =>\n%s\nwant:\n%s", i, got, test.want)
+			continue
+		}
+		q2, err := ParseQuery(got)
+		if err != nil {
+			t.Errorf("#%d: ParseQuery(String()) => %v", i, err)
+			continue
+		}
+		if q2.String() != got {
+			t.Errorf("#%d: ParseQuery(String()).String() =>\n%s\nwant:\n%s", i, q2.String(), got)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`SELECT`, "unexpected end of input, expected projection"},
+		{`SELECT ?x { ?x ex:p ?y }`, "missing namespace for prefix: 'ex'"},
+		{`SELECT ?x { ?x <p> ?y ?x <p> ?z }`, `unexpected "x", expected '.' or '}'`},
+		{`SELECT ?x { ?x <p> ?y } LIMIT x`, `unexpected "x", expected integer`},
+		{`SELECT ?x { FILTER(FOO(?x)) }`, "unknown function: FOO"},
+		{`SELECT ?x { FILTER(STRLEN(?x, 1)) }`, "wrong number of arguments to STRLEN: 2"},
+		{`SELECT ?x { FILTER(BOUND(1)) }`, "BOUND requires a variable"},
+		{`SELECT ?x { VALUES (?x ?y) { (1) } }`, "VALUES row has 1 values, want 2"},
+		{`SELECT ?x { SERVICE "s" { ?x ?p ?o } }`, `unexpected "s", expected IRI`},
+		{`SELECT ?x { ?x <p> ?y } }`, `unexpected "}", expected end of query`},
+		{`CONSTRUCT { ?x <p>/<q> ?y } WHERE {}`, "property paths are not allowed in templates"},
+		{`SELECT ?x { ?x <p>/ ?y }`, `unexpected "y", expected IRI`},
+		{`DELETE { ?x <p> ?y }`, `unexpected "DELETE", expected SELECT, CONSTRUCT, ASK or DESCRIBE`},
+	}
+	for _, test := range tests {
+		_, err := ParseQuery(test.input)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseQuery(%q) => %v, want error containing %q", test.input, err, test.want)
+		}
+	}
+}
+
+func TestQueryAlgebra(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			`SELECT * { ?s <p> ?o }`,
+			`(project (?s ?o) (bgp (triple ?s <p> ?o)))`,
+		},
+		{
+			`SELECT ?s { ?s <p> ?o OPTIONAL { ?o <q> ?x FILTER(?x > 1) } FILTER(?o != 2) }`,
+			`(project (?s) (filter (!= ?o 2) (leftjoin (bgp (triple ?s <p> ?o)) (bgp (triple ?o <q> ?x)) (> ?x 1))))`,
+		},
+		{
+			`SELECT * { { ?s <p> ?o } UNION { ?s <q> ?o } MINUS { ?s <r> 1 } BIND(-?o AS ?n) }`,
+			`(project (?s ?o ?n) (extend ((?n (- ?o))) (minus (union (bgp (triple ?s <p> ?o)) (bgp (triple ?s <q> ?o))) (bgp (triple ?s <r> 1)))))`,
+		},
+		{
+			`SELECT * { ?s <p> ?o SERVICE <http://example.org/sparql> { ?o <q> ?x } SERVICE SILENT ?e { ?x <r> 1 } }`,
+			`(project (?s ?o ?x ?e) (join (join (bgp (triple ?s <p> ?o)) (service <http://example.org/sparql> (bgp (triple ?o <q> ?x)))) (service silent ?e (bgp (triple ?x <r> 1)))))`,
+		},
+		{
+			`SELECT ?g { GRAPH ?g { ?s ?p ?o } FILTER(?s = <a> && ?o = "x") }`,
+			`(project (?g) (filter (&& (= ?s <a>) (= ?o "x")) (graph ?g (bgp (triple ?s ?p ?o)))))`,
+		},
+		{
+			`SELECT ?s (SUM(?v) * 2 AS ?t) { ?s <p> ?v } GROUP BY ?s HAVING (SUM(?v) > 10) ORDER BY DESC(COUNT(*))`,
+			`(project (?s ?t) (order ((desc ?.2)) (extend ((?t (* ?.1 2))) (filter (> ?.1 10) (group (?s) ((?.1 (sum ?v)) (?.2 (count))) (bgp (triple ?s <p> ?v)))))))`,
+		},
+		{
+			`SELECT (COUNT(*) AS ?n) { ?s ?p ?o }`,
+			`(project (?n) (extend ((?n ?.1)) (group () ((?.1 (count))) (bgp (triple ?s ?p ?o)))))`,
+		},
+		{
+			`SELECT REDUCED ?x { VALUES ?x { 1 2 } } OFFSET 1`,
+			`(slice 1 _ (reduced (project (?x) (table (vars ?x) (row [?x 1]) (row [?x 2])))))`,
+		},
+		{
+			`SELECT DISTINCT ?x { ?x <p> ?y { SELECT ?y { ?y <q> 1 } LIMIT 1 } FILTER EXISTS { ?x <r> ?y } } LIMIT 10`,
+			`(slice _ 10 (distinct (project (?x) (filter (exists (bgp (triple ?x <r> ?y))) (join (bgp (triple ?x <p> ?y)) (slice _ 1 (project (?y) (bgp (triple ?y <q> 1)))))))))`,
+		},
+		{
+			`CONSTRUCT { ?s <p> ?o } { ?s <q> ?o } ORDER BY ?o`,
+			`(order (?o) (bgp (triple ?s <q> ?o)))`,
+		},
+		{
+			`ASK {}`,
+			`(bgp)`,
+		},
+		{
+			`SELECT * { ?s <p> ?o ; ^<q> ?x ; <p>+/<q> ?y . ?y <r> 1 }`,
+			`(project (?s ?o ?x ?y) (join (join (bgp (triple ?s <p> ?o) (triple ?x <q> ?s)) (path ?s (seq (path+ <p>) <q>) ?y)) (bgp (triple ?y <r> 1))))`,
+		},
+	}
+	for _, test := range tests {
+		q, err := ParseQuery(test.input)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) => %v", test.input, err)
+			continue
+		}
+		if got := q.Algebra().String(); got != test.want {
+			t.Errorf("Algebra() of %q =>\n%s\nwant:\n%s", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package rdf
+
+import "strings"
+
+// Update is a SPARQL 1.1 update request, as parsed by ParseUpdate; a
+// sequence of operations, applied in order. As in queries, prefixed names
+// and relative IRIs are resolved when parsing.
+type Update struct {
+	Base       string            // base IRI, if declared
+	Prefixes   map[string]string // declared prefix labels, mapped to their namespaces
+	Operations []UpdateOperation
+}
+
+// UpdateOperation is an operation of a SPARQL update; one of
+// *InsertDataOperation, *DeleteDataOperation, *ModifyOperation,
+// *LoadOperation, *ClearOperation, *DropOperation, *CreateOperation,
+// *AddOperation, *MoveOperation and *CopyOperation.
+type UpdateOperation interface {
+	format(w *sparqlWriter)
+}
+
+// QuadPattern is a triple pattern in a graph, where Graph is an IRI, a
+// variable, or nil for the default graph.
+type QuadPattern struct {
+	TriplePattern
+	Graph PatternTerm
+}
+
+// InsertDataOperation is an INSERT DATA operation. Its quads have no
+// variables.
+type InsertDataOperation struct {
+	Quads []QuadPattern
+}
+
+// DeleteDataOperation is a DELETE DATA operation. Its quads have neither
+// variables nor blank nodes.
+type DeleteDataOperation struct {
+	Quads []QuadPattern
+}
+
+// ModifyOperation is a DELETE/INSERT operation: the templates are
+// instantiated by each solution of the WHERE pattern, deleting and then
+// inserting the resulting quads. DELETE WHERE is parsed as a ModifyOperation
+// where the template is also the pattern.
+type ModifyOperation struct {
+	With       IRI           // graph of the templates and the pattern; the zero IRI if none
+	Delete     []QuadPattern // nil if there is no DELETE clause
+	Insert     []QuadPattern // nil if there is no INSERT clause
+	Using      []IRI         // graphs merged into the default graph of the pattern
+	UsingNamed []IRI         // named graphs of the pattern
+	Where      *GroupPattern
+}
+
+// LoadOperation is a LOAD operation, loading the document into the graph,
+// or into the default graph if Into is the zero IRI.
+type LoadOperation struct {
+	Silent bool
+	Source IRI
+	Into   IRI
+}
+
+// GraphTarget is the target of CLEAR and DROP; a named graph, or one of the
+// keywords DEFAULT, NAMED and ALL.
+type GraphTarget struct {
+	Keyword string // "DEFAULT", "NAMED", "ALL" or "" for a named graph
+	Graph   IRI    // the named graph, if there is no keyword
+}
+
+// ClearOperation is a CLEAR operation, removing all the triples of the
+// target graphs.
+type ClearOperation struct {
+	Silent bool
+	Target GraphTarget
+}
+
+// DropOperation is a DROP operation, removing the target graphs.
+type DropOperation struct {
+	Silent bool
+	Target GraphTarget
+}
+
+// CreateOperation is a CREATE operation, adding an empty named graph.
+type CreateOperation struct {
+	Silent bool
+	Graph  IRI
+}
+
+// AddOperation is an ADD operation, adding the triples of a graph to
+// another. The zero IRI is the default graph.
+type AddOperation struct {
+	Silent   bool
+	From, To IRI
+}
+
+// MoveOperation is a MOVE operation, replacing the triples of a graph by
+// those of another, which is then dropped. The zero IRI is the default
+// graph.
+type MoveOperation struct {
+	Silent   bool
+	From, To IRI
+}
+
+// CopyOperation is a COPY operation, replacing the triples of a graph by
+// those of another. The zero IRI is the default graph.
+type CopyOperation struct {
+	Silent   bool
+	From, To IRI
+}
+
+// ParseUpdate parses a SPARQL 1.1 update request.
+func ParseUpdate(update string) (u *Update, err error) {
+	p := newSPARQLParser(update)
+	defer p.recover(&err)
+	u = p.parseUpdate()
+	return u, nil
+}
+
+// String returns the update in SPARQL syntax, with the operations
+// separated by ';'.
+func (u *Update) String() string {
+	w := newSPARQLWriter(u.Prefixes)
+	w.prologue(u.Base, u.Prefixes)
+	for i, op := range u.Operations {
+		if i > 0 {
+			w.line(";")
+		}
+		op.format(w)
+	}
+	return w.buf.String()
+}
+
+func (op *InsertDataOperation) format(w *sparqlWriter) {
+	w.quads("INSERT DATA ", op.Quads)
+}
+
+func (op *DeleteDataOperation) format(w *sparqlWriter) {
+	w.quads("DELETE DATA ", op.Quads)
+}
+
+func (op *ModifyOperation) format(w *sparqlWriter) {
+	if op.With != (IRI{}) {
+		w.line("WITH " + w.iri(op.With))
+	}
+	if op.Delete != nil {
+		w.quads("DELETE ", op.Delete)
+	}
+	if op.Insert != nil {
+		w.quads("INSERT ", op.Insert)
+	}
+	for _, iri := range op.Using {
+		w.line("USING " + w.iri(iri))
+	}
+	for _, iri := range op.UsingNamed {
+		w.line("USING NAMED " + w.iri(iri))
+	}
+	w.group("WHERE ", op.Where)
+}
+
+func (op *LoadOperation) format(w *sparqlWriter) {
+	s := "LOAD " + silent(op.Silent) + w.iri(op.Source)
+	if op.Into != (IRI{}) {
+		s += " INTO GRAPH " + w.iri(op.Into)
+	}
+	w.line(s)
+}
+
+func (op *ClearOperation) format(w *sparqlWriter) {
+	w.line("CLEAR " + silent(op.Silent) + op.Target.format(w))
+}
+
+func (op *DropOperation) format(w *sparqlWriter) {
+	w.line("DROP " + silent(op.Silent) + op.Target.format(w))
+}
+
+func (op *CreateOperation) format(w *sparqlWriter) {
+	w.line("CREATE " + silent(op.Silent) + "GRAPH " + w.iri(op.Graph))
+}
+
+func (op *AddOperation) format(w *sparqlWriter) {
+	w.line("ADD " + silent(op.Silent) + graphOrDefault(w, op.From) + " TO " + graphOrDefault(w, op.To))
+}
+
+func (op *MoveOperation) format(w *sparqlWriter) {
+	w.line("MOVE " + silent(op.Silent) + graphOrDefault(w, op.From) + " TO " + graphOrDefault(w, op.To))
+}
+
+func (op *CopyOperation) format(w *sparqlWriter) {
+	w.line("COPY " + silent(op.Silent) + graphOrDefault(w, op.From) + " TO " + graphOrDefault(w, op.To))
+}
+
+func (t GraphTarget) format(w *sparqlWriter) string {
+	if t.Keyword != "" {
+		return t.Keyword
+	}
+	return "GRAPH " + w.iri(t.Graph)
+}
+
+// silent returns the SILENT keyword, followed by a space, if s is true.
+func silent(s bool) string {
+	if s {
+		return "SILENT "
+	}
+	return ""
+}
+
+// graphOrDefault returns the graph, or DEFAULT for the zero IRI.
+func graphOrDefault(w *sparqlWriter, iri IRI) string {
+	if iri == (IRI{}) {
+		return "DEFAULT"
+	}
+	return "GRAPH " + w.iri(iri)
+}
+
+// quads writes quads in braces, starting with the given keywords. The
+// quads of named graphs are written in GRAPH blocks.
+func (w *sparqlWriter) quads(keywords string, qs []QuadPattern) {
+	w.line(keywords + "{")
+	w.indent++
+	for i := 0; i < len(qs); {
+		if qs[i].Graph == nil {
+			w.line(w.triple(qs[i].TriplePattern))
+			i++
+			continue
+		}
+		name := qs[i].Graph
+		w.line("GRAPH " + w.term(name) + " {")
+		w.indent++
+		for ; i < len(qs) && qs[i].Graph != nil && qs[i].Graph == name; i++ {
+			w.line(w.triple(qs[i].TriplePattern))
+		}
+		w.indent--
+		w.line("}")
+	}
+	w.indent--
+	w.line("}")
+}
+
+// Parsing:
+
+// parseUpdate parses an update request, until the end of input.
+func (p *sparqlParser) parseUpdate() *Update {
+	u := &Update{}
+	for {
+		p.parsePrologue()
+		if p.peek().typ == tokenEOF {
+			break
+		}
+		u.Operations = append(u.Operations, p.parseUpdateOperation())
+		if !p.acceptSymbol(";") {
+			break
+		}
+	}
+	if t := p.next(); t.typ != tokenEOF {
+		p.unexpected(t, "';' or end of update")
+	}
+	u.Base = p.base
+	if len(p.ns) > 0 {
+		u.Prefixes = p.ns
+	}
+	return u
+}
+
+// parseUpdateOperation parses an update operation.
+func (p *sparqlParser) parseUpdateOperation() UpdateOperation {
+	t := p.next()
+	switch {
+	case isKeyword(t, "LOAD"):
+		op := &LoadOperation{Silent: p.acceptKeyword("SILENT"), Source: p.parseIRI()}
+		if p.acceptKeyword("INTO") {
+			p.expectKeyword("GRAPH")
+			op.Into = p.parseIRI()
+		}
+		return op
+	case isKeyword(t, "CLEAR"):
+		return &ClearOperation{Silent: p.acceptKeyword("SILENT"), Target: p.parseGraphTarget()}
+	case isKeyword(t, "DROP"):
+		return &DropOperation{Silent: p.acceptKeyword("SILENT"), Target: p.parseGraphTarget()}
+	case isKeyword(t, "CREATE"):
+		op := &CreateOperation{Silent: p.acceptKeyword("SILENT")}
+		p.expectKeyword("GRAPH")
+		op.Graph = p.parseIRI()
+		return op
+	case isKeyword(t, "ADD"):
+		op := &AddOperation{Silent: p.acceptKeyword("SILENT")}
+		op.From, op.To = p.parseGraphTransfer()
+		return op
+	case isKeyword(t, "MOVE"):
+		op := &MoveOperation{Silent: p.acceptKeyword("SILENT")}
+		op.From, op.To = p.parseGraphTransfer()
+		return op
+	case isKeyword(t, "COPY"):
+		op := &CopyOperation{Silent: p.acceptKeyword("SILENT")}
+		op.From, op.To = p.parseGraphTransfer()
+		return op
+	case isKeyword(t, "INSERT"):
+		if p.acceptKeyword("DATA") {
+			qs := p.parseQuadData(t)
+			return &InsertDataOperation{Quads: qs}
+		}
+		op := &ModifyOperation{}
+		p.parseModify(op, t)
+		return op
+	case isKeyword(t, "DELETE"):
+		if p.acceptKeyword("DATA") {
+			qs := p.parseQuadData(t)
+			p.checkNoBlanks(t, qs)
+			return &DeleteDataOperation{Quads: qs}
+		}
+		if p.acceptKeyword("WHERE") {
+			qs := p.parseQuads()
+			p.checkNoBlanks(t, qs)
+			return &ModifyOperation{Delete: qs, Where: quadsPattern(qs)}
+		}
+		op := &ModifyOperation{}
+		p.parseModify(op, t)
+		return op
+	case isKeyword(t, "WITH"):
+		op := &ModifyOperation{With: p.parseIRI()}
+		if kw := p.next(); isKeyword(kw, "DELETE") || isKeyword(kw, "INSERT") {
+			p.parseModify(op, kw)
+		} else {
+			p.unexpected(kw, "DELETE or INSERT")
+		}
+		return op
+	}
+	p.unexpected(t, "update operation")
+	return nil
+}
+
+// parseGraphTarget parses the target of CLEAR and DROP.
+func (p *sparqlParser) parseGraphTarget() GraphTarget {
+	for _, kw := range []string{"DEFAULT", "NAMED", "ALL"} {
+		if p.acceptKeyword(kw) {
+			return GraphTarget{Keyword: kw}
+		}
+	}
+	p.expectKeyword("GRAPH")
+	return GraphTarget{Graph: p.parseIRI()}
+}
+
+// parseGraphTransfer parses the source and target graphs of ADD, MOVE and
+// COPY, where the keyword GRAPH is optional.
+func (p *sparqlParser) parseGraphTransfer() (from, to IRI) {
+	graph := func() IRI {
+		if p.acceptKeyword("DEFAULT") {
+			return IRI{}
+		}
+		p.acceptKeyword("GRAPH")
+		return p.parseIRI()
+	}
+	from = graph()
+	p.expectKeyword("TO")
+	return from, graph()
+}
+
+// parseModify parses the DELETE and INSERT templates, the USING clauses and
+// the WHERE pattern of a DELETE/INSERT operation, following the given
+// DELETE or INSERT keyword.
+func (p *sparqlParser) parseModify(op *ModifyOperation, t token) {
+	if isKeyword(t, "DELETE") {
+		op.Delete = p.parseQuads()
+		p.checkNoBlanks(t, op.Delete)
+		if p.acceptKeyword("INSERT") {
+			op.Insert = p.parseQuads()
+		}
+	} else {
+		op.Insert = p.parseQuads()
+	}
+	for p.acceptKeyword("USING") {
+		if p.acceptKeyword("NAMED") {
+			op.UsingNamed = append(op.UsingNamed, p.parseIRI())
+		} else {
+			op.Using = append(op.Using, p.parseIRI())
+		}
+	}
+	p.expectKeyword("WHERE")
+	op.Where = p.parseGroupGraphPattern()
+}
+
+// parseQuadData parses the quads of INSERT DATA and DELETE DATA, which must
+// not have variables.
+func (p *sparqlParser) parseQuadData(t token) []QuadPattern {
+	qs := p.parseQuads()
+	for _, q := range qs {
+		for _, term := range []PatternTerm{q.Subj, q.Pred, q.Obj, q.Graph} {
+			if _, ok := term.(Var); ok {
+				p.errorf("%d:%d: variables are not allowed in %s DATA", t.line, t.col, strings.ToUpper(t.text))
+			}
+		}
+	}
+	return qs
+}
+
+// checkNoBlanks complains if the quads, of a DELETE operation, have blank
+// nodes.
+func (p *sparqlParser) checkNoBlanks(t token, qs []QuadPattern) {
+	for _, q := range qs {
+		for _, term := range []PatternTerm{q.Subj, q.Obj} {
+			if _, ok := term.(Blank); ok {
+				p.errorf("%d:%d: blank nodes are not allowed in %s", t.line, t.col, strings.ToUpper(t.text))
+			}
+		}
+	}
+}
+
+// parseQuads parses quads in braces; triples, in the default graph, and
+// GRAPH blocks of triples.
+func (p *sparqlParser) parseQuads() []QuadPattern {
+	p.expectSymbol("{")
+	qs := []QuadPattern{}
+	for !p.acceptSymbol("}") {
+		if p.acceptKeyword("GRAPH") {
+			name := p.parseVarOrIRI()
+			for _, tp := range p.parseTemplate() {
+				qs = append(qs, QuadPattern{TriplePattern: tp, Graph: name})
+			}
+			p.acceptSymbol(".")
+			continue
+		}
+		var ts []TriplePattern
+		p.parseTemplateTriples(&ts)
+		for _, tp := range ts {
+			qs = append(qs, QuadPattern{TriplePattern: tp})
+		}
+		if !p.acceptSymbol(".") && !isKeyword(p.peek(), "GRAPH") {
+			p.expectSymbol("}")
+			break
+		}
+	}
+	return qs
+}
+
+// quadsPattern returns the graph pattern matching the quads, as given by
+// DELETE WHERE.
+func quadsPattern(qs []QuadPattern) *GroupPattern {
+	g := &GroupPattern{}
+	for i := 0; i < len(qs); {
+		name := qs[i].Graph
+		bgp := &BasicPattern{}
+		for ; i < len(qs) && (qs[i].Graph == nil) == (name == nil) &&
+			(name == nil || qs[i].Graph == name); i++ {
+			bgp.Triples = append(bgp.Triples, qs[i].TriplePattern)
+		}
+		if name == nil {
+			g.Patterns = append(g.Patterns, bgp)
+			continue
+		}
+		g.Patterns = append(g.Patterns, &NamedGraphPattern{
+			Name:    name,
+			Pattern: &GroupPattern{Patterns: []Pattern{bgp}},
+		})
+	}
+	return g
+}
@@ -0,0 +1,409 @@
+package rdf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Exec applies the operations of the update to the dataset, in order.
+//
+// Each operation is atomic: it is either applied in whole or, if it fails,
+// not at all, in which case Exec returns its error without applying the
+// following operations. The errors of SILENT operations are ignored.
+//
+// LOAD reads local files, given by file: IRIs or by IRIs without a scheme,
+// which are paths relative to the working directory. The format is given by
+// the file extension; one of .nt, .ttl, .rdf, .owl, .xml, .jsonld, .json,
+// .nq and .trig. Unless loaded INTO a graph, the quads of a N-Quads, TriG or
+// JSON-LD document are added to their own graphs.
+//
+// Blank nodes of inserted data, and of loaded documents, are given labels
+// not used in the dataset.
+func (u *Update) Exec(d *Dataset) error {
+	return u.ExecWithOptions(d, ExecOptions{})
+}
+
+// ExecOptions are options of the execution of an update.
+type ExecOptions struct {
+	// EvalOptions are the options of the evaluation of the WHERE patterns.
+	EvalOptions EvalOptions
+
+	// Load returns the document of the source IRI of a LOAD operation, and
+	// its format; the source IRI is its base IRI. If nil, LOAD reads local
+	// files, as described in Exec.
+	Load func(source IRI) (io.ReadCloser, Format, error)
+}
+
+// ExecWithOptions applies the operations of the update to the dataset, as
+// Exec does, with the given options.
+func (u *Update) ExecWithOptions(d *Dataset, opts ExecOptions) error {
+	for _, op := range u.Operations {
+		apply, err := u.prepare(op, d, opts)
+		if err != nil {
+			if isSilent(op) {
+				continue
+			}
+			return err
+		}
+		if apply != nil {
+			apply()
+		}
+	}
+	return nil
+}
+
+// isSilent returns true if the operation is marked SILENT.
+func isSilent(op UpdateOperation) bool {
+	switch op := op.(type) {
+	case *LoadOperation:
+		return op.Silent
+	case *ClearOperation:
+		return op.Silent
+	case *DropOperation:
+		return op.Silent
+	case *CreateOperation:
+		return op.Silent
+	case *AddOperation:
+		return op.Silent
+	case *MoveOperation:
+		return op.Silent
+	case *CopyOperation:
+		return op.Silent
+	}
+	return false
+}
+
+// prepare computes the changes of the operation, without changing the
+// dataset. The returned function applies them, and cannot fail; it is nil
+// if there is nothing to change.
+func (u *Update) prepare(op UpdateOperation, d *Dataset, opts ExecOptions) (apply func(), err error) {
+	e := &evaluator{base: u.Base, now: time.Now(), opts: opts.EvalOptions}
+	defer e.recover(&err)
+	switch op := op.(type) {
+	case *InsertDataOperation:
+		qs := e.quads(d, op.Quads, IRI{}, nil)
+		return func() {
+			for _, q := range qs {
+				d.Add(q)
+			}
+		}, nil
+	case *DeleteDataOperation:
+		qs := e.quads(d, op.Quads, IRI{}, nil)
+		return func() {
+			for _, q := range qs {
+				d.Remove(q)
+			}
+		}, nil
+	case *ModifyOperation:
+		using := op.Using
+		if using == nil && op.With != (IRI{}) {
+			using = []IRI{op.With}
+		}
+		e.ds = newEvalDataset(d, using, op.UsingNamed)
+		e.active = e.ds.def
+		var del, ins []Quad
+		for _, sol := range e.eval(translateGroup(op.Where), nil) {
+			del = append(del, e.quads(d, op.Delete, op.With, sol)...)
+			ins = append(ins, e.quads(d, op.Insert, op.With, sol)...)
+		}
+		return func() {
+			for _, q := range del {
+				d.Remove(q)
+			}
+			for _, q := range ins {
+				d.Add(q)
+			}
+		}, nil
+	case *LoadOperation:
+		qs := e.load(d, op, opts.Load)
+		return func() {
+			for _, q := range qs {
+				d.Add(q)
+			}
+		}, nil
+	case *ClearOperation:
+		names := e.targetGraphs(d, op.Target)
+		return func() {
+			for _, name := range names {
+				clearGraph(d, name)
+			}
+		}, nil
+	case *DropOperation:
+		names := e.targetGraphs(d, op.Target)
+		return func() {
+			for _, name := range names {
+				d.DropGraph(name)
+			}
+		}, nil
+	case *CreateOperation:
+		if d.namedGraph(op.Graph) != nil {
+			e.errorf("graph already exists: %s", op.Graph.Serialize(NTriples))
+		}
+		return func() { d.CreateGraph(op.Graph) }, nil
+	case *AddOperation:
+		ts := e.sourceTriples(d, op.From)
+		return func() {
+			to := d.CreateGraph(graphName(op.To))
+			for _, t := range ts {
+				to.Add(t)
+			}
+		}, nil
+	case *CopyOperation:
+		if op.From == op.To {
+			return nil, nil
+		}
+		ts := e.sourceTriples(d, op.From)
+		return func() {
+			clearGraph(d, graphName(op.To))
+			to := d.CreateGraph(graphName(op.To))
+			for _, t := range ts {
+				to.Add(t)
+			}
+		}, nil
+	case *MoveOperation:
+		if op.From == op.To {
+			return nil, nil
+		}
+		ts := e.sourceTriples(d, op.From)
+		return func() {
+			clearGraph(d, graphName(op.To))
+			to := d.CreateGraph(graphName(op.To))
+			for _, t := range ts {
+				to.Add(t)
+			}
+			d.DropGraph(graphName(op.From))
+		}, nil
+	}
+	e.errorf("cannot execute %T", op)
+	return nil, nil
+}
+
+// graphName returns the name of a graph, where the zero IRI denotes the
+// default graph.
+func graphName(iri IRI) Context {
+	if iri == (IRI{}) {
+		return nil
+	}
+	return iri
+}
+
+// clearGraph removes all the triples of the graph, keeping it in the
+// dataset.
+func clearGraph(d *Dataset, name Context) {
+	d.DropGraph(name)
+	if name != nil {
+		d.CreateGraph(name)
+	}
+}
+
+// quads instantiates the quad templates with the solution. Quads outside of
+// a GRAPH block are in the given graph. Blank nodes are replaced by fresh
+// ones, and quads which would not be valid RDF, such as those with an
+// unbound variable, are left out.
+func (e *evaluator) quads(d *Dataset, template []QuadPattern, with IRI, sol Solution) []Quad {
+	var qs []Quad
+	bnodes := make(map[string]Blank)
+	inst := func(t PatternTerm) Term {
+		switch t := t.(type) {
+		case Var:
+			return sol[string(t)]
+		case Blank:
+			b, ok := bnodes[t.id]
+			if !ok {
+				b = e.freshBlank(d)
+				bnodes[t.id] = b
+			}
+			return b
+		}
+		return t.(Term)
+	}
+	for _, qp := range template {
+		s, ok1 := inst(qp.Subj).(Subject)
+		p, ok2 := inst(qp.Pred).(Predicate)
+		o, ok3 := inst(qp.Obj).(Object)
+		if !ok1 || !ok2 || !ok3 {
+			continue
+		}
+		q := Quad{Triple: Triple{Subj: s, Pred: p, Obj: o}, Ctx: graphName(with)}
+		if qp.Graph != nil {
+			g, ok := inst(qp.Graph).(IRI)
+			if !ok {
+				continue
+			}
+			q.Ctx = g
+		}
+		qs = append(qs, q)
+	}
+	return qs
+}
+
+// freshBlank returns a new blank node, which is not in the dataset.
+func (e *evaluator) freshBlank(d *Dataset) Blank {
+	for {
+		b := e.blank()
+		if len(d.Match(b, nil, nil, nil)) == 0 && len(d.Match(nil, nil, b, nil)) == 0 {
+			return b
+		}
+	}
+}
+
+// targetGraphs returns the names of the target graphs of CLEAR or DROP,
+// where nil is the default graph.
+func (e *evaluator) targetGraphs(d *Dataset, t GraphTarget) []Context {
+	switch t.Keyword {
+	case "DEFAULT":
+		return []Context{nil}
+	case "NAMED":
+		return d.Graphs()
+	case "ALL":
+		return append([]Context{nil}, d.Graphs()...)
+	}
+	if d.namedGraph(t.Graph) == nil {
+		e.errorf("graph does not exist: %s", t.Graph.Serialize(NTriples))
+	}
+	return []Context{t.Graph}
+}
+
+// sourceTriples returns the triples of the source graph of ADD, MOVE or
+// COPY, which must exist unless it is the default graph.
+func (e *evaluator) sourceTriples(d *Dataset, from IRI) []Triple {
+	if from == (IRI{}) {
+		return d.Graph(nil).Triples()
+	}
+	g := d.namedGraph(from)
+	if g == nil {
+		e.errorf("graph does not exist: %s", from.Serialize(NTriples))
+	}
+	return g.Triples()
+}
+
+// formatExtensions maps file extensions to the formats of LOAD.
+var formatExtensions = map[string]Format{
+	".nt":     NTriples,
+	".ttl":    Turtle,
+	".rdf":    RDFXML,
+	".owl":    RDFXML,
+	".xml":    RDFXML,
+	".jsonld": JSONLD,
+	".json":   JSONLD,
+	".nq":     NQuads,
+	".trig":   TriG,
+}
+
+// load returns the quads of the document of a LOAD operation, given by the
+// load function, or read from a local file if it is nil.
+func (e *evaluator) load(d *Dataset, op *LoadOperation, load func(IRI) (io.ReadCloser, Format, error)) []Quad {
+	var f io.ReadCloser
+	var format Format
+	var err error
+	base := op.Source
+	if load != nil {
+		f, format, err = load(op.Source)
+	} else {
+		f, format, base, err = openLocalFile(op.Source)
+	}
+	if err != nil {
+		e.errorf("cannot load %s: %v", op.Source.Serialize(NTriples), err)
+	}
+	defer f.Close()
+
+	var qs []Quad
+	switch format {
+	case NTriples, Turtle, RDFXML:
+		dec := NewTripleDecoder(f, format)
+		dec.SetOption(Base, base)
+		ts, err := dec.DecodeAll()
+		if err != nil {
+			e.errorf("cannot load %s: %v", op.Source.Serialize(NTriples), err)
+		}
+		for _, t := range ts {
+			qs = append(qs, Quad{Triple: t})
+		}
+	default:
+		dec := NewQuadDecoder(f, format)
+		dec.SetOption(Base, base)
+		for {
+			q, err := dec.Decode()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				e.errorf("cannot load %s: %v", op.Source.Serialize(NTriples), err)
+			}
+			if q.Ctx == nil || (dec.DefaultGraph != nil && TermsEqual(q.Ctx, dec.DefaultGraph)) {
+				q.Ctx = nil
+			}
+			qs = append(qs, q)
+		}
+	}
+
+	bnodes := make(map[string]Blank)
+	fresh := func(t Term) Term {
+		b, ok := t.(Blank)
+		if !ok {
+			return t
+		}
+		if _, ok := bnodes[b.id]; !ok {
+			bnodes[b.id] = e.freshBlank(d)
+		}
+		return bnodes[b.id]
+	}
+	for i, q := range qs {
+		q.Subj = fresh(q.Subj).(Subject)
+		q.Obj = fresh(q.Obj).(Object)
+		if q.Ctx != nil {
+			q.Ctx = fresh(q.Ctx).(Context)
+		}
+		if op.Into != (IRI{}) {
+			q.Ctx = op.Into
+		}
+		qs[i] = q
+	}
+	return qs
+}
+
+// openLocalFile opens the local file given by a file: IRI or by an IRI
+// without a scheme, and returns it with its format, given by its extension,
+// and its file: IRI, as base IRI.
+func openLocalFile(iri IRI) (io.ReadCloser, Format, IRI, error) {
+	path, base, err := localFile(iri)
+	if err != nil {
+		return nil, 0, IRI{}, err
+	}
+	format, ok := formatExtensions[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, 0, IRI{}, errors.New("unknown file extension")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, IRI{}, err
+	}
+	return f, format, base, nil
+}
+
+// localFile returns the path of a local file given by a file: IRI or by an
+// IRI without a scheme, and the file: IRI of the file, as base IRI.
+func localFile(iri IRI) (string, IRI, error) {
+	path := iri.str
+	if isAbsoluteIRI(path) {
+		u, err := url.Parse(path)
+		if err != nil {
+			return "", IRI{}, err
+		}
+		if u.Scheme != "file" {
+			return "", IRI{}, fmt.Errorf("not a local file")
+		}
+		path = u.Path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", IRI{}, err
+	}
+	return abs, IRI{str: (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()}, nil
+}
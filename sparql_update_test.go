@@ -0,0 +1,337 @@
+package rdf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseUpdate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{
+			`PREFIX ex: <http://example.org/>
+INSERT DATA { ex:a ex:p "x" . GRAPH ex:g { ex:a ex:q _:b } ex:b ex:p 1 } ;
+delete data { GRAPH ex:g { ex:a ex:q ex:c } }`,
+			`PREFIX ex: <http://example.org/>
+INSERT DATA {
+	ex:a ex:p "x" .
+	GRAPH ex:g {
+		ex:a ex:q _:b .
+	}
+	ex:b ex:p 1 .
+}
+;
+DELETE DATA {
+	GRAPH ex:g {
+		ex:a ex:q ex:c .
+	}
+}
+`,
+		},
+		{
+			`PREFIX ex: <http://example.org/>
+WITH ex:g DELETE { ?s ex:p ?o } INSERT { ?s ex:q ?o ; a ex:C } USING ex:h USING NAMED ex:i WHERE { ?s ex:p ?o }`,
+			`PREFIX ex: <http://example.org/>
+WITH ex:g
+DELETE {
+	?s ex:p ?o .
+}
+INSERT {
+	?s ex:q ?o .
+	?s a ex:C .
+}
+USING ex:h
+USING NAMED ex:i
+WHERE {
+	?s ex:p ?o .
+}
+`,
+		},
+		{
+			`DELETE WHERE { ?s <p> ?o . GRAPH ?g { ?s <q> ?x } }`,
+			`DELETE {
+	?s <p> ?o .
+	GRAPH ?g {
+		?s <q> ?x .
+	}
+}
+WHERE {
+	?s <p> ?o .
+	GRAPH ?g {
+		?s <q> ?x .
+	}
+}
+`,
+		},
+		{
+			`BASE <http://example.org/>
+LOAD SILENT <data.ttl> INTO GRAPH <g>; CLEAR DEFAULT; DROP SILENT NAMED; CLEAR ALL; DROP GRAPH <g>;
+CREATE GRAPH <h>; ADD DEFAULT TO <h>; MOVE SILENT GRAPH <h> TO DEFAULT; COPY <g> TO GRAPH <h>`,
+			`BASE <http://example.org/>
+LOAD SILENT <http://example.org/data.ttl> INTO GRAPH <http://example.org/g>
+;
+CLEAR DEFAULT
+;
+DROP SILENT NAMED
+;
+CLEAR ALL
+;
+DROP GRAPH <http://example.org/g>
+;
+CREATE GRAPH <http://example.org/h>
+;
+ADD DEFAULT TO GRAPH <http://example.org/h>
+;
+MOVE SILENT GRAPH <http://example.org/h> TO DEFAULT
+;
+COPY GRAPH <http://example.org/g> TO GRAPH <http://example.org/h>
+`,
+		},
+		{
+			`INSERT { ?s <p> 1 } WHERE { ?s <q> 2 } ;`,
+			`INSERT {
+	?s <p> 1 .
+}
+WHERE {
+	?s <q> 2 .
+}
+`,
+		},
+		{``, ``},
+	}
+	for i, test := range tests {
+		u, err := ParseUpdate(test.input)
+		if err != nil {
+			t.Errorf("#%d: ParseUpdate() => %v", i, err)
+			continue
+		}
+		got := u.String()
+		if got != test.want {
+			t.Errorf("#%d: String() =>\n%s\nwant:\n%s", i, got, test.want)
+			continue
+		}
+		u2, err := ParseUpdate(got)
+		if err != nil {
+			t.Errorf("#%d: ParseUpdate(String()) => %v", i, err)
+			continue
+		}
+		if u2.String() != got {
+			t.Errorf("#%d: ParseUpdate(String()).String() =>\n%s\nwant:\n%s", i, u2.String(), got)
+		}
+	}
+}
+
+func TestParseUpdateErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`INSERT DATA { ?s <p> 1 }`, "variables are not allowed in INSERT DATA"},
+		{`delete data { _:b <p> 1 }`, "blank nodes are not allowed in DELETE"},
+		{`DELETE { ?s <p> [] } WHERE { ?s <p> ?o }`, "blank nodes are not allowed in DELETE"},
+		{`DELETE WHERE { ?s <p>/<q> ?o }`, "property paths are not allowed in templates"},
+		{`WITH <g> WHERE { ?s ?p ?o }`, `unexpected "WHERE", expected DELETE or INSERT`},
+		{`INSERT { <a> <p> 1 }`, `unexpected end of input, expected WHERE`},
+		{`CLEAR <g>`, `expected GRAPH`},
+		{`COPY DEFAULT <g>`, `expected TO`},
+		{`INSERT DATA { <a> <p> 1 } INSERT DATA { <a> <p> 2 }`, `unexpected "INSERT", expected ';' or end of update`},
+		{`SELECT * { ?s ?p ?o }`, `unexpected "SELECT", expected update operation`},
+	}
+	for _, test := range tests {
+		_, err := ParseUpdate(test.input)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseUpdate(%q) => %v, want error containing %q", test.input, err, test.want)
+		}
+	}
+}
+
+// datasetString returns the quads of the dataset in N-Quads, sorted, and
+// the names of its named graphs.
+func datasetString(d *Dataset) string {
+	var lines []string
+	for _, q := range d.Quads() {
+		lines = append(lines, q.Serialize(NQuads))
+	}
+	sort.Strings(lines)
+	var names []string
+	for _, name := range d.Graphs() {
+		names = append(names, name.Serialize(NQuads))
+	}
+	return strings.Join(lines, "") + "graphs: " + strings.Join(names, " ")
+}
+
+func TestExecUpdate(t *testing.T) {
+	data := `@prefix : <http://example.org/> .
+:a :p :b .
+:b :p "x" .
+:g { :a :q 1 . :b :q 2 . }
+:h { :c :q 3 . }
+`
+	tests := []struct {
+		update string
+		want   string
+	}{
+		{
+			`INSERT DATA { :c :p :d . GRAPH :i { :c :p _:x . _:x :p 4 } } ; DELETE DATA { :a :p :b . GRAPH :g { :b :q 2 } }`,
+			`<http://example.org/a> <http://example.org/q> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+<http://example.org/c> <http://example.org/p> <http://example.org/d> _:defaultGraph .
+<http://example.org/c> <http://example.org/p> _:b1 <http://example.org/i> .
+<http://example.org/c> <http://example.org/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+_:b1 <http://example.org/p> "4"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/i> .
+graphs: <http://example.org/g> <http://example.org/h> <http://example.org/i>`,
+		},
+		{
+			`DELETE { ?s :p ?o } INSERT { ?o :r ?s } WHERE { ?s :p ?o FILTER(isIRI(?o)) }`,
+			`<http://example.org/a> <http://example.org/q> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+<http://example.org/b> <http://example.org/q> "2"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/b> <http://example.org/r> <http://example.org/a> _:defaultGraph .
+<http://example.org/c> <http://example.org/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+graphs: <http://example.org/g> <http://example.org/h>`,
+		},
+		{
+			`WITH :g DELETE { ?s :q ?n } INSERT { ?s :q ?m . GRAPH :h { ?s :q ?m } } WHERE { ?s :q ?n BIND(?n * 10 AS ?m) }`,
+			`<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/a> <http://example.org/q> "10"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/a> <http://example.org/q> "10"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+<http://example.org/b> <http://example.org/q> "20"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/b> <http://example.org/q> "20"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+<http://example.org/c> <http://example.org/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+graphs: <http://example.org/g> <http://example.org/h>`,
+		},
+		{
+			`DELETE WHERE { GRAPH ?g { ?s :q ?n } } ; INSERT { GRAPH :i { ?s :p ?o } } USING :g USING :h WHERE { ?s ?p ?o }`,
+			`<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+graphs: <http://example.org/g> <http://example.org/h>`,
+		},
+		{
+			`CLEAR GRAPH :g ; DROP GRAPH :h ; CREATE GRAPH :i`,
+			`<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+graphs: <http://example.org/g> <http://example.org/i>`,
+		},
+		{
+			`CLEAR NAMED ; DROP DEFAULT`,
+			`graphs: <http://example.org/g> <http://example.org/h>`,
+		},
+		{
+			`DROP ALL`,
+			`graphs: `,
+		},
+		{
+			`COPY DEFAULT TO :g ; MOVE :h TO :i ; ADD :i TO DEFAULT`,
+			`<http://example.org/a> <http://example.org/p> <http://example.org/b> <http://example.org/g> .
+<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/b> <http://example.org/p> "x" <http://example.org/g> .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+<http://example.org/c> <http://example.org/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/i> .
+<http://example.org/c> <http://example.org/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer> _:defaultGraph .
+graphs: <http://example.org/g> <http://example.org/i>`,
+		},
+		{
+			`MOVE :g TO :g ; CLEAR SILENT GRAPH :x ; COPY SILENT :x TO :g ; CREATE SILENT GRAPH :g`,
+			`<http://example.org/a> <http://example.org/p> <http://example.org/b> _:defaultGraph .
+<http://example.org/a> <http://example.org/q> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/b> <http://example.org/p> "x" _:defaultGraph .
+<http://example.org/b> <http://example.org/q> "2"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/g> .
+<http://example.org/c> <http://example.org/q> "3"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+graphs: <http://example.org/g> <http://example.org/h>`,
+		},
+	}
+	for _, test := range tests {
+		d := NewDataset()
+		if err := d.Load(NewQuadDecoder(bytes.NewBufferString(data), TriG)); err != nil {
+			t.Fatal(err)
+		}
+		u, err := ParseUpdate("PREFIX : <http://example.org/>\n" + test.update)
+		if err != nil {
+			t.Errorf("ParseUpdate(%q) => %v", test.update, err)
+			continue
+		}
+		if err := u.Exec(d); err != nil {
+			t.Errorf("Exec(%q) => %v", test.update, err)
+			continue
+		}
+		if got := datasetString(d); got != test.want {
+			t.Errorf("Exec(%q) =>\n%s\nwant:\n%s", test.update, got, test.want)
+		}
+	}
+}
+
+func TestExecUpdateErrors(t *testing.T) {
+	tests := []struct {
+		update string
+		want   string
+	}{
+		{`INSERT DATA { <a> <p> 1 } ; DROP GRAPH <g>`, "graph does not exist: <g>"},
+		{`INSERT DATA { <a> <p> 1 } ; CREATE GRAPH <a> ; CREATE GRAPH <a>`, "graph already exists: <a>"},
+		{`INSERT DATA { <a> <p> 1 } ; ADD <g> TO DEFAULT`, "graph does not exist: <g>"},
+		{`INSERT DATA { <a> <p> 1 } ; LOAD <http://example.org/data.ttl>`, "cannot load <http://example.org/data.ttl>: not a local file"},
+		{`INSERT DATA { <a> <p> 1 } ; LOAD <data.txt>`, "cannot load <data.txt>: unknown file extension"},
+		{`INSERT DATA { <a> <p> 1 } ; LOAD <missing.ttl>`, "cannot load <missing.ttl>"},
+	}
+	for _, test := range tests {
+		u, err := ParseUpdate(test.update)
+		if err != nil {
+			t.Errorf("ParseUpdate(%q) => %v", test.update, err)
+			continue
+		}
+		d := NewDataset()
+		err = u.Exec(d)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Exec(%q) => %v, want error containing %q", test.update, err, test.want)
+		}
+		// The operations before the failing one are applied.
+		if d.Len() != 1 {
+			t.Errorf("Exec(%q): Len() => %d, want 1", test.update, d.Len())
+		}
+	}
+}
+
+func TestExecUpdateLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.ttl":  "@prefix : <http://example.org/> .\n:a :p [ :q \"z\" ] .\n",
+		"b.nq":   "<http://example.org/a> <http://example.org/p> _:x <http://example.org/g> .\n_:x <http://example.org/q> \"y\" .\n",
+		"c.nt":   "broken\n",
+		"d.trig": "<http://example.org/c> <http://example.org/p> 1 .\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewDataset()
+	d.Add(Quad{Triple: Triple{Subj: Blank{id: "_:b1"}, Pred: IRI{str: "http://example.org/p"}, Obj: Blank{id: "_:b2"}}})
+	update := "LOAD <file://" + filepath.ToSlash(dir) + "/a.ttl> ;\n" +
+		"LOAD <" + filepath.ToSlash(filepath.Join(dir, "b.nq")) + "> ;\n" +
+		"LOAD SILENT <" + filepath.ToSlash(filepath.Join(dir, "c.nt")) + "> ;\n" +
+		"LOAD <" + filepath.ToSlash(filepath.Join(dir, "d.trig")) + "> INTO GRAPH <http://example.org/h>"
+	u, err := ParseUpdate(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Exec(d); err != nil {
+		t.Fatal(err)
+	}
+	// The blank nodes of the documents are relabeled.
+	want := `<http://example.org/a> <http://example.org/p> _:b3 _:defaultGraph .
+<http://example.org/a> <http://example.org/p> _:b4 <http://example.org/g> .
+<http://example.org/c> <http://example.org/p> "1"^^<http://www.w3.org/2001/XMLSchema#integer> <http://example.org/h> .
+_:b1 <http://example.org/p> _:b2 _:defaultGraph .
+_:b3 <http://example.org/q> "z" _:defaultGraph .
+_:b4 <http://example.org/q> "y" _:defaultGraph .
+graphs: <http://example.org/g> <http://example.org/h>`
+	if got := datasetString(d); got != want {
+		t.Errorf("Exec() =>\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+package rdf
+
+import (
+	"fmt"
+	"io"
+)
+
+// newTriGDecoder returns a Turtle decoder which also accepts the TriG
+// graph blocks. The graph of each triple is available in d.graph
+// when the triple is returned from Decode().
+func newTriGDecoder(r io.Reader) *ttlDecoder {
+	return &ttlDecoder{
+		l:        newTriGLexer(r),
+		ns:       make(map[string]string),
+		ctxStack: make([]ctxTriple, 0, 8),
+		triples:  make([]Triple, 0, 4),
+		trig:     true,
+	}
+}
+
+// parseTriG returns the next valid Quad from a TriG document, or an error.
+func (d *QuadDecoder) parseTriG() (q Quad, err error) {
+	q.Triple, err = d.ttl.Decode()
+	if err != nil {
+		return q, err
+	}
+	if d.ttl.graph != nil {
+		q.Ctx = d.ttl.graph
+	} else {
+		q.Ctx = d.DefaultGraph
+	}
+	return q, nil
+}
+
+// parseGraphLabel parses the label of a graph following the GRAPH keyword.
+func (d *ttlDecoder) parseGraphLabel() Context {
+	tok := d.next()
+	switch tok.typ {
+	case tokenIRIAbs:
+		return IRI{str: tok.text}
+	case tokenIRIRel:
+		return IRI{str: d.base.str + tok.text}
+	case tokenBNode:
+		return Blank{id: tok.text}
+	case tokenAnonBNode:
+		d.bnodeN++
+		return Blank{id: fmt.Sprintf("_:b%d", d.bnodeN)}
+	case tokenPrefixLabel:
+		ns, ok := d.ns[tok.text]
+		if !ok {
+			d.errorf("missing namespace for prefix: '%s'", tok.text)
+		}
+		suf := d.expect1As("IRI suffix", tokenIRISuffix)
+		return IRI{str: ns + suf.text}
+	case tokenError:
+		d.errorf("%d:%d: syntax error: %v", tok.line, tok.col, tok.text)
+	default:
+		d.unexpected(tok, "graph label")
+	}
+	return nil
+}
+
+// peekGraphLabel checks if the next tokens is a graph label followed by the
+// start of a graph block, in which case it returns the graph label, leaving
+// the '{' token to be consumed. Otherwise the tokens are unread, and it
+// returns nil.
+func (d *ttlDecoder) peekGraphLabel() Context {
+	tok := d.next()
+	switch tok.typ {
+	case tokenIRIAbs, tokenIRIRel, tokenBNode, tokenAnonBNode:
+		if d.peek().typ == tokenGraphStart {
+			d.backup2(tok)
+			return d.parseGraphLabel()
+		}
+		d.backup2(tok)
+	case tokenPrefixLabel:
+		suf := d.next()
+		if d.peek().typ == tokenGraphStart {
+			d.backup3(tok, suf)
+			return d.parseGraphLabel()
+		}
+		d.backup3(tok, suf)
+	default:
+		d.backup()
+	}
+	return nil
+}
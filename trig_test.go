@@ -0,0 +1,365 @@
+package rdf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTriG(t *testing.T) {
+	for _, test := range trigTestSuite {
+		dec := NewQuadDecoder(bytes.NewBufferString(test.input), TriG)
+		quads, err := dec.DecodeAll()
+		if test.errWant != "" && err == nil {
+			t.Errorf("parseTriG(%s) => <no error>, want %q", test.input, test.errWant)
+			continue
+		}
+
+		if test.errWant != "" && err != nil {
+			if !strings.HasSuffix(err.Error(), test.errWant) {
+				t.Errorf("parseTriG(%s) => %v, want %q", test.input, err.Error(), test.errWant)
+			}
+			continue
+		}
+
+		if test.errWant == "" && err != nil {
+			t.Errorf("parseTriG(%s) => %v, want %v", test.input, err.Error(), test.want)
+			continue
+		}
+
+		if !reflect.DeepEqual(quads, test.want) {
+			t.Errorf("parseTriG(%s) => %v, want %v", test.input, quads, test.want)
+		}
+	}
+}
+
+func TestTriGBase(t *testing.T) {
+	dec := NewQuadDecoder(bytes.NewBufferString(`<g> { <s> <p> <o> . }`), TriG)
+	if err := dec.SetOption(Base, IRI{str: "http://example/"}); err != nil {
+		t.Fatal(err)
+	}
+	quads, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Quad{
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  IRI{str: "http://example/o"},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+	}
+	if !reflect.DeepEqual(quads, want) {
+		t.Errorf("got %v, want %v", quads, want)
+	}
+
+	dec = NewQuadDecoder(bytes.NewBufferString(""), NQuads)
+	if err := dec.SetOption(Base, IRI{str: "http://example/"}); err == nil {
+		t.Error("N-Quads decoder accepted Base option, want error")
+	}
+}
+
+// trigTestSuite is a selection of tests from the official W3C test suite for TriG
+// which is found at: http://www.w3.org/2013/TriGTests/
+var trigTestSuite = []struct {
+	input   string
+	errWant string
+	want    []Quad
+}{
+	//<#trig-syntax-minimal-whitespace-01> rdf:type rdft:TestTrigPositiveSyntax ;
+	//   mf:name    "trig-syntax-minimal-whitespace-01" ;
+	//   rdfs:comment "GRAPH and default graph blocks" ;
+
+	{`{<http://example/s> <http://example/p> <http://example/o>}
+GRAPH <http://example/g> {<http://example/s> <http://example/p> <http://example/o> .}`, "", []Quad{
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  IRI{str: "http://example/o"},
+			},
+			Ctx: defaultGraph,
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  IRI{str: "http://example/o"},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+	}},
+
+	//<#trig-kw-graph-01> rdf:type rdft:TestTrigPositiveSyntax ;
+	//   mf:name    "trig-kw-graph-01" ;
+	//   rdfs:comment "Graph label without GRAPH keyword, prefixed names and triples outside graph blocks" ;
+
+	{`@prefix : <http://example/> .
+:s :p :o .
+:g { :s :p 1, 2 ; :q ( :a ) }
+graph :g2 { :s :p [ :q "x"@en ] ; }`, "", []Quad{
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  IRI{str: "http://example/o"},
+			},
+			Ctx: defaultGraph,
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  Literal{str: "1", DataType: xsdInteger},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  Literal{str: "2", DataType: xsdInteger},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/q"},
+				Obj:  Blank{id: "_:b1"},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: Blank{id: "_:b1"},
+				Pred: IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#first"},
+				Obj:  IRI{str: "http://example/a"},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: Blank{id: "_:b1"},
+				Pred: IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#rest"},
+				Obj:  IRI{str: "http://www.w3.org/1999/02/22-rdf-syntax-ns#nil"},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  Blank{id: "_:b2"},
+			},
+			Ctx: IRI{str: "http://example/g2"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: Blank{id: "_:b2"},
+				Pred: IRI{str: "http://example/q"},
+				Obj:  Literal{str: "x", lang: "en", DataType: rdfLangString},
+			},
+			Ctx: IRI{str: "http://example/g2"},
+		},
+	}},
+
+	//<#trig-bnode-graph-01> rdf:type rdft:TestTrigPositiveSyntax ;
+	//   mf:name    "trig-bnode-graph-01" ;
+	//   rdfs:comment "Blank node graph labels" ;
+
+	{`_:g { <http://example/s> <http://example/p> _:g . }
+[] { <http://example/s> <http://example/p> <http://example/o> }`, "", []Quad{
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  Blank{id: "_:g"},
+			},
+			Ctx: Blank{id: "_:g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  IRI{str: "http://example/o"},
+			},
+			Ctx: Blank{id: "_:b1"},
+		},
+	}},
+
+	//<#trig-graph-prefix-01> rdf:type rdft:TestTrigPositiveSyntax ;
+	//   mf:name    "trig-graph-prefix-01" ;
+	//   rdfs:comment "Prefix named graph is not the GRAPH keyword" ;
+
+	{`@prefix graph: <http://example/> .
+graph:g { graph:s graph:p graph:o }
+graph:s graph:p 1.5 .`, "", []Quad{
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  IRI{str: "http://example/o"},
+			},
+			Ctx: IRI{str: "http://example/g"},
+		},
+		Quad{
+			Triple: Triple{
+				Subj: IRI{str: "http://example/s"},
+				Pred: IRI{str: "http://example/p"},
+				Obj:  Literal{str: "1.5", DataType: xsdDecimal},
+			},
+			Ctx: defaultGraph,
+		},
+	}},
+
+	//<#trig-graph-empty> rdf:type rdft:TestTrigPositiveSyntax ;
+	//   mf:name    "trig-graph-empty" ;
+	//   rdfs:comment "Empty graph blocks" ;
+
+	{`{} <http://example/g> {}`, "", nil},
+
+	//<#trig-graph-bad-01> rdf:type rdft:TestTrigNegativeSyntax ;
+	//   mf:name    "trig-graph-bad-01" ;
+	//   rdfs:comment "GRAPH but no name" ;
+
+	{`GRAPH { <http://example/s> <http://example/p> <http://example/o> . }`,
+		"unexpected Graph start as graph label", nil},
+
+	//<#trig-graph-bad-07> rdf:type rdft:TestTrigNegativeSyntax ;
+	//   mf:name    "trig-graph-bad-07" ;
+	//   rdfs:comment "Nested GRAPH" ;
+
+	{`{ <http://example/s> <http://example/p> <http://example/o> .
+GRAPH <http://example/g> { <http://example/s> <http://example/p> <http://example/o> . } }`,
+		"unexpected GRAPH keyword inside graph block", nil},
+
+	//<#trig-graph-bad-08> rdf:type rdft:TestTrigNegativeSyntax ;
+	//   mf:name    "trig-graph-bad-08" ;
+	//   rdfs:comment "Unclosed graph block" ;
+
+	{`<http://example/g> { <http://example/s> <http://example/p> <http://example/o> .`,
+		"unexpected EOF in graph block, expected '}'", nil},
+
+	//<#trig-graph-bad-09> rdf:type rdft:TestTrigNegativeSyntax ;
+	//   mf:name    "trig-graph-bad-09" ;
+	//   rdfs:comment "Closing brace without graph block" ;
+
+	{`<http://example/s> <http://example/p> <http://example/o> . }`,
+		"unexpected '}' outside graph block", nil},
+
+	//<#trig-graph-bad-10> rdf:type rdft:TestTrigNegativeSyntax ;
+	//   mf:name    "trig-graph-bad-10" ;
+	//   rdfs:comment "Literal as graph label" ;
+
+	{`GRAPH "g" { <http://example/s> <http://example/p> <http://example/o> . }`,
+		"unexpected Literal as graph label", nil},
+}
+
+func TestEncodeTriG(t *testing.T) {
+	input := `@prefix : <http://example.org/> .
+:g2 { :s :p :o1 . }
+:s :name "default" .
+GRAPH :g1 { :s :p :o2, :o1 ; :q "x"@en . _:b :p 1 }
+:g2 { :s :p :o1 . :s :q <http://other.example/o3> . }
+`
+	dec := NewQuadDecoder(bytes.NewBufferString(input), TriG)
+	quads, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	enc := NewQuadEncoder(&out, TriG)
+	if err := enc.EncodeAll(quads); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Prefix directives are written first, and quads are grouped by graph,
+	// with the default graph outside any graph block.
+	want := `@prefix ns0:	<http://example.org/> .
+@prefix ns1:	<http://other.example/> .
+ns0:s	ns0:name	"default" .
+ns0:g1 {
+ns0:s	ns0:p	ns0:o2 ,
+			ns0:o1 ;
+	ns0:q	"x"@en .
+_:b	ns0:p	1 .
+}
+ns0:g2 {
+ns0:s	ns0:p	ns0:o1 ;
+	ns0:q	ns1:o3 .
+}
+`
+	if out.String() != want {
+		t.Fatalf("TriG encoding:\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	dec = NewQuadDecoder(&out, TriG)
+	quads2, err := dec.DecodeAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quads2) != 7 {
+		t.Fatalf("TriG decode-encode-decode roundtrip: got %d quads, want 7", len(quads2))
+	}
+	for _, q := range quads2 {
+		found := false
+		for _, q2 := range quads {
+			if QuadsEqual(q, q2) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("TriG decode-encode-decode roundtrip: unexpected quad %v", q)
+		}
+	}
+}
+
+func TestEncodeTriGStreaming(t *testing.T) {
+	g := IRI{str: "http://example.org/g"}
+	s := IRI{str: "http://example.org/s"}
+	p := IRI{str: "http://example.org/p"}
+	o := IRI{str: "http://other.example/o"}
+
+	var out bytes.Buffer
+	enc := NewQuadEncoder(&out, TriG)
+	for _, q := range []Quad{
+		{Triple{Subj: s, Pred: p, Obj: s}, g},
+		{Triple{Subj: s, Pred: p, Obj: o}, g},
+		{Triple{Subj: s, Pred: p, Obj: s}, nil},
+	} {
+		if err := enc.Encode(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new prefix directive interrupts the current graph block.
+	want := `@prefix ns0:	<http://example.org/> .
+ns0:g {
+ns0:s	ns0:p	ns0:s .
+}
+@prefix ns1:	<http://other.example/> .
+ns0:g {
+ns0:s	ns0:p	ns1:o .
+}
+ns0:s	ns0:p	ns0:s .
+`
+	if out.String() != want {
+		t.Fatalf("TriG encoding:\ngot:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if err := enc.Encode(Quad{Triple{Subj: s, Pred: p, Obj: s}, g}); err != ErrEncoderClosed {
+		t.Errorf("Encode after Close() => %v; want %v", err, ErrEncoderClosed)
+	}
+}
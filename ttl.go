@@ -26,6 +26,11 @@ type ttlDecoder struct {
 	// triples contains complete triples ready to be emitted. Usually it will have just one triple,
 	// but can have more when parsing nested list/collections. Decode() will always return the first item.
 	triples []Triple
+
+	// TriG parsing state; the Turtle grammar is extended with graph blocks.
+	trig    bool    // true when parsing TriG
+	inGraph bool    // true when inside a graph block '{ ... }'
+	graph   Context // graph of the triples currently parsed, or nil for the default graph
 }
 
 func newTTLDecoder(r io.Reader) *ttlDecoder {
@@ -63,6 +68,9 @@ func (d *ttlDecoder) Decode() (t Triple, err error) {
 
 	// Return io.EOF when there is no more tokens to parse.
 	if d.next().typ == tokenEOF {
+		if d.inGraph {
+			d.errorf("unexpected EOF in graph block, expected '}'")
+		}
 		return t, io.EOF
 	}
 	d.backup()
@@ -128,10 +136,39 @@ func parseStart(d *ttlDecoder) parseFn {
 	case tokenSparqlBase:
 		uri := d.expect1As("base IRI", tokenIRIAbs)
 		d.base.str = uri.text
+	case tokenSparqlGraph:
+		if d.inGraph || len(d.ctxStack) > 0 {
+			d.errorf("unexpected GRAPH keyword inside graph block")
+		}
+		d.graph = d.parseGraphLabel()
+		d.expect1As("graph block start", tokenGraphStart)
+		d.inGraph = true
+	case tokenGraphStart:
+		if d.inGraph || len(d.ctxStack) > 0 {
+			d.errorf("unexpected '{' inside graph block")
+		}
+		// Graph block without label; the triples belong to the default graph.
+		d.graph = nil
+		d.inGraph = true
+	case tokenGraphEnd:
+		if !d.inGraph || len(d.ctxStack) > 0 {
+			d.errorf("unexpected '}' outside graph block")
+		}
+		d.graph = nil
+		d.inGraph = false
 	case tokenEOF:
 		return nil
 	default:
 		d.backup()
+		if d.trig && !d.inGraph && len(d.ctxStack) == 0 {
+			// A graph label without the GRAPH keyword: labelOrSubject '{'
+			if g := d.peekGraphLabel(); g != nil {
+				d.graph = g
+				d.expect1As("graph block start", tokenGraphStart)
+				d.inGraph = true
+				return parseStart
+			}
+		}
 		return parseTriple
 	}
 	return parseStart
@@ -154,6 +191,9 @@ func parseEnd(d *ttlDecoder) parseFn {
 			// TODO only allowed in property lists?
 			d.errorf("%d:%d: expected triple termination, got %v", tok.line, tok.col, tok.typ)
 			return nil
+		case tokenGraphEnd:
+			// parse trailing semicolon before end of graph block
+			return parseEnd
 		}
 		d.current.Pred = nil
 		d.current.Obj = nil
@@ -197,6 +237,15 @@ func parseEnd(d *ttlDecoder) parseFn {
 			return parseEnd
 		}
 		return nil
+	case tokenGraphEnd:
+		if d.inGraph && d.current.Ctx == ctxTop {
+			// The final triple in a graph block need not be terminated by a dot.
+			// Leave the '}' to be parsed by parseStart.
+			d.backup()
+			return nil
+		}
+		d.errorf("%d:%d: expected triple termination, got %v", tok.line, tok.col, tok.typ)
+		return nil
 	case tokenError:
 		d.errorf("%d:%d: syntax error: %v", tok.line, tok.col, tok.text)
 		return nil